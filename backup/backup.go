@@ -18,6 +18,7 @@ import (
 	"github.com/greenplum-db/gpbackup/history"
 	"github.com/greenplum-db/gpbackup/options"
 	"github.com/greenplum-db/gpbackup/report"
+	"github.com/greenplum-db/gpbackup/storage"
 	"github.com/greenplum-db/gpbackup/toc"
 	"github.com/greenplum-db/gpbackup/utils"
 	"github.com/spf13/cobra"
@@ -29,6 +30,10 @@ func DoInit(cmd *cobra.Command) {
 	CleanupGroup.Add(1)
 	gplog.InitializeLogging("gpbackup", "")
 	SetCmdFlags(cmd.Flags())
+	// The config file is applied before cobra checks for required flags, which it may set
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		return options.ApplyConfigFile(cmd.Flags())
+	}
 	_ = cmd.MarkFlagRequired(options.DBNAME)
 	utils.InitializeSignalHandler(DoCleanup, "backup process", &wasTerminated)
 	objectCounts = make(map[string]int)
@@ -48,6 +53,11 @@ func DoSetup() {
 	utils.CheckGpexpandRunning(utils.BackupPreventedByGpexpandMessage)
 	timestamp := history.CurrentTimestamp()
 	createBackupLockFile(timestamp)
+	if MustGetFlagString(options.SESSION_GUC_FILE) != "" {
+		var err error
+		sessionGUCs, err = utils.ReadSessionGUCFile(MustGetFlagString(options.SESSION_GUC_FILE))
+		gplog.FatalOnError(err)
+	}
 	initializeConnectionPool(timestamp)
 	gplog.Info("Greenplum Database Version = %s", connectionPool.Version.VersionString)
 
@@ -55,6 +65,7 @@ func DoSetup() {
 	opts, err := options.NewOptions(cmdFlags)
 	gplog.FatalOnError(err)
 
+	expandFilterPatterns(opts)
 	validateFilterLists(opts)
 
 	err = opts.ExpandIncludesForPartitions(connectionPool, cmdFlags)
@@ -64,7 +75,7 @@ func DoSetup() {
 	globalCluster = cluster.NewCluster(segConfig)
 	segPrefix := filepath.GetSegPrefix(connectionPool)
 	globalFPInfo = filepath.NewFilePathInfo(globalCluster, MustGetFlagString(options.BACKUP_DIR), timestamp, segPrefix)
-	if MustGetFlagBool(options.METADATA_ONLY) {
+	if MustGetFlagBool(options.METADATA_ONLY) || MustGetFlagBool(options.GLOBALS_ONLY) {
 		_, err = globalCluster.ExecuteLocalCommand(fmt.Sprintf("mkdir -p %s", globalFPInfo.GetDirForContent(-1)))
 		gplog.FatalOnError(err)
 	} else {
@@ -80,6 +91,7 @@ func DoSetup() {
 	if pluginConfigFlag != "" {
 		pluginConfig, err = utils.ReadPluginConfig(pluginConfigFlag)
 		gplog.FatalOnError(err)
+		storageBackend = storage.MustNewStorageBackend(pluginConfig)
 		configFilename := path.Base(pluginConfig.ConfigPath)
 		configDirname := path.Dir(pluginConfig.ConfigPath)
 		pluginConfig.ConfigPath = path.Join(configDirname, timestamp+"_"+configFilename)
@@ -111,18 +123,22 @@ func DoBackup() {
 
 		if pluginConfigFlag != "" {
 			// These files need to be downloaded from the remote system into the local filesystem
-			pluginConfig.MustRestoreFile(targetBackupFPInfo.GetConfigFilePath())
-			pluginConfig.MustRestoreFile(targetBackupFPInfo.GetTOCFilePath())
-			pluginConfig.MustRestoreFile(targetBackupFPInfo.GetPluginConfigPath())
+			storage.MustGetFile(storageBackend, targetBackupFPInfo.GetConfigFilePath())
+			storage.MustGetFile(storageBackend, targetBackupFPInfo.GetTOCFilePath())
+			storage.MustGetFile(storageBackend, targetBackupFPInfo.GetPluginConfigPath())
 		}
 	}
 
-	gplog.Info("Gathering table state information")
-	metadataTables, dataTables := RetrieveAndProcessTables()
-	if !(MustGetFlagBool(options.METADATA_ONLY) || MustGetFlagBool(options.DATA_ONLY)) {
-		backupIncrementalMetadata()
+	isGlobalsOnly := MustGetFlagBool(options.GLOBALS_ONLY)
+	var metadataTables, dataTables []Table
+	if !isGlobalsOnly {
+		gplog.Info("Gathering table state information")
+		metadataTables, dataTables = RetrieveAndProcessTables()
+		if !(MustGetFlagBool(options.METADATA_ONLY) || MustGetFlagBool(options.DATA_ONLY)) {
+			backupIncrementalMetadata()
+		}
+		CheckTablesContainData(dataTables)
 	}
-	CheckTablesContainData(dataTables)
 	metadataFilename := globalFPInfo.GetMetadataFilePath()
 	gplog.Info("Metadata will be written to %s", metadataFilename)
 	metadataFile := utils.NewFileWithByteCountFromFile(metadataFilename)
@@ -134,9 +150,11 @@ func DoBackup() {
 			backupGlobals(metadataFile)
 		}
 
-		isFilteredBackup := !isFullBackup
-		backupPredata(metadataFile, metadataTables, isFilteredBackup)
-		backupPostdata(metadataFile)
+		if !isGlobalsOnly {
+			isFilteredBackup := !isFullBackup
+			backupPredata(metadataFile, metadataTables, isFilteredBackup)
+			backupPostdata(metadataFile)
+		}
 	}
 
 	/*
@@ -174,13 +192,13 @@ func DoBackup() {
 	}
 	metadataFile.Close()
 	if pluginConfigFlag != "" {
-		pluginConfig.MustBackupFile(metadataFilename)
-		pluginConfig.MustBackupFile(globalFPInfo.GetTOCFilePath())
+		storage.MustPutFile(storageBackend, metadataFilename)
+		storage.MustPutFile(storageBackend, globalFPInfo.GetTOCFilePath())
 		if MustGetFlagBool(options.WITH_STATS) {
-			pluginConfig.MustBackupFile(globalFPInfo.GetStatisticsFilePath())
+			storage.MustPutFile(storageBackend, globalFPInfo.GetStatisticsFilePath())
 		}
 		_ = utils.CopyFile(pluginConfigFlag, globalFPInfo.GetPluginConfigPath())
-		pluginConfig.MustBackupFile(globalFPInfo.GetPluginConfigPath())
+		storage.MustPutFile(storageBackend, globalFPInfo.GetPluginConfigPath())
 	}
 }
 
@@ -257,30 +275,57 @@ func backupData(tables []Table) {
 		return
 	}
 
+	var dataStreams [][]Table
 	if MustGetFlagBool(options.SINGLE_DATA_FILE) {
-		gplog.Verbose("Initializing pipes and gpbackup_helper on segments for single data file backup")
-		utils.VerifyHelperVersionOnSegments(version, globalCluster)
-		oidList := make([]string, 0, len(tables))
-		for _, table := range tables {
-			if !table.SkipDataBackup() {
-				oidList = append(oidList, fmt.Sprintf("%d", table.Oid))
+		dataStreams = AssignTablesToDataStreams(tables, MustGetFlagInt(options.DATA_STREAMS))
+		// Only the streams that have tables are used
+		backupReport.DataStreams = len(dataStreams)
+		tableDataStreams = make(map[uint32]int)
+		for stream, streamTables := range dataStreams {
+			for _, table := range streamTables {
+				tableDataStreams[table.Oid] = stream
 			}
 		}
-		utils.WriteOidListToSegments(oidList, globalCluster, globalFPInfo)
-		utils.CreateFirstSegmentPipeOnAllHosts(oidList[0], globalCluster, globalFPInfo)
+	}
+
+	rateLimit := utils.RateLimit{MaxRate: MustGetFlagInt(options.MAX_RATE), MaxSegmentRate: MustGetFlagInt(options.MAX_SEGMENT_RATE)}
+	if rateLimit.IsLimited() {
+		// With a single data file, each segment's data is written by one gpbackup_helper agent per data stream
+		streamsPerSegment := MustGetFlagInt(options.JOBS)
+		if MustGetFlagBool(options.SINGLE_DATA_FILE) {
+			streamsPerSegment = backupReport.NumDataStreams()
+		}
+		utils.StartRateController(globalCluster, globalFPInfo, globalFPInfo.GetBackupRateLimitFilePath(), rateLimit, streamsPerSegment)
+		defer utils.StopRateController()
+	}
+
+	if MustGetFlagBool(options.SINGLE_DATA_FILE) {
+		gplog.Verbose("Initializing pipes and gpbackup_helper on segments for single data file backup")
+		utils.VerifyHelperVersionOnSegments(version, globalCluster)
 		compressStr := fmt.Sprintf(" --compression-level %d", MustGetFlagInt(options.COMPRESSION_LEVEL))
 		if MustGetFlagBool(options.NO_COMPRESSION) {
 			compressStr = " --compression-level 0"
 		}
-		// Do not pass through the --on-error-continue flag because it does not apply to gpbackup
-		utils.StartGpbackupHelpers(globalCluster, globalFPInfo, "--backup-agent",
-			MustGetFlagString(options.PLUGIN_CONFIG), compressStr, false, false, &wasTerminated)
+		for stream, streamTables := range dataStreams {
+			streamFPInfo := globalFPInfo.ForDataStream(stream)
+			oidList := make([]string, 0, len(streamTables))
+			for _, table := range streamTables {
+				oidList = append(oidList, fmt.Sprintf("%d", table.Oid))
+			}
+			utils.WriteOidListToSegments(oidList, globalCluster, streamFPInfo)
+			utils.CreateFirstSegmentPipeOnAllHosts(oidList[0], globalCluster, streamFPInfo)
+			// Do not pass through the --on-error-continue flag because it does not apply to gpbackup
+			utils.StartGpbackupHelpers(globalCluster, streamFPInfo, "--backup-agent",
+				MustGetFlagString(options.PLUGIN_CONFIG), compressStr, false, false, 1, &wasTerminated)
+		}
 	}
 	gplog.Info("Writing data to file")
-	rowsCopiedMaps := backupDataForAllTables(tables)
-	AddTableDataEntriesToTOC(tables, rowsCopiedMaps)
+	rowsCopiedMaps, fingerprintMaps := backupDataForAllTables(tables)
+	AddTableDataEntriesToTOC(tables, rowsCopiedMaps, fingerprintMaps)
 	if MustGetFlagBool(options.SINGLE_DATA_FILE) && MustGetFlagString(options.PLUGIN_CONFIG) != "" {
-		pluginConfig.BackupSegmentTOCs(globalCluster, globalFPInfo)
+		for stream := range dataStreams {
+			pluginConfig.BackupSegmentTOCs(globalCluster, globalFPInfo.ForDataStream(stream))
+		}
 	}
 
 	logCompletionMessage("Data backup")
@@ -386,15 +431,18 @@ func DoTeardown() {
 				backupReport.BackupConfig.EndTime = history.CurrentTimestamp()
 			}
 			endtime, _ := time.ParseInLocation("20060102150405", backupReport.BackupConfig.EndTime, operating.System.Local)
+			if pluginConfig != nil {
+				backupReport.PluginRetries = pluginConfig.Retries()
+			}
 			backupReport.WriteBackupReportFile(reportFilename, globalFPInfo.Timestamp, endtime, objectCounts, errMsg)
 			report.EmailReport(globalCluster, globalFPInfo.Timestamp, reportFilename, "gpbackup", !backupFailed)
 			if pluginConfig != nil {
-				err = pluginConfig.BackupFile(configFilename)
+				err = storageBackend.PutFile(configFilename)
 				if err != nil {
 					gplog.Error(fmt.Sprintf("%v", err))
 					return
 				}
-				err = pluginConfig.BackupFile(reportFilename)
+				err = storageBackend.PutFile(reportFilename)
 				if err != nil {
 					gplog.Error(fmt.Sprintf("%v", err))
 					return
@@ -418,17 +466,25 @@ func DoCleanup(backupFailed bool) {
 	}()
 
 	gplog.Verbose("Beginning cleanup")
+	utils.StopRateController()
 	if globalFPInfo.Timestamp != "" {
 		if MustGetFlagBool(options.SINGLE_DATA_FILE) {
-			if backupFailed {
-				// Cleanup only if terminated or fataled
-				utils.CleanUpSegmentHelperProcesses(globalCluster, globalFPInfo, "backup")
+			numStreams := 1
+			if backupReport != nil {
+				numStreams = backupReport.NumDataStreams()
 			}
-			if wasTerminated {
-				// It is possible for the COPY command to become orphaned if an agent process is killed
-				utils.TerminateHangingCopySessions(connectionPool, globalFPInfo, "gpbackup")
+			for stream := 0; stream < numStreams; stream++ {
+				streamFPInfo := globalFPInfo.ForDataStream(stream)
+				if backupFailed {
+					// Cleanup only if terminated or fataled
+					utils.CleanUpSegmentHelperProcesses(globalCluster, streamFPInfo, "backup")
+				}
+				if wasTerminated {
+					// It is possible for the COPY command to become orphaned if an agent process is killed
+					utils.TerminateHangingCopySessions(connectionPool, streamFPInfo, "gpbackup")
+				}
+				utils.CleanUpHelperFilesOnAllHosts(globalCluster, streamFPInfo)
 			}
-			utils.CleanUpHelperFilesOnAllHosts(globalCluster, globalFPInfo)
 		}
 	}
 	err := backupLockFile.Unlock()
@@ -15,6 +15,7 @@ import (
 	"github.com/greenplum-db/gpbackup/options"
 	"github.com/greenplum-db/gpbackup/utils"
 	"github.com/jackc/pgconn"
+	"github.com/pkg/errors"
 	"gopkg.in/cheggaaa/pb.v1"
 )
 
@@ -33,7 +34,7 @@ func ConstructTableAttributesList(columnDefs []ColumnDefinition) string {
 	return ""
 }
 
-func AddTableDataEntriesToTOC(tables []Table, rowsCopiedMaps []map[uint32]int64) {
+func AddTableDataEntriesToTOC(tables []Table, rowsCopiedMaps []map[uint32]int64, fingerprintMaps []map[uint32]string) {
 	for _, table := range tables {
 		if !table.SkipDataBackup() {
 			var rowsCopied int64
@@ -43,12 +44,41 @@ func AddTableDataEntriesToTOC(tables []Table, rowsCopiedMaps []map[uint32]int64)
 					break
 				}
 			}
+			var fingerprint string
+			for _, fingerprintMap := range fingerprintMaps {
+				if val, ok := fingerprintMap[table.Oid]; ok {
+					fingerprint = val
+					break
+				}
+			}
 			attributes := ConstructTableAttributesList(table.ColumnDefs)
-			globalTOC.AddMasterDataEntry(table.Schema, table.Name, table.Oid, attributes, rowsCopied, table.PartitionLevelInfo.RootName)
+			globalTOC.AddMasterDataEntry(table.Schema, table.Name, table.Oid, attributes, rowsCopied, table.PartitionLevelInfo.RootName, fingerprint, tableDataStreams[table.Oid])
 		}
 	}
 }
 
+/*
+ * Each data stream's gpbackup_helper reads the pipes of its tables in the order
+ * of its oid list, so tables are assigned to streams before any data is backed
+ * up.  Tables are assigned in turn, so every stream returned has a table.
+ */
+func AssignTablesToDataStreams(tables []Table, numStreams int) [][]Table {
+	dataStreams := make([][]Table, 0, numStreams)
+	numTables := 0
+	for _, table := range tables {
+		if table.SkipDataBackup() {
+			continue
+		}
+		stream := numTables % numStreams
+		if stream == len(dataStreams) {
+			dataStreams = append(dataStreams, make([]Table, 0))
+		}
+		dataStreams[stream] = append(dataStreams[stream], table)
+		numTables++
+	}
+	return dataStreams
+}
+
 type BackupProgressCounters struct {
 	NumRegTables   int64
 	TotalRegTables int64
@@ -68,8 +98,13 @@ func CopyTableOut(connectionPool *dbconn.DBConn, table Table, destinationToWrite
 		 */
 		checkPipeExistsCommand = fmt.Sprintf("(test -p \"%s\" || (echo \"Pipe not found %s\">&2; exit 1)) && ", destinationToWrite, destinationToWrite)
 		customPipeThroughCommand = "cat -"
-	} else if MustGetFlagString(options.PLUGIN_CONFIG) != "" {
-		sendToDestinationCommand = fmt.Sprintf("| %s backup_data %s", pluginConfig.ExecutablePath, pluginConfig.ConfigPath)
+	} else {
+		if throttleCommand := utils.GetThrottleCommand(); throttleCommand != "" {
+			customPipeThroughCommand = fmt.Sprintf("%s | %s", customPipeThroughCommand, throttleCommand)
+		}
+		if MustGetFlagString(options.PLUGIN_CONFIG) != "" {
+			sendToDestinationCommand = fmt.Sprintf("| %s", pluginConfig.BackupDataCommand())
+		}
 	}
 
 	copyCommand := fmt.Sprintf("PROGRAM '%s%s %s %s'", checkPipeExistsCommand, customPipeThroughCommand, sendToDestinationCommand, destinationToWrite)
@@ -84,7 +119,37 @@ func CopyTableOut(connectionPool *dbconn.DBConn, table Table, destinationToWrite
 	return numRows, nil
 }
 
-func BackupSingleTableData(table Table, rowsCopiedMap map[uint32]int64, counters *BackupProgressCounters, whichConn int) error {
+/*
+ * When backing up to one file per table with a plugin, a COPY that fails can
+ * be re-run if the plugin config allows backup_data to be retried.  A failed
+ * COPY aborts the worker's transaction, so each attempt is made in a savepoint
+ * that is rolled back before the next attempt.
+ */
+func CopyTableOutWithRetries(table Table, destinationToWrite string, connNum int) (int64, error) {
+	if MustGetFlagBool(options.SINGLE_DATA_FILE) || pluginConfig == nil || !pluginConfig.CanRetry("backup_data") {
+		return CopyTableOut(connectionPool, table, destinationToWrite, connNum)
+	}
+	var rowsCopied int64
+	err := pluginConfig.WithRetries("backup_data", table.FQN(), func() error {
+		_, err := connectionPool.Exec("SAVEPOINT gpbackup_copy", connNum)
+		if err != nil {
+			return err
+		}
+		rowsCopied, err = CopyTableOut(connectionPool, table, destinationToWrite, connNum)
+		if err != nil {
+			_, rollbackErr := connectionPool.Exec("ROLLBACK TO SAVEPOINT gpbackup_copy", connNum)
+			if rollbackErr != nil {
+				return errors.Wrapf(rollbackErr, "Unable to roll back failed COPY of table %s: %v", table.FQN(), err)
+			}
+			return err
+		}
+		_, err = connectionPool.Exec("RELEASE SAVEPOINT gpbackup_copy", connNum)
+		return err
+	})
+	return rowsCopied, err
+}
+
+func BackupSingleTableData(table Table, rowsCopiedMap map[uint32]int64, fingerprintMap map[uint32]string, counters *BackupProgressCounters, whichConn int) error {
 	atomic.AddInt64(&counters.NumRegTables, 1)
 	numTables := counters.NumRegTables //We save this so it won't be modified before we log it
 	if gplog.GetVerbosity() > gplog.LOGINFO {
@@ -96,20 +161,46 @@ func BackupSingleTableData(table Table, rowsCopiedMap map[uint32]int64, counters
 
 	destinationToWrite := ""
 	if MustGetFlagBool(options.SINGLE_DATA_FILE) {
-		destinationToWrite = fmt.Sprintf("%s_%d", globalFPInfo.GetSegmentPipePathForCopyCommand(), table.Oid)
+		streamFPInfo := globalFPInfo.ForDataStream(tableDataStreams[table.Oid])
+		destinationToWrite = fmt.Sprintf("%s_%d", streamFPInfo.GetSegmentPipePathForCopyCommand(), table.Oid)
 	} else {
 		destinationToWrite = globalFPInfo.GetTableBackupFilePathForCopyCommand(table.Oid, utils.GetPipeThroughProgram().Extension, false)
 	}
-	rowsCopied, err := CopyTableOut(connectionPool, table, destinationToWrite, whichConn)
+	rowsCopied, err := CopyTableOutWithRetries(table, destinationToWrite, whichConn)
 	if err != nil {
 		return err
 	}
 	rowsCopiedMap[table.Oid] = rowsCopied
+	if MustGetFlagBool(options.WITH_FINGERPRINTS) {
+		err = BackupTableFingerprint(table, rowsCopied, fingerprintMap, whichConn)
+		if err != nil {
+			return err
+		}
+	}
 	counters.ProgressBar.Increment()
 	return nil
 }
 
-func backupDataForAllTables(tables []Table) []map[uint32]int64 {
+/*
+ * The fingerprint is computed in the same transaction as the COPY, so both see
+ * the same snapshot of the table.  A fingerprint whose row count differs from
+ * the rows copied, as for a partition table with external partitions that COPY
+ * skips, would never match the restored data and so is not recorded.
+ */
+func BackupTableFingerprint(table Table, rowsCopied int64, fingerprintMap map[uint32]string, whichConn int) error {
+	fingerprint, err := utils.GetTableFingerprint(connectionPool, table.FQN(), whichConn)
+	if err != nil {
+		return err
+	}
+	if fingerprint.Rows != rowsCopied {
+		gplog.Verbose("Not recording fingerprint for table %s: found %d rows, but %d rows were copied", table.FQN(), fingerprint.Rows, rowsCopied)
+		return nil
+	}
+	fingerprintMap[table.Oid] = fingerprint.String()
+	return nil
+}
+
+func backupDataForAllTables(tables []Table) ([]map[uint32]int64, []map[uint32]string) {
 	var numExtOrForeignTables int64
 	for _, table := range tables {
 		if table.SkipDataBackup() {
@@ -120,22 +211,46 @@ func backupDataForAllTables(tables []Table) []map[uint32]int64 {
 	counters.ProgressBar = utils.NewProgressBar(int(counters.TotalRegTables), "Tables backed up: ", utils.PB_INFO)
 	counters.ProgressBar.Start()
 	rowsCopiedMaps := make([]map[uint32]int64, connectionPool.NumConns)
+	fingerprintMaps := make([]map[uint32]string, connectionPool.NumConns)
 	/*
 	 * We break when an interrupt is received and rely on
 	 * TerminateHangingCopySessions to kill any COPY statements
 	 * in progress if they don't finish on their own.
 	 */
-	tasks := make(chan Table, len(tables))
+	taskQueues := make([]chan Table, connectionPool.NumConns)
+	hasDataStreams := MustGetFlagBool(options.SINGLE_DATA_FILE) && connectionPool.NumConns > 1
+	if hasDataStreams {
+		// Each worker backs up the tables of its own data stream, in order
+		for connNum := range taskQueues {
+			taskQueues[connNum] = make(chan Table, len(tables))
+		}
+		for _, table := range tables {
+			taskQueues[tableDataStreams[table.Oid]] <- table
+		}
+		for _, queue := range taskQueues {
+			close(queue)
+		}
+	} else {
+		tasks := make(chan Table, len(tables))
+		for _, table := range tables {
+			tasks <- table
+		}
+		close(tasks)
+		for connNum := range taskQueues {
+			taskQueues[connNum] = tasks
+		}
+	}
 	deferredTables := []Table{}
 	deferredTablesMutex := &sync.Mutex{}
 	var workerPool sync.WaitGroup
 	var copyErr error
 	for connNum := 0; connNum < connectionPool.NumConns; connNum++ {
 		rowsCopiedMaps[connNum] = make(map[uint32]int64)
+		fingerprintMaps[connNum] = make(map[uint32]string)
 		workerPool.Add(1)
 		go func(whichConn int) {
 			defer workerPool.Done()
-			for table := range tasks {
+			for table := range taskQueues[whichConn] {
 				if wasTerminated || copyErr != nil {
 					counters.ProgressBar.(*pb.ProgressBar).NotPrint = true
 					return
@@ -173,6 +288,12 @@ func backupDataForAllTables(tables []Table) []map[uint32]int64 {
 						// Defer table to main worker thread
 						deferredTablesMutex.Lock()
 						deferredTables = append(deferredTables, table)
+						if hasDataStreams {
+							// The rest of the stream's tables must still be backed up after this one
+							for remainingTable := range taskQueues[whichConn] {
+								deferredTables = append(deferredTables, remainingTable)
+							}
+						}
 						deferredTablesMutex.Unlock()
 
 						// Rollback transaction since it's in an aborted state
@@ -183,17 +304,13 @@ func backupDataForAllTables(tables []Table) []map[uint32]int64 {
 					}
 				}
 
-				err := BackupSingleTableData(table, rowsCopiedMaps[whichConn], &counters, whichConn)
+				err := BackupSingleTableData(table, rowsCopiedMaps[whichConn], fingerprintMaps[whichConn], &counters, whichConn)
 				if err != nil {
 					copyErr = err
 				}
 			}
 		}(connNum)
 	}
-	for _, table := range tables {
-		tasks <- table
-	}
-	close(tasks)
 	workerPool.Wait()
 
 	// Handle all tables deferred by the deadlock detection. This can only
@@ -204,7 +321,7 @@ func backupDataForAllTables(tables []Table) []map[uint32]int64 {
 			counters.ProgressBar.(*pb.ProgressBar).NotPrint = true
 			break
 		}
-		err := BackupSingleTableData(table, rowsCopiedMaps[0], &counters, 0)
+		err := BackupSingleTableData(table, rowsCopiedMaps[0], fingerprintMaps[0], &counters, 0)
 		if err != nil {
 			copyErr = err
 		}
@@ -212,7 +329,9 @@ func backupDataForAllTables(tables []Table) []map[uint32]int64 {
 
 	var agentErr error
 	if MustGetFlagBool(options.SINGLE_DATA_FILE) {
-		agentErr = utils.CheckAgentErrorsOnSegments(globalCluster, globalFPInfo)
+		for stream := 0; stream < backupReport.NumDataStreams() && agentErr == nil; stream++ {
+			agentErr = utils.CheckAgentErrorsOnSegments(globalCluster, globalFPInfo.ForDataStream(stream))
+		}
 	}
 
 	if copyErr != nil && agentErr != nil {
@@ -226,7 +345,7 @@ func backupDataForAllTables(tables []Table) []map[uint32]int64 {
 
 	counters.ProgressBar.Finish()
 	printDataBackupWarnings(numExtOrForeignTables)
-	return rowsCopiedMaps
+	return rowsCopiedMaps, fingerprintMaps
 }
 
 func printDataBackupWarnings(numExtTables int64) {
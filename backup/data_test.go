@@ -1,14 +1,21 @@
 package backup_test
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	path "path/filepath"
 	"regexp"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
 	"github.com/greenplum-db/gpbackup/backup"
+	"github.com/greenplum-db/gpbackup/filepath"
 	"github.com/greenplum-db/gpbackup/history"
 	"github.com/greenplum-db/gpbackup/options"
 	"github.com/greenplum-db/gpbackup/report"
+	"github.com/greenplum-db/gpbackup/testutils"
 	"github.com/greenplum-db/gpbackup/toc"
 	"github.com/greenplum-db/gpbackup/utils"
 	"gopkg.in/cheggaaa/pb.v1"
@@ -37,14 +44,16 @@ var _ = Describe("backup/data tests", func() {
 	})
 	Describe("AddTableDataEntriesToTOC", func() {
 		var (
-			tocfile        *toc.TOC
-			rowsCopiedMaps []map[uint32]int64
-			table          backup.Table
+			tocfile         *toc.TOC
+			rowsCopiedMaps  []map[uint32]int64
+			fingerprintMaps []map[uint32]string
+			table           backup.Table
 		)
 		BeforeEach(func() {
 			tocfile = &toc.TOC{}
 			backup.SetTOC(tocfile)
 			rowsCopiedMaps = make([]map[uint32]int64, connectionPool.NumConns)
+			fingerprintMaps = make([]map[uint32]string, connectionPool.NumConns)
 			columnDefs := []backup.ColumnDefinition{{Oid: 1, Name: "a"}}
 			table = backup.Table{
 				Relation:        backup.Relation{Oid: 1, Schema: "public", Name: "table"},
@@ -53,24 +62,50 @@ var _ = Describe("backup/data tests", func() {
 		})
 		It("adds an entry for a regular table to the TOC", func() {
 			tables := []backup.Table{table}
-			backup.AddTableDataEntriesToTOC(tables, rowsCopiedMaps)
+			backup.AddTableDataEntriesToTOC(tables, rowsCopiedMaps, fingerprintMaps)
 			expectedDataEntries := []toc.MasterDataEntry{{Schema: "public", Name: "table", Oid: 1, AttributeString: "(a)"}}
 			Expect(tocfile.DataEntries).To(Equal(expectedDataEntries))
 		})
+		It("adds an entry with a fingerprint to the TOC", func() {
+			rowsCopiedMaps[0] = map[uint32]int64{1: 10}
+			fingerprintMaps[0] = map[uint32]string{1: "10:-12345"}
+			tables := []backup.Table{table}
+			backup.AddTableDataEntriesToTOC(tables, rowsCopiedMaps, fingerprintMaps)
+			expectedDataEntries := []toc.MasterDataEntry{{Schema: "public", Name: "table", Oid: 1, AttributeString: "(a)", RowsCopied: 10, Fingerprint: "10:-12345"}}
+			Expect(tocfile.DataEntries).To(Equal(expectedDataEntries))
+		})
 		It("does not add an entry for an external table to the TOC", func() {
 			table.IsExternal = true
 			tables := []backup.Table{table}
-			backup.AddTableDataEntriesToTOC(tables, rowsCopiedMaps)
+			backup.AddTableDataEntriesToTOC(tables, rowsCopiedMaps, fingerprintMaps)
 			Expect(tocfile.DataEntries).To(BeNil())
 		})
 		It("does not add an entry for a foreign table to the TOC", func() {
 			foreignDef := backup.ForeignTableDefinition{Oid: 23, Options: "", Server: "fs"}
 			table.ForeignDef = foreignDef
 			tables := []backup.Table{table}
-			backup.AddTableDataEntriesToTOC(tables, rowsCopiedMaps)
+			backup.AddTableDataEntriesToTOC(tables, rowsCopiedMaps, fingerprintMaps)
 			Expect(tocfile.DataEntries).To(BeNil())
 		})
 	})
+	Describe("AssignTablesToDataStreams", func() {
+		tables := []backup.Table{
+			{Relation: backup.Relation{Oid: 1, Schema: "public", Name: "t1"}},
+			{Relation: backup.Relation{Oid: 2, Schema: "public", Name: "ext"}, TableDefinition: backup.TableDefinition{IsExternal: true}},
+			{Relation: backup.Relation{Oid: 3, Schema: "public", Name: "t3"}},
+			{Relation: backup.Relation{Oid: 4, Schema: "public", Name: "t4"}},
+		}
+		It("assigns the tables with data to the streams in turn, keeping their order", func() {
+			dataStreams := backup.AssignTablesToDataStreams(tables, 2)
+
+			Expect(dataStreams).To(Equal([][]backup.Table{{tables[0], tables[3]}, {tables[2]}}))
+		})
+		It("only returns streams that have tables", func() {
+			dataStreams := backup.AssignTablesToDataStreams(tables, 8)
+
+			Expect(dataStreams).To(Equal([][]backup.Table{{tables[0]}, {tables[2]}, {tables[3]}}))
+		})
+	})
 	Describe("CopyTableOut", func() {
 		testTable := backup.Table{Relation: backup.Relation{SchemaOid: 2345, Oid: 3456, Schema: "public", Name: "foo"}}
 		It("will back up a table to its own file with compression", func() {
@@ -119,6 +154,21 @@ var _ = Describe("backup/data tests", func() {
 
 			Expect(err).ShouldNot(HaveOccurred())
 		})
+		It("will back up a table to its own file through a throttling stage when data is rate limited", func() {
+			testCluster := testutils.SetupTestCluster()
+			testCluster.Executor = &testhelper.TestExecutor{ClusterOutput: &cluster.RemoteOutput{}}
+			fpInfo := filepath.NewFilePathInfo(testCluster, "", "20170101010101", "gpseg")
+			utils.StartRateController(testCluster, fpInfo, path.Join(os.TempDir(), "gpbackup_test_rate_limit.yaml"), utils.RateLimit{MaxRate: 10}, 1)
+			defer utils.StopRateController()
+			utils.SetPipeThroughProgram(utils.PipeThroughProgram{Name: "gzip", OutputCommand: "gzip -c -8", InputCommand: "gzip -d -c", Extension: ".gz"})
+			execStr := regexp.QuoteMeta(fmt.Sprintf("COPY public.foo TO PROGRAM 'gzip -c -8 | gpbackup_helper --throttle --rate-file <SEG_DATA_DIR>/gpbackup_<SEGID>_20170101010101_rate_%d > <SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz' WITH CSV DELIMITER ',' ON SEGMENT IGNORE EXTERNAL PARTITIONS;", fpInfo.PID))
+			mock.ExpectExec(execStr).WillReturnResult(sqlmock.NewResult(10, 0))
+			filename := "<SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz"
+
+			_, err := backup.CopyTableOut(connectionPool, testTable, filename, defaultConnNum)
+
+			Expect(err).ShouldNot(HaveOccurred())
+		})
 		It("will back up a table to a single file", func() {
 			_ = cmdFlags.Set(options.SINGLE_DATA_FILE, "true")
 			execStr := regexp.QuoteMeta(`COPY public.foo TO PROGRAM '(test -p "<SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456" || (echo "Pipe not found <SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456">&2; exit 1)) && cat - > <SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456' WITH CSV DELIMITER ',' ON SEGMENT IGNORE EXTERNAL PARTITIONS;`)
@@ -132,10 +182,11 @@ var _ = Describe("backup/data tests", func() {
 	})
 	Describe("BackupSingleTableData", func() {
 		var (
-			testTable     backup.Table
-			rowsCopiedMap map[uint32]int64
-			counters      backup.BackupProgressCounters
-			copyFmtStr    = "COPY(.*)%s(.*)"
+			testTable      backup.Table
+			rowsCopiedMap  map[uint32]int64
+			fingerprintMap map[uint32]string
+			counters       backup.BackupProgressCounters
+			copyFmtStr     = "COPY(.*)%s(.*)"
 		)
 		BeforeEach(func() {
 			testTable = backup.Table{
@@ -144,6 +195,7 @@ var _ = Describe("backup/data tests", func() {
 			}
 			_ = cmdFlags.Set(options.SINGLE_DATA_FILE, "false")
 			rowsCopiedMap = make(map[uint32]int64)
+			fingerprintMap = make(map[uint32]string)
 			counters = backup.BackupProgressCounters{NumRegTables: 0, TotalRegTables: 1}
 			counters.ProgressBar = utils.NewProgressBar(int(counters.TotalRegTables), "Tables backed up: ", utils.PB_INFO)
 			counters.ProgressBar.(*pb.ProgressBar).NotPrint = true
@@ -155,7 +207,7 @@ var _ = Describe("backup/data tests", func() {
 			backupFile := fmt.Sprintf("<SEG_DATA_DIR>/gpbackup_<SEGID>_20170101010101_pipe_(.*)_%d", testTable.Oid)
 			copyCmd := fmt.Sprintf(copyFmtStr, backupFile)
 			mock.ExpectExec(copyCmd).WillReturnResult(sqlmock.NewResult(0, 10))
-			err := backup.BackupSingleTableData(testTable, rowsCopiedMap, &counters, 0)
+			err := backup.BackupSingleTableData(testTable, rowsCopiedMap, fingerprintMap, &counters, 0)
 
 			Expect(err).ShouldNot(HaveOccurred())
 			Expect(rowsCopiedMap[0]).To(Equal(int64(10)))
@@ -167,12 +219,81 @@ var _ = Describe("backup/data tests", func() {
 			backupFile := fmt.Sprintf("<SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_%d", testTable.Oid)
 			copyCmd := fmt.Sprintf(copyFmtStr, backupFile)
 			mock.ExpectExec(copyCmd).WillReturnResult(sqlmock.NewResult(0, 10))
-			err := backup.BackupSingleTableData(testTable, rowsCopiedMap, &counters, 0)
+			err := backup.BackupSingleTableData(testTable, rowsCopiedMap, fingerprintMap, &counters, 0)
 
 			Expect(err).ShouldNot(HaveOccurred())
 			Expect(rowsCopiedMap[0]).To(Equal(int64(10)))
 			Expect(counters.NumRegTables).To(Equal(int64(1)))
 		})
+		It("backs up a single regular table with a fingerprint", func() {
+			_ = cmdFlags.Set(options.WITH_FINGERPRINTS, "true")
+			defer func() { _ = cmdFlags.Set(options.WITH_FINGERPRINTS, "false") }()
+
+			mock.ExpectExec("COPY (.*)").WillReturnResult(sqlmock.NewResult(0, 10))
+			mock.ExpectQuery("SELECT count(.*) FROM public.testtable t").WillReturnRows(sqlmock.NewRows([]string{"rowcount", "hashsum"}).AddRow(10, -12345))
+			err := backup.BackupSingleTableData(testTable, rowsCopiedMap, fingerprintMap, &counters, 0)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(fingerprintMap[0]).To(Equal("10:-12345"))
+		})
+		It("does not record a fingerprint whose row count differs from the rows copied", func() {
+			_ = cmdFlags.Set(options.WITH_FINGERPRINTS, "true")
+			defer func() { _ = cmdFlags.Set(options.WITH_FINGERPRINTS, "false") }()
+
+			mock.ExpectExec("COPY (.*)").WillReturnResult(sqlmock.NewResult(0, 10))
+			mock.ExpectQuery("SELECT count(.*) FROM public.testtable t").WillReturnRows(sqlmock.NewRows([]string{"rowcount", "hashsum"}).AddRow(12, -12345))
+			err := backup.BackupSingleTableData(testTable, rowsCopiedMap, fingerprintMap, &counters, 0)
+
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(fingerprintMap).To(BeEmpty())
+		})
+	})
+	Describe("CopyTableOutWithRetries", func() {
+		testTable := backup.Table{Relation: backup.Relation{Oid: 3456, Schema: "public", Name: "foo"}}
+		filename := "<SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456"
+		var pluginConfig utils.PluginConfig
+		BeforeEach(func() {
+			_ = cmdFlags.Set(options.PLUGIN_CONFIG, "/tmp/plugin_config")
+			pluginConfig = utils.PluginConfig{ExecutablePath: "/tmp/fake-plugin.sh", ConfigPath: "/tmp/plugin_config", Retry: &utils.RetryPolicy{MaxAttempts: 2}}
+			backup.SetPluginConfig(&pluginConfig)
+		})
+		AfterEach(func() {
+			backup.SetPluginConfig(nil)
+		})
+		It("re-runs a failed COPY after rolling back to a savepoint", func() {
+			mock.ExpectExec("SAVEPOINT gpbackup_copy").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("COPY public.foo TO (.*)").WillReturnError(errors.New("plugin failed"))
+			mock.ExpectExec("ROLLBACK TO SAVEPOINT gpbackup_copy").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("SAVEPOINT gpbackup_copy").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("COPY public.foo TO (.*)").WillReturnResult(sqlmock.NewResult(0, 10))
+			mock.ExpectExec("RELEASE SAVEPOINT gpbackup_copy").WillReturnResult(sqlmock.NewResult(0, 0))
+
+			rowsCopied, err := backup.CopyTableOutWithRetries(testTable, filename, 0)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rowsCopied).To(Equal(int64(10)))
+			Expect(pluginConfig.Retries()).To(Equal([]utils.PluginRetry{{Command: "backup_data", Filename: "public.foo", Attempt: 1, Error: "plugin failed"}}))
+		})
+		It("returns the error from the last attempt", func() {
+			for i := 0; i < 2; i++ {
+				mock.ExpectExec("SAVEPOINT gpbackup_copy").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("COPY public.foo TO (.*)").WillReturnError(errors.New("plugin failed"))
+				mock.ExpectExec("ROLLBACK TO SAVEPOINT gpbackup_copy").WillReturnResult(sqlmock.NewResult(0, 0))
+			}
+
+			_, err := backup.CopyTableOutWithRetries(testTable, filename, 0)
+
+			Expect(err).To(MatchError("plugin failed"))
+			Expect(pluginConfig.Retries()).To(HaveLen(1))
+		})
+		It("does not use a savepoint when backup_data cannot be retried", func() {
+			pluginConfig.Retry.IdempotentCommands = []string{"backup_file"}
+			mock.ExpectExec("COPY public.foo TO (.*)").WillReturnError(errors.New("plugin failed"))
+
+			_, err := backup.CopyTableOutWithRetries(testTable, filename, 0)
+
+			Expect(err).To(MatchError("plugin failed"))
+		})
 	})
 	Describe("CheckDBContainsData", func() {
 		config := history.BackupConfig{}
@@ -8,6 +8,7 @@ import (
 	"github.com/greenplum-db/gpbackup/filepath"
 	"github.com/greenplum-db/gpbackup/options"
 	"github.com/greenplum-db/gpbackup/report"
+	"github.com/greenplum-db/gpbackup/storage"
 	"github.com/greenplum-db/gpbackup/toc"
 	"github.com/greenplum-db/gpbackup/utils"
 	"github.com/nightlyone/lockfile"
@@ -30,11 +31,14 @@ var (
 	globalTOC            *toc.TOC
 	objectCounts         map[string]int
 	pluginConfig         *utils.PluginConfig
+	storageBackend       storage.StorageBackend
 	version              string
 	wasTerminated        bool
 	backupLockFile       lockfile.Lockfile
 	filterRelationClause string
 	quotedRoleNames      map[string]string
+	sessionGUCs          []utils.SessionGUC
+	tableDataStreams     map[uint32]int
 	/*
 	 * Used for synchronizing DoCleanup.  In DoInit() we increment the group
 	 * and then wait for at least one DoCleanup to finish, either in DoTeardown
@@ -73,6 +77,10 @@ func SetPluginConfig(config *utils.PluginConfig) {
 	pluginConfig = config
 }
 
+func SetStorageBackend(backend storage.StorageBackend) {
+	storageBackend = backend
+}
+
 func SetReport(report *report.Report) {
 	backupReport = report
 }
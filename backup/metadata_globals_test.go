@@ -5,6 +5,7 @@ import (
 
 	"github.com/greenplum-db/gp-common-go-libs/testhelper"
 	"github.com/greenplum-db/gpbackup/backup"
+	"github.com/greenplum-db/gpbackup/options"
 	"github.com/greenplum-db/gpbackup/testutils"
 
 	. "github.com/onsi/ginkgo"
@@ -346,6 +347,14 @@ ALTER ROLE "testRole2" WITH SUPERUSER INHERIT CREATEROLE CREATEDB LOGIN REPLICAT
 				`SECURITY LABEL FOR dummy ON ROLE "testRole2" IS 'unclassified';`}
 			testutils.AssertBufferContents(tocfile.GlobalEntries, buffer, expectedStatements...)
 
+		})
+		It("prints a role without its resource group when resource groups are not backed up", func() {
+			_ = cmdFlags.Set(options.WITHOUT_RES_GROUPS, "true")
+			emptyMetadataMap := backup.MetadataMap{}
+			backup.PrintCreateRoleStatements(backupfile, tocfile, []backup.Role{testrole1}, emptyMetadataMap)
+
+			testutils.AssertBufferContents(tocfile.GlobalEntries, buffer, `CREATE ROLE testrole1;
+ALTER ROLE testrole1 WITH NOSUPERUSER NOINHERIT NOCREATEROLE NOCREATEDB NOLOGIN RESOURCE QUEUE pg_default;`)
 		})
 		It("prints multiple roles", func() {
 			emptyMetadataMap := backup.MetadataMap{}
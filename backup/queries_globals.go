@@ -7,11 +7,13 @@ package backup
 
 import (
 	"fmt"
+	"regexp"
 
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
 	"github.com/greenplum-db/gpbackup/toc"
 	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
 )
 
 type SessionGUCs struct {
@@ -524,6 +526,56 @@ func GetRoleMembers(connectionPool *dbconn.DBConn) []RoleMember {
 	return results
 }
 
+/*
+ * Roles are filtered by their unquoted names, and the pattern must match the
+ * entire name.  A nil pattern matches every role.
+ */
+func GetRolePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	rolePattern, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", pattern))
+	if err != nil {
+		return nil, errors.Errorf("Invalid role pattern %s: %v", pattern, err)
+	}
+	return rolePattern, nil
+}
+
+func roleMatchesPattern(quotedName string, rolePattern *regexp.Regexp) bool {
+	return rolePattern == nil || rolePattern.MatchString(utils.UnquoteIdent(quotedName))
+}
+
+func FilterRoles(roles []Role, rolePattern *regexp.Regexp) []Role {
+	filteredRoles := make([]Role, 0)
+	for _, role := range roles {
+		if roleMatchesPattern(role.Name, rolePattern) {
+			filteredRoles = append(filteredRoles, role)
+		}
+	}
+	return filteredRoles
+}
+
+func FilterRoleGUCs(roleGUCs map[string][]RoleGUC, rolePattern *regexp.Regexp) map[string][]RoleGUC {
+	filteredGUCs := make(map[string][]RoleGUC)
+	for roleName, gucs := range roleGUCs {
+		if roleMatchesPattern(roleName, rolePattern) {
+			filteredGUCs[roleName] = gucs
+		}
+	}
+	return filteredGUCs
+}
+
+// A membership is only kept if both roles are, as granting a role that is not restored would fail
+func FilterRoleMembers(roleMembers []RoleMember, rolePattern *regexp.Regexp) []RoleMember {
+	filteredMembers := make([]RoleMember, 0)
+	for _, roleMember := range roleMembers {
+		if roleMatchesPattern(roleMember.Role, rolePattern) && roleMatchesPattern(roleMember.Member, rolePattern) {
+			filteredMembers = append(filteredMembers, roleMember)
+		}
+	}
+	return filteredMembers
+}
+
 type Tablespace struct {
 	Oid              uint32
 	Tablespace       string
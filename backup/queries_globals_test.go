@@ -0,0 +1,58 @@
+package backup_test
+
+import (
+	"github.com/greenplum-db/gpbackup/backup"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("backup/queries_globals tests", func() {
+	Describe("GetRolePattern", func() {
+		It("returns a nil pattern if no pattern is given", func() {
+			rolePattern, err := backup.GetRolePattern("")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rolePattern).To(BeNil())
+		})
+		It("matches entire role names", func() {
+			rolePattern, err := backup.GetRolePattern("app_.*|admin")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rolePattern.MatchString("app_reader")).To(BeTrue())
+			Expect(rolePattern.MatchString("admin")).To(BeTrue())
+			Expect(rolePattern.MatchString("my_app_reader")).To(BeFalse())
+			Expect(rolePattern.MatchString("administrator")).To(BeFalse())
+		})
+		It("returns an error for an invalid pattern", func() {
+			_, err := backup.GetRolePattern("app_(")
+			Expect(err).To(MatchError(ContainSubstring("Invalid role pattern app_(")))
+		})
+	})
+	Describe("role filtering", func() {
+		rolePattern, _ := backup.GetRolePattern("app_.*")
+		It("keeps all roles if there is no pattern", func() {
+			roles := []backup.Role{{Name: "app_reader"}, {Name: "other"}}
+			Expect(backup.FilterRoles(roles, nil)).To(Equal(roles))
+		})
+		It("keeps roles matching the pattern by their unquoted names", func() {
+			roles := []backup.Role{{Name: "app_reader"}, {Name: `"app_Writer"`}, {Name: "other"}}
+			Expect(backup.FilterRoles(roles, rolePattern)).To(Equal([]backup.Role{{Name: "app_reader"}, {Name: `"app_Writer"`}}))
+		})
+		It("keeps configuration parameters of roles matching the pattern", func() {
+			roleGUCs := map[string][]backup.RoleGUC{
+				"app_reader": {{RoleName: "app_reader", Config: "SET search_path TO public"}},
+				"other":      {{RoleName: "other", Config: "SET search_path TO public"}},
+			}
+			Expect(backup.FilterRoleGUCs(roleGUCs, rolePattern)).To(Equal(map[string][]backup.RoleGUC{
+				"app_reader": {{RoleName: "app_reader", Config: "SET search_path TO public"}},
+			}))
+		})
+		It("keeps memberships only if both roles match the pattern", func() {
+			roleMembers := []backup.RoleMember{
+				{Role: "app_group", Member: "app_reader"},
+				{Role: "other_group", Member: "app_reader"},
+				{Role: "app_group", Member: "other"},
+			}
+			Expect(backup.FilterRoleMembers(roleMembers, rolePattern)).To(Equal([]backup.RoleMember{{Role: "app_group", Member: "app_reader"}}))
+		})
+	})
+})
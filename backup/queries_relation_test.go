@@ -4,10 +4,16 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"regexp"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/greenplum-db/gp-common-go-libs/structmatcher"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
 	"github.com/greenplum-db/gpbackup/backup"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/report"
+	"github.com/jackc/pgconn"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -36,6 +42,110 @@ var _ = Describe("backup internal tests", func() {
 			Expect(len(lockQueries)).To(Equal(3))
 		})
 	})
+	Describe("LockTables", func() {
+		foo := backup.Relation{SchemaOid: 2200, Oid: 1, Schema: "public", Name: "foo"}
+		bar := backup.Relation{SchemaOid: 2200, Oid: 2, Schema: "public", Name: "bar"}
+		lockTimeoutErr := &pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"}
+		var backupReport *report.Report
+		BeforeEach(func() {
+			backupReport = &report.Report{}
+			backup.SetReport(backupReport)
+			backup.SetFilterRelationClause("n.nspname = 'public'")
+			_ = cmdFlags.Set(options.LOCK_WAIT_TIMEOUT, "5")
+		})
+		AfterEach(func() {
+			backup.SetFilterRelationClause("")
+		})
+		expectLock := func(tables string, err error) {
+			mock.ExpectExec("SAVEPOINT gpbackup_lock_tables").WillReturnResult(sqlmock.NewResult(0, 0))
+			if err == nil {
+				mock.ExpectExec(regexp.QuoteMeta(fmt.Sprintf("LOCK TABLE %s IN ACCESS SHARE MODE", tables))).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("RELEASE SAVEPOINT gpbackup_lock_tables").WillReturnResult(sqlmock.NewResult(0, 0))
+			} else {
+				mock.ExpectExec(regexp.QuoteMeta(fmt.Sprintf("LOCK TABLE %s IN ACCESS SHARE MODE", tables))).WillReturnError(err)
+				mock.ExpectExec("ROLLBACK TO SAVEPOINT gpbackup_lock_tables").WillReturnResult(sqlmock.NewResult(0, 0))
+			}
+		}
+		expectSetTimeout := func() {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT current_setting('statement_timeout')")).WillReturnRows(sqlmock.NewRows([]string{"current_setting"}).AddRow("0"))
+			mock.ExpectExec("SET statement_timeout = 5000").WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		expectLockHolders := func() {
+			mock.ExpectQuery("SELECT DISTINCT l.pid AS pid").WillReturnRows(sqlmock.NewRows([]string{"pid", "query"}).AddRow(1234, "ALTER TABLE public.bar ADD COLUMN j int"))
+		}
+		It("locks all tables in a batch when none of them are blocked", func() {
+			expectSetTimeout()
+			expectLock("public.foo, public.bar", nil)
+			mock.ExpectExec("SET statement_timeout = '0'").WillReturnResult(sqlmock.NewResult(0, 0))
+
+			lockedTables := backup.LockTables(connectionPool, []backup.Relation{foo, bar})
+
+			Expect(lockedTables).To(Equal([]backup.Relation{foo, bar}))
+			Expect(backupReport.BlockedTables).To(BeEmpty())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("restores the timeout that was in effect before the tables were locked", func() {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT current_setting('statement_timeout')")).WillReturnRows(sqlmock.NewRows([]string{"current_setting"}).AddRow("1h"))
+			mock.ExpectExec("SET statement_timeout = 5000").WillReturnResult(sqlmock.NewResult(0, 0))
+			expectLock("public.foo", nil)
+			mock.ExpectExec("SET statement_timeout = '1h'").WillReturnResult(sqlmock.NewResult(0, 0))
+
+			backup.LockTables(connectionPool, []backup.Relation{foo})
+
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("skips a blocked table with the skip strategy and records what blocked it", func() {
+			_ = cmdFlags.Set(options.LOCK_WAIT_STRATEGY, "skip")
+			expectSetTimeout()
+			expectLock("public.foo, public.bar", lockTimeoutErr)
+			expectLock("public.foo", nil)
+			expectLock("public.bar", lockTimeoutErr)
+			expectLockHolders()
+			mock.ExpectExec("SET statement_timeout = '0'").WillReturnResult(sqlmock.NewResult(0, 0))
+
+			lockedTables := backup.LockTables(connectionPool, []backup.Relation{foo, bar})
+
+			Expect(lockedTables).To(Equal([]backup.Relation{foo}))
+			Expect(backupReport.BlockedTables).To(HaveLen(1))
+			Expect(backupReport.BlockedTables[0].Table).To(Equal("public.bar"))
+			Expect(backupReport.BlockedTables[0].Action).To(Equal("skipped"))
+			Expect(backupReport.BlockedTables[0].Blockers).To(Equal([]report.LockHolder{{Pid: 1234, Query: "ALTER TABLE public.bar ADD COLUMN j int"}}))
+			Expect(string(logfile.Contents())).To(ContainSubstring("Skipping table public.bar, which could not be locked within 5 seconds"))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("locks a blocked table after the other tables with the retry strategy", func() {
+			_ = cmdFlags.Set(options.LOCK_WAIT_STRATEGY, "retry")
+			expectSetTimeout()
+			expectLock("public.bar, public.foo", lockTimeoutErr)
+			expectLock("public.bar", lockTimeoutErr)
+			expectLockHolders()
+			expectLock("public.foo", nil)
+			expectLock("public.bar", nil)
+			mock.ExpectExec("SET statement_timeout = '0'").WillReturnResult(sqlmock.NewResult(0, 0))
+
+			lockedTables := backup.LockTables(connectionPool, []backup.Relation{bar, foo})
+
+			Expect(lockedTables).To(Equal([]backup.Relation{foo, bar}))
+			Expect(backupReport.BlockedTables).To(HaveLen(1))
+			Expect(backupReport.BlockedTables[0].Action).To(Equal("locked after retrying"))
+			Expect(backupReport.BlockedTables[0].Waited).To(BeNumerically(">=", time.Duration(0)))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("fails on a blocked table with the fail strategy", func() {
+			expectSetTimeout()
+			expectLock("public.bar", lockTimeoutErr)
+			expectLock("public.bar", lockTimeoutErr)
+			expectLockHolders()
+
+			defer func() {
+				Expect(backupReport.BlockedTables).To(HaveLen(1))
+				Expect(backupReport.BlockedTables[0].Action).To(Equal("failed"))
+				Expect(string(logfile.Contents())).To(ContainSubstring("Table public.bar is blocked by pid 1234 (ALTER TABLE public.bar ADD COLUMN j int)"))
+			}()
+			defer testhelper.ShouldPanicWithMessage("Unable to acquire ACCESS SHARE lock within 5 seconds on table(s) public.bar")
+			backup.LockTables(connectionPool, []backup.Relation{bar})
+		})
+	})
 	Describe("GetAllViews", func() {
 		It("GetAllViews properly handles NULL view definitions", func() {
 			header := []string{"oid", "schema", "name", "options", "definition", "tablespace", "ismaterialized"}
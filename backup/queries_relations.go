@@ -9,12 +9,15 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
 	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/report"
 	"github.com/greenplum-db/gpbackup/toc"
 	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/jackc/pgconn"
 	"github.com/pkg/errors"
 )
 
@@ -52,6 +55,86 @@ func getOidsFromRelationList(connectionPool *dbconn.DBConn, quotedIncludeRelatio
 	return dbconn.MustSelectStringSlice(connectionPool, query)
 }
 
+/*
+ * Returns the unquoted names of the user schemas that schema patterns are
+ * matched against.  Names containing a dot are left out, as the schema and
+ * table filters cannot name them.
+ */
+func GetSchemaNamesForFilterPatterns(connectionPool *dbconn.DBConn) []string {
+	query := fmt.Sprintf(`
+	SELECT n.nspname AS string
+	FROM pg_namespace n
+	WHERE n.nspname NOT LIKE 'pg_temp_%%' AND n.nspname NOT LIKE 'pg_toast%%'
+		AND n.nspname NOT IN ('gp_toolkit', 'information_schema', 'pg_aoseg', 'pg_bitmapindex', 'pg_catalog')
+		AND n.nspname NOT LIKE '%%.%%'
+		AND %s
+	ORDER BY n.nspname`, ExtensionFilterClause("n"))
+	return dbconn.MustSelectStringSlice(connectionPool, query)
+}
+
+/*
+ * Returns the unquoted names of the user relations that table patterns are
+ * matched against: tables, foreign tables, sequences, views, and materialized
+ * views, the same relations that gprestore matches patterns against in the
+ * TOC.  Child partitions are left out, so that a pattern matching a partition
+ * table does not also name its children, unless --leaf-partition-data is set,
+ * in which case leaf partitions can be matched on their own as they can be at
+ * restore.  Intermediate partitions cannot be filtered on, so they are never
+ * matched.
+ */
+func GetTableNamesForFilterPatterns(connectionPool *dbconn.DBConn) []options.FqnStruct {
+	relkinds := "'r', 'f', 'S', 'v', 'm'"
+	childPartitionFilter := ""
+	if connectionPool.Version.AtLeast("7") {
+		// GPDB 7 partition tables have a relkind of their own, and their partitions are found with pg_partition_tree
+		relkinds += ", 'p'"
+		leafPartitionFilter := ""
+		if MustGetFlagBool(options.LEAF_PARTITION_DATA) {
+			leafPartitionFilter = " AND NOT t.isleaf"
+		}
+		childPartitionFilter = fmt.Sprintf(`
+		AND c.oid NOT IN (SELECT t.relid FROM (SELECT (pg_partition_tree(r.oid)).* FROM pg_class r WHERE r.relkind = 'p' AND NOT r.relispartition) t WHERE t.level > 0%s)`, leafPartitionFilter)
+	} else if !MustGetFlagBool(options.LEAF_PARTITION_DATA) {
+		childPartitionFilter = `
+		AND c.oid NOT IN (SELECT parchildrelid FROM pg_partition_rule)`
+	}
+	query := fmt.Sprintf(`
+	SELECT c.oid,
+		n.nspname AS schemaname,
+		c.relname AS tablename
+	FROM pg_class c
+		JOIN pg_namespace n ON c.relnamespace = n.oid
+	WHERE n.nspname NOT LIKE 'pg_temp_%%' AND n.nspname NOT LIKE 'pg_toast%%'
+		AND n.nspname NOT IN ('gp_toolkit', 'information_schema', 'pg_aoseg', 'pg_bitmapindex', 'pg_catalog')
+		AND n.nspname NOT LIKE '%%.%%'
+		AND c.relname NOT LIKE '%%.%%'
+		AND c.relkind IN (%s)%s
+		AND %s
+	ORDER BY n.nspname, c.relname`, relkinds, childPartitionFilter, ExtensionFilterClause("c"))
+
+	relations := make([]struct {
+		Oid        uint32
+		SchemaName string
+		TableName  string
+	}, 0)
+	err := connectionPool.Select(&relations, query)
+	gplog.FatalOnError(err)
+
+	// On GPDB 7, intermediate partitions are already left out by the query
+	partTableMap := make(map[uint32]PartitionLevelInfo)
+	if connectionPool.Version.Before("7") {
+		partTableMap = GetPartitionTableMap(connectionPool)
+	}
+	results := make([]options.FqnStruct, 0)
+	for _, relation := range relations {
+		if partTableMap[relation.Oid].Level == "i" {
+			continue
+		}
+		results = append(results, options.FqnStruct{SchemaName: relation.SchemaName, TableName: relation.TableName})
+	}
+	return results
+}
+
 func GetIncludedUserTableRelations(connectionPool *dbconn.DBConn, includedRelationsQuoted []string) []Relation {
 	if len(MustGetFlagStringArray(options.INCLUDE_RELATION)) > 0 {
 		return getUserTableRelationsWithIncludeFiltering(connectionPool, includedRelationsQuoted)
@@ -365,13 +448,23 @@ func GetAllViews(connectionPool *dbconn.DBConn) []View {
 // dumping part but it also makes the main worker thread (worker 0) the
 // most resilient for the later data dumping logic. Locks will still be
 // taken for --data-only calls.
-func LockTables(connectionPool *dbconn.DBConn, tables []Relation) {
+//
+// If --lock-wait-timeout is set, tables that cannot be locked in time are
+// handled according to --lock-wait-strategy, and the tables that are locked
+// are returned; otherwise, all tables are locked and returned.
+func LockTables(connectionPool *dbconn.DBConn, tables []Relation) []Relation {
 	gplog.Info("Acquiring ACCESS SHARE locks on tables")
 
 	progressBar := utils.NewProgressBar(len(tables), "Locks acquired: ", utils.PB_VERBOSE)
 	progressBar.Start()
 
 	const batchSize = 100
+	if MustGetFlagInt(options.LOCK_WAIT_TIMEOUT) > 0 {
+		lockedTables := lockTablesWithTimeout(connectionPool, tables, batchSize, progressBar)
+		progressBar.Finish()
+		return lockedTables
+	}
+
 	lastBatchSize := len(tables) % batchSize
 	tableBatches := GenerateTableBatches(tables, batchSize)
 	currentBatchSize := batchSize
@@ -396,6 +489,208 @@ func LockTables(connectionPool *dbconn.DBConn, tables []Relation) {
 	}
 
 	progressBar.Finish()
+	return tables
+}
+
+const (
+	LockWaitFail  = "fail"
+	LockWaitSkip  = "skip"
+	LockWaitRetry = "retry"
+
+	// The number of times tables are retried with the retry strategy before the backup fails
+	lockWaitRetryAttempts = 3
+)
+
+func ValidateLockWaitStrategy(strategy string) error {
+	switch strategy {
+	case LockWaitFail, LockWaitSkip, LockWaitRetry:
+		return nil
+	}
+	return errors.Errorf("Invalid lock wait strategy %s.  Valid values are %s, %s, and %s.", strategy, LockWaitFail, LockWaitSkip, LockWaitRetry)
+}
+
+/*
+ * Tables are locked in batches as usual, but if a batch cannot be locked
+ * within the timeout, its tables are locked one at a time to find the ones
+ * that are blocked, and the sessions blocking them are recorded in the
+ * backup report.
+ */
+func lockTablesWithTimeout(connectionPool *dbconn.DBConn, tables []Relation, batchSize int, progressBar utils.ProgressBar) []Relation {
+	timeout := MustGetFlagInt(options.LOCK_WAIT_TIMEOUT)
+	strategy := MustGetFlagString(options.LOCK_WAIT_STRATEGY)
+	timeoutGUC := lockWaitTimeoutGUC(connectionPool)
+	// The timeout in effect before locking, which may come from --session-guc-file, is restored afterward
+	previousTimeout := dbconn.MustSelectString(connectionPool, fmt.Sprintf("SELECT current_setting('%s')", timeoutGUC))
+	connectionPool.MustExec(fmt.Sprintf("SET %s = %d", timeoutGUC, timeout*1000))
+
+	lockedTables := make([]Relation, 0, len(tables))
+	skippedTables := make([]Relation, 0)
+	retryTables := make([]Relation, 0)
+	blockedTables := make(map[uint32]*report.BlockedTable)
+	for start := 0; start < len(tables); start += batchSize {
+		end := start + batchSize
+		if end > len(tables) {
+			end = len(tables)
+		}
+		batch := tables[start:end]
+		if tryLockTables(connectionPool, batch) {
+			lockedTables = append(lockedTables, batch...)
+			progressBar.Add(len(batch))
+			continue
+		}
+		for _, table := range batch {
+			startTime := time.Now()
+			if tryLockTables(connectionPool, []Relation{table}) {
+				lockedTables = append(lockedTables, table)
+				progressBar.Add(1)
+				continue
+			}
+			blocked := &report.BlockedTable{
+				Table:    table.FQN(),
+				Blockers: GetLockHolders(connectionPool, table.Oid),
+				Waited:   time.Since(startTime),
+			}
+			switch strategy {
+			case LockWaitFail:
+				failBlockedTables(timeout, blocked)
+			case LockWaitSkip:
+				blocked.Action = "skipped"
+				recordBlockedTable(blocked)
+				gplog.Warn("Skipping table %s, which could not be locked within %d seconds", table.FQN(), timeout)
+				skippedTables = append(skippedTables, table)
+			case LockWaitRetry:
+				blockedTables[table.Oid] = blocked
+				retryTables = append(retryTables, table)
+			}
+		}
+	}
+
+	for attempt := 1; attempt <= lockWaitRetryAttempts && len(retryTables) > 0; attempt++ {
+		gplog.Info("Retrying locks on %d blocked table(s), attempt %d of %d", len(retryTables), attempt, lockWaitRetryAttempts)
+		stillBlocked := make([]Relation, 0)
+		for _, table := range retryTables {
+			blocked := blockedTables[table.Oid]
+			startTime := time.Now()
+			if tryLockTables(connectionPool, []Relation{table}) {
+				blocked.Waited += time.Since(startTime)
+				blocked.Action = "locked after retrying"
+				recordBlockedTable(blocked)
+				lockedTables = append(lockedTables, table)
+				progressBar.Add(1)
+				continue
+			}
+			blocked.Waited += time.Since(startTime)
+			if holders := GetLockHolders(connectionPool, table.Oid); len(holders) > 0 {
+				blocked.Blockers = holders
+			}
+			stillBlocked = append(stillBlocked, table)
+		}
+		retryTables = stillBlocked
+	}
+	if len(retryTables) > 0 {
+		failed := make([]*report.BlockedTable, 0)
+		for _, table := range retryTables {
+			failed = append(failed, blockedTables[table.Oid])
+		}
+		failBlockedTables(timeout, failed...)
+	}
+
+	connectionPool.MustExec(fmt.Sprintf("SET %s = '%s'", timeoutGUC, utils.EscapeSingleQuotes(previousTimeout)))
+
+	if len(skippedTables) > 0 {
+		excludeRelationsFromFilter(skippedTables)
+	}
+	return lockedTables
+}
+
+func failBlockedTables(timeout int, blockedTables ...*report.BlockedTable) {
+	tableNames := make([]string, 0)
+	for _, blocked := range blockedTables {
+		blocked.Action = "failed"
+		recordBlockedTable(blocked)
+		blockers := make([]string, 0)
+		for _, holder := range blocked.Blockers {
+			blockers = append(blockers, holder.String())
+		}
+		gplog.Error("Table %s is blocked by %s", blocked.Table, strings.Join(blockers, ", "))
+		tableNames = append(tableNames, blocked.Table)
+	}
+	gplog.Fatal(errors.Errorf("Unable to acquire ACCESS SHARE lock within %d seconds on table(s) %s", timeout, strings.Join(tableNames, ", ")),
+		"See the backup report for the sessions holding conflicting locks.")
+}
+
+func recordBlockedTable(blocked *report.BlockedTable) {
+	if backupReport != nil {
+		backupReport.BlockedTables = append(backupReport.BlockedTables, *blocked)
+	}
+}
+
+// lock_timeout is not available before GPDB 6, so statement_timeout is used instead
+func lockWaitTimeoutGUC(connectionPool *dbconn.DBConn) string {
+	if connectionPool.Version.AtLeast("6") {
+		return "lock_timeout"
+	}
+	return "statement_timeout"
+}
+
+/*
+ * The locks are taken in a savepoint so that a lock timeout does not abort
+ * the transaction; locks taken before the savepoint are kept either way.
+ * Returns false if the tables could not be locked within the timeout.
+ */
+func tryLockTables(connectionPool *dbconn.DBConn, tables []Relation) bool {
+	tableNames := make([]string, 0, len(tables))
+	for _, table := range tables {
+		tableNames = append(tableNames, table.FQN())
+	}
+	connectionPool.MustExec("SAVEPOINT gpbackup_lock_tables")
+	_, err := connectionPool.Exec(fmt.Sprintf("LOCK TABLE %s IN ACCESS SHARE MODE", strings.Join(tableNames, ", ")))
+	if err != nil {
+		if wasTerminated {
+			gplog.Warn("Interrupt received while acquiring ACCESS SHARE locks on tables")
+			select {} // wait for cleanup thread to exit gpbackup
+		}
+		connectionPool.MustExec("ROLLBACK TO SAVEPOINT gpbackup_lock_tables")
+		// Postgres Error Codes 55P03 and 57014 translate to LOCK_NOT_AVAILABLE and QUERY_CANCELED
+		if pgErr, ok := err.(*pgconn.PgError); ok && (pgErr.Code == "55P03" || pgErr.Code == "57014") {
+			return false
+		}
+		gplog.FatalOnError(err)
+	}
+	connectionPool.MustExec("RELEASE SAVEPOINT gpbackup_lock_tables")
+	return true
+}
+
+// Returns the sessions holding or waiting for locks on the table that conflict with an ACCESS SHARE lock
+func GetLockHolders(connectionPool *dbconn.DBConn, relationOid uint32) []report.LockHolder {
+	pidColumn, queryColumn := "pid", "query"
+	if connectionPool.Version.Before("6") {
+		pidColumn, queryColumn = "procpid", "current_query"
+	}
+	query := fmt.Sprintf(`
+	SELECT DISTINCT l.pid AS pid,
+		coalesce(a.%s, '') AS query
+	FROM pg_locks l
+		LEFT JOIN pg_stat_activity a ON l.pid = a.%s
+	WHERE l.locktype = 'relation'
+		AND l.relation = %d
+		AND l.mode = 'AccessExclusiveLock'
+		AND l.pid <> pg_backend_pid()
+	ORDER BY l.pid`, queryColumn, pidColumn, relationOid)
+
+	results := make([]report.LockHolder, 0)
+	err := connectionPool.Select(&results, query)
+	gplog.FatalOnError(err)
+	return results
+}
+
+// Excludes the relations from all later relation queries, so that none of their metadata is backed up
+func excludeRelationsFromFilter(relations []Relation) {
+	oids := make([]string, 0, len(relations))
+	for _, relation := range relations {
+		oids = append(oids, fmt.Sprintf("%d", relation.Oid))
+	}
+	filterRelationClause = relationAndSchemaFilterClause() + fmt.Sprintf("\nAND c.oid NOT IN (%s)", strings.Join(oids, ", "))
 }
 
 // GenerateTableBatches batches tables to reduce network congestion and
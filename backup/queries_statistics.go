@@ -7,6 +7,7 @@ package backup
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
@@ -146,3 +147,170 @@ func GetTupleStatistics(connectionPool *dbconn.DBConn, tables []Table) map[uint3
 	}
 	return stats
 }
+
+type relatedRelation struct {
+	TableOid uint32
+	Relation
+}
+
+/*
+ * Statistics are also kept for the relations that belong to a table: its
+ * indexes and, for a partitioned table, its child partitions and their
+ * indexes.  Child partitions that are backed up as tables of their own are
+ * left to themselves.  The relations are returned by the oid of the table.
+ */
+func GetRelatedStatisticsRelations(connectionPool *dbconn.DBConn, tables []Table) map[uint32][]Relation {
+	related := make(map[uint32][]Relation)
+	if len(tables) == 0 {
+		return related
+	}
+	owners := make(map[uint32]uint32)
+	tableOids := make([]string, 0)
+	for _, table := range tables {
+		owners[table.Oid] = table.Oid
+		tableOids = append(tableOids, fmt.Sprintf("%d", table.Oid))
+	}
+
+	partitionQuery := fmt.Sprintf(`
+	SELECT p.parrelid AS tableoid,
+		n.oid AS schemaoid,
+		c.oid AS oid,
+		quote_ident(n.nspname) AS schema,
+		quote_ident(c.relname) AS name
+	FROM pg_partition p
+		JOIN pg_partition_rule r ON r.paroid = p.oid
+		JOIN pg_class c ON r.parchildrelid = c.oid
+		JOIN pg_namespace n ON c.relnamespace = n.oid
+	WHERE p.parrelid IN (%s)
+	ORDER BY c.oid`, strings.Join(tableOids, ", "))
+	if connectionPool.Version.AtLeast("7") {
+		partitionQuery = fmt.Sprintf(`
+	SELECT t.tableoid,
+		n.oid AS schemaoid,
+		c.oid AS oid,
+		quote_ident(n.nspname) AS schema,
+		quote_ident(c.relname) AS name
+	FROM (SELECT r.oid AS tableoid, (pg_partition_tree(r.oid)).* FROM pg_class r WHERE r.oid IN (%s)) t
+		JOIN pg_class c ON t.relid = c.oid
+		JOIN pg_namespace n ON c.relnamespace = n.oid
+	WHERE t.level > 0
+	ORDER BY c.oid`, strings.Join(tableOids, ", "))
+	}
+	partitions := make([]relatedRelation, 0)
+	err := connectionPool.Select(&partitions, partitionQuery)
+	gplog.FatalOnError(err)
+	relationOids := tableOids
+	for _, partition := range partitions {
+		if _, ok := owners[partition.Oid]; ok {
+			continue
+		}
+		owners[partition.Oid] = partition.TableOid
+		related[partition.TableOid] = append(related[partition.TableOid], partition.Relation)
+		relationOids = append(relationOids, fmt.Sprintf("%d", partition.Oid))
+	}
+
+	indexQuery := fmt.Sprintf(`
+	SELECT i.indrelid AS tableoid,
+		n.oid AS schemaoid,
+		c.oid AS oid,
+		quote_ident(n.nspname) AS schema,
+		quote_ident(c.relname) AS name
+	FROM pg_index i
+		JOIN pg_class c ON i.indexrelid = c.oid
+		JOIN pg_namespace n ON c.relnamespace = n.oid
+	WHERE i.indrelid IN (%s)
+	ORDER BY c.oid`, strings.Join(relationOids, ", "))
+	indexes := make([]relatedRelation, 0)
+	err = connectionPool.Select(&indexes, indexQuery)
+	gplog.FatalOnError(err)
+	for _, index := range indexes {
+		owner := owners[index.TableOid]
+		related[owner] = append(related[owner], index.Relation)
+	}
+	return related
+}
+
+/*
+ * An append-optimized table keeps its segment files, block directory, and
+ * visibility map in auxiliary relations, which are named after the table's
+ * oid, so their statistics are identified by the column of pg_appendonly
+ * that refers to them.
+ */
+type AuxiliaryStatistic struct {
+	Oid       uint32
+	AuxColumn string
+	RelPages  int
+	RelTuples float64
+}
+
+func GetAuxiliaryStatistics(connectionPool *dbconn.DBConn, tables []Table) map[uint32][]AuxiliaryStatistic {
+	stats := make(map[uint32][]AuxiliaryStatistic)
+	if len(tables) == 0 {
+		return stats
+	}
+	tableOids := make([]string, 0)
+	for _, table := range tables {
+		tableOids = append(tableOids, fmt.Sprintf("%d", table.Oid))
+	}
+	auxQueries := make([]string, 0)
+	for _, auxColumn := range []string{"segrelid", "blkdirrelid", "visimaprelid"} {
+		auxQueries = append(auxQueries, fmt.Sprintf(`
+	SELECT a.relid AS oid,
+		'%[1]s' AS auxcolumn,
+		c.relpages,
+		c.reltuples
+	FROM pg_appendonly a
+		JOIN pg_class c ON a.%[1]s = c.oid
+	WHERE a.relid IN (%[2]s)`, auxColumn, strings.Join(tableOids, ", ")))
+	}
+	query := fmt.Sprintf(`%s
+	ORDER BY oid, auxcolumn`, strings.Join(auxQueries, "\n\tUNION ALL"))
+
+	results := make([]AuxiliaryStatistic, 0)
+	err := connectionPool.Select(&results, query)
+	gplog.FatalOnError(err)
+	for _, stat := range results {
+		stats[stat.Oid] = append(stats[stat.Oid], stat)
+	}
+	return stats
+}
+
+/*
+ * The data of extended statistics objects cannot be loaded, as their types
+ * have no input functions, so only the objects themselves are kept, to be
+ * filled by the next ANALYZE after a restore.
+ */
+type ExtendedStatistic struct {
+	Oid        uint32
+	Schema     string
+	Name       string
+	Definition string
+}
+
+func GetExtendedStatistics(connectionPool *dbconn.DBConn, tables []Table) map[uint32][]ExtendedStatistic {
+	stats := make(map[uint32][]ExtendedStatistic)
+	if connectionPool.Version.Before("7") || len(tables) == 0 {
+		return stats
+	}
+	tableOids := make([]string, 0)
+	for _, table := range tables {
+		tableOids = append(tableOids, fmt.Sprintf("%d", table.Oid))
+	}
+	query := fmt.Sprintf(`
+	SELECT s.stxrelid AS oid,
+		quote_ident(n.nspname) AS schema,
+		quote_ident(s.stxname) AS name,
+		pg_get_statisticsobjdef(s.oid) AS definition
+	FROM pg_statistic_ext s
+		JOIN pg_namespace n ON s.stxnamespace = n.oid
+	WHERE s.stxrelid IN (%s)
+	ORDER BY s.oid`, strings.Join(tableOids, ", "))
+
+	results := make([]ExtendedStatistic, 0)
+	err := connectionPool.Select(&results, query)
+	gplog.FatalOnError(err)
+	for _, stat := range results {
+		stats[stat.Oid] = append(stats[stat.Oid], stat)
+	}
+	return stats
+}
@@ -27,10 +27,55 @@ func PrintStatisticsStatements(statisticsFile *utils.FileWithByteCount, tocfile
 	}
 }
 
+/*
+ * The statistics of the relations belonging to a table are restored with the
+ * table's statistics, so their TOC entries are those of the table.
+ */
+func PrintRelatedStatisticsStatements(statisticsFile *utils.FileWithByteCount, tocfile *toc.TOC, tables []Table, relatedRelations map[uint32][]Relation, attStats map[uint32][]AttributeStatistic, tupleStats map[uint32]TupleStatistic) {
+	for _, table := range tables {
+		for _, relation := range relatedRelations[table.Oid] {
+			relationTable := Table{Relation: relation}
+			tupleQuery := GenerateTupleStatisticsQuery(relationTable, tupleStats[relation.Oid])
+			printStatisticsStatementForTable(statisticsFile, tocfile, table, tupleQuery)
+			for _, attStat := range attStats[relation.Oid] {
+				for _, attrQuery := range GenerateAttributeStatisticsQueries(relationTable, attStat) {
+					printStatisticsStatementForTable(statisticsFile, tocfile, table, attrQuery)
+				}
+			}
+		}
+	}
+}
+
+func PrintAuxiliaryStatisticsStatements(statisticsFile *utils.FileWithByteCount, tocfile *toc.TOC, tables []Table, relatedRelations map[uint32][]Relation, auxStats map[uint32][]AuxiliaryStatistic) {
+	for _, table := range tables {
+		relations := append([]Relation{table.Relation}, relatedRelations[table.Oid]...)
+		for _, relation := range relations {
+			for _, auxStat := range auxStats[relation.Oid] {
+				auxQuery := GenerateAuxiliaryStatisticsQuery(Table{Relation: relation}, auxStat)
+				printStatisticsStatementForTable(statisticsFile, tocfile, table, auxQuery)
+			}
+		}
+	}
+}
+
+func PrintExtendedStatisticsStatements(statisticsFile *utils.FileWithByteCount, tocfile *toc.TOC, tables []Table, extStats map[uint32][]ExtendedStatistic) {
+	for _, table := range tables {
+		for _, extStat := range extStats[table.Oid] {
+			for _, extQuery := range GenerateExtendedStatisticsQueries(extStat) {
+				printStatisticsStatement(statisticsFile, tocfile, table, "EXTENDED STATISTICS", extQuery)
+			}
+		}
+	}
+}
+
 func printStatisticsStatementForTable(statisticsFile *utils.FileWithByteCount, tocfile *toc.TOC, table Table, query string){
+	printStatisticsStatement(statisticsFile, tocfile, table, "STATISTICS", query)
+}
+
+func printStatisticsStatement(statisticsFile *utils.FileWithByteCount, tocfile *toc.TOC, table Table, objectType string, query string) {
 	start := statisticsFile.ByteCount
 	statisticsFile.MustPrintf("\n\n%s\n", query)
-	entry := toc.MetadataEntry{Schema: table.Schema, Name: table.Name, ObjectType: "STATISTICS"}
+	entry := toc.MetadataEntry{Schema: table.Schema, Name: table.Name, ObjectType: objectType}
 	tocfile.AddMetadataEntry("statistics", entry, start, statisticsFile.ByteCount)
 }
 
@@ -47,6 +92,28 @@ WHERE oid = '%s'::regclass::oid;`
 		utils.EscapeSingleQuotes(table.FQN()))
 }
 
+func GenerateAuxiliaryStatisticsQuery(table Table, auxStat AuxiliaryStatistic) string {
+	auxQuery := `UPDATE pg_class
+SET
+	relpages = %d::int,
+	reltuples = %f::real
+WHERE oid = (SELECT %s FROM pg_appendonly WHERE relid = '%s'::regclass::oid);`
+	return fmt.Sprintf(
+		auxQuery,
+		auxStat.RelPages,
+		auxStat.RelTuples,
+		auxStat.AuxColumn,
+		utils.EscapeSingleQuotes(table.FQN()))
+}
+
+// An existing statistics object is replaced, as the object cannot be altered to match
+func GenerateExtendedStatisticsQueries(extStat ExtendedStatistic) []string {
+	return []string{
+		fmt.Sprintf("DROP STATISTICS IF EXISTS %s;", utils.MakeFQN(extStat.Schema, extStat.Name)),
+		fmt.Sprintf("%s;", extStat.Definition),
+	}
+}
+
 func GenerateAttributeStatisticsQueries(table Table, attStat AttributeStatistic) []string {
 	/*
 	 * When restoring statistics to a new database, we cannot determine what the
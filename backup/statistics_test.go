@@ -131,6 +131,77 @@ fmt.Sprintf(`INSERT INTO pg_statistic VALUES (
 			testutils.AssertBufferContents(tocfile.StatisticsEntries, buffer, expected...)
 		})
 	})
+	Describe("PrintRelatedStatisticsStatements", func() {
+		It("prints the statistics of the relations belonging to a table under the table's TOC entry", func() {
+			tocfile, backupfile = testutils.InitializeTestTOC(buffer, "statistics")
+			testTable := backup.Table{Relation: backup.Relation{Oid: 123, Schema: "testschema", Name: "testtable"}}
+			relatedRelations := map[uint32][]backup.Relation{
+				123: {{Oid: 456, Schema: "testschema", Name: "testtable_1_prt_1"}, {Oid: 789, Schema: "testschema", Name: "testindex"}},
+			}
+			tupleStats := map[uint32]backup.TupleStatistic{
+				456: {RelPages: 1, RelTuples: 2},
+				789: {RelPages: 3, RelTuples: 2},
+			}
+
+			backup.PrintRelatedStatisticsStatements(backupfile, tocfile, []backup.Table{testTable}, relatedRelations, map[uint32][]backup.AttributeStatistic{}, tupleStats)
+
+			testutils.ExpectEntry(tocfile.StatisticsEntries, 0, "testschema", "", "testtable", "STATISTICS")
+			testutils.ExpectEntry(tocfile.StatisticsEntries, 1, "testschema", "", "testtable", "STATISTICS")
+			testutils.AssertBufferContents(tocfile.StatisticsEntries, buffer, `UPDATE pg_class
+SET
+	relpages = 1::int,
+	reltuples = 2.000000::real
+WHERE oid = 'testschema.testtable_1_prt_1'::regclass::oid;`, `UPDATE pg_class
+SET
+	relpages = 3::int,
+	reltuples = 2.000000::real
+WHERE oid = 'testschema.testindex'::regclass::oid;`)
+		})
+	})
+	Describe("PrintAuxiliaryStatisticsStatements", func() {
+		It("prints the statistics of the auxiliary relations of a table and its partitions", func() {
+			tocfile, backupfile = testutils.InitializeTestTOC(buffer, "statistics")
+			testTable := backup.Table{Relation: backup.Relation{Oid: 123, Schema: "testschema", Name: "testtable"}}
+			relatedRelations := map[uint32][]backup.Relation{
+				123: {{Oid: 456, Schema: "testschema", Name: "testtable_1_prt_1"}},
+			}
+			auxStats := map[uint32][]backup.AuxiliaryStatistic{
+				123: {{Oid: 123, AuxColumn: "segrelid", RelPages: 1, RelTuples: 1}},
+				456: {{Oid: 456, AuxColumn: "visimaprelid", RelPages: 0, RelTuples: 0}},
+			}
+
+			backup.PrintAuxiliaryStatisticsStatements(backupfile, tocfile, []backup.Table{testTable}, relatedRelations, auxStats)
+
+			testutils.ExpectEntry(tocfile.StatisticsEntries, 0, "testschema", "", "testtable", "STATISTICS")
+			testutils.ExpectEntry(tocfile.StatisticsEntries, 1, "testschema", "", "testtable", "STATISTICS")
+			testutils.AssertBufferContents(tocfile.StatisticsEntries, buffer, `UPDATE pg_class
+SET
+	relpages = 1::int,
+	reltuples = 1.000000::real
+WHERE oid = (SELECT segrelid FROM pg_appendonly WHERE relid = 'testschema.testtable'::regclass::oid);`, `UPDATE pg_class
+SET
+	relpages = 0::int,
+	reltuples = 0.000000::real
+WHERE oid = (SELECT visimaprelid FROM pg_appendonly WHERE relid = 'testschema.testtable_1_prt_1'::regclass::oid);`)
+		})
+	})
+	Describe("PrintExtendedStatisticsStatements", func() {
+		It("replaces the extended statistics objects of a table", func() {
+			tocfile, backupfile = testutils.InitializeTestTOC(buffer, "statistics")
+			testTable := backup.Table{Relation: backup.Relation{Oid: 123, Schema: "testschema", Name: "testtable"}}
+			extStats := map[uint32][]backup.ExtendedStatistic{
+				123: {{Oid: 123, Schema: "testschema", Name: "teststats", Definition: "CREATE STATISTICS testschema.teststats (ndistinct) ON i, j FROM testschema.testtable"}},
+			}
+
+			backup.PrintExtendedStatisticsStatements(backupfile, tocfile, []backup.Table{testTable}, extStats)
+
+			testutils.ExpectEntry(tocfile.StatisticsEntries, 0, "testschema", "", "testtable", "EXTENDED STATISTICS")
+			testutils.ExpectEntry(tocfile.StatisticsEntries, 1, "testschema", "", "testtable", "EXTENDED STATISTICS")
+			testutils.AssertBufferContents(tocfile.StatisticsEntries, buffer,
+				`DROP STATISTICS IF EXISTS testschema.teststats;`,
+				`CREATE STATISTICS testschema.teststats (ndistinct) ON i, j FROM testschema.testtable;`)
+		})
+	})
 	Describe("GenerateTupleStatisticsQuery", func() {
 		It("generates tuple statistics query with double quotes and a single quote in the table name and schema name", func() {
 			tableTestTable := backup.Table{Relation: backup.Relation{Schema: `"""test'schema"""`, Name: `"""test'table"""`}}
@@ -144,6 +215,20 @@ WHERE oid = '"""test''schema"""."""test''table"""'::regclass::oid;`))
 		})
 
 	})
+	Describe("GenerateAuxiliaryStatisticsQuery", func() {
+		It("generates auxiliary statistics query with a single quote in the table name", func() {
+			tableTestTable := backup.Table{Relation: backup.Relation{Schema: "testschema", Name: `"test'table"`}}
+			auxStat := backup.AuxiliaryStatistic{AuxColumn: "blkdirrelid", RelPages: 2, RelTuples: 5}
+
+			auxQuery := backup.GenerateAuxiliaryStatisticsQuery(tableTestTable, auxStat)
+
+			Expect(auxQuery).To(Equal(`UPDATE pg_class
+SET
+	relpages = 2::int,
+	reltuples = 5.000000::real
+WHERE oid = (SELECT blkdirrelid FROM pg_appendonly WHERE relid = 'testschema."test''table"'::regclass::oid);`))
+		})
+	})
 	Describe("GenerateAttributeStatisticsQueries", func() {
 		tableTestTable := backup.Table{Relation: backup.Relation{Schema: "testschema", Name: `"test'table"`}}
 
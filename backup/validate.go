@@ -8,6 +8,8 @@ import (
 	"github.com/greenplum-db/gpbackup/filepath"
 	"github.com/greenplum-db/gpbackup/history"
 	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/storage"
+	"github.com/greenplum-db/gpbackup/toc"
 	"github.com/greenplum-db/gpbackup/utils"
 	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
@@ -94,19 +96,37 @@ func ValidateTablesExist(conn *dbconn.DBConn, tableList []string, excludeSet boo
 func validateFlagCombinations(flags *pflag.FlagSet) {
 	options.CheckExclusiveFlags(flags, options.DEBUG, options.QUIET, options.VERBOSE)
 	options.CheckExclusiveFlags(flags, options.DATA_ONLY, options.METADATA_ONLY, options.INCREMENTAL)
-	options.CheckExclusiveFlags(flags, options.INCLUDE_SCHEMA, options.INCLUDE_SCHEMA_FILE, options.INCLUDE_RELATION, options.INCLUDE_RELATION_FILE)
-	options.CheckExclusiveFlags(flags, options.EXCLUDE_SCHEMA, options.EXCLUDE_SCHEMA_FILE, options.INCLUDE_SCHEMA, options.INCLUDE_SCHEMA_FILE)
-	options.CheckExclusiveFlags(flags, options.EXCLUDE_SCHEMA, options.EXCLUDE_SCHEMA_FILE, options.EXCLUDE_RELATION, options.INCLUDE_RELATION, options.EXCLUDE_RELATION_FILE, options.INCLUDE_RELATION_FILE)
+	options.CheckExclusiveFlags(flags, options.INCLUDE_SCHEMA, options.INCLUDE_SCHEMA_FILE, options.INCLUDE_SCHEMA_PATTERN, options.INCLUDE_RELATION, options.INCLUDE_RELATION_FILE, options.INCLUDE_RELATION_PATTERN)
+	options.CheckExclusiveFlags(flags, options.EXCLUDE_SCHEMA, options.EXCLUDE_SCHEMA_FILE, options.EXCLUDE_SCHEMA_PATTERN, options.INCLUDE_SCHEMA, options.INCLUDE_SCHEMA_FILE, options.INCLUDE_SCHEMA_PATTERN)
+	options.CheckExclusiveFlags(flags, options.EXCLUDE_SCHEMA, options.EXCLUDE_SCHEMA_FILE, options.EXCLUDE_SCHEMA_PATTERN, options.EXCLUDE_RELATION, options.INCLUDE_RELATION, options.EXCLUDE_RELATION_FILE, options.INCLUDE_RELATION_FILE,
+		options.EXCLUDE_RELATION_PATTERN, options.INCLUDE_RELATION_PATTERN)
 	options.CheckExclusiveFlags(flags, options.JOBS, options.METADATA_ONLY, options.SINGLE_DATA_FILE)
 	options.CheckExclusiveFlags(flags, options.METADATA_ONLY, options.LEAF_PARTITION_DATA)
+	options.CheckExclusiveFlags(flags, options.METADATA_ONLY, options.WITH_FINGERPRINTS)
 	options.CheckExclusiveFlags(flags, options.NO_COMPRESSION, options.COMPRESSION_LEVEL)
 	options.CheckExclusiveFlags(flags, options.PLUGIN_CONFIG, options.BACKUP_DIR)
+	options.CheckExclusiveFlags(flags, options.INCLUDE_OBJECT_TYPE, options.EXCLUDE_OBJECT_TYPE, options.DATA_ONLY, options.INCREMENTAL)
 	if MustGetFlagString(options.FROM_TIMESTAMP) != "" && !MustGetFlagBool(options.INCREMENTAL) {
 		gplog.Fatal(errors.Errorf("--from-timestamp must be specified with --incremental"), "")
 	}
 	if MustGetFlagBool(options.INCREMENTAL) && !MustGetFlagBool(options.LEAF_PARTITION_DATA) {
 		gplog.Fatal(errors.Errorf("--leaf-partition-data must be specified with --incremental"), "")
 	}
+	if flags.Changed(options.DATA_STREAMS) && !MustGetFlagBool(options.SINGLE_DATA_FILE) {
+		gplog.Fatal(errors.Errorf("--single-data-file must be specified with --data-streams"), "")
+	}
+	if flags.Changed(options.LOCK_WAIT_STRATEGY) && MustGetFlagInt(options.LOCK_WAIT_TIMEOUT) == 0 {
+		gplog.Fatal(errors.Errorf("--lock-wait-timeout must be specified with --lock-wait-strategy"), "")
+	}
+	for _, flagName := range []string{options.DATA_ONLY, options.METADATA_ONLY, options.INCREMENTAL, options.WITHOUT_GLOBALS,
+		options.INCLUDE_SCHEMA, options.INCLUDE_SCHEMA_FILE, options.EXCLUDE_SCHEMA, options.EXCLUDE_SCHEMA_FILE,
+		options.INCLUDE_RELATION, options.INCLUDE_RELATION_FILE, options.EXCLUDE_RELATION, options.EXCLUDE_RELATION_FILE,
+		options.INCLUDE_SCHEMA_PATTERN, options.EXCLUDE_SCHEMA_PATTERN, options.INCLUDE_RELATION_PATTERN, options.EXCLUDE_RELATION_PATTERN,
+		options.INCLUDE_OBJECT_TYPE, options.EXCLUDE_OBJECT_TYPE, options.LEAF_PARTITION_DATA, options.SINGLE_DATA_FILE, options.WITH_STATS, options.WITH_FINGERPRINTS} {
+		options.CheckExclusiveFlags(flags, options.GLOBALS_ONLY, flagName)
+	}
+	options.CheckExclusiveFlags(flags, options.WITHOUT_GLOBALS, options.INCLUDE_ROLE_PATTERN)
+	options.CheckExclusiveFlags(flags, options.WITHOUT_GLOBALS, options.WITHOUT_RES_GROUPS)
 }
 
 func validateFlagValues() {
@@ -116,10 +136,36 @@ func validateFlagValues() {
 	gplog.FatalOnError(err)
 	err = utils.ValidateCompressionLevel(MustGetFlagInt(options.COMPRESSION_LEVEL))
 	gplog.FatalOnError(err)
+	rateLimit := utils.RateLimit{MaxRate: MustGetFlagInt(options.MAX_RATE), MaxSegmentRate: MustGetFlagInt(options.MAX_SEGMENT_RATE)}
+	gplog.FatalOnError(rateLimit.Validate())
+	if MustGetFlagInt(options.DATA_STREAMS) < 1 {
+		gplog.Fatal(errors.Errorf("--data-streams must be at least 1"), "")
+	}
+	if MustGetFlagInt(options.LOCK_WAIT_TIMEOUT) < 0 {
+		gplog.Fatal(errors.Errorf("--lock-wait-timeout cannot be negative"), "")
+	}
+	err = ValidateLockWaitStrategy(MustGetFlagString(options.LOCK_WAIT_STRATEGY))
+	gplog.FatalOnError(err)
+	_, err = GetRolePattern(MustGetFlagString(options.INCLUDE_ROLE_PATTERN))
+	gplog.FatalOnError(err)
 	if MustGetFlagString(options.FROM_TIMESTAMP) != "" && !filepath.IsValidTimestamp(MustGetFlagString(options.FROM_TIMESTAMP)) {
 		gplog.Fatal(errors.Errorf("Timestamp %s is invalid.  Timestamps must be in the format YYYYMMDDHHMMSS.",
 			MustGetFlagString(options.FROM_TIMESTAMP)), "")
 	}
+	validObjectTypes := getBackupObjectTypes()
+	gplog.FatalOnError(toc.ValidateObjectTypes(MustGetFlagStringArray(options.INCLUDE_OBJECT_TYPE), validObjectTypes))
+	gplog.FatalOnError(toc.ValidateObjectTypes(MustGetFlagStringArray(options.EXCLUDE_OBJECT_TYPE), validObjectTypes))
+}
+
+// Table definitions are always backed up with their data, so tables cannot be filtered by object type
+func getBackupObjectTypes() []string {
+	objectTypes := make([]string, 0)
+	for _, objectType := range append(append([]string{}, toc.PredataObjectTypes...), toc.PostdataObjectTypes...) {
+		if objectType != "TABLE" && objectType != "FOREIGN TABLE" {
+			objectTypes = append(objectTypes, objectType)
+		}
+	}
+	return objectTypes
 }
 
 func validateFromTimestamp(fromTimestamp string) {
@@ -127,7 +173,7 @@ func validateFromTimestamp(fromTimestamp string) {
 		fromTimestamp, globalFPInfo.UserSpecifiedSegPrefix)
 	if MustGetFlagString(options.PLUGIN_CONFIG) != "" {
 		// The config file needs to be downloaded from the remote system into the local filesystem
-		pluginConfig.MustRestoreFile(fromTimestampFPInfo.GetConfigFilePath())
+		storage.MustGetFile(storageBackend, fromTimestampFPInfo.GetConfigFilePath())
 	}
 	fromBackupConfig := history.ReadConfigFile(fromTimestampFPInfo.GetConfigFilePath())
 
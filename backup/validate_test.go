@@ -269,6 +269,54 @@ var _ = Describe("backup/validate tests", func() {
 			Entry("jobs combos", "--jobs 2 --single-data-file", false),
 			Entry("jobs combos", "--jobs 2 --plugin-config /tmp/file", true),
 			Entry("jobs combos", "--jobs 2 --data-only", true),
+
+			/*
+			 * Below are various different with-fingerprints combinations
+			 */
+			Entry("--with-fingerprints combos", "--with-fingerprints", true),
+			Entry("--with-fingerprints combos", "--with-fingerprints --leaf-partition-data", true),
+			Entry("--with-fingerprints combos", "--with-fingerprints --metadata-only", false),
+
+			/*
+			 * Below are various different lock wait combinations
+			 */
+			Entry("--data-streams combos", "--single-data-file --data-streams 4", true),
+			Entry("--data-streams combos", "--data-streams 4", false),
+			Entry("--data-streams combos", "--single-data-file --data-streams 0", false),
+			Entry("--lock-wait-timeout combos", "--lock-wait-timeout 30", true),
+			Entry("--lock-wait-timeout combos", "--lock-wait-timeout 30 --lock-wait-strategy skip", true),
+			Entry("--lock-wait-timeout combos", "--lock-wait-timeout 30 --lock-wait-strategy wait", false),
+			Entry("--lock-wait-timeout combos", "--lock-wait-timeout -1", false),
+			Entry("--lock-wait-timeout combos", "--lock-wait-strategy retry", false),
+
+			/*
+			 * Below are various different globals-only combinations
+			 */
+			Entry("--globals-only combos", "--globals-only", true),
+			Entry("--globals-only combos", "--globals-only --include-role-pattern app_.* --without-resource-groups", true),
+			Entry("--globals-only combos", "--globals-only --metadata-only", false),
+			Entry("--globals-only combos", "--globals-only --data-only", false),
+			Entry("--globals-only combos", "--globals-only --without-globals", false),
+			Entry("--globals-only combos", "--globals-only --include-schema schema1", false),
+			Entry("--globals-only combos", "--globals-only --exclude-table schema1.table1", false),
+			Entry("--globals-only combos", "--globals-only --with-stats", false),
+			Entry("--globals-only combos", "--include-role-pattern app_.*", true),
+			Entry("--globals-only combos", "--include-role-pattern app_(", false),
+			Entry("--globals-only combos", "--include-role-pattern app_.* --without-globals", false),
+			Entry("--globals-only combos", "--without-resource-groups --without-globals", false),
+
+			/*
+			 * Below are various different object type combinations
+			 */
+			Entry("--include-object-type combos", "--include-object-type VIEW --include-object-type FUNCTION", true),
+			Entry("--include-object-type combos", "--include-object-type VIEW --metadata-only", true),
+			Entry("--include-object-type combos", "--include-object-type VIEW --exclude-object-type FUNCTION", false),
+			Entry("--include-object-type combos", "--include-object-type VIEW --data-only", false),
+			Entry("--include-object-type combos", "--include-object-type VIEW --incremental --leaf-partition-data", false),
+			Entry("--include-object-type combos", "--include-object-type VIEW --globals-only", false),
+			Entry("--include-object-type combos", "--include-object-type TABLE", false),
+			Entry("--exclude-object-type combos", "--exclude-object-type TRIGGER --exclude-object-type RULE", true),
+			Entry("--exclude-object-type combos", "--exclude-object-type TRIGGERS", false),
 		)
 	})
 })
@@ -2,8 +2,8 @@ package backup
 
 import (
 	"fmt"
-	"path"
 	"reflect"
+	"regexp"
 
 	"github.com/greenplum-db/gp-common-go-libs/cluster"
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
@@ -39,7 +39,12 @@ func SetLoggerVerbosity() {
 
 func initializeConnectionPool(timestamp string) {
 	connectionPool = dbconn.NewDBConnFromEnvironment(MustGetFlagString(options.DBNAME))
-	connectionPool.MustConnect(MustGetFlagInt(options.JOBS))
+	// Each data stream of a single data file backup is written by its own connection
+	numConns := MustGetFlagInt(options.JOBS)
+	if MustGetFlagBool(options.SINGLE_DATA_FILE) {
+		numConns = MustGetFlagInt(options.DATA_STREAMS)
+	}
+	connectionPool.MustConnect(numConns)
 	utils.ValidateGPDBVersionCompatibility(connectionPool)
 	InitializeMetadataParams(connectionPool)
 	for connNum := 0; connNum < connectionPool.NumConns; connNum++ {
@@ -73,6 +78,15 @@ func SetSessionGUCs(connNum int) {
 		connectionPool.MustExec("SET INTERVALSTYLE = POSTGRES", connNum)
 		connectionPool.MustExec("SET lock_timeout = 0", connNum)
 	}
+
+	if MustGetFlagBool(options.WITH_FINGERPRINTS) {
+		utils.SetFingerprintSessionGUCs(connectionPool, connNum)
+	}
+
+	// Settings from --session-guc-file are applied last so that they override the ones above
+	for _, guc := range sessionGUCs {
+		connectionPool.MustExec(guc.SetStatement(), connNum)
+	}
 }
 
 func NewBackupConfig(dbName string, dbVersion string, backupVersion string, plugin string, timestamp string, opts options.Options) *history.BackupConfig {
@@ -87,20 +101,25 @@ func NewBackupConfig(dbName string, dbVersion string, backupVersion string, plug
 		ExcludeSchemaFiltered: len(MustGetFlagStringArray(options.EXCLUDE_SCHEMA)) > 0,
 		ExcludeSchemas:        MustGetFlagStringArray(options.EXCLUDE_SCHEMA),
 		ExcludeTableFiltered:  len(MustGetFlagStringArray(options.EXCLUDE_RELATION)) > 0,
+		GlobalsOnly:           MustGetFlagBool(options.GLOBALS_ONLY),
 		IncludeRelations:      opts.GetOriginalIncludedTables(),
 		IncludeSchemaFiltered: len(MustGetFlagStringArray(options.INCLUDE_SCHEMA)) > 0,
 		IncludeSchemas:        MustGetFlagStringArray(options.INCLUDE_SCHEMA),
 		IncludeTableFiltered:  len(opts.GetOriginalIncludedTables()) > 0,
 		Incremental:           MustGetFlagBool(options.INCREMENTAL),
 		LeafPartitionData:     MustGetFlagBool(options.LEAF_PARTITION_DATA),
-		MetadataOnly:          MustGetFlagBool(options.METADATA_ONLY),
+		MetadataOnly:          MustGetFlagBool(options.METADATA_ONLY) || MustGetFlagBool(options.GLOBALS_ONLY),
 		Plugin:                plugin,
+		SessionGUCs:           sessionGUCs,
 		SingleDataFile:        MustGetFlagBool(options.SINGLE_DATA_FILE),
 		Timestamp:             timestamp,
 		WithoutGlobals:        MustGetFlagBool(options.WITHOUT_GLOBALS),
 		WithStatistics:        MustGetFlagBool(options.WITH_STATS),
 		Status:                history.BackupStatusFailed,
 	}
+	if backupConfig.SingleDataFile {
+		backupConfig.DataStreams = MustGetFlagInt(options.DATA_STREAMS)
+	}
 
 	return &backupConfig
 }
@@ -109,7 +128,7 @@ func initializeBackupReport(opts options.Options) {
 	escapedDBName := dbconn.MustSelectString(connectionPool, fmt.Sprintf("select quote_ident(datname) AS string FROM pg_database where datname='%s'", utils.EscapeSingleQuotes(connectionPool.DBName)))
 	plugin := ""
 	if pluginConfig != nil {
-		_, plugin = path.Split(pluginConfig.ExecutablePath)
+		plugin = pluginConfig.Name()
 	}
 	config := NewBackupConfig(escapedDBName, connectionPool.Version.VersionString, version,
 		plugin, globalFPInfo.Timestamp, opts)
@@ -117,19 +136,35 @@ func initializeBackupReport(opts options.Options) {
 	isFilteredBackup := config.IncludeTableFiltered || config.IncludeSchemaFiltered ||
 		config.ExcludeTableFiltered || config.ExcludeSchemaFiltered
 	dbSize := ""
-	if !MustGetFlagBool(options.METADATA_ONLY) && !isFilteredBackup {
+	if !config.MetadataOnly && !isFilteredBackup {
 		gplog.Verbose("Getting database size")
 		//Potentially expensive query
 		dbSize = GetDBSize(connectionPool)
 	}
 
 	backupReport = &report.Report{
-		DatabaseSize: dbSize,
-		BackupConfig: *config,
+		DatabaseSize:    dbSize,
+		BackupConfig:    *config,
+		EffectiveConfig: options.EffectiveConfig(cmdFlags),
 	}
 	backupReport.ConstructBackupParamsString()
 }
 
+/*
+ * Schema and table patterns are expanded against the catalog into the
+ * schema and table filters, so the backup records the names that matched.
+ */
+func expandFilterPatterns(opts *options.Options) {
+	if opts.HasSchemaPatterns() {
+		err := opts.ExpandSchemaPatterns(cmdFlags, GetSchemaNamesForFilterPatterns(connectionPool), false)
+		gplog.FatalOnError(err)
+	}
+	if opts.HasRelationPatterns() {
+		err := opts.ExpandRelationPatterns(cmdFlags, GetTableNamesForFilterPatterns(connectionPool), false)
+		gplog.FatalOnError(err)
+	}
+}
+
 func createBackupLockFile(timestamp string) {
 	var err error
 	timestampLockFile := fmt.Sprintf("/tmp/%s.lck", timestamp)
@@ -162,7 +197,7 @@ func RetrieveAndProcessTables() ([]Table, []Table) {
 	gplog.FatalOnError(err)
 
 	tableRelations := GetIncludedUserTableRelations(connectionPool, quotedIncludeRelations)
-	LockTables(connectionPool, tableRelations)
+	tableRelations = LockTables(connectionPool, tableRelations)
 
 	if connectionPool.Version.AtLeast("6") {
 		tableRelations = append(tableRelations, GetForeignTableRelations(connectionPool)...)
@@ -182,38 +217,45 @@ func retrieveFunctions(sortables *[]Sortable, metadataMap MetadataMap) ([]Functi
 	addToMetadataMap(functionMetadata, metadataMap)
 	functions := GetFunctionsAllVersions(connectionPool)
 	funcInfoMap := GetFunctionOidToInfoMap(connectionPool)
-	objectCounts["Functions"] = len(functions)
-	*sortables = append(*sortables, convertToSortableSlice(functions)...)
+	// Functions are still needed to back up the objects that use them
+	if shouldBackupObjectType("FUNCTION") {
+		objectCounts["Functions"] = len(functions)
+		*sortables = append(*sortables, convertToSortableSlice(functions)...)
+	}
 
 	return functions, funcInfoMap
 }
 
 func retrieveAndBackupTypes(metadataFile *utils.FileWithByteCount, sortables *[]Sortable, metadataMap MetadataMap) {
 	gplog.Verbose("Retrieving type information")
-	shells := GetShellTypes(connectionPool)
-	bases := GetBaseTypes(connectionPool)
-	composites := GetCompositeTypes(connectionPool)
-	domains := GetDomainTypes(connectionPool)
-	rangeTypes := make([]RangeType, 0)
-	if connectionPool.Version.AtLeast("6") {
-		rangeTypes = GetRangeTypes(connectionPool)
-	}
 	typeMetadata := GetMetadataForObjectType(connectionPool, TYPE_TYPE)
-
-	backupShellTypes(metadataFile, shells, bases, rangeTypes)
-	if connectionPool.Version.AtLeast("5") {
-		backupEnumTypes(metadataFile, typeMetadata)
-	}
-
-	objectCounts["Types"] += len(shells)
-	objectCounts["Types"] += len(bases)
-	objectCounts["Types"] += len(composites)
-	objectCounts["Types"] += len(domains)
-	objectCounts["Types"] += len(rangeTypes)
-	*sortables = append(*sortables, convertToSortableSlice(bases)...)
-	*sortables = append(*sortables, convertToSortableSlice(composites)...)
-	*sortables = append(*sortables, convertToSortableSlice(domains)...)
-	*sortables = append(*sortables, convertToSortableSlice(rangeTypes)...)
+	if shouldBackupObjectType("TYPE") {
+		shells := GetShellTypes(connectionPool)
+		bases := GetBaseTypes(connectionPool)
+		composites := GetCompositeTypes(connectionPool)
+		rangeTypes := make([]RangeType, 0)
+		if connectionPool.Version.AtLeast("6") {
+			rangeTypes = GetRangeTypes(connectionPool)
+		}
+
+		backupShellTypes(metadataFile, shells, bases, rangeTypes)
+		if connectionPool.Version.AtLeast("5") {
+			backupEnumTypes(metadataFile, typeMetadata)
+		}
+
+		objectCounts["Types"] += len(shells)
+		objectCounts["Types"] += len(bases)
+		objectCounts["Types"] += len(composites)
+		objectCounts["Types"] += len(rangeTypes)
+		*sortables = append(*sortables, convertToSortableSlice(bases)...)
+		*sortables = append(*sortables, convertToSortableSlice(composites)...)
+		*sortables = append(*sortables, convertToSortableSlice(rangeTypes)...)
+	}
+	if shouldBackupObjectType("DOMAIN") {
+		domains := GetDomainTypes(connectionPool)
+		objectCounts["Types"] += len(domains)
+		*sortables = append(*sortables, convertToSortableSlice(domains)...)
+	}
 	addToMetadataMap(typeMetadata, metadataMap)
 }
 
@@ -226,6 +268,9 @@ func retrieveConstraints(tables ...Relation) ([]Constraint, MetadataMap) {
 
 func retrieveAndBackupSequences(metadataFile *utils.FileWithByteCount,
 	relationMetadata MetadataMap) []Sequence {
+	if !shouldBackupObjectType("SEQUENCE") {
+		return []Sequence{}
+	}
 	gplog.Verbose("Writing CREATE SEQUENCE statements to metadata file")
 	sequences := GetAllSequences(connectionPool)
 	objectCounts["Sequences"] = len(sequences)
@@ -234,6 +279,9 @@ func retrieveAndBackupSequences(metadataFile *utils.FileWithByteCount,
 }
 
 func retrieveProtocols(sortables *[]Sortable, metadataMap MetadataMap) []ExternalProtocol {
+	if !shouldBackupObjectType("PROTOCOL") {
+		return []ExternalProtocol{}
+	}
 	gplog.Verbose("Retrieving protocols")
 	protocols := GetExternalProtocols(connectionPool)
 	objectCounts["Protocols"] = len(protocols)
@@ -247,7 +295,12 @@ func retrieveProtocols(sortables *[]Sortable, metadataMap MetadataMap) []Externa
 
 func retrieveViews(sortables *[]Sortable) {
 	gplog.Verbose("Retrieving views")
-	views := GetAllViews(connectionPool)
+	views := make([]View, 0)
+	for _, view := range GetAllViews(connectionPool) {
+		if shouldBackupObjectType(view.ObjectType()) {
+			views = append(views, view)
+		}
+	}
 	objectCounts["Views"] = len(views)
 
 	*sortables = append(*sortables, convertToSortableSlice(views)...)
@@ -265,6 +318,9 @@ func retrieveTSObjects(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveTSParsers(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("TEXT SEARCH PARSER") {
+		return
+	}
 	gplog.Verbose("Retrieving Text Search Parsers")
 	parsers := GetTextSearchParsers(connectionPool)
 	objectCounts["Text Search Parsers"] = len(parsers)
@@ -275,6 +331,9 @@ func retrieveTSParsers(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveTSTemplates(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("TEXT SEARCH TEMPLATE") {
+		return
+	}
 	gplog.Verbose("Retrieving TEXT SEARCH TEMPLATE information")
 	templates := GetTextSearchTemplates(connectionPool)
 	objectCounts["Text Search Templates"] = len(templates)
@@ -285,6 +344,9 @@ func retrieveTSTemplates(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveTSDictionaries(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("TEXT SEARCH DICTIONARY") {
+		return
+	}
 	gplog.Verbose("Retrieving TEXT SEARCH DICTIONARY information")
 	dictionaries := GetTextSearchDictionaries(connectionPool)
 	objectCounts["Text Search Dictionaries"] = len(dictionaries)
@@ -295,6 +357,9 @@ func retrieveTSDictionaries(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveTSConfigurations(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("TEXT SEARCH CONFIGURATION") {
+		return
+	}
 	gplog.Verbose("Retrieving TEXT SEARCH CONFIGURATION information")
 	configurations := GetTextSearchConfigurations(connectionPool)
 	objectCounts["Text Search Configurations"] = len(configurations)
@@ -310,6 +375,9 @@ func retrieveOperatorObjects(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveOperators(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("OPERATOR") {
+		return
+	}
 	gplog.Verbose("Retrieving OPERATOR information")
 	operators := GetOperators(connectionPool)
 	objectCounts["Operators"] = len(operators)
@@ -320,6 +388,9 @@ func retrieveOperators(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveOperatorClasses(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("OPERATOR CLASS") {
+		return
+	}
 	gplog.Verbose("Retrieving OPERATOR CLASS information")
 	operatorClasses := GetOperatorClasses(connectionPool)
 	objectCounts["Operator Classes"] = len(operatorClasses)
@@ -330,6 +401,9 @@ func retrieveOperatorClasses(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveAggregates(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("AGGREGATE") {
+		return
+	}
 	gplog.Verbose("Retrieving AGGREGATE information")
 	aggregates := GetAggregates(connectionPool)
 	objectCounts["Aggregates"] = len(aggregates)
@@ -340,6 +414,9 @@ func retrieveAggregates(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveCasts(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("CAST") {
+		return
+	}
 	gplog.Verbose("Retrieving CAST information")
 	casts := GetCasts(connectionPool)
 	objectCounts["Casts"] = len(casts)
@@ -359,6 +436,9 @@ func retrieveFDWObjects(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveForeignDataWrappers(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("FOREIGN DATA WRAPPER") {
+		return
+	}
 	gplog.Verbose("Writing CREATE FOREIGN DATA WRAPPER statements to metadata file")
 	wrappers := GetForeignDataWrappers(connectionPool)
 	objectCounts["Foreign Data Wrappers"] = len(wrappers)
@@ -369,6 +449,9 @@ func retrieveForeignDataWrappers(sortables *[]Sortable, metadataMap MetadataMap)
 }
 
 func retrieveForeignServers(sortables *[]Sortable, metadataMap MetadataMap) {
+	if !shouldBackupObjectType("FOREIGN SERVER") {
+		return
+	}
 	gplog.Verbose("Writing CREATE SERVER statements to metadata file")
 	servers := GetForeignServers(connectionPool)
 	objectCounts["Foreign Servers"] = len(servers)
@@ -379,6 +462,9 @@ func retrieveForeignServers(sortables *[]Sortable, metadataMap MetadataMap) {
 }
 
 func retrieveUserMappings(sortables *[]Sortable) {
+	if !shouldBackupObjectType("USER MAPPING") {
+		return
+	}
 	gplog.Verbose("Writing CREATE USER MAPPING statements to metadata file")
 	mappings := GetUserMappings(connectionPool)
 	objectCounts["User Mappings"] = len(mappings)
@@ -429,7 +515,7 @@ func backupResourceQueues(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupResourceGroups(metadataFile *utils.FileWithByteCount) {
-	if !connectionPool.Version.AtLeast("5") {
+	if !connectionPool.Version.AtLeast("5") || MustGetFlagBool(options.WITHOUT_RES_GROUPS) {
 		return
 	}
 	gplog.Verbose("Writing CREATE RESOURCE GROUP statements to metadata file")
@@ -442,7 +528,7 @@ func backupResourceGroups(metadataFile *utils.FileWithByteCount) {
 
 func backupRoles(metadataFile *utils.FileWithByteCount) {
 	gplog.Verbose("Writing CREATE ROLE statements to metadata file")
-	roles := GetRoles(connectionPool)
+	roles := FilterRoles(GetRoles(connectionPool), mustGetRolePattern())
 	objectCounts["Roles"] = len(roles)
 	roleMetadata := GetMetadataForObjectType(connectionPool, TYPE_ROLE)
 	PrintCreateRoleStatements(metadataFile, globalTOC, roles, roleMetadata)
@@ -450,21 +536,43 @@ func backupRoles(metadataFile *utils.FileWithByteCount) {
 
 func backupRoleGUCs(metadataFile *utils.FileWithByteCount) {
 	gplog.Verbose("Writing ROLE Configuration Parameter to meadata file")
-	roleGUCs := GetRoleGUCs(connectionPool)
+	roleGUCs := FilterRoleGUCs(GetRoleGUCs(connectionPool), mustGetRolePattern())
 	PrintRoleGUCStatements(metadataFile, globalTOC, roleGUCs)
 }
 
 func backupRoleGrants(metadataFile *utils.FileWithByteCount) {
 	gplog.Verbose("Writing GRANT ROLE statements to metadata file")
-	roleMembers := GetRoleMembers(connectionPool)
+	roleMembers := FilterRoleMembers(GetRoleMembers(connectionPool), mustGetRolePattern())
 	PrintRoleMembershipStatements(metadataFile, globalTOC, roleMembers)
 }
 
+func mustGetRolePattern() *regexp.Regexp {
+	rolePattern, err := GetRolePattern(MustGetFlagString(options.INCLUDE_ROLE_PATTERN))
+	gplog.FatalOnError(err)
+	return rolePattern
+}
+
+/*
+ * Whether metadata of the given pre-data or post-data object type is backed
+ * up, given --include-object-type and --exclude-object-type.  Table definitions
+ * are always backed up with their data, so tables are not filtered this way.
+ */
+func shouldBackupObjectType(objectType string) bool {
+	includeObjectTypes := MustGetFlagStringArray(options.INCLUDE_OBJECT_TYPE)
+	if len(includeObjectTypes) > 0 {
+		return utils.Exists(includeObjectTypes, objectType)
+	}
+	return !utils.Exists(MustGetFlagStringArray(options.EXCLUDE_OBJECT_TYPE), objectType)
+}
+
 /*
  * Predata wrapper functions
  */
 
 func backupSchemas(metadataFile *utils.FileWithByteCount, partitionAlteredSchemas map[string]bool) {
+	if !shouldBackupObjectType("SCHEMA") {
+		return
+	}
 	gplog.Verbose("Writing CREATE SCHEMA statements to metadata file")
 	schemas := GetAllUserSchemas(connectionPool, partitionAlteredSchemas)
 	objectCounts["Schemas"] = len(schemas)
@@ -474,6 +582,9 @@ func backupSchemas(metadataFile *utils.FileWithByteCount, partitionAlteredSchema
 
 func backupProceduralLanguages(metadataFile *utils.FileWithByteCount,
 	functions []Function, funcInfoMap map[uint32]FunctionInfo, functionMetadata MetadataMap) {
+	if !shouldBackupObjectType("LANGUAGE") {
+		return
+	}
 	gplog.Verbose("Writing CREATE PROCEDURAL LANGUAGE statements to metadata file")
 	procLangs := GetProceduralLanguages(connectionPool)
 	objectCounts["Procedural Languages"] = len(procLangs)
@@ -545,7 +656,12 @@ func backupDependentObjects(metadataFile *utils.FileWithByteCount, tables []Tabl
 	sortedSlice := TopologicalSort(sortables, relevantDeps)
 
 	PrintDependentObjectStatements(metadataFile, globalTOC, sortedSlice, filteredMetadata, constraints, funcInfoMap)
-	PrintAlterSequenceStatements(metadataFile, globalTOC, sequences)
+	if shouldBackupObjectType("SEQUENCE OWNER") {
+		PrintAlterSequenceStatements(metadataFile, globalTOC, sequences)
+	}
+	if !shouldBackupObjectType("EXCHANGE PARTITION") {
+		return
+	}
 	extPartInfo, partInfoMap := GetExternalPartitionInfo(connectionPool)
 	if len(extPartInfo) > 0 {
 		gplog.Verbose("Writing EXCHANGE PARTITION statements to metadata file")
@@ -554,6 +670,9 @@ func backupDependentObjects(metadataFile *utils.FileWithByteCount, tables []Tabl
 }
 
 func backupConversions(metadataFile *utils.FileWithByteCount) {
+	if !shouldBackupObjectType("CONVERSION") {
+		return
+	}
 	gplog.Verbose("Writing CREATE CONVERSION statements to metadata file")
 	conversions := GetConversions(connectionPool)
 	objectCounts["Conversions"] = len(conversions)
@@ -562,7 +681,7 @@ func backupConversions(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupOperatorFamilies(metadataFile *utils.FileWithByteCount) {
-	if !connectionPool.Version.AtLeast("5") {
+	if !connectionPool.Version.AtLeast("5") || !shouldBackupObjectType("OPERATOR FAMILY") {
 		return
 	}
 	gplog.Verbose("Writing CREATE OPERATOR FAMILY statements to metadata file")
@@ -573,7 +692,7 @@ func backupOperatorFamilies(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupCollations(metadataFile *utils.FileWithByteCount) {
-	if !connectionPool.Version.AtLeast("6") {
+	if !connectionPool.Version.AtLeast("6") || !shouldBackupObjectType("COLLATION") {
 		return
 	}
 	gplog.Verbose("Writing CREATE COLLATION statements to metadata file")
@@ -585,7 +704,7 @@ func backupCollations(metadataFile *utils.FileWithByteCount) {
 
 func backupExtensions(metadataFile *utils.FileWithByteCount) {
 	if !(len(MustGetFlagStringArray(options.INCLUDE_SCHEMA)) == 0 &&
-		connectionPool.Version.AtLeast("5")) {
+		connectionPool.Version.AtLeast("5")) || !shouldBackupObjectType("EXTENSION") {
 		return
 	}
 	gplog.Verbose("Writing CREATE EXTENSION statements to metadata file")
@@ -596,6 +715,9 @@ func backupExtensions(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupConstraints(metadataFile *utils.FileWithByteCount, constraints []Constraint, conMetadata MetadataMap) {
+	if !shouldBackupObjectType("CONSTRAINT") {
+		return
+	}
 	gplog.Verbose("Writing ADD CONSTRAINT statements to metadata file")
 	objectCounts["Constraints"] = len(constraints)
 	PrintConstraintStatements(metadataFile, globalTOC, constraints, conMetadata)
@@ -606,6 +728,9 @@ func backupConstraints(metadataFile *utils.FileWithByteCount, constraints []Cons
  */
 
 func backupIndexes(metadataFile *utils.FileWithByteCount) {
+	if !shouldBackupObjectType("INDEX") {
+		return
+	}
 	gplog.Verbose("Writing CREATE INDEX statements to metadata file")
 	indexes := GetIndexes(connectionPool)
 	objectCounts["Indexes"] = len(indexes)
@@ -614,6 +739,9 @@ func backupIndexes(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupRules(metadataFile *utils.FileWithByteCount) {
+	if !shouldBackupObjectType("RULE") {
+		return
+	}
 	gplog.Verbose("Writing CREATE RULE statements to metadata file")
 	rules := GetRules(connectionPool)
 	objectCounts["Rules"] = len(rules)
@@ -622,6 +750,9 @@ func backupRules(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupTriggers(metadataFile *utils.FileWithByteCount) {
+	if !shouldBackupObjectType("TRIGGER") {
+		return
+	}
 	gplog.Verbose("Writing CREATE TRIGGER statements to metadata file")
 	triggers := GetTriggers(connectionPool)
 	objectCounts["Triggers"] = len(triggers)
@@ -630,6 +761,9 @@ func backupTriggers(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupEventTriggers(metadataFile *utils.FileWithByteCount) {
+	if !shouldBackupObjectType("EVENT TRIGGER") {
+		return
+	}
 	gplog.Verbose("Writing CREATE EVENT TRIGGER statements to metadata file")
 	eventTriggers := GetEventTriggers(connectionPool)
 	objectCounts["Event Triggers"] = len(eventTriggers)
@@ -638,6 +772,9 @@ func backupEventTriggers(metadataFile *utils.FileWithByteCount) {
 }
 
 func backupDefaultPrivileges(metadataFile *utils.FileWithByteCount) {
+	if !shouldBackupObjectType("DEFAULT PRIVILEGES") {
+		return
+	}
 	gplog.Verbose("Writing ALTER DEFAULT PRIVILEGES statements to metadata file")
 	defaultPrivileges := GetDefaultPrivileges(connectionPool)
 	objectCounts["DEFAULT PRIVILEGES"] = len(defaultPrivileges)
@@ -649,11 +786,23 @@ func backupDefaultPrivileges(metadataFile *utils.FileWithByteCount) {
  */
 
 func backupTableStatistics(statisticsFile *utils.FileWithByteCount, tables []Table) {
-	attStats := GetAttributeStatistics(connectionPool, tables)
-	tupleStats := GetTupleStatistics(connectionPool, tables)
+	relatedRelations := GetRelatedStatisticsRelations(connectionPool, tables)
+	statisticsTables := append([]Table{}, tables...)
+	for _, table := range tables {
+		for _, relation := range relatedRelations[table.Oid] {
+			statisticsTables = append(statisticsTables, Table{Relation: relation})
+		}
+	}
+	attStats := GetAttributeStatistics(connectionPool, statisticsTables)
+	tupleStats := GetTupleStatistics(connectionPool, statisticsTables)
+	auxStats := GetAuxiliaryStatistics(connectionPool, statisticsTables)
+	extStats := GetExtendedStatistics(connectionPool, tables)
 
 	backupSessionGUC(statisticsFile)
 	PrintStatisticsStatements(statisticsFile, globalTOC, tables, attStats, tupleStats)
+	PrintRelatedStatisticsStatements(statisticsFile, globalTOC, tables, relatedRelations, attStats, tupleStats)
+	PrintAuxiliaryStatisticsStatements(statisticsFile, globalTOC, tables, relatedRelations, auxStats)
+	PrintExtendedStatisticsStatements(statisticsFile, globalTOC, tables, extStats)
 }
 
 func backupIncrementalMetadata() {
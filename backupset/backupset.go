@@ -0,0 +1,267 @@
+package backupset
+
+/*
+ * This file contains the backup-set subcommand of gpbackup, which backs up
+ * several databases in one run.  Each database is backed up by its own
+ * gpbackup process, so that it has its own backup and timestamp as usual,
+ * and global metadata is only backed up with the first of them.
+ */
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"runtime/debug"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/report"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func NewBackupSetCommand(gpbackupVersion string) *cobra.Command {
+	backupSetCmd := &cobra.Command{
+		Use:   "backup-set",
+		Short: "Back up several databases, backing up global metadata only once",
+		Args:  cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return options.ApplyConfigFile(cmd.Flags())
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			defer DoTeardown()
+			SetCmdFlags(cmd.Flags())
+			SetVersion(gpbackupVersion)
+			DoBackupSetValidation()
+			DoBackupSet()
+		},
+	}
+	options.SetBackupSetFlagDefaults(backupSetCmd.Flags())
+	return backupSetCmd
+}
+
+// These flags name objects in a single database, or skip the databases entirely, so they cannot be used for a whole set
+var backupSetExcludedFlags = []string{options.DBNAME, options.FROM_TIMESTAMP, options.GLOBALS_ONLY, options.INCLUDE_RELATION,
+	options.INCLUDE_RELATION_FILE, options.EXCLUDE_RELATION, options.EXCLUDE_RELATION_FILE}
+
+func DoBackupSetValidation() {
+	SetLoggerVerbosity()
+	gplog.Verbose("Backup Set Command: %s", os.Args)
+	options.CheckExclusiveFlags(cmdFlags, options.DEBUG, options.QUIET, options.VERBOSE)
+	options.CheckExclusiveFlags(cmdFlags, options.DBNAMES, options.ALL_DATABASES)
+	if !cmdFlags.Changed(options.DBNAMES) && !cmdFlags.Changed(options.ALL_DATABASES) {
+		gplog.Fatal(errors.Errorf("Either --%s or --%s must be specified", options.DBNAMES, options.ALL_DATABASES), "")
+	}
+	checkExcludedFlags(backupSetExcludedFlags)
+}
+
+func checkExcludedFlags(flagNames []string) {
+	for _, flagName := range flagNames {
+		if cmdFlags.Changed(flagName) {
+			gplog.Fatal(errors.Errorf("--%s cannot be used with a backup set", flagName), "")
+		}
+	}
+}
+
+func SetLoggerVerbosity() {
+	if MustGetFlagBool(options.QUIET) {
+		gplog.SetVerbosity(gplog.LOGERROR)
+	} else if MustGetFlagBool(options.DEBUG) {
+		gplog.SetVerbosity(gplog.LOGDEBUG)
+	} else if MustGetFlagBool(options.VERBOSE) {
+		gplog.SetVerbosity(gplog.LOGVERBOSE)
+	}
+}
+
+func DoBackupSet() {
+	backupSet := &history.BackupSet{Timestamp: history.CurrentTimestamp()}
+	gplog.Info("Backup Set Timestamp = %s", backupSet.Timestamp)
+
+	connectionPool := dbconn.NewDBConnFromEnvironment("postgres")
+	connectionPool.MustConnect(1)
+	databases := GetDatabases(connectionPool, MustGetFlagStringSlice(options.DBNAMES))
+	segConfig := cluster.MustGetSegmentConfiguration(connectionPool)
+	segPrefix := filepath.GetSegPrefix(connectionPool)
+	connectionPool.Close()
+	globalCluster = cluster.NewCluster(segConfig)
+	globalFPInfo = filepath.NewFilePathInfo(globalCluster, MustGetFlagString(options.BACKUP_DIR), backupSet.Timestamp, segPrefix)
+	if len(databases) == 0 {
+		gplog.Fatal(errors.Errorf("No databases to back up"), "")
+	}
+
+	// Each backup in the set gets its own timestamp, which must differ from that of the set
+	time.Sleep(time.Second)
+	BackupDatabases(backupSet, databases)
+
+	backupSet.EndTime = history.CurrentTimestamp()
+	err := history.AddBackupSetToHistory(globalFPInfo.GetBackupHistoryFilePath(), *backupSet)
+	if err != nil {
+		gplog.Warn("Unable to record backup set in backup history: %v", err)
+	}
+	reportFilename := globalFPInfo.GetBackupSetReportFilePath()
+	err = operating.System.MkdirAll(path.Dir(reportFilename), 0755)
+	gplog.FatalOnError(err)
+	endtime, _ := time.ParseInLocation("20060102150405", backupSet.EndTime, operating.System.Local)
+	report.WriteBackupSetReportFile(reportFilename, backupSet, version, endtime)
+
+	numFailed := 0
+	for _, entry := range backupSet.Backups {
+		if entry.Status != history.BackupStatusSucceed {
+			numFailed++
+		}
+	}
+	if numFailed > 0 {
+		gplog.Fatal(errors.Errorf("%d of %d database backups in backup set %s failed", numFailed, len(backupSet.Backups), backupSet.Timestamp),
+			fmt.Sprintf("See %s for details.", reportFilename))
+	}
+	gplog.Info("Backup set %s of %d databases completed successfully", backupSet.Timestamp, len(backupSet.Backups))
+}
+
+type Database struct {
+	Name       string
+	QuotedName string
+}
+
+/*
+ * Returns the listed databases in the order in which they were listed, or all
+ * databases that allow connections, except templates, if none are listed.
+ */
+func GetDatabases(connectionPool *dbconn.DBConn, dbnames []string) []Database {
+	whereClause := "datallowconn AND NOT datistemplate"
+	if len(dbnames) > 0 {
+		whereClause = fmt.Sprintf("datname IN (%s)", utils.SliceToQuotedString(dbnames))
+	}
+	query := fmt.Sprintf(`
+	SELECT datname AS name,
+		quote_ident(datname) AS quotedname
+	FROM pg_database
+	WHERE %s
+	ORDER BY datname`, whereClause)
+
+	results := make([]Database, 0)
+	err := connectionPool.Select(&results, query)
+	gplog.FatalOnError(err)
+	if len(dbnames) == 0 {
+		return results
+	}
+
+	databaseMap := make(map[string]Database)
+	for _, database := range results {
+		databaseMap[database.Name] = database
+	}
+	databases := make([]Database, 0)
+	for _, dbname := range dbnames {
+		database, ok := databaseMap[dbname]
+		if !ok {
+			gplog.Fatal(errors.Errorf("Database %s does not exist", dbname), "")
+		}
+		databases = append(databases, database)
+	}
+	return databases
+}
+
+/*
+ * A failed backup does not stop the set, so that one database cannot prevent
+ * the others from being backed up; if the backup that was to include the
+ * global metadata fails, the next backup includes it instead.
+ */
+func BackupDatabases(backupSet *history.BackupSet, databases []Database) {
+	historyFilePath := globalFPInfo.GetBackupHistoryFilePath()
+	globalsPending := !MustGetFlagBool(options.WITHOUT_GLOBALS) && !MustGetFlagBool(options.DATA_ONLY)
+	backupSet.Status = history.BackupStatusSucceed
+	for i, database := range databases {
+		gplog.Info("Backing up database %s (%d of %d)", database.Name, i+1, len(databases))
+		excludedFlags := []string{options.DBNAMES, options.ALL_DATABASES, options.WITHOUT_GLOBALS}
+		if !globalsPending {
+			// The global metadata filters cannot be used without global metadata
+			excludedFlags = append(excludedFlags, options.INCLUDE_ROLE_PATTERN, options.WITHOUT_RES_GROUPS)
+		}
+		args := ChildArgs(cmdFlags, excludedFlags...)
+		args = append(args, fmt.Sprintf("--%s=%s", options.DBNAME, database.Name))
+		if !globalsPending {
+			args = append(args, fmt.Sprintf("--%s", options.WITHOUT_GLOBALS))
+		}
+		err := executeCommand(args)
+
+		entry := history.BackupSetEntry{DatabaseName: database.QuotedName, Status: history.BackupStatusFailed}
+		if backupConfig := findDatabaseBackup(historyFilePath, database.QuotedName, backupSet); backupConfig != nil {
+			entry.Timestamp = backupConfig.Timestamp
+			entry.Status = backupConfig.Status
+		}
+		if err != nil {
+			gplog.Error("Backup of database %s failed: %v", database.Name, err)
+			entry.Status = history.BackupStatusFailed
+		}
+		if entry.Status == history.BackupStatusSucceed && globalsPending {
+			backupSet.GlobalsTimestamp = entry.Timestamp
+			globalsPending = false
+		}
+		if entry.Status != history.BackupStatusSucceed {
+			backupSet.Status = history.BackupStatusFailed
+		}
+		backupSet.Backups = append(backupSet.Backups, entry)
+	}
+}
+
+/*
+ * The backup of a database is the latest backup of it in the history that
+ * was started after the set and is not already part of it.
+ */
+func findDatabaseBackup(historyFilePath string, databaseName string, backupSet *history.BackupSet) *history.BackupConfig {
+	backupHistory, err := history.NewHistory(historyFilePath)
+	if err != nil {
+		return nil
+	}
+	setTimestamps := make(map[string]bool)
+	for _, entry := range backupSet.Backups {
+		setTimestamps[entry.Timestamp] = true
+	}
+	for _, backupConfig := range backupHistory.BackupConfigs {
+		if backupConfig.DatabaseName == databaseName && backupConfig.Timestamp > backupSet.Timestamp && !setTimestamps[backupConfig.Timestamp] {
+			return &backupConfig
+		}
+	}
+	return nil
+}
+
+// Returns the flags that were set, except those excluded, as arguments for a gpbackup or gprestore process
+func ChildArgs(flags *pflag.FlagSet, excludedFlags ...string) []string {
+	// The config file has already been applied to the flags, which are passed on individually
+	excluded := map[string]bool{options.CONFIG: true}
+	for _, flagName := range excludedFlags {
+		excluded[flagName] = true
+	}
+	args := make([]string, 0)
+	flags.Visit(func(flag *pflag.Flag) {
+		if excluded[flag.Name] {
+			return
+		}
+		if sliceValue, ok := flag.Value.(pflag.SliceValue); ok {
+			for _, value := range sliceValue.GetSlice() {
+				args = append(args, fmt.Sprintf("--%s=%s", flag.Name, value))
+			}
+			return
+		}
+		args = append(args, fmt.Sprintf("--%s=%s", flag.Name, flag.Value.String()))
+	})
+	return args
+}
+
+func DoTeardown() {
+	if err := recover(); err != nil {
+		// gplog's Fatal will cause a panic with error code 2
+		if gplog.GetErrorCode() != 2 {
+			gplog.Error(fmt.Sprintf("%v: %s", err, debug.Stack()))
+			gplog.SetErrorCode(2)
+		}
+	}
+	os.Exit(gplog.GetErrorCode())
+}
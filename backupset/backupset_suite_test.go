@@ -0,0 +1,38 @@
+package backupset_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+func TestBackupSet(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "backupset tests")
+}
+
+var (
+	connectionPool *dbconn.DBConn
+	mock           sqlmock.Sqlmock
+	logfile        *Buffer
+	tempDir        string
+)
+
+var _ = BeforeEach(func() {
+	connectionPool, mock, _, _, logfile = testhelper.SetupTestEnvironment()
+	var err error
+	tempDir, err = ioutil.TempDir("", "backupset_test")
+	Expect(err).ToNot(HaveOccurred())
+})
+
+var _ = AfterEach(func() {
+	_ = os.RemoveAll(tempDir)
+})
@@ -0,0 +1,226 @@
+package backupset_test
+
+import (
+	"fmt"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/backupset"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("backupset tests", func() {
+	var (
+		cmdFlags        *pflag.FlagSet
+		historyFilePath string
+		executedArgs    [][]string
+	)
+	setTimestamp := "20170101010101"
+	BeforeEach(func() {
+		testCluster := cluster.NewCluster([]cluster.SegConfig{{ContentID: -1, Hostname: "localhost", DataDir: tempDir}})
+		backupset.SetCluster(testCluster)
+		fpInfo := filepath.NewFilePathInfo(testCluster, "", setTimestamp, "gpseg")
+		backupset.SetFPInfo(fpInfo)
+		historyFilePath = fpInfo.GetBackupHistoryFilePath()
+		executedArgs = make([][]string, 0)
+	})
+	Describe("ChildArgs", func() {
+		It("passes on the flags that were set, except those excluded", func() {
+			cmdFlags = pflag.NewFlagSet("gpbackup", pflag.ContinueOnError)
+			options.SetBackupSetFlagDefaults(cmdFlags)
+			err := cmdFlags.Parse([]string{"--all-databases", "--jobs", "4", "--include-schema", "s1", "--include-schema", "s 2", "--backup-dir", "/tmp/backups"})
+			Expect(err).ToNot(HaveOccurred())
+
+			args := backupset.ChildArgs(cmdFlags, options.ALL_DATABASES)
+
+			Expect(args).To(Equal([]string{"--backup-dir=/tmp/backups", "--include-schema=s1", "--include-schema=s 2", "--jobs=4"}))
+		})
+		It("does not pass on the config file, whose flags were already applied", func() {
+			cmdFlags = pflag.NewFlagSet("gpbackup", pflag.ContinueOnError)
+			options.SetBackupSetFlagDefaults(cmdFlags)
+			err := cmdFlags.Parse([]string{"--all-databases", "--config", "/tmp/config.yaml", "--jobs", "4"})
+			Expect(err).ToNot(HaveOccurred())
+
+			args := backupset.ChildArgs(cmdFlags, options.ALL_DATABASES)
+
+			Expect(args).To(Equal([]string{"--jobs=4"}))
+		})
+	})
+	Describe("GetDatabases", func() {
+		It("returns all databases that allow connections when none are listed", func() {
+			rows := sqlmock.NewRows([]string{"name", "quotedname"}).AddRow("Test DB", `"Test DB"`).AddRow("testdb", "testdb")
+			mock.ExpectQuery("WHERE datallowconn AND NOT datistemplate").WillReturnRows(rows)
+
+			databases := backupset.GetDatabases(connectionPool, []string{})
+
+			Expect(databases).To(Equal([]backupset.Database{{Name: "Test DB", QuotedName: `"Test DB"`}, {Name: "testdb", QuotedName: "testdb"}}))
+		})
+		It("returns the listed databases in the order in which they were listed", func() {
+			rows := sqlmock.NewRows([]string{"name", "quotedname"}).AddRow("db1", "db1").AddRow("db2", "db2")
+			mock.ExpectQuery("WHERE datname IN \\('db2','db1'\\)").WillReturnRows(rows)
+
+			databases := backupset.GetDatabases(connectionPool, []string{"db2", "db1"})
+
+			Expect(databases).To(Equal([]backupset.Database{{Name: "db2", QuotedName: "db2"}, {Name: "db1", QuotedName: "db1"}}))
+		})
+		It("panics if a listed database does not exist", func() {
+			rows := sqlmock.NewRows([]string{"name", "quotedname"}).AddRow("db1", "db1")
+			mock.ExpectQuery("SELECT datname").WillReturnRows(rows)
+
+			defer testhelper.ShouldPanicWithMessage("Database db2 does not exist")
+			backupset.GetDatabases(connectionPool, []string{"db1", "db2"})
+		})
+	})
+	Describe("BackupDatabases", func() {
+		var failingDatabases map[string]bool
+		BeforeEach(func() {
+			cmdFlags = pflag.NewFlagSet("gpbackup", pflag.ContinueOnError)
+			options.SetBackupSetFlagDefaults(cmdFlags)
+			backupset.SetCmdFlags(cmdFlags)
+			failingDatabases = make(map[string]bool)
+			backupNum := 0
+			// Simulates gpbackup, which records each backup in the history file
+			backupset.SetExecuteCommand(func(args []string) error {
+				executedArgs = append(executedArgs, args)
+				backupNum++
+				dbname := args[len(args)-1]
+				if dbname == "--without-globals" {
+					dbname = args[len(args)-2]
+				}
+				config := history.BackupConfig{
+					DatabaseName: dbname[len("--dbname="):],
+					Timestamp:    fmt.Sprintf("2017010101010%d", backupNum+1),
+					Status:       history.BackupStatusSucceed,
+				}
+				if failingDatabases[config.DatabaseName] {
+					config.Status = history.BackupStatusFailed
+				}
+				err := history.WriteBackupHistory(historyFilePath, &config)
+				Expect(err).ToNot(HaveOccurred())
+				if failingDatabases[config.DatabaseName] {
+					return errors.New("exit status 2")
+				}
+				return nil
+			})
+		})
+		It("backs up each database, with global metadata only in the first backup", func() {
+			_ = cmdFlags.Set(options.JOBS, "2")
+			backupSet := &history.BackupSet{Timestamp: setTimestamp}
+
+			backupset.BackupDatabases(backupSet, []backupset.Database{{Name: "db1", QuotedName: "db1"}, {Name: "db2", QuotedName: "db2"}})
+
+			Expect(executedArgs).To(Equal([][]string{
+				{"--jobs=2", "--dbname=db1"},
+				{"--jobs=2", "--dbname=db2", "--without-globals"},
+			}))
+			Expect(*backupSet).To(Equal(history.BackupSet{
+				Timestamp:        setTimestamp,
+				Status:           history.BackupStatusSucceed,
+				GlobalsTimestamp: "20170101010102",
+				Backups: []history.BackupSetEntry{
+					{DatabaseName: "db1", Timestamp: "20170101010102", Status: history.BackupStatusSucceed},
+					{DatabaseName: "db2", Timestamp: "20170101010103", Status: history.BackupStatusSucceed},
+				},
+			}))
+		})
+		It("continues after a failed backup and includes global metadata in the next backup", func() {
+			failingDatabases["db1"] = true
+			backupSet := &history.BackupSet{Timestamp: setTimestamp}
+
+			backupset.BackupDatabases(backupSet, []backupset.Database{{Name: "db1", QuotedName: "db1"}, {Name: "db2", QuotedName: "db2"}, {Name: "db3", QuotedName: "db3"}})
+
+			Expect(executedArgs).To(Equal([][]string{
+				{"--dbname=db1"},
+				{"--dbname=db2"},
+				{"--dbname=db3", "--without-globals"},
+			}))
+			Expect(backupSet.Status).To(Equal(history.BackupStatusFailed))
+			Expect(backupSet.GlobalsTimestamp).To(Equal("20170101010103"))
+			Expect(backupSet.Backups[0]).To(Equal(history.BackupSetEntry{DatabaseName: "db1", Timestamp: "20170101010102", Status: history.BackupStatusFailed}))
+			Expect(string(logfile.Contents())).To(ContainSubstring("Backup of database db1 failed: exit status 2"))
+		})
+		It("only passes global metadata filters to the backup that includes global metadata", func() {
+			_ = cmdFlags.Set(options.INCLUDE_ROLE_PATTERN, "app_.*")
+			_ = cmdFlags.Set(options.WITHOUT_RES_GROUPS, "true")
+			backupSet := &history.BackupSet{Timestamp: setTimestamp}
+
+			backupset.BackupDatabases(backupSet, []backupset.Database{{Name: "db1", QuotedName: "db1"}, {Name: "db2", QuotedName: "db2"}})
+
+			Expect(executedArgs).To(Equal([][]string{
+				{"--include-role-pattern=app_.*", "--without-resource-groups=true", "--dbname=db1"},
+				{"--dbname=db2", "--without-globals"},
+			}))
+		})
+		It("does not back up global metadata when it is disabled", func() {
+			_ = cmdFlags.Set(options.WITHOUT_GLOBALS, "true")
+			backupSet := &history.BackupSet{Timestamp: setTimestamp}
+
+			backupset.BackupDatabases(backupSet, []backupset.Database{{Name: "db1", QuotedName: "db1"}})
+
+			Expect(executedArgs).To(Equal([][]string{{"--dbname=db1", "--without-globals"}}))
+			Expect(backupSet.GlobalsTimestamp).To(BeEmpty())
+		})
+	})
+	Describe("restoring a backup set", func() {
+		backupSet := &history.BackupSet{
+			Timestamp:        setTimestamp,
+			Status:           history.BackupStatusFailed,
+			GlobalsTimestamp: "20170101010103",
+			Backups: []history.BackupSetEntry{
+				{DatabaseName: "db1", Timestamp: "20170101010102", Status: history.BackupStatusFailed},
+				{DatabaseName: "db2", Timestamp: "20170101010103", Status: history.BackupStatusSucceed},
+				{DatabaseName: "db3", Timestamp: "20170101010104", Status: history.BackupStatusSucceed},
+			},
+		}
+		BeforeEach(func() {
+			cmdFlags = pflag.NewFlagSet("gprestore", pflag.ContinueOnError)
+			options.SetRestoreSetFlagDefaults(cmdFlags)
+			backupset.SetCmdFlags(cmdFlags)
+			_ = cmdFlags.Set(options.TIMESTAMP, setTimestamp)
+			backupset.SetExecuteCommand(func(args []string) error {
+				executedArgs = append(executedArgs, args)
+				return nil
+			})
+		})
+		It("restores the successful backups, with global metadata first", func() {
+			_ = cmdFlags.Set(options.WITH_GLOBALS, "true")
+			_ = cmdFlags.Set(options.CREATE_DB, "true")
+
+			entries := backupset.SelectSetEntries(backupSet, map[string]bool{})
+			numFailed := backupset.RestoreDatabases(backupSet, entries)
+
+			Expect(numFailed).To(Equal(0))
+			Expect(executedArgs).To(Equal([][]string{
+				{"--create-db=true", "--timestamp=20170101010103", "--with-globals"},
+				{"--create-db=true", "--timestamp=20170101010104"},
+			}))
+			Expect(string(logfile.Contents())).To(ContainSubstring("Skipping database db1, as its backup in the set failed"))
+		})
+		It("restores only the given databases", func() {
+			entries := backupset.SelectSetEntries(backupSet, map[string]bool{"db3": true})
+
+			Expect(entries).To(Equal([]history.BackupSetEntry{backupSet.Backups[2]}))
+		})
+		It("panics if a given database is not in the set", func() {
+			defer testhelper.ShouldPanicWithMessage("Database db4 is not in backup set 20170101010101")
+			backupset.SelectSetEntries(backupSet, map[string]bool{"db4": true})
+		})
+		It("counts the databases that failed to restore", func() {
+			backupset.SetExecuteCommand(func(args []string) error {
+				return errors.New("exit status 2")
+			})
+
+			numFailed := backupset.RestoreDatabases(backupSet, backupSet.Backups[1:])
+
+			Expect(numFailed).To(Equal(2))
+		})
+	})
+})
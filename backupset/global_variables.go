@@ -0,0 +1,81 @@
+package backupset
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/spf13/pflag"
+)
+
+/*
+ * This file contains global variables and setter functions for those variables
+ * used in testing.
+ */
+
+/*
+ * Non-flag variables
+ */
+
+var (
+	globalCluster *cluster.Cluster
+	globalFPInfo  filepath.FilePathInfo
+	version       string
+
+	// Runs the utility again with the given arguments to back up or restore one database
+	executeCommand = func(args []string) error {
+		executable, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command(executable, args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	}
+)
+
+/*
+ * Command-line flags
+ */
+var cmdFlags *pflag.FlagSet
+
+/*
+ * Setter functions
+ */
+
+func SetCmdFlags(flagSet *pflag.FlagSet) {
+	cmdFlags = flagSet
+}
+
+func SetCluster(c *cluster.Cluster) {
+	globalCluster = c
+}
+
+func SetFPInfo(fpInfo filepath.FilePathInfo) {
+	globalFPInfo = fpInfo
+}
+
+func SetVersion(v string) {
+	version = v
+}
+
+func SetExecuteCommand(execute func(args []string) error) {
+	executeCommand = execute
+}
+
+// Util functions to enable ease of access to global flag values
+
+func MustGetFlagString(flagName string) string {
+	return options.MustGetFlagString(cmdFlags, flagName)
+}
+
+func MustGetFlagBool(flagName string) bool {
+	return options.MustGetFlagBool(cmdFlags, flagName)
+}
+
+func MustGetFlagStringSlice(flagName string) []string {
+	return options.MustGetFlagStringSlice(cmdFlags, flagName)
+}
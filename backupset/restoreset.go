@@ -0,0 +1,142 @@
+package backupset
+
+/*
+ * This file contains the restore-set subcommand of gprestore, which restores
+ * the databases backed up by gpbackup backup-set.  Each database is restored
+ * by its own gprestore process, from the backup recorded for it in the set.
+ */
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func NewRestoreSetCommand(gprestoreVersion string) *cobra.Command {
+	restoreSetCmd := &cobra.Command{
+		Use:   "restore-set",
+		Short: "Restore the databases backed up by gpbackup backup-set",
+		Args:  cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return options.ApplyConfigFile(cmd.Flags())
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			defer DoTeardown()
+			SetCmdFlags(cmd.Flags())
+			SetVersion(gprestoreVersion)
+			DoRestoreSetValidation()
+			DoRestoreSet()
+		},
+	}
+	options.SetRestoreSetFlagDefaults(restoreSetCmd.Flags())
+	_ = restoreSetCmd.MarkFlagRequired(options.TIMESTAMP)
+	return restoreSetCmd
+}
+
+var restoreSetExcludedFlags = []string{options.REDIRECT_DB, options.GLOBALS_ONLY, options.INCLUDE_RELATION,
+	options.INCLUDE_RELATION_FILE, options.EXCLUDE_RELATION, options.EXCLUDE_RELATION_FILE}
+
+func DoRestoreSetValidation() {
+	SetLoggerVerbosity()
+	gplog.Verbose("Restore Set Command: %s", os.Args)
+	options.CheckExclusiveFlags(cmdFlags, options.DEBUG, options.QUIET, options.VERBOSE)
+	timestamp := MustGetFlagString(options.TIMESTAMP)
+	if !filepath.IsValidTimestamp(timestamp) {
+		gplog.Fatal(errors.Errorf("Timestamp %s is invalid.  Timestamps must be in the format YYYYMMDDHHMMSS.", timestamp), "")
+	}
+	checkExcludedFlags(restoreSetExcludedFlags)
+}
+
+func DoRestoreSet() {
+	timestamp := MustGetFlagString(options.TIMESTAMP)
+	gplog.Info("Restoring backup set %s", timestamp)
+
+	connectionPool := dbconn.NewDBConnFromEnvironment("postgres")
+	connectionPool.MustConnect(1)
+	segConfig := cluster.MustGetSegmentConfiguration(connectionPool)
+	quotedDBNames := make(map[string]bool)
+	for _, dbname := range MustGetFlagStringSlice(options.DBNAMES) {
+		quotedDBNames[utils.QuoteIdent(connectionPool, dbname)] = true
+	}
+	connectionPool.Close()
+	globalCluster = cluster.NewCluster(segConfig)
+	globalFPInfo = filepath.NewFilePathInfo(globalCluster, "", timestamp, "")
+
+	historyFilePath := globalFPInfo.GetBackupHistoryFilePath()
+	backupHistory, err := history.NewHistory(historyFilePath)
+	gplog.FatalOnError(err)
+	backupSet := backupHistory.FindBackupSet(timestamp)
+	if backupSet == nil {
+		gplog.Fatal(errors.Errorf("Backup set %s was not found in history file %s", timestamp, historyFilePath), "")
+	}
+
+	entries := SelectSetEntries(backupSet, quotedDBNames)
+	numFailed := RestoreDatabases(backupSet, entries)
+	if numFailed > 0 {
+		gplog.Fatal(errors.Errorf("%d of %d database restores from backup set %s failed", numFailed, len(entries), timestamp), "")
+	}
+	gplog.Info("Restore of backup set %s completed successfully", timestamp)
+}
+
+/*
+ * Returns the successful backups in the set of the given databases, or of all
+ * databases if none are given.  If global metadata is restored, the backup
+ * that includes it is restored first, so that roles and tablespaces exist
+ * before the other databases are restored.
+ */
+func SelectSetEntries(backupSet *history.BackupSet, quotedDBNames map[string]bool) []history.BackupSetEntry {
+	found := make(map[string]bool)
+	entries := make([]history.BackupSetEntry, 0)
+	for _, entry := range backupSet.Backups {
+		if len(quotedDBNames) > 0 && !quotedDBNames[entry.DatabaseName] {
+			continue
+		}
+		found[entry.DatabaseName] = true
+		if entry.Status != history.BackupStatusSucceed {
+			gplog.Warn("Skipping database %s, as its backup in the set failed", entry.DatabaseName)
+			continue
+		}
+		if MustGetFlagBool(options.WITH_GLOBALS) && entry.Timestamp == backupSet.GlobalsTimestamp {
+			entries = append([]history.BackupSetEntry{entry}, entries...)
+		} else {
+			entries = append(entries, entry)
+		}
+	}
+	for dbname := range quotedDBNames {
+		if !found[dbname] {
+			gplog.Fatal(errors.Errorf("Database %s is not in backup set %s", dbname, backupSet.Timestamp), "")
+		}
+	}
+	if MustGetFlagBool(options.WITH_GLOBALS) && (len(entries) == 0 || entries[0].Timestamp != backupSet.GlobalsTimestamp) {
+		gplog.Warn("Global metadata will not be restored, as the backup that includes it is not being restored")
+	}
+	return entries
+}
+
+// Returns the number of databases that failed to restore
+func RestoreDatabases(backupSet *history.BackupSet, entries []history.BackupSetEntry) int {
+	numFailed := 0
+	for i, entry := range entries {
+		gplog.Info("Restoring database %s from backup %s (%d of %d)", entry.DatabaseName, entry.Timestamp, i+1, len(entries))
+		args := ChildArgs(cmdFlags, options.TIMESTAMP, options.DBNAMES, options.WITH_GLOBALS)
+		args = append(args, fmt.Sprintf("--%s=%s", options.TIMESTAMP, entry.Timestamp))
+		if MustGetFlagBool(options.WITH_GLOBALS) && entry.Timestamp == backupSet.GlobalsTimestamp {
+			args = append(args, fmt.Sprintf("--%s", options.WITH_GLOBALS))
+		}
+		err := executeCommand(args)
+		if err != nil {
+			gplog.Error("Restore of database %s failed: %v", entry.DatabaseName, err)
+			numFailed++
+		}
+	}
+	return numFailed
+}
@@ -1327,6 +1327,116 @@ var _ = Describe("backup and restore end to end tests", func() {
 			"SELECT count(*) FROM pg_class WHERE oid >= 16384 AND relnamespace in (SELECT oid from pg_namespace WHERE nspname in ('public', 'schema2'));")
 		Expect(restoreTableCount).To(Equal(strconv.Itoa(1)))
 	})
+	It("runs gprestore with stats-only flag and skips the statistics of stale tables", func() {
+		// gpbackup before version 1.18.0 does not dump pg_class statistics correctly
+		skipIfOldBackupVersionBefore("1.18.0")
+
+		timestamp := gpbackup(gpbackupPath, backupHelperPath,
+			"--with-stats",
+			"--backup-dir", backupDir)
+		gprestore(gprestorePath, restoreHelperPath, timestamp,
+			"--redirect-db", "restoredb",
+			"--backup-dir", backupDir)
+		testhelper.AssertQueryRuns(restoreConn,
+			"INSERT INTO schema2.foo3 SELECT generate_series(1, 100)")
+
+		output := gprestore(gprestorePath, restoreHelperPath, timestamp,
+			"--redirect-db", "restoredb",
+			"--stats-only",
+			"--stats-tolerance", "10",
+			"--backup-dir", backupDir)
+
+		Expect(string(output)).To(ContainSubstring("Skipping statistics of table schema2.foo3 as stale"))
+		Expect(string(output)).To(ContainSubstring("Query planner statistics restore complete"))
+		assertPGClassStatsRestored(backupConn, restoreConn, publicSchemaTupleCounts)
+	})
+	It("runs gpbackup and gprestore with session-guc-file flag and records the settings in the backup config", func() {
+		if useOldBackupVersion {
+			Skip("This test is not needed for old backup versions")
+		}
+		gucFile := path.Join(backupDir, "session-gucs.conf")
+		gucFileHandle := iohelper.MustOpenFileForWriting(gucFile)
+		utils.MustPrintln(gucFileHandle, "# Session settings for backup and restore\nstatement_mem = '125MB'")
+		defer os.Remove(gucFile)
+
+		timestamp := gpbackup(gpbackupPath, backupHelperPath,
+			"--backup-dir", backupDir,
+			"--session-guc-file", gucFile)
+		configFileContents := getMetdataFileContents(backupDir, timestamp, "config.yaml")
+		Expect(string(configFileContents)).To(ContainSubstring("sessiongucs:\n- name: statement_mem\n  value: 125MB"))
+
+		gprestore(gprestorePath, restoreHelperPath, timestamp,
+			"--redirect-db", "restoredb",
+			"--backup-dir", backupDir,
+			"--session-guc-file", gucFile)
+		assertRelationsCreated(restoreConn, TOTAL_RELATIONS)
+		assertDataRestored(restoreConn, publicSchemaTupleCounts)
+	})
+	It("runs gprestore with role-mapping flag and restores ownership and privileges for the mapped roles", func() {
+		testhelper.AssertQueryRuns(backupConn, "CREATE ROLE etl_owner")
+		defer testhelper.AssertQueryRuns(backupConn, "DROP ROLE etl_owner")
+		testhelper.AssertQueryRuns(backupConn, "CREATE ROLE old_reporting")
+		defer testhelper.AssertQueryRuns(backupConn, "DROP ROLE old_reporting")
+		testhelper.AssertQueryRuns(backupConn, "CREATE ROLE etl_admin")
+		defer testhelper.AssertQueryRuns(backupConn, "DROP ROLE etl_admin")
+		testhelper.AssertQueryRuns(backupConn, "CREATE TABLE public.role_mapping_table(i int)")
+		defer testhelper.AssertQueryRuns(backupConn, "DROP TABLE public.role_mapping_table")
+		testhelper.AssertQueryRuns(backupConn, "ALTER TABLE public.role_mapping_table OWNER TO etl_owner")
+		testhelper.AssertQueryRuns(backupConn, "GRANT SELECT ON TABLE public.role_mapping_table TO old_reporting")
+		roleMappingFile := path.Join(backupDir, "role-mapping.txt")
+		roleMappingFileHandle := iohelper.MustOpenFileForWriting(roleMappingFile)
+		utils.MustPrintln(roleMappingFileHandle, "etl_owner -> etl_admin\nold_reporting -> DROP")
+		defer os.Remove(roleMappingFile)
+
+		timestamp := gpbackup(gpbackupPath, backupHelperPath,
+			"--metadata-only")
+		gprestore(gprestorePath, restoreHelperPath, timestamp,
+			"--redirect-db", "restoredb",
+			"--role-mapping", roleMappingFile)
+
+		owner := dbconn.MustSelectString(restoreConn,
+			"SELECT pg_get_userbyid(relowner) AS string FROM pg_class WHERE oid = 'public.role_mapping_table'::regclass")
+		Expect(owner).To(Equal("etl_admin"))
+		acl := dbconn.MustSelectString(restoreConn,
+			"SELECT relacl::text AS string FROM pg_class WHERE oid = 'public.role_mapping_table'::regclass")
+		Expect(acl).To(ContainSubstring("etl_admin="))
+		Expect(acl).ToNot(ContainSubstring("old_reporting"))
+	})
+	It("runs gprestore with no-owner and no-privileges flags", func() {
+		timestamp := gpbackup(gpbackupPath, backupHelperPath)
+		gprestore(gprestorePath, restoreHelperPath, timestamp,
+			"--redirect-db", "restoredb",
+			"--no-owner", "--no-privileges")
+
+		assertRelationsCreated(restoreConn, TOTAL_RELATIONS)
+		assertDataRestored(restoreConn, publicSchemaTupleCounts)
+	})
+	It("runs gpbackup and gprestore with config flag, with command-line flags overriding the config file", func() {
+		if useOldBackupVersion {
+			Skip("This test is not needed for old backup versions")
+		}
+		backupConfigFile := path.Join(backupDir, "backup-config.yaml")
+		backupConfigHandle := iohelper.MustOpenFileForWriting(backupConfigFile)
+		utils.MustPrintf(backupConfigHandle, "backup-dir: %s\njobs: 2\ninclude-schema:\n  - schema2\n", backupDir)
+		defer os.Remove(backupConfigFile)
+		restoreConfigFile := path.Join(backupDir, "restore-config.yaml")
+		restoreConfigHandle := iohelper.MustOpenFileForWriting(restoreConfigFile)
+		utils.MustPrintf(restoreConfigHandle, "backup-dir: %s\nredirect-db: otherdb\n", backupDir)
+		defer os.Remove(restoreConfigFile)
+
+		timestamp := gpbackup(gpbackupPath, backupHelperPath,
+			"--config", backupConfigFile,
+			"--jobs", "4")
+		reportFileContents := getMetdataFileContents(backupDir, timestamp, "report")
+		Expect(string(reportFileContents)).To(ContainSubstring("effective configuration:"))
+		Expect(string(reportFileContents)).To(ContainSubstring("include-schema:\n- schema2\njobs: 4\n"))
+
+		gprestore(gprestorePath, restoreHelperPath, timestamp,
+			"--config", restoreConfigFile,
+			"--redirect-db", "restoredb")
+		assertRelationsCreated(restoreConn, 5)
+		assertDataRestored(restoreConn, schema2TupleCounts)
+	})
 	It("runs gpbackup and gprestore with jobs flag", func() {
 		skipIfOldBackupVersionBefore("1.3.0")
 		timestamp := gpbackup(gpbackupPath, backupHelperPath,
@@ -1344,6 +1454,38 @@ var _ = Describe("backup and restore end to end tests", func() {
 		assertDataRestored(restoreConn, schema2TupleCounts)
 		assertDataRestored(restoreConn, publicSchemaTupleCounts)
 	})
+	It("runs gpbackup and gprestore with single-data-file and data-streams flags", func() {
+		if useOldBackupVersion {
+			Skip("This test is not needed for old backup versions")
+		}
+		timestamp := gpbackup(gpbackupPath, backupHelperPath,
+			"--backup-dir", backupDir,
+			"--single-data-file",
+			"--data-streams", "3")
+		gprestore(gprestorePath, restoreHelperPath, timestamp,
+			"--redirect-db", "restoredb",
+			"--backup-dir", backupDir)
+
+		assertRelationsCreated(restoreConn, TOTAL_RELATIONS)
+		assertDataRestored(restoreConn, schema2TupleCounts)
+		assertDataRestored(restoreConn, publicSchemaTupleCounts)
+	})
+	It("runs gprestore with jobs flag on a backup with a single data file", func() {
+		if useOldBackupVersion {
+			Skip("This test is not needed for old backup versions")
+		}
+		timestamp := gpbackup(gpbackupPath, backupHelperPath,
+			"--backup-dir", backupDir,
+			"--single-data-file")
+		gprestore(gprestorePath, restoreHelperPath, timestamp,
+			"--redirect-db", "restoredb",
+			"--backup-dir", backupDir,
+			"--jobs", "4")
+
+		assertRelationsCreated(restoreConn, TOTAL_RELATIONS)
+		assertDataRestored(restoreConn, schema2TupleCounts)
+		assertDataRestored(restoreConn, publicSchemaTupleCounts)
+	})
 	It("runs gpbackup with jobs flag and COPY deadlock handling occurs", func() {
 		if useOldBackupVersion {
 			Skip("This test is not needed for old backup versions")
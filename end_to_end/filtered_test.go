@@ -70,6 +70,18 @@ var _ = Describe("End to End Filtered tests", func() {
 
 			_ = os.Remove("/tmp/include-schema.txt")
 		})
+		It("runs gpbackup and gprestore with include-schema-pattern backup flag", func() {
+			if useOldBackupVersion {
+				Skip("This test is not needed for old backup versions")
+			}
+			timestamp := gpbackup(gpbackupPath, backupHelperPath,
+				"--include-schema-pattern", "pub*")
+			gprestore(gprestorePath, restoreHelperPath, timestamp,
+				"--redirect-db", "restoredb")
+
+			assertRelationsCreated(restoreConn, 20)
+			assertDataRestored(restoreConn, publicSchemaTupleCounts)
+		})
 		It("runs gpbackup with --include-table flag with partitions (non-special chars)", func() {
 			testhelper.AssertQueryRuns(backupConn,
 				`CREATE TABLE public.testparent (id int, rank int, year int, gender
@@ -184,6 +196,37 @@ PARTITION BY LIST (gender)
 
 			_ = os.Remove("/tmp/include-tables.txt")
 		})
+		It("runs gpbackup and gprestore with include-table-pattern restore flag", func() {
+			timestamp := gpbackup(gpbackupPath, backupHelperPath)
+			gprestore(gprestorePath, restoreHelperPath, timestamp,
+				"--redirect-db", "restoredb",
+				"--include-table-pattern", "public.(foo|sales|myseq1|myview1)")
+
+			assertRelationsCreated(restoreConn, 16)
+			assertDataRestored(restoreConn, map[string]int{
+				"public.sales": 13, "public.foo": 40000})
+		})
+		It("runs gpbackup and gprestore with exclude-object-type restore flag", func() {
+			timestamp := gpbackup(gpbackupPath, backupHelperPath)
+			gprestore(gprestorePath, restoreHelperPath, timestamp,
+				"--redirect-db", "restoredb",
+				"--exclude-object-type", "VIEW")
+
+			assertRelationsCreated(restoreConn, TOTAL_RELATIONS-2)
+			assertDataRestored(restoreConn, publicSchemaTupleCounts)
+		})
+		It("runs gpbackup and gprestore with exclude-object-type backup flag", func() {
+			if useOldBackupVersion {
+				Skip("This test is not needed for old backup versions")
+			}
+			timestamp := gpbackup(gpbackupPath, backupHelperPath,
+				"--exclude-object-type", "VIEW")
+			gprestore(gprestorePath, restoreHelperPath, timestamp,
+				"--redirect-db", "restoredb")
+
+			assertRelationsCreated(restoreConn, TOTAL_RELATIONS-2)
+			assertDataRestored(restoreConn, publicSchemaTupleCounts)
+		})
 		It("runs gpbackup and gprestore with include-table restore flag against a leaf partition", func() {
 			skipIfOldBackupVersionBefore("1.7.2")
 			timestamp := gpbackup(gpbackupPath, backupHelperPath,
@@ -0,0 +1,111 @@
+package extract
+
+/*
+ * This file contains structs and functions related to parsing the CSV format
+ * written by COPY ... WITH CSV, which encoding/csv cannot do because it does
+ * not distinguish a NULL (an unquoted empty field) from an empty string.
+ */
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type CSVRecordReader struct {
+	reader    *bufio.Reader
+	delimiter byte
+}
+
+func NewCSVRecordReader(reader io.Reader, delimiter byte) *CSVRecordReader {
+	return &CSVRecordReader{reader: bufio.NewReader(reader), delimiter: delimiter}
+}
+
+/*
+ * Returns the fields of the next record, with nil representing NULL, or io.EOF
+ * once all records have been read.
+ */
+func (r *CSVRecordReader) Read() ([]*string, error) {
+	firstByte, err := r.reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	_ = r.reader.UnreadByte()
+	if firstByte == '\n' {
+		// An empty line is a single NULL field
+		_, _ = r.reader.ReadByte()
+		return []*string{nil}, nil
+	}
+
+	fields := make([]*string, 0)
+	for {
+		field, endOfRecord, err := r.readField()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+		if endOfRecord {
+			return fields, nil
+		}
+	}
+}
+
+func (r *CSVRecordReader) readField() (field *string, endOfRecord bool, err error) {
+	var value strings.Builder
+	quoted := false
+	inQuotes := false
+	for {
+		b, err := r.reader.ReadByte()
+		if err == io.EOF {
+			if inQuotes {
+				return nil, false, errors.New("Unexpected end of data inside quoted CSV field")
+			}
+			break
+		} else if err != nil {
+			return nil, false, err
+		}
+
+		if inQuotes {
+			if b != '"' {
+				value.WriteByte(b)
+				continue
+			}
+			next, err := r.reader.ReadByte()
+			if err == nil && next == '"' {
+				value.WriteByte('"')
+				continue
+			} else if err == nil {
+				_ = r.reader.UnreadByte()
+			}
+			inQuotes = false
+			continue
+		}
+
+		if b == '"' {
+			quoted = true
+			inQuotes = true
+		} else if b == r.delimiter {
+			return fieldValue(value.String(), quoted), false, nil
+		} else if b == '\n' {
+			break
+		} else if b == '\r' {
+			next, err := r.reader.ReadByte()
+			if err == nil && next != '\n' {
+				_ = r.reader.UnreadByte()
+			}
+			break
+		} else {
+			value.WriteByte(b)
+		}
+	}
+	return fieldValue(value.String(), quoted), true, nil
+}
+
+func fieldValue(value string, quoted bool) *string {
+	if value == "" && !quoted {
+		return nil
+	}
+	return &value
+}
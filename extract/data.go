@@ -0,0 +1,203 @@
+package extract
+
+/*
+ * This file contains structs and functions related to extracting the data of a
+ * single table from the segment data files of a backup.
+ */
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/iohelper"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/restore"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+var (
+	// Must match the delimiter gpbackup uses when writing table data
+	tableDelim = ','
+
+	validFormats = []string{"csv", "parquet"}
+)
+
+func ValidateDataFlagCombinations(flags *pflag.FlagSet) {
+	err := utils.ValidateFQNs([]string{options.MustGetFlagString(flags, options.RELATION)})
+	gplog.FatalOnError(err)
+	format := options.MustGetFlagString(flags, options.FORMAT)
+	if !utils.Exists(validFormats, format) {
+		gplog.Fatal(errors.Errorf("Invalid format %s.  Valid formats are: %s", format, strings.Join(validFormats, ", ")), "")
+	}
+	if format == "parquet" && !flags.Changed(options.OUTPUT_FILE) {
+		gplog.Fatal(errors.Errorf("Cannot write parquet output without --output-file"), "")
+	}
+}
+
+func DoExtractData() {
+	fpInfo := GetBackupFPInfo()
+	tableFQN := MustGetFlagString(options.RELATION)
+
+	backupConfig := history.ReadConfigFile(fpInfo.GetConfigFilePath())
+	if backupConfig.MetadataOnly {
+		gplog.Fatal(errors.Errorf("Backup %s is a metadata-only backup and contains no data", fpInfo.Timestamp), "")
+	}
+	tocfile := toc.NewTOC(fpInfo.GetTOCFilePath())
+	dataEntries := GetDataEntriesForTable(tocfile, tableFQN)
+	if len(dataEntries) == 0 {
+		gplog.Fatal(errors.Errorf("Table %s has no data in backup %s", tableFQN, fpInfo.Timestamp), "")
+	}
+	contentIDs, err := filepath.ParseContentIDs(fpInfo.UserSpecifiedBackupDir, fpInfo.UserSpecifiedSegPrefix, fpInfo.Timestamp)
+	gplog.FatalOnError(err)
+
+	var output io.Writer = os.Stdout
+	outputFilename := MustGetFlagString(options.OUTPUT_FILE)
+	if outputFilename != "" {
+		outputFile := iohelper.MustOpenFileForWriting(outputFilename)
+		defer outputFile.Close()
+		output = outputFile
+	}
+	columns := ParseAttributeString(dataEntries[0].AttributeString)
+	tableWriter, err := NewTableDataWriter(MustGetFlagString(options.FORMAT), output, columns)
+	gplog.FatalOnError(err)
+
+	for _, entry := range dataEntries {
+		for _, contentID := range contentIDs {
+			reader, err := restore.OpenSegmentTableData(fpInfo.ForDataStream(entry.DataStream), backupConfig, contentID, entry.Oid)
+			gplog.FatalOnError(err)
+			err = tableWriter.WriteData(reader)
+			_ = reader.Close()
+			if err != nil {
+				gplog.Fatal(err, fmt.Sprintf("Unable to extract data for table %s on segment %d", utils.MakeFQN(entry.Schema, entry.Name), contentID))
+			}
+		}
+	}
+	gplog.FatalOnError(tableWriter.Close())
+	if outputFilename != "" {
+		gplog.Info("Extracted data for table %s from %d segment(s) to %s", tableFQN, len(contentIDs), outputFilename)
+	}
+}
+
+/*
+ * A partition table backed up with --leaf-partition-data has no data entry of
+ * its own, so its data is extracted from all of its leaf partitions.
+ */
+func GetDataEntriesForTable(tocfile *toc.TOC, tableFQN string) []toc.MasterDataEntry {
+	entries := make([]toc.MasterDataEntry, 0)
+	for _, entry := range tocfile.DataEntries {
+		if utils.MakeFQN(entry.Schema, entry.Name) == tableFQN {
+			return []toc.MasterDataEntry{entry}
+		}
+		if entry.PartitionRoot != "" && utils.MakeFQN(entry.Schema, entry.PartitionRoot) == tableFQN {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+/*
+ * The attribute string is the parenthesized, comma-separated list of quoted
+ * column names that is passed to COPY, e.g. (i,"Mixed Case",j).
+ */
+func ParseAttributeString(attributeString string) []string {
+	attributeString = strings.TrimSuffix(strings.TrimPrefix(attributeString, "("), ")")
+	columns := make([]string, 0)
+	if attributeString == "" {
+		return columns
+	}
+	var column strings.Builder
+	inQuotes := false
+	for i := 0; i < len(attributeString); i++ {
+		c := attributeString[i]
+		switch {
+		case c == '"' && inQuotes && i+1 < len(attributeString) && attributeString[i+1] == '"':
+			column.WriteByte('"')
+			i++
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == ',' && !inQuotes:
+			columns = append(columns, column.String())
+			column.Reset()
+		default:
+			column.WriteByte(c)
+		}
+	}
+	return append(columns, column.String())
+}
+
+type TableDataWriter interface {
+	WriteData(reader io.Reader) error
+	Close() error
+}
+
+func NewTableDataWriter(format string, output io.Writer, columns []string) (TableDataWriter, error) {
+	if format == "parquet" {
+		parquetWriter, err := NewParquetWriter(output, columns, fmt.Sprintf("gpbackup_extract version %s", GetVersion()))
+		if err != nil {
+			return nil, err
+		}
+		return &ParquetTableDataWriter{parquetWriter}, nil
+	}
+	return NewCSVTableDataWriter(output, columns)
+}
+
+/*
+ * Backup data files are already in CSV format, so after the header they can be
+ * copied to the output as-is.
+ */
+type CSVTableDataWriter struct {
+	output io.Writer
+}
+
+func NewCSVTableDataWriter(output io.Writer, columns []string) (*CSVTableDataWriter, error) {
+	headerWriter := csv.NewWriter(output)
+	headerWriter.Comma = tableDelim
+	err := headerWriter.Write(columns)
+	if err != nil {
+		return nil, err
+	}
+	headerWriter.Flush()
+	return &CSVTableDataWriter{output}, headerWriter.Error()
+}
+
+func (w *CSVTableDataWriter) WriteData(reader io.Reader) error {
+	_, err := io.Copy(w.output, reader)
+	return err
+}
+
+func (w *CSVTableDataWriter) Close() error {
+	return nil
+}
+
+type ParquetTableDataWriter struct {
+	writer *ParquetWriter
+}
+
+func (w *ParquetTableDataWriter) WriteData(reader io.Reader) error {
+	recordReader := NewCSVRecordReader(reader, byte(tableDelim))
+	for {
+		record, err := recordReader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		err = w.writer.WriteRow(record)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (w *ParquetTableDataWriter) Close() error {
+	return w.writer.Close()
+}
@@ -0,0 +1,190 @@
+package extract_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/extract"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/parquet-go/parquet-go"
+	"github.com/spf13/pflag"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func stringPtr(value string) *string {
+	return &value
+}
+
+var _ = Describe("extract/data tests", func() {
+	Describe("ParseAttributeString", func() {
+		It("returns the column names in an attribute string", func() {
+			Expect(extract.ParseAttributeString("(i,j,k)")).To(Equal([]string{"i", "j", "k"}))
+		})
+		It("removes quotes from quoted column names", func() {
+			Expect(extract.ParseAttributeString(`(i,"Mixed, Case","has ""quotes""")`)).To(Equal([]string{"i", "Mixed, Case", `has "quotes"`}))
+		})
+		It("returns no columns for an empty attribute string", func() {
+			Expect(extract.ParseAttributeString("")).To(BeEmpty())
+		})
+	})
+	Describe("GetDataEntriesForTable", func() {
+		tocfile := &toc.TOC{DataEntries: []toc.MasterDataEntry{
+			{Schema: "public", Name: "foo", Oid: 1},
+			{Schema: "public", Name: "part_1_prt_1", Oid: 2, PartitionRoot: "part"},
+			{Schema: "public", Name: "part_1_prt_2", Oid: 3, PartitionRoot: "part"},
+		}}
+		It("returns the data entry for a table", func() {
+			Expect(extract.GetDataEntriesForTable(tocfile, "public.foo")).To(Equal([]toc.MasterDataEntry{tocfile.DataEntries[0]}))
+		})
+		It("returns the data entries of all leaf partitions of a partition table", func() {
+			Expect(extract.GetDataEntriesForTable(tocfile, "public.part")).To(Equal(tocfile.DataEntries[1:]))
+		})
+		It("returns no entries for a table not in the backup", func() {
+			Expect(extract.GetDataEntriesForTable(tocfile, "public.missing")).To(BeEmpty())
+		})
+	})
+	Describe("CSVRecordReader", func() {
+		readAll := func(data string) [][]*string {
+			reader := extract.NewCSVRecordReader(strings.NewReader(data), ',')
+			records := make([][]*string, 0)
+			for {
+				record, err := reader.Read()
+				if err == io.EOF {
+					return records
+				}
+				Expect(err).ToNot(HaveOccurred())
+				records = append(records, record)
+			}
+		}
+		It("reads unquoted fields", func() {
+			Expect(readAll("1,abc\n2,def\n")).To(Equal([][]*string{
+				{stringPtr("1"), stringPtr("abc")},
+				{stringPtr("2"), stringPtr("def")},
+			}))
+		})
+		It("distinguishes NULL from an empty string", func() {
+			Expect(readAll("1,,\"\"\n")).To(Equal([][]*string{{stringPtr("1"), nil, stringPtr("")}}))
+		})
+		It("reads quoted fields containing delimiters, quotes, and newlines", func() {
+			Expect(readAll("\"a,b\",\"say \"\"hi\"\"\",\"two\nlines\"\n")).To(Equal([][]*string{
+				{stringPtr("a,b"), stringPtr(`say "hi"`), stringPtr("two\nlines")},
+			}))
+		})
+		It("reads an empty line as a single NULL field", func() {
+			Expect(readAll("\n1\n")).To(Equal([][]*string{{nil}, {stringPtr("1")}}))
+		})
+		It("reads a final record without a trailing newline", func() {
+			Expect(readAll("1,2")).To(Equal([][]*string{{stringPtr("1"), stringPtr("2")}}))
+		})
+		It("returns an error for an unterminated quoted field", func() {
+			reader := extract.NewCSVRecordReader(strings.NewReader("1,\"abc"), ',')
+			_, err := reader.Read()
+			Expect(err).To(MatchError("Unexpected end of data inside quoted CSV field"))
+		})
+	})
+	Describe("NewTableDataWriter", func() {
+		It("writes a CSV header followed by the data unchanged", func() {
+			output := &bytes.Buffer{}
+			writer, err := extract.NewTableDataWriter("csv", output, []string{"i", "Mixed, Case"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.WriteData(strings.NewReader("1,\"a\"\n"))).To(Succeed())
+			Expect(writer.WriteData(strings.NewReader("2,\n"))).To(Succeed())
+			Expect(writer.Close()).To(Succeed())
+			Expect(output.String()).To(Equal("i,\"Mixed, Case\"\n1,\"a\"\n2,\n"))
+		})
+		It("writes a parquet file with a footer", func() {
+			output := &bytes.Buffer{}
+			writer, err := extract.NewTableDataWriter("parquet", output, []string{"i", "j"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.WriteData(strings.NewReader("1,abc\n2,\n"))).To(Succeed())
+			Expect(writer.Close()).To(Succeed())
+
+			contents := output.Bytes()
+			Expect(string(contents[:4])).To(Equal("PAR1"))
+			Expect(string(contents[len(contents)-4:])).To(Equal("PAR1"))
+			footerLength := binary.LittleEndian.Uint32(contents[len(contents)-8 : len(contents)-4])
+			footer := contents[len(contents)-8-int(footerLength) : len(contents)-8]
+			Expect(bytes.Contains(footer, []byte("schema"))).To(BeTrue())
+			Expect(bytes.Contains(footer, []byte("gpbackup_extract version"))).To(BeTrue())
+			// Column values are written as length-prefixed strings, so "abc" is preceded by its length
+			Expect(bytes.Contains(contents, []byte{3, 0, 0, 0, 'a', 'b', 'c'})).To(BeTrue())
+		})
+		It("writes a parquet file that a parquet reader reads back as optional string columns", func() {
+			output := &bytes.Buffer{}
+			writer, err := extract.NewTableDataWriter("parquet", output, []string{"i", "Mixed, Case", "k"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.WriteData(strings.NewReader("1,abc,\"\"\n2,,\"x,\"\"y\"\"\"\n"))).To(Succeed())
+			Expect(writer.WriteData(strings.NewReader(",\"two\nlines\",\n"))).To(Succeed())
+			Expect(writer.Close()).To(Succeed())
+
+			file, err := parquet.OpenFile(bytes.NewReader(output.Bytes()), int64(output.Len()))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(file.NumRows()).To(Equal(int64(3)))
+			fields := file.Schema().Fields()
+			Expect(fields).To(HaveLen(3))
+			for i, name := range []string{"i", "Mixed, Case", "k"} {
+				Expect(fields[i].Name()).To(Equal(name))
+				Expect(fields[i].Optional()).To(BeTrue())
+				Expect(fields[i].Type().Kind()).To(Equal(parquet.ByteArray))
+				Expect(fields[i].Type().LogicalType().UTF8).ToNot(BeNil())
+			}
+
+			reader := parquet.NewReader(file)
+			defer reader.Close()
+			rows := make([]parquet.Row, 4)
+			n, err := reader.ReadRows(rows)
+			Expect(err).To(Equal(io.EOF))
+			values := make([][]*string, 0)
+			for _, row := range rows[:n] {
+				record := make([]*string, 0)
+				for _, value := range row {
+					if value.IsNull() {
+						record = append(record, nil)
+					} else {
+						record = append(record, stringPtr(string(value.ByteArray())))
+					}
+				}
+				values = append(values, record)
+			}
+			Expect(values).To(Equal([][]*string{
+				{stringPtr("1"), stringPtr("abc"), stringPtr("")},
+				{stringPtr("2"), nil, stringPtr(`x,"y"`)},
+				{nil, stringPtr("two\nlines"), nil},
+			}))
+		})
+		It("returns an error when a row does not match the number of columns", func() {
+			writer, _ := extract.NewTableDataWriter("parquet", &bytes.Buffer{}, []string{"i", "j"})
+			err := writer.WriteData(strings.NewReader("1,2,3\n"))
+			Expect(err).To(MatchError("Row has 3 fields, but table has 2 columns"))
+		})
+	})
+	Describe("ValidateDataFlagCombinations", func() {
+		var dataFlags *pflag.FlagSet
+		BeforeEach(func() {
+			dataFlags = pflag.NewFlagSet("data", pflag.ExitOnError)
+			options.SetExtractDataFlagDefaults(dataFlags)
+			_ = dataFlags.Set(options.RELATION, "public.foo")
+		})
+		It("panics if the table is not fully qualified", func() {
+			_ = dataFlags.Set(options.RELATION, "foo")
+			defer testhelper.ShouldPanicWithMessage(`Table "foo" is not correctly fully-qualified.`)
+			extract.ValidateDataFlagCombinations(dataFlags)
+		})
+		It("panics if the format is invalid", func() {
+			_ = dataFlags.Set(options.FORMAT, "json")
+			defer testhelper.ShouldPanicWithMessage("Invalid format json.  Valid formats are: csv, parquet")
+			extract.ValidateDataFlagCombinations(dataFlags)
+		})
+		It("panics if parquet output is requested without an output file", func() {
+			_ = dataFlags.Set(options.FORMAT, "parquet")
+			defer testhelper.ShouldPanicWithMessage("Cannot write parquet output without --output-file")
+			extract.ValidateDataFlagCombinations(dataFlags)
+		})
+	})
+})
@@ -0,0 +1,97 @@
+package extract
+
+/*
+ * This file contains the subcommands of gpbackup_extract.  The tool works
+ * directly against backup files on disk and never connects to a database, so
+ * it can be used on any host that can read the backup directory.
+ */
+
+import (
+	"os"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func DoInit(cmd *cobra.Command) {
+	gplog.InitializeLogging("gpbackup_extract", "")
+	cmd.AddCommand(newMetadataCommand(), newDataCommand())
+}
+
+func newMetadataCommand() *cobra.Command {
+	metadataCmd := &cobra.Command{
+		Use:   "metadata",
+		Short: "Write a SQL script containing metadata from a backup",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			SetCmdFlags(cmd.Flags())
+			DoValidation()
+			ValidateMetadataFlagCombinations(cmd.Flags())
+			DoExtractMetadata()
+		},
+	}
+	options.SetExtractMetadataFlagDefaults(metadataCmd.Flags())
+	_ = metadataCmd.MarkFlagRequired(options.BACKUP_DIR)
+	_ = metadataCmd.MarkFlagRequired(options.TIMESTAMP)
+	return metadataCmd
+}
+
+func newDataCommand() *cobra.Command {
+	dataCmd := &cobra.Command{
+		Use:   "data",
+		Short: "Write the data of a single table from a backup to a CSV or Parquet file",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			SetCmdFlags(cmd.Flags())
+			DoValidation()
+			ValidateDataFlagCombinations(cmd.Flags())
+			DoExtractData()
+		},
+	}
+	options.SetExtractDataFlagDefaults(dataCmd.Flags())
+	_ = dataCmd.MarkFlagRequired(options.BACKUP_DIR)
+	_ = dataCmd.MarkFlagRequired(options.TIMESTAMP)
+	_ = dataCmd.MarkFlagRequired(options.RELATION)
+	return dataCmd
+}
+
+/*
+ * Validation common to all subcommands
+ */
+func DoValidation() {
+	SetLoggerVerbosity()
+	gplog.Verbose("Extract Command: %s", os.Args)
+	options.CheckExclusiveFlags(cmdFlags, options.DEBUG, options.QUIET, options.VERBOSE)
+	err := utils.ValidateFullPath(MustGetFlagString(options.BACKUP_DIR))
+	gplog.FatalOnError(err)
+	if !filepath.IsValidTimestamp(MustGetFlagString(options.TIMESTAMP)) {
+		gplog.Fatal(errors.Errorf("Timestamp %s is invalid.  Timestamps must be in the format YYYYMMDDHHMMSS.", MustGetFlagString(options.TIMESTAMP)), "")
+	}
+}
+
+func SetLoggerVerbosity() {
+	if MustGetFlagBool(options.QUIET) {
+		gplog.SetVerbosity(gplog.LOGERROR)
+	} else if MustGetFlagBool(options.DEBUG) {
+		gplog.SetVerbosity(gplog.LOGDEBUG)
+	} else if MustGetFlagBool(options.VERBOSE) {
+		gplog.SetVerbosity(gplog.LOGVERBOSE)
+	}
+}
+
+/*
+ * There is no cluster to query for segment data directories, so backup files
+ * are always located relative to the user-specified backup directory.
+ */
+func GetBackupFPInfo() filepath.FilePathInfo {
+	backupDir := MustGetFlagString(options.BACKUP_DIR)
+	timestamp := MustGetFlagString(options.TIMESTAMP)
+	segPrefix, err := filepath.ParseSegPrefix(backupDir, timestamp)
+	gplog.FatalOnError(err)
+	return filepath.NewFilePathInfo(&cluster.Cluster{}, backupDir, timestamp, segPrefix)
+}
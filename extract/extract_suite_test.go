@@ -0,0 +1,28 @@
+package extract_test
+
+import (
+	"testing"
+
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/extract"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/spf13/pflag"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestExtract(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "extract tests")
+}
+
+var cmdFlags *pflag.FlagSet
+
+var _ = BeforeEach(func() {
+	_, _, _ = testhelper.SetupTestLogger()
+
+	cmdFlags = pflag.NewFlagSet("metadata", pflag.ExitOnError)
+	options.SetExtractMetadataFlagDefaults(cmdFlags)
+	extract.SetCmdFlags(cmdFlags)
+})
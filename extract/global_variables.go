@@ -0,0 +1,58 @@
+package extract
+
+import (
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/spf13/pflag"
+)
+
+/*
+ * This file contains global variables and setter functions for those variables
+ * used in testing.
+ */
+
+/*
+ * Non-flag variables
+ */
+
+var (
+	version string
+)
+
+/*
+ * Command-line flags
+ */
+var cmdFlags *pflag.FlagSet
+
+/*
+ * Setter functions
+ */
+
+func SetCmdFlags(flagSet *pflag.FlagSet) {
+	cmdFlags = flagSet
+}
+
+// Util functions to enable ease of access to global flag values
+
+func MustGetFlagString(flagName string) string {
+	return options.MustGetFlagString(cmdFlags, flagName)
+}
+
+func MustGetFlagInt(flagName string) int {
+	return options.MustGetFlagInt(cmdFlags, flagName)
+}
+
+func MustGetFlagBool(flagName string) bool {
+	return options.MustGetFlagBool(cmdFlags, flagName)
+}
+
+func MustGetFlagStringArray(flagName string) []string {
+	return options.MustGetFlagStringArray(cmdFlags, flagName)
+}
+
+func GetVersion() string {
+	return version
+}
+
+func SetVersion(v string) {
+	version = v
+}
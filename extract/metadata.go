@@ -0,0 +1,169 @@
+package extract
+
+/*
+ * This file contains structs and functions related to extracting metadata
+ * statements from a backup into a standalone SQL script.
+ */
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/iohelper"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// Sections are always written in the order in which gprestore would restore them
+var validSections = []string{"global", "predata", "postdata", "statistics"}
+
+func ValidateMetadataFlagCombinations(flags *pflag.FlagSet) {
+	options.CheckExclusiveFlags(flags, options.INCLUDE_OBJECT_TYPE, options.EXCLUDE_OBJECT_TYPE)
+	options.CheckExclusiveFlags(flags, options.INCLUDE_SCHEMA, options.INCLUDE_SCHEMA_FILE, options.INCLUDE_RELATION, options.INCLUDE_RELATION_FILE)
+	options.CheckExclusiveFlags(flags, options.EXCLUDE_SCHEMA, options.EXCLUDE_SCHEMA_FILE, options.INCLUDE_SCHEMA, options.INCLUDE_SCHEMA_FILE)
+	options.CheckExclusiveFlags(flags, options.EXCLUDE_SCHEMA, options.EXCLUDE_SCHEMA_FILE, options.EXCLUDE_RELATION, options.INCLUDE_RELATION, options.EXCLUDE_RELATION_FILE, options.INCLUDE_RELATION_FILE)
+
+	if flags.Changed(options.REDIRECT_SCHEMA) {
+		if flags.Changed(options.EXCLUDE_SCHEMA) || flags.Changed(options.EXCLUDE_SCHEMA_FILE) ||
+			flags.Changed(options.EXCLUDE_RELATION) || flags.Changed(options.EXCLUDE_RELATION_FILE) {
+			gplog.Fatal(errors.Errorf("Cannot use --redirect-schema with exclude flags"), "")
+		}
+		if !(flags.Changed(options.INCLUDE_RELATION) || flags.Changed(options.INCLUDE_RELATION_FILE) ||
+			flags.Changed(options.INCLUDE_SCHEMA) || flags.Changed(options.INCLUDE_SCHEMA_FILE)) {
+			gplog.Fatal(errors.Errorf("Cannot use --redirect-schema without --include-table, --include-table-file, --include-schema, or --include-schema-file"), "")
+		}
+	}
+
+	sections, err := flags.GetStringArray(options.SECTION)
+	gplog.FatalOnError(err)
+	for _, section := range sections {
+		if !utils.Exists(validSections, section) {
+			gplog.Fatal(errors.Errorf("Invalid section %s.  Valid sections are: %s", section, strings.Join(validSections, ", ")), "")
+		}
+	}
+
+	validObjectTypes := append(append(append(append([]string{}, toc.GlobalObjectTypes...), toc.PredataObjectTypes...),
+		toc.PostdataObjectTypes...), toc.StatisticsObjectTypes...)
+	for _, flagName := range []string{options.INCLUDE_OBJECT_TYPE, options.EXCLUDE_OBJECT_TYPE} {
+		objectTypes, err := flags.GetStringArray(flagName)
+		gplog.FatalOnError(err)
+		gplog.FatalOnError(toc.ValidateObjectTypes(objectTypes, validObjectTypes))
+	}
+}
+
+func DoExtractMetadata() {
+	fpInfo := GetBackupFPInfo()
+	opts, err := options.NewOptions(cmdFlags)
+	gplog.FatalOnError(err)
+
+	backupConfig := history.ReadConfigFile(fpInfo.GetConfigFilePath())
+	if backupConfig.DataOnly {
+		gplog.Fatal(errors.Errorf("Backup %s is a data-only backup and contains no metadata", fpInfo.Timestamp), "")
+	}
+	tocfile := toc.NewTOC(fpInfo.GetTOCFilePath())
+	tocfile.InitializeMetadataEntryMap()
+
+	var output io.Writer = os.Stdout
+	if outputFilename := MustGetFlagString(options.OUTPUT_FILE); outputFilename != "" {
+		outputFile := iohelper.MustOpenFileForWriting(outputFilename)
+		defer outputFile.Close()
+		output = outputFile
+	}
+
+	requestedSections := MustGetFlagStringArray(options.SECTION)
+	_, err = fmt.Fprintf(output, "--\n-- Metadata extracted from backup %s of database %s\n--\n", fpInfo.Timestamp, backupConfig.DatabaseName)
+	gplog.FatalOnError(err)
+
+	metadataFile := iohelper.MustOpenFileForReading(fpInfo.GetMetadataFilePath())
+	defer metadataFile.Close()
+	if !utils.Exists(requestedSections, "global") {
+		// Always carry over the session settings, so the script loads with the same encoding it was written in
+		sessionStatements := tocfile.GetSQLStatementForObjectTypes("global", metadataFile, []string{"SESSION GUCS"}, []string{}, []string{}, []string{}, []string{}, []string{})
+		WriteMetadataScript(output, "", sessionStatements)
+	}
+
+	numStatements := 0
+	for _, section := range validSections {
+		if !utils.Exists(requestedSections, section) {
+			continue
+		}
+		var statements []toc.StatementWithType
+		if section == "statistics" {
+			if !backupConfig.WithStatistics {
+				gplog.Warn("Backup %s does not contain statistics; skipping statistics section", fpInfo.Timestamp)
+				continue
+			}
+			statisticsFile := iohelper.MustOpenFileForReading(fpInfo.GetStatisticsFilePath())
+			statements = GetMetadataStatements(tocfile, section, statisticsFile, opts)
+			statisticsFile.Close()
+		} else {
+			statements = GetMetadataStatements(tocfile, section, metadataFile, opts)
+		}
+		WriteMetadataScript(output, section, statements)
+		numStatements += len(statements)
+	}
+	gplog.Info("Extracted %d metadata statement(s) from backup %s", numStatements, fpInfo.Timestamp)
+}
+
+/*
+ * Filtering follows the same rules as gprestore: partition roots of included
+ * leaf partitions are pulled in, schemas of included relations are created
+ * before anything else in the pre-data section, and no schemas are created
+ * when redirecting to a different schema.
+ */
+func GetMetadataStatements(tocfile *toc.TOC, section string, metadataFile io.ReaderAt, opts *options.Options) []toc.StatementWithType {
+	includeObjectTypes := MustGetFlagStringArray(options.INCLUDE_OBJECT_TYPE)
+	excludeObjectTypes := MustGetFlagStringArray(options.EXCLUDE_OBJECT_TYPE)
+
+	inRelations := opts.IncludedRelations
+	if len(inRelations) > 0 {
+		inRelations = append(inRelations, toc.GetIncludedPartitionRoots(tocfile.DataEntries, inRelations)...)
+	}
+
+	var schemaStatements []toc.StatementWithType
+	schemaRequested := (len(includeObjectTypes) == 0 || utils.Exists(includeObjectTypes, "SCHEMA")) && !utils.Exists(excludeObjectTypes, "SCHEMA")
+	if section == "predata" && len(inRelations) > 0 && opts.RedirectSchema == "" && schemaRequested {
+		relationSchemas := make([]string, 0)
+		for _, inRelation := range inRelations {
+			schema := inRelation[:strings.Index(inRelation, ".")]
+			if !utils.Exists(relationSchemas, schema) {
+				relationSchemas = append(relationSchemas, schema)
+			}
+		}
+		schemaStatements = tocfile.GetSQLStatementForObjectTypes(section, metadataFile, []string{"SCHEMA"}, []string{}, relationSchemas, []string{}, []string{}, []string{})
+	}
+
+	statements := tocfile.GetSQLStatementForObjectTypes(section, metadataFile, includeObjectTypes, excludeObjectTypes,
+		opts.IncludedSchemas, opts.ExcludedSchemas, inRelations, opts.ExcludedRelations)
+	if opts.RedirectSchema != "" {
+		redirectedStatements := make([]toc.StatementWithType, 0)
+		for _, statement := range statements {
+			if statement.ObjectType != "SCHEMA" {
+				redirectedStatements = append(redirectedStatements, statement)
+			}
+		}
+		statements = toc.SubstituteRedirectSchemaInStatements(redirectedStatements, opts.RedirectSchema)
+	}
+	return append(schemaStatements, statements...)
+}
+
+func WriteMetadataScript(output io.Writer, section string, statements []toc.StatementWithType) {
+	if len(statements) == 0 {
+		return
+	}
+	if section != "" {
+		_, err := fmt.Fprintf(output, "\n--\n-- %s\n--\n", section)
+		gplog.FatalOnError(err)
+	}
+	for _, statement := range statements {
+		_, err := io.WriteString(output, statement.Statement)
+		gplog.FatalOnError(err)
+	}
+}
@@ -0,0 +1,131 @@
+package extract_test
+
+import (
+	"bytes"
+
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/extract"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/testutils"
+	"github.com/greenplum-db/gpbackup/toc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("extract/metadata tests", func() {
+	var (
+		tocfile      *toc.TOC
+		metadataFile *bytes.Reader
+	)
+	schema1 := "\n\nCREATE SCHEMA schema1;\n"
+	schema2 := "\n\nCREATE SCHEMA schema2;\n"
+	table1 := "\n\nCREATE TABLE schema1.table1 (\n\ti integer\n) DISTRIBUTED BY (i);\n"
+	table2 := "\n\nCREATE TABLE schema2.table2 (\n\ti integer\n) DISTRIBUTED BY (i);\n"
+	function1 := "\n\nCREATE FUNCTION schema1.func1() RETURNS integer AS $$SELECT 1$$ LANGUAGE sql;\n"
+	index1 := "\n\nCREATE INDEX idx1 ON schema1.table1 USING btree (i);\n"
+
+	BeforeEach(func() {
+		tocfile, _ = testutils.InitializeTestTOC(NewBuffer(), "metadata")
+		contents := ""
+		addEntry := func(section string, entry toc.MetadataEntry, statement string) {
+			start := uint64(len(contents))
+			contents += statement
+			tocfile.AddMetadataEntry(section, entry, start, uint64(len(contents)))
+		}
+		addEntry("predata", toc.MetadataEntry{Schema: "schema1", Name: "schema1", ObjectType: "SCHEMA"}, schema1)
+		addEntry("predata", toc.MetadataEntry{Schema: "schema2", Name: "schema2", ObjectType: "SCHEMA"}, schema2)
+		addEntry("predata", toc.MetadataEntry{Schema: "schema1", Name: "func1", ObjectType: "FUNCTION"}, function1)
+		addEntry("predata", toc.MetadataEntry{Schema: "schema1", Name: "table1", ObjectType: "TABLE"}, table1)
+		addEntry("predata", toc.MetadataEntry{Schema: "schema2", Name: "table2", ObjectType: "TABLE"}, table2)
+		addEntry("postdata", toc.MetadataEntry{Schema: "schema1", Name: "idx1", ObjectType: "INDEX", ReferenceObject: "schema1.table1"}, index1)
+		metadataFile = bytes.NewReader([]byte(contents))
+	})
+	getStatements := func(section string) []string {
+		opts, err := options.NewOptions(cmdFlags)
+		Expect(err).ToNot(HaveOccurred())
+		statements := extract.GetMetadataStatements(tocfile, section, metadataFile, opts)
+		result := make([]string, len(statements))
+		for i, statement := range statements {
+			result[i] = statement.Statement
+		}
+		return result
+	}
+	Describe("GetMetadataStatements", func() {
+		It("returns all statements in a section when no filters are set", func() {
+			Expect(getStatements("predata")).To(Equal([]string{schema1, schema2, function1, table1, table2}))
+			Expect(getStatements("postdata")).To(Equal([]string{index1}))
+		})
+		It("returns only statements of the included object types", func() {
+			_ = cmdFlags.Set(options.INCLUDE_OBJECT_TYPE, "FUNCTION")
+			Expect(getStatements("predata")).To(Equal([]string{function1}))
+		})
+		It("returns statements except those of the excluded object types", func() {
+			_ = cmdFlags.Set(options.EXCLUDE_OBJECT_TYPE, "TABLE")
+			_ = cmdFlags.Set(options.EXCLUDE_OBJECT_TYPE, "SCHEMA")
+			Expect(getStatements("predata")).To(Equal([]string{function1}))
+		})
+		It("returns only statements in the included schema", func() {
+			_ = cmdFlags.Set(options.INCLUDE_SCHEMA, "schema2")
+			Expect(getStatements("predata")).To(Equal([]string{schema2, table2}))
+		})
+		It("returns the schema of an included relation before the relation itself", func() {
+			_ = cmdFlags.Set(options.INCLUDE_RELATION, "schema1.table1")
+			Expect(getStatements("predata")).To(Equal([]string{schema1, table1}))
+			Expect(getStatements("postdata")).To(Equal([]string{index1}))
+		})
+		It("does not return the schema of an included relation when schemas are excluded", func() {
+			_ = cmdFlags.Set(options.INCLUDE_RELATION, "schema1.table1")
+			_ = cmdFlags.Set(options.EXCLUDE_OBJECT_TYPE, "SCHEMA")
+			Expect(getStatements("predata")).To(Equal([]string{table1}))
+		})
+		It("rewrites statements to the redirect schema and omits schema creation", func() {
+			_ = cmdFlags.Set(options.INCLUDE_RELATION, "schema1.table1")
+			_ = cmdFlags.Set(options.REDIRECT_SCHEMA, "newschema")
+			Expect(getStatements("predata")).To(Equal([]string{"\n\nCREATE TABLE newschema.table1 (\n\ti integer\n) DISTRIBUTED BY (i);\n"}))
+			Expect(getStatements("postdata")).To(Equal([]string{"\n\nCREATE INDEX idx1 ON newschema.table1 USING btree (i);\n"}))
+		})
+	})
+	Describe("WriteMetadataScript", func() {
+		It("writes a section header followed by each statement", func() {
+			output := NewBuffer()
+			statements := []toc.StatementWithType{{Statement: schema1}, {Statement: table1}}
+			extract.WriteMetadataScript(output, "predata", statements)
+			Expect(string(output.Contents())).To(Equal("\n--\n-- predata\n--\n" + schema1 + table1))
+		})
+		It("writes nothing for a section with no statements", func() {
+			output := NewBuffer()
+			extract.WriteMetadataScript(output, "postdata", []toc.StatementWithType{})
+			Expect(output.Contents()).To(BeEmpty())
+		})
+	})
+	Describe("ValidateMetadataFlagCombinations", func() {
+		It("accepts valid sections", func() {
+			_ = cmdFlags.Set(options.SECTION, "global")
+			_ = cmdFlags.Set(options.SECTION, "statistics")
+			extract.ValidateMetadataFlagCombinations(cmdFlags)
+		})
+		It("panics if an invalid section is specified", func() {
+			_ = cmdFlags.Set(options.SECTION, "data")
+			defer testhelper.ShouldPanicWithMessage("Invalid section data.  Valid sections are: global, predata, postdata, statistics")
+			extract.ValidateMetadataFlagCombinations(cmdFlags)
+		})
+		It("panics if both include and exclude object types are specified", func() {
+			_ = cmdFlags.Set(options.INCLUDE_OBJECT_TYPE, "TABLE")
+			_ = cmdFlags.Set(options.EXCLUDE_OBJECT_TYPE, "VIEW")
+			defer testhelper.ShouldPanicWithMessage("The following flags may not be specified together: include-object-type, exclude-object-type")
+			extract.ValidateMetadataFlagCombinations(cmdFlags)
+		})
+		It("panics if an invalid object type is specified", func() {
+			_ = cmdFlags.Set(options.EXCLUDE_OBJECT_TYPE, "FUNCTIONS")
+			defer testhelper.ShouldPanicWithMessage("Invalid object type FUNCTIONS.  Valid object types are: SESSION GUCS, DATABASE GUC")
+			extract.ValidateMetadataFlagCombinations(cmdFlags)
+		})
+		It("panics if redirect schema is specified without an include flag", func() {
+			_ = cmdFlags.Set(options.REDIRECT_SCHEMA, "newschema")
+			defer testhelper.ShouldPanicWithMessage("Cannot use --redirect-schema without --include-table, --include-table-file, --include-schema, or --include-schema-file")
+			extract.ValidateMetadataFlagCombinations(cmdFlags)
+		})
+	})
+})
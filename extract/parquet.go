@@ -0,0 +1,321 @@
+package extract
+
+/*
+ * This file contains a minimal Parquet writer.  Backup data files are CSV and
+ * carry no type information beyond the column names, so every column is written
+ * as an optional UTF8 string using PLAIN encoding and no compression, which any
+ * Parquet reader can load and cast as needed.
+ *
+ * See https://github.com/apache/parquet-format for the file format and the
+ * Thrift compact protocol used for its metadata structures.
+ */
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	parquetMagic = "PAR1"
+
+	// Row groups are flushed when either limit is reached
+	parquetRowGroupMaxRows  = 1000000
+	parquetRowGroupMaxBytes = 64 * 1024 * 1024
+
+	parquetTypeByteArray      = 6
+	parquetRepetitionOptional = 1
+	parquetConvertedTypeUTF8  = 0
+	parquetEncodingPlain      = 0
+	parquetEncodingRLE        = 3
+	parquetCodecUncompressed  = 0
+	parquetPageTypeDataPage   = 0
+)
+
+type parquetColumnChunk struct {
+	fileOffset int64
+	numValues  int64
+	totalSize  int64
+}
+
+type parquetRowGroup struct {
+	columns       []parquetColumnChunk
+	totalByteSize int64
+	numRows       int64
+}
+
+type ParquetWriter struct {
+	writer        io.Writer
+	offset        int64
+	columns       []string
+	createdBy     string
+	values        [][]*string
+	bufferedRows  int64
+	bufferedBytes int64
+	rowGroups     []parquetRowGroup
+	numRows       int64
+}
+
+func NewParquetWriter(writer io.Writer, columns []string, createdBy string) (*ParquetWriter, error) {
+	pw := &ParquetWriter{writer: writer, columns: columns, createdBy: createdBy}
+	pw.values = make([][]*string, len(columns))
+	if err := pw.write([]byte(parquetMagic)); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+func (pw *ParquetWriter) write(data []byte) error {
+	n, err := pw.writer.Write(data)
+	pw.offset += int64(n)
+	return err
+}
+
+func (pw *ParquetWriter) WriteRow(row []*string) error {
+	if len(row) != len(pw.columns) {
+		return fmt.Errorf("Row has %d fields, but table has %d columns", len(row), len(pw.columns))
+	}
+	for i, value := range row {
+		pw.values[i] = append(pw.values[i], value)
+		if value != nil {
+			pw.bufferedBytes += int64(len(*value))
+		}
+	}
+	pw.bufferedRows++
+	if pw.bufferedRows >= parquetRowGroupMaxRows || pw.bufferedBytes >= parquetRowGroupMaxBytes {
+		return pw.flushRowGroup()
+	}
+	return nil
+}
+
+func (pw *ParquetWriter) flushRowGroup() error {
+	if pw.bufferedRows == 0 {
+		return nil
+	}
+	rowGroup := parquetRowGroup{numRows: pw.bufferedRows}
+	for i := range pw.columns {
+		chunk, err := pw.writeColumnChunk(pw.values[i])
+		if err != nil {
+			return err
+		}
+		rowGroup.columns = append(rowGroup.columns, chunk)
+		rowGroup.totalByteSize += chunk.totalSize
+		pw.values[i] = pw.values[i][:0]
+	}
+	pw.rowGroups = append(pw.rowGroups, rowGroup)
+	pw.numRows += pw.bufferedRows
+	pw.bufferedRows = 0
+	pw.bufferedBytes = 0
+	return nil
+}
+
+/*
+ * Each column chunk is written as a single data page: the definition levels
+ * (0 for NULL, 1 for a value) using the RLE hybrid encoding, followed by the
+ * non-NULL values using PLAIN encoding.
+ */
+func (pw *ParquetWriter) writeColumnChunk(values []*string) (parquetColumnChunk, error) {
+	var levels bytes.Buffer
+	for i := 0; i < len(values); {
+		level := byte(0)
+		if values[i] != nil {
+			level = 1
+		}
+		runLength := 1
+		for i+runLength < len(values) && (values[i+runLength] != nil) == (level == 1) {
+			runLength++
+		}
+		writeUvarint(&levels, uint64(runLength)<<1)
+		levels.WriteByte(level)
+		i += runLength
+	}
+
+	var page bytes.Buffer
+	_ = binary.Write(&page, binary.LittleEndian, uint32(levels.Len()))
+	page.Write(levels.Bytes())
+	for _, value := range values {
+		if value != nil {
+			_ = binary.Write(&page, binary.LittleEndian, uint32(len(*value)))
+			page.WriteString(*value)
+		}
+	}
+
+	header := newThriftCompactWriter()
+	header.i32Field(1, parquetPageTypeDataPage)
+	header.i32Field(2, int32(page.Len()))
+	header.i32Field(3, int32(page.Len()))
+	header.structField(5)
+	header.i32Field(1, int32(len(values)))
+	header.i32Field(2, parquetEncodingPlain)
+	header.i32Field(3, parquetEncodingRLE)
+	header.i32Field(4, parquetEncodingRLE)
+	header.structEnd()
+	header.structEnd()
+
+	chunk := parquetColumnChunk{fileOffset: pw.offset, numValues: int64(len(values))}
+	if err := pw.write(header.bytes()); err != nil {
+		return chunk, err
+	}
+	if err := pw.write(page.Bytes()); err != nil {
+		return chunk, err
+	}
+	chunk.totalSize = pw.offset - chunk.fileOffset
+	return chunk, nil
+}
+
+func (pw *ParquetWriter) Close() error {
+	if err := pw.flushRowGroup(); err != nil {
+		return err
+	}
+
+	footer := newThriftCompactWriter()
+	footer.i32Field(1, 1)
+	footer.listField(2, thriftTypeStruct, len(pw.columns)+1)
+	footer.structBegin()
+	footer.binaryField(4, []byte("schema"))
+	footer.i32Field(5, int32(len(pw.columns)))
+	footer.structEnd()
+	for _, column := range pw.columns {
+		footer.structBegin()
+		footer.i32Field(1, parquetTypeByteArray)
+		footer.i32Field(3, parquetRepetitionOptional)
+		footer.binaryField(4, []byte(column))
+		footer.i32Field(6, parquetConvertedTypeUTF8)
+		footer.structEnd()
+	}
+	footer.i64Field(3, pw.numRows)
+	footer.listField(4, thriftTypeStruct, len(pw.rowGroups))
+	for _, rowGroup := range pw.rowGroups {
+		footer.structBegin()
+		footer.listField(1, thriftTypeStruct, len(rowGroup.columns))
+		for i, chunk := range rowGroup.columns {
+			footer.structBegin()
+			footer.i64Field(2, chunk.fileOffset)
+			footer.structField(3)
+			footer.i32Field(1, parquetTypeByteArray)
+			footer.listField(2, thriftTypeI32, 2)
+			footer.i32Value(parquetEncodingPlain)
+			footer.i32Value(parquetEncodingRLE)
+			footer.listField(3, thriftTypeBinary, 1)
+			footer.binaryValue([]byte(pw.columns[i]))
+			footer.i32Field(4, parquetCodecUncompressed)
+			footer.i64Field(5, chunk.numValues)
+			footer.i64Field(6, chunk.totalSize)
+			footer.i64Field(7, chunk.totalSize)
+			footer.i64Field(9, chunk.fileOffset)
+			footer.structEnd()
+			footer.structEnd()
+		}
+		footer.i64Field(2, rowGroup.totalByteSize)
+		footer.i64Field(3, rowGroup.numRows)
+		footer.structEnd()
+	}
+	footer.binaryField(6, []byte(pw.createdBy))
+	footer.structEnd()
+
+	footerBytes := footer.bytes()
+	if err := pw.write(footerBytes); err != nil {
+		return err
+	}
+	var trailer bytes.Buffer
+	_ = binary.Write(&trailer, binary.LittleEndian, uint32(len(footerBytes)))
+	trailer.WriteString(parquetMagic)
+	return pw.write(trailer.Bytes())
+}
+
+/*
+ * Thrift compact protocol encoding, limited to the types Parquet metadata needs
+ */
+
+const (
+	thriftTypeI32    = 5
+	thriftTypeI64    = 6
+	thriftTypeBinary = 8
+	thriftTypeList   = 9
+	thriftTypeStruct = 12
+)
+
+type thriftCompactWriter struct {
+	buffer bytes.Buffer
+	// The ID of the last field written in each enclosing struct, since field IDs are delta-encoded
+	lastFieldIDs []int16
+}
+
+func newThriftCompactWriter() *thriftCompactWriter {
+	return &thriftCompactWriter{lastFieldIDs: []int16{0}}
+}
+
+func (t *thriftCompactWriter) bytes() []byte {
+	return t.buffer.Bytes()
+}
+
+func (t *thriftCompactWriter) fieldHeader(id int16, fieldType byte) {
+	last := &t.lastFieldIDs[len(t.lastFieldIDs)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buffer.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		t.buffer.WriteByte(fieldType)
+		writeUvarint(&t.buffer, zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (t *thriftCompactWriter) i32Field(id int16, value int32) {
+	t.fieldHeader(id, thriftTypeI32)
+	t.i32Value(value)
+}
+
+func (t *thriftCompactWriter) i32Value(value int32) {
+	writeUvarint(&t.buffer, zigzag(int64(value)))
+}
+
+func (t *thriftCompactWriter) i64Field(id int16, value int64) {
+	t.fieldHeader(id, thriftTypeI64)
+	writeUvarint(&t.buffer, zigzag(value))
+}
+
+func (t *thriftCompactWriter) binaryField(id int16, value []byte) {
+	t.fieldHeader(id, thriftTypeBinary)
+	t.binaryValue(value)
+}
+
+func (t *thriftCompactWriter) binaryValue(value []byte) {
+	writeUvarint(&t.buffer, uint64(len(value)))
+	t.buffer.Write(value)
+}
+
+func (t *thriftCompactWriter) listField(id int16, elementType byte, size int) {
+	t.fieldHeader(id, thriftTypeList)
+	if size < 15 {
+		t.buffer.WriteByte(byte(size)<<4 | elementType)
+	} else {
+		t.buffer.WriteByte(0xF0 | elementType)
+		writeUvarint(&t.buffer, uint64(size))
+	}
+}
+
+func (t *thriftCompactWriter) structField(id int16) {
+	t.fieldHeader(id, thriftTypeStruct)
+	t.structBegin()
+}
+
+// Begins a struct that is not itself a field, such as a list element
+func (t *thriftCompactWriter) structBegin() {
+	t.lastFieldIDs = append(t.lastFieldIDs, 0)
+}
+
+func (t *thriftCompactWriter) structEnd() {
+	t.buffer.WriteByte(0)
+	t.lastFieldIDs = t.lastFieldIDs[:len(t.lastFieldIDs)-1]
+}
+
+func zigzag(value int64) uint64 {
+	return uint64((value << 1) ^ (value >> 63))
+}
+
+func writeUvarint(buffer *bytes.Buffer, value uint64) {
+	var encoded [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(encoded[:], value)
+	buffer.Write(encoded[:n])
+}
@@ -11,6 +11,7 @@ import (
 	"os"
 	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -26,6 +27,7 @@ type FilePathInfo struct {
 	Timestamp              string
 	UserSpecifiedBackupDir string
 	UserSpecifiedSegPrefix string
+	DataStream             int
 }
 
 func NewFilePathInfo(c *cluster.Cluster, userSpecifiedBackupDir string, timestamp string, userSegPrefix string) FilePathInfo {
@@ -51,6 +53,24 @@ func IsValidTimestamp(timestamp string) bool {
 	return timestampFormat.MatchString(timestamp)
 }
 
+/*
+ * A single data file backup with several data streams per segment has one
+ * gpbackup_helper agent per stream, each with its own data file, segment TOC,
+ * pipes, and helper files.  The files of the first stream have the same names
+ * as those of a backup with only one stream.
+ */
+func (backupFPInfo FilePathInfo) ForDataStream(stream int) FilePathInfo {
+	backupFPInfo.DataStream = stream
+	return backupFPInfo
+}
+
+func (backupFPInfo *FilePathInfo) segmentFilePrefix() string {
+	if backupFPInfo.DataStream > 0 {
+		return fmt.Sprintf("gpbackup_<SEGID>_%s_stream%d", backupFPInfo.Timestamp, backupFPInfo.DataStream)
+	}
+	return fmt.Sprintf("gpbackup_<SEGID>_%s", backupFPInfo.Timestamp)
+}
+
 func (backupFPInfo *FilePathInfo) IsUserSpecifiedBackupDir() bool {
 	return backupFPInfo.UserSpecifiedBackupDir != ""
 }
@@ -74,7 +94,7 @@ func (backupFPInfo *FilePathInfo) GetSegmentPipeFilePath(contentID int) string {
 }
 
 func (backupFPInfo *FilePathInfo) GetSegmentPipePathForCopyCommand() string {
-	return fmt.Sprintf("<SEG_DATA_DIR>/gpbackup_<SEGID>_%s_pipe_%d", backupFPInfo.Timestamp, backupFPInfo.PID)
+	return fmt.Sprintf("<SEG_DATA_DIR>/%s_pipe_%d", backupFPInfo.segmentFilePrefix(), backupFPInfo.PID)
 }
 
 func (backupFPInfo *FilePathInfo) GetTableBackupFilePath(contentID int, tableOid uint32, extension string, singleDataFile bool) string {
@@ -84,7 +104,9 @@ func (backupFPInfo *FilePathInfo) GetTableBackupFilePath(contentID int, tableOid
 
 func (backupFPInfo *FilePathInfo) GetTableBackupFilePathForCopyCommand(tableOid uint32, extension string, singleDataFile bool) string {
 	backupFilePath := fmt.Sprintf("gpbackup_<SEGID>_%s", backupFPInfo.Timestamp)
-	if !singleDataFile {
+	if singleDataFile {
+		backupFilePath = backupFPInfo.segmentFilePrefix()
+	} else {
 		backupFilePath += fmt.Sprintf("_%d", tableOid)
 	}
 
@@ -105,6 +127,8 @@ var metadataFilenameMap = map[string]string{
 	"plugin_config":         "plugin_config.yaml",
 	"error_tables_metadata": "error_tables_metadata",
 	"error_tables_data":     "error_tables_data",
+	"rate_limit":            "rate_limit.yaml",
+	"set_report":            "set_report",
 }
 
 func (backupFPInfo *FilePathInfo) GetBackupFilePath(filetype string) string {
@@ -132,6 +156,10 @@ func (backupFPInfo *FilePathInfo) GetBackupReportFilePath() string {
 	return backupFPInfo.GetBackupFilePath("report")
 }
 
+func (backupFPInfo *FilePathInfo) GetBackupSetReportFilePath() string {
+	return backupFPInfo.GetBackupFilePath("set_report")
+}
+
 func (backupFPInfo *FilePathInfo) GetRestoreFilePath(restoreTimestamp string, filetype string) string {
 	return path.Join(backupFPInfo.GetDirForContent(-1), fmt.Sprintf("gprestore_%s_%s_%s", backupFPInfo.Timestamp, restoreTimestamp, metadataFilenameMap[filetype]))
 }
@@ -148,12 +176,21 @@ func (backupFPInfo *FilePathInfo) GetErrorTablesDataFilePath(restoreTimestamp st
 	return backupFPInfo.GetRestoreFilePath(restoreTimestamp, "error_tables_data")
 }
 
+func (backupFPInfo *FilePathInfo) GetBackupRateLimitFilePath() string {
+	return backupFPInfo.GetBackupFilePath("rate_limit")
+}
+
+func (backupFPInfo *FilePathInfo) GetRestoreRateLimitFilePath(restoreTimestamp string) string {
+	return backupFPInfo.GetRestoreFilePath(restoreTimestamp, "rate_limit")
+}
+
 func (backupFPInfo *FilePathInfo) GetConfigFilePath() string {
 	return backupFPInfo.GetBackupFilePath("config")
 }
 
 func (backupFPInfo *FilePathInfo) GetSegmentTOCFilePath(contentID int) string {
-	return fmt.Sprintf("%s/gpbackup_%d_%s_toc.yaml", backupFPInfo.GetDirForContent(contentID), contentID, backupFPInfo.Timestamp)
+	templateFilePath := fmt.Sprintf("%s/%s_toc.yaml", backupFPInfo.GetDirForContent(contentID), backupFPInfo.segmentFilePrefix())
+	return backupFPInfo.replaceCopyFormatStringsInPath(templateFilePath, contentID)
 }
 
 func (backupFPInfo *FilePathInfo) GetPluginConfigPath() string {
@@ -161,7 +198,12 @@ func (backupFPInfo *FilePathInfo) GetPluginConfigPath() string {
 }
 
 func (backupFPInfo *FilePathInfo) GetSegmentHelperFilePath(contentID int, suffix string) string {
-	return path.Join(backupFPInfo.SegDirMap[contentID], fmt.Sprintf("gpbackup_%d_%s_%s_%d", contentID, backupFPInfo.Timestamp, suffix, backupFPInfo.PID))
+	templateFilePath := backupFPInfo.GetSegmentHelperFilePathForCopyCommand(suffix)
+	return backupFPInfo.replaceCopyFormatStringsInPath(templateFilePath, contentID)
+}
+
+func (backupFPInfo *FilePathInfo) GetSegmentHelperFilePathForCopyCommand(suffix string) string {
+	return fmt.Sprintf("<SEG_DATA_DIR>/%s_%s_%d", backupFPInfo.segmentFilePrefix(), suffix, backupFPInfo.PID)
 }
 
 func (backupFPInfo *FilePathInfo) GetHelperLogPath() string {
@@ -208,3 +250,32 @@ func ParseSegPrefix(backupDir string, timestamp string) (string, error) {
 	}
 	return segPrefix, nil
 }
+
+/*
+ * Used when no cluster is available to provide the segment configuration, this
+ * determines which segments have backup files for a given timestamp by looking
+ * at the per-segment directories inside a user-specified backup directory.
+ */
+func ParseContentIDs(backupDir string, segPrefix string, timestamp string) ([]int, error) {
+	pattern := path.Join(backupDir, fmt.Sprintf("%s*", segPrefix), "backups", timestamp[0:8], timestamp)
+	timestampDirs, err := operating.System.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	contentIDs := make([]int, 0)
+	for _, timestampDir := range timestampDirs {
+		// The matched path ends in <segDir>/backups/<date>/<timestamp>
+		dirs := strings.Split(timestampDir, "/")
+		segDir := dirs[len(dirs)-4]
+		contentID, err := strconv.Atoi(strings.TrimPrefix(segDir, segPrefix))
+		if err != nil || contentID < 0 {
+			continue
+		}
+		contentIDs = append(contentIDs, contentID)
+	}
+	if len(contentIDs) == 0 {
+		return nil, fmt.Errorf("No segment backup directories for timestamp %s found in %s", timestamp, backupDir)
+	}
+	sort.Ints(contentIDs)
+	return contentIDs, nil
+}
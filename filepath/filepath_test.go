@@ -106,6 +106,10 @@ var _ = Describe("filepath tests", func() {
 			fpInfo := NewFilePathInfo(c, "/foo/bar", "20170101010101", "gpseg")
 			Expect(fpInfo.GetBackupReportFilePath()).To(Equal("/foo/bar/gpseg-1/backups/20170101/20170101010101/gpbackup_20170101010101_report"))
 		})
+		It("returns backup set report file path", func() {
+			fpInfo := NewFilePathInfo(c, "", "20170101010101", "gpseg")
+			Expect(fpInfo.GetBackupSetReportFilePath()).To(Equal("/data/gpseg-1/backups/20170101/20170101010101/gpbackup_20170101010101_set_report"))
+		})
 	})
 	Describe("GetTableBackupFilePath", func() {
 		It("returns table file path", func() {
@@ -125,6 +129,43 @@ var _ = Describe("filepath tests", func() {
 			Expect(fpInfo.GetTableBackupFilePath(-1, 1234, "", true)).To(Equal("/foo/bar/gpseg-1/backups/20170101/20170101010101/gpbackup_-1_20170101010101"))
 		})
 	})
+	Describe("GetSegmentHelperFilePath", func() {
+		It("returns the helper file path for a segment", func() {
+			fpInfo := NewFilePathInfo(c, "/foo/bar", "20170101010101", "gpseg")
+			fpInfo.PID = 1234
+			Expect(fpInfo.GetSegmentHelperFilePath(-1, "rate")).To(Equal("/data/gpseg-1/gpbackup_-1_20170101010101_rate_1234"))
+		})
+		It("returns the helper file path for copy command", func() {
+			fpInfo := NewFilePathInfo(c, "/foo/bar", "20170101010101", "gpseg")
+			fpInfo.PID = 1234
+			Expect(fpInfo.GetSegmentHelperFilePathForCopyCommand("rate")).To(Equal("<SEG_DATA_DIR>/gpbackup_<SEGID>_20170101010101_rate_1234"))
+		})
+	})
+	Describe("ForDataStream", func() {
+		It("returns the same segment file paths for the first data stream", func() {
+			fpInfo := NewFilePathInfo(c, "", "20170101010101", "gpseg")
+			fpInfo.PID = 1234
+			streamFPInfo := fpInfo.ForDataStream(0)
+			Expect(streamFPInfo.GetTableBackupFilePath(-1, 0, ".gz", true)).To(Equal("/data/gpseg-1/backups/20170101/20170101010101/gpbackup_-1_20170101010101.gz"))
+			Expect(streamFPInfo.GetSegmentTOCFilePath(-1)).To(Equal("/data/gpseg-1/backups/20170101/20170101010101/gpbackup_-1_20170101010101_toc.yaml"))
+			Expect(streamFPInfo.GetSegmentPipeFilePath(-1)).To(Equal("/data/gpseg-1/gpbackup_-1_20170101010101_pipe_1234"))
+		})
+		It("returns separate segment file paths for later data streams", func() {
+			fpInfo := NewFilePathInfo(c, "", "20170101010101", "gpseg")
+			fpInfo.PID = 1234
+			streamFPInfo := fpInfo.ForDataStream(2)
+			Expect(streamFPInfo.GetTableBackupFilePath(-1, 0, ".gz", true)).To(Equal("/data/gpseg-1/backups/20170101/20170101010101/gpbackup_-1_20170101010101_stream2.gz"))
+			Expect(streamFPInfo.GetSegmentTOCFilePath(-1)).To(Equal("/data/gpseg-1/backups/20170101/20170101010101/gpbackup_-1_20170101010101_stream2_toc.yaml"))
+			Expect(streamFPInfo.GetSegmentPipeFilePath(-1)).To(Equal("/data/gpseg-1/gpbackup_-1_20170101010101_stream2_pipe_1234"))
+			Expect(streamFPInfo.GetSegmentHelperFilePath(-1, "oid")).To(Equal("/data/gpseg-1/gpbackup_-1_20170101010101_stream2_oid_1234"))
+		})
+		It("does not change the paths of per-table data files or metadata files", func() {
+			fpInfo := NewFilePathInfo(c, "", "20170101010101", "gpseg")
+			streamFPInfo := fpInfo.ForDataStream(2)
+			Expect(streamFPInfo.GetTableBackupFilePath(-1, 1234, "", false)).To(Equal("/data/gpseg-1/backups/20170101/20170101010101/gpbackup_-1_20170101010101_1234"))
+			Expect(streamFPInfo.GetTOCFilePath()).To(Equal(fpInfo.GetTOCFilePath()))
+		})
+	})
 	Describe("ParseSegPrefix", func() {
 		AfterEach(func() {
 			operating.System.Glob = path.Glob
@@ -176,6 +217,32 @@ var _ = Describe("filepath tests", func() {
 			_, err := ParseSegPrefix("/tmp/foo", "timestamp1")
 			Expect(err.Error()).To(Equal("Timestamp directory timestamp1 inside backup directory /tmp/foo is missing or inaccessible"))
 		})
+		Describe("ParseContentIDs", func() {
+			AfterEach(func() {
+				operating.System.Glob = path.Glob
+			})
+			It("returns the sorted content IDs of the segment backup directories, excluding the master", func() {
+				operating.System.Glob = func(pattern string) (matches []string, err error) {
+					Expect(pattern).To(Equal("/tmp/foo/gpseg*/backups/20170101/20170101010101"))
+					return []string{
+						"/tmp/foo/gpseg10/backups/20170101/20170101010101",
+						"/tmp/foo/gpseg-1/backups/20170101/20170101010101",
+						"/tmp/foo/gpseg2/backups/20170101/20170101010101",
+						"/tmp/foo/gpseg0/backups/20170101/20170101010101",
+					}, nil
+				}
+				contentIDs, err := ParseContentIDs("/tmp/foo", "gpseg", "20170101010101")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(contentIDs).To(Equal([]int{0, 2, 10}))
+			})
+			It("returns an error if there are no segment backup directories", func() {
+				operating.System.Glob = func(pattern string) (matches []string, err error) {
+					return []string{"/tmp/foo/gpseg-1/backups/20170101/20170101010101"}, nil
+				}
+				_, err := ParseContentIDs("/tmp/foo", "gpseg", "20170101010101")
+				Expect(err).To(MatchError("No segment backup directories for timestamp 20170101010101 found in /tmp/foo"))
+			})
+		})
 		Describe("IsValidTimestamp", func() {
 			It("allows a valid timestamp", func() {
 				timestamp := "20170101010101"
@@ -6,7 +6,10 @@ import (
 	"os"
 
 	. "github.com/greenplum-db/gpbackup/backup"
+	"github.com/greenplum-db/gpbackup/backupset"
 	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/plugincheck"
+	"github.com/greenplum-db/gpbackup/replicate"
 	"github.com/spf13/cobra"
 )
 
@@ -24,6 +27,9 @@ func main() {
 		}}
 	rootCmd.SetArgs(options.HandleSingleDashes(os.Args[1:]))
 	DoInit(rootCmd)
+	rootCmd.AddCommand(plugincheck.NewPluginCheckCommand())
+	rootCmd.AddCommand(replicate.NewReplicateCommand())
+	rootCmd.AddCommand(backupset.NewBackupSetCommand(GetVersion()))
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(2)
 	}
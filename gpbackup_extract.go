@@ -0,0 +1,25 @@
+// +build gpbackup_extract
+
+package main
+
+import (
+	"os"
+
+	. "github.com/greenplum-db/gpbackup/extract"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	var rootCmd = &cobra.Command{
+		Use:     "gpbackup_extract",
+		Short:   "gpbackup_extract reads metadata and data directly from gpbackup backup files, without a database connection",
+		Args:    cobra.NoArgs,
+		Version: GetVersion(),
+	}
+	rootCmd.SetArgs(options.HandleSingleDashes(os.Args[1:]))
+	DoInit(rootCmd)
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(2)
+	}
+}
@@ -5,6 +5,7 @@ package main
 import (
 	"os"
 
+	"github.com/greenplum-db/gpbackup/backupset"
 	"github.com/greenplum-db/gpbackup/options"
 	. "github.com/greenplum-db/gpbackup/restore"
 	"github.com/spf13/cobra"
@@ -24,6 +25,7 @@ func main() {
 		}}
 	rootCmd.SetArgs(options.HandleSingleDashes(os.Args[1:]))
 	DoInit(rootCmd)
+	rootCmd.AddCommand(backupset.NewRestoreSetCommand(GetVersion()))
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(2)
 	}
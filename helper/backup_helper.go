@@ -6,9 +6,8 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
-	"strings"
 
+	"github.com/greenplum-db/gpbackup/storage"
 	"github.com/greenplum-db/gpbackup/toc"
 	"github.com/greenplum-db/gpbackup/utils"
 	"github.com/pkg/errors"
@@ -20,12 +19,12 @@ import (
 
 func doBackupAgent() error {
 	var lastRead uint64
+	var lastFrameEnd uint64
 	var (
 		finalWriter io.Writer
-		gzipWriter  *gzip.Writer
+		gzipWriter  *frameWriter
 		bufIoWriter *bufio.Writer
 		writeHandle io.WriteCloser
-		writeCmd    *exec.Cmd
 	)
 	tocfile := &toc.SegmentTOC{}
 	tocfile.DataEntries = make(map[uint]toc.SegmentDataEntry)
@@ -60,7 +59,7 @@ func doBackupAgent() error {
 			return err
 		}
 		if i == 0 {
-			finalWriter, gzipWriter, bufIoWriter, writeHandle, writeCmd, err = getBackupPipeWriter(*compressionLevel)
+			finalWriter, gzipWriter, bufIoWriter, writeHandle, err = getBackupPipeWriter(*compressionLevel)
 			if err != nil {
 				return err
 			}
@@ -69,12 +68,27 @@ func doBackupAgent() error {
 		log(fmt.Sprintf("Backing up table with oid %d\n", oid))
 		numBytes, err := io.Copy(finalWriter, reader)
 		if err != nil {
-			return errors.Wrap(err, strings.Trim(errBuf.String(), "\x00"))
+			if *pluginConfigFile != "" {
+				// The plugin's own error, if any, is reported when the writer is closed
+				if closeErr := writeHandle.Close(); closeErr != nil {
+					return errors.Wrap(err, closeErr.Error())
+				}
+			}
+			return err
 		}
 		log(fmt.Sprintf("Read %d bytes\n", numBytes))
 
 		lastProcessed := lastRead + uint64(numBytes)
-		tocfile.AddSegmentDataEntry(uint(oid), lastRead, lastProcessed)
+		if gzipWriter != nil {
+			frameEnd, err := gzipWriter.EndFrame()
+			if err != nil {
+				return err
+			}
+			tocfile.AddCompressedSegmentDataEntry(uint(oid), lastRead, lastProcessed, lastFrameEnd, frameEnd)
+			lastFrameEnd = frameEnd
+		} else {
+			tocfile.AddSegmentDataEntry(uint(oid), lastRead, lastProcessed)
+		}
 		lastRead = lastProcessed
 
 		lastPipe = currentPipe
@@ -89,25 +103,25 @@ func doBackupAgent() error {
 	/*
 	 * The order for flushing and closing the writers below is very specific
 	 * to ensure all data is written to the file and file handles are not leaked.
+	 * Each compressed frame has already been closed by EndFrame.
 	 */
-	if gzipWriter != nil {
-		_ = gzipWriter.Close()
-	}
 	_ = bufIoWriter.Flush()
-	_ = writeHandle.Close()
 	if *pluginConfigFile != "" {
 		/*
 		 * When using a plugin, the agent may take longer to finish than the
 		 * main gpbackup process. We either write the TOC file if the agent finishes
 		 * successfully or write an error file if it has an error after the COPYs have
 		 * finished. We then wait on the gpbackup side until one of those files is
-		 * written to verify the agent completed.
+		 * written to verify the agent completed.  Closing the storage writer waits
+		 * for the upload to finish.
 		 */
 		log("Uploading remaining data to plugin destination")
-		err := writeCmd.Wait()
+		err = writeHandle.Close()
 		if err != nil {
-			return errors.Wrap(err, strings.Trim(errBuf.String(), "\x00"))
+			return err
 		}
+	} else {
+		_ = writeHandle.Close()
 	}
 	err = tocfile.WriteToFileAndMakeReadOnly(*tocFile)
 	if err != nil {
@@ -129,49 +143,86 @@ func getBackupPipeReader(currentPipe string) (io.Reader, io.ReadCloser, error) {
 	return reader, readHandle, nil
 }
 
-func getBackupPipeWriter(compressLevel int) (io.Writer, *gzip.Writer, *bufio.Writer, io.WriteCloser, *exec.Cmd, error) {
+func getBackupPipeWriter(compressLevel int) (io.Writer, *frameWriter, *bufio.Writer, io.WriteCloser, error) {
 	var writeHandle io.WriteCloser
 	var err error
-	var writeCmd *exec.Cmd
 	if *pluginConfigFile != "" {
-		writeCmd, writeHandle, err = startBackupPluginCommand()
+		writeHandle, err = openStorageWriter()
 	} else {
 		writeHandle, err = os.Create(*dataFile)
 	}
 	if err != nil {
-		return nil, nil, nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
 	var finalWriter io.Writer
-	var gzipWriter *gzip.Writer
-	bufIoWriter := bufio.NewWriter(writeHandle)
+	var gzipWriter *frameWriter
+	bufIoWriter := bufio.NewWriter(throttleWriter(writeHandle))
 	finalWriter = bufIoWriter
 	if compressLevel > 0 {
-		gzipWriter, err = gzip.NewWriterLevel(bufIoWriter, compressLevel)
+		gzipWriter, err = newFrameWriter(bufIoWriter, compressLevel)
 		if err != nil {
-			return nil, nil, nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 		finalWriter = gzipWriter
 	}
-	return finalWriter, gzipWriter, bufIoWriter, writeHandle, writeCmd, nil
+	return finalWriter, gzipWriter, bufIoWriter, writeHandle, nil
 }
 
-func startBackupPluginCommand() (*exec.Cmd, io.WriteCloser, error) {
-	pluginConfig, err := utils.ReadPluginConfig(*pluginConfigFile)
+/*
+ * frameWriter compresses the data of each table as a separate gzip member, or
+ * frame.  Concatenated gzip members form a valid gzip file, so the data file
+ * can still be decompressed from the start as a whole, but the offsets of the
+ * frames also let the restore helper seek to a table's frame and decompress
+ * it alone.
+ */
+type frameWriter struct {
+	gzipWriter *gzip.Writer
+	output     *countingWriter
+}
+
+type countingWriter struct {
+	writer       io.Writer
+	bytesWritten uint64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.bytesWritten += uint64(n)
+	return n, err
+}
+
+func newFrameWriter(writer io.Writer, compressLevel int) (*frameWriter, error) {
+	output := &countingWriter{writer: writer}
+	gzipWriter, err := gzip.NewWriterLevel(output, compressLevel)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	return &frameWriter{gzipWriter: gzipWriter, output: output}, nil
+}
+
+func (w *frameWriter) Write(p []byte) (int, error) {
+	return w.gzipWriter.Write(p)
+}
+
+// Closes the current frame and returns the compressed byte offset at which it ends
+func (w *frameWriter) EndFrame() (uint64, error) {
+	err := w.gzipWriter.Close()
+	if err != nil {
+		return 0, err
 	}
-	cmdStr := fmt.Sprintf("%s backup_data %s %s", pluginConfig.ExecutablePath, pluginConfig.ConfigPath, *dataFile)
-	writeCmd := exec.Command("bash", "-c", cmdStr)
+	w.gzipWriter.Reset(w.output)
+	return w.output.bytesWritten, nil
+}
 
-	writeHandle, err := writeCmd.StdinPipe()
+func openStorageWriter() (io.WriteCloser, error) {
+	pluginConfig, err := utils.ReadPluginConfig(*pluginConfigFile)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	writeCmd.Stderr = &errBuf
-	err = writeCmd.Start()
+	backend, err := storage.NewStorageBackend(pluginConfig)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	return writeCmd, writeHandle, nil
+	return backend.OpenWriter(*dataFile)
 }
@@ -2,9 +2,9 @@ package helper
 
 import (
 	"bufio"
-	"bytes"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"runtime/debug"
@@ -29,13 +29,11 @@ import (
 var (
 	CleanupGroup  *sync.WaitGroup
 	currentPipe   string
-	errBuf        bytes.Buffer
 	lastPipe      string
 	nextPipe      string
+	rateLimiter   *utils.RateLimiter
 	version       string
 	wasTerminated bool
-	writeHandle   *os.File
-	writer        *bufio.Writer
 )
 
 /*
@@ -45,13 +43,20 @@ var (
 	backupAgent      *bool
 	compressionLevel *int
 	content          *int
+	copyWorkers      *int
 	dataFile         *string
+	getData          *bool
+	getFile          *bool
 	oidFile          *string
 	onErrorContinue  *bool
 	pipeFile         *string
 	pluginConfigFile *string
 	printVersion     *bool
+	putData          *bool
+	putFile          *bool
+	rateFile         *string
 	restoreAgent     *bool
+	throttle         *bool
 	tocFile          *string
 	isFiltered       *bool
 )
@@ -85,6 +90,18 @@ func DoHelper() {
 		err = doBackupAgent()
 	} else if *restoreAgent {
 		err = doRestoreAgent()
+	} else if *putData || *getData || *putFile || *getFile {
+		err = doStorageCommand()
+		if err != nil {
+			logError(err.Error())
+		}
+		return
+	} else if *throttle {
+		err = doThrottle()
+		if err != nil {
+			logError(err.Error())
+		}
+		return
 	}
 	if err != nil {
 		logError(fmt.Sprintf("%v: %s", err, debug.Stack()))
@@ -101,13 +118,20 @@ func InitializeGlobals() {
 	backupAgent = flag.Bool("backup-agent", false, "Use gpbackup_helper as an agent for backup")
 	content = flag.Int("content", -2, "Content ID of the corresponding segment")
 	compressionLevel = flag.Int("compression-level", 0, "The level of compression to use with gzip. O indicates no compression.")
+	copyWorkers = flag.Int("copy-workers", 1, "The number of pipes to serve concurrently for restore")
 	dataFile = flag.String("data-file", "", "Absolute path to the data file")
+	getData = flag.Bool("get-data", false, "Write the data file from the plugin config's storage backend to stdout")
+	getFile = flag.Bool("get-file", false, "Copy the data file from the plugin config's storage backend to the local path")
 	oidFile = flag.String("oid-file", "", "Absolute path to the file containing a list of oids to restore")
 	onErrorContinue = flag.Bool("on-error-continue", false, "Continue restore even when encountering an error")
 	pipeFile = flag.String("pipe-file", "", "Absolute path to the pipe file")
 	pluginConfigFile = flag.String("plugin-config", "", "The configuration file to use for a plugin")
 	printVersion = flag.Bool("version", false, "Print version number and exit")
+	putData = flag.Bool("put-data", false, "Write stdin to the data file in the plugin config's storage backend")
+	putFile = flag.Bool("put-file", false, "Copy the local data file to the plugin config's storage backend")
+	rateFile = flag.String("rate-file", "", "Absolute path to the file containing the rate limit for data, in bytes per second")
 	restoreAgent = flag.Bool("restore-agent", false, "Use gpbackup_helper as an agent for restore")
+	throttle = flag.Bool("throttle", false, "Copy stdin to stdout, limited to the rate in the rate file")
 	tocFile = flag.String("toc-file", "", "Absolute path to the table of contents file")
 	isFiltered = flag.Bool("with-filters", false, "Used with table/schema filters")
 
@@ -148,20 +172,44 @@ func getOidListFromFile() ([]int, error) {
 	return oidList, nil
 }
 
-func flushAndCloseRestoreWriter() error {
+/*
+ * Data moved to or from the backup destination is limited to the rate in the
+ * rate file, if one is given.  All of the readers or writers of an agent
+ * share one limiter, so that together they stay within the rate.
+ */
+func throttleReader(reader io.Reader) io.Reader {
+	if *rateFile == "" {
+		return reader
+	}
+	return utils.NewThrottledReader(reader, getRateLimiter())
+}
+
+func throttleWriter(writer io.Writer) io.Writer {
+	if *rateFile == "" {
+		return writer
+	}
+	return utils.NewThrottledWriter(writer, getRateLimiter())
+}
+
+func getRateLimiter() *utils.RateLimiter {
+	if rateLimiter == nil {
+		rateLimiter = utils.NewRateLimiter(*rateFile)
+	}
+	return rateLimiter
+}
+
+func flushAndCloseRestoreWriter(writer *bufio.Writer, writeHandle *os.File) error {
 	if writer != nil {
 		err := writer.Flush()
 		if err != nil {
 			return err
 		}
-		writer = nil
 	}
 	if writeHandle != nil {
 		err := writeHandle.Close()
 		if err != nil {
 			return err
 		}
-		writeHandle = nil
 	}
 	return nil
 }
@@ -182,7 +230,7 @@ func DoCleanup() {
 		handle, _ := utils.OpenFileForWrite(fmt.Sprintf("%s_error", *pipeFile))
 		_ = handle.Close()
 	}
-	err := flushAndCloseRestoreWriter()
+	err := cleanUpRestorePipes()
 	if err != nil {
 		log("Encountered error during cleanup: %v", err)
 	}
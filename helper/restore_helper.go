@@ -7,11 +7,12 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/greenplum-db/gpbackup/storage"
 	"github.com/greenplum-db/gpbackup/toc"
 	"github.com/greenplum-db/gpbackup/utils"
 	"github.com/pkg/errors"
@@ -25,19 +26,25 @@ const (
 	SEEKABLE ReaderType = "seekable"	// reader which supports seek
 	NONSEEKABLE			= "discard"		// reader which is not seekable
 	SUBSET				= "subset"		// reader which operates on pre filtered data
+	FRAMED				= "framed"		// reader which seeks to the compressed frames of each table
 )
 
 /* RestoreReader structure to wrap the underlying reader.
  * readerType identifies how the reader can be used
  * SEEKABLE uses seekReader. Used when restoring from uncompressed data with filters from local filesystem
  * NONSEEKABLE and SUBSET types uses bufReader.
- * SUBSET type applies when restoring using plugin(if compatible) from uncompressed data with filters
+ * FRAMED uses seekReader to find the frames of a table and bufReader to decompress them.
+ * FRAMED type applies when restoring from framed compressed data with filters from local filesystem
+ * SUBSET type applies when restoring using plugin(if compatible) from uncompressed or framed compressed data with filters
  * NONSEEKABLE type applies for every other restore scenario
+ * SEEKABLE and FRAMED types also apply without filters when pipes are served concurrently,
+ * as each concurrent pipe has a reader of its own
  */
 type RestoreReader struct {
 	bufReader  *bufio.Reader
 	seekReader io.ReadSeeker
 	readerType ReaderType
+	lastByte   uint64
 }
 
 func (r *RestoreReader) positionReader(pos uint64) error {
@@ -45,16 +52,12 @@ func (r *RestoreReader) positionReader(pos uint64) error {
 	case SEEKABLE:
 		seekPosition, err := r.seekReader.Seek(int64(pos), io.SeekCurrent)
 		if err != nil {
-			// Always hard quit if data reader has issues
-			_ = utils.RemoveFileIfExists(currentPipe)
 			return err
 		}
 		log(fmt.Sprintf("Data Reader seeked forward to %d byte offset", seekPosition))
 	case NONSEEKABLE:
 		numDiscarded, err := r.bufReader.Discard(int(pos))
 		if err != nil {
-			// Always hard quit if data reader has issues
-			_ = utils.RemoveFileIfExists(currentPipe)
 			return err
 		}
 		log(fmt.Sprintf("Data Reader discarded %d bytes", numDiscarded))
@@ -64,28 +67,128 @@ func (r *RestoreReader) positionReader(pos uint64) error {
 	return nil
 }
 
-func (r *RestoreReader) copyData(num int64) (int64, error) {
+func (r *RestoreReader) positionFrames(entry toc.SegmentDataEntry) error {
+	seekPosition, err := r.seekReader.Seek(int64(entry.CompressedStartByte), io.SeekStart)
+	if err != nil {
+		return err
+	}
+	log(fmt.Sprintf("Data Reader seeked to compressed frames at %d byte offset", seekPosition))
+	gzipReader, err := gzip.NewReader(io.LimitReader(r.seekReader, int64(entry.CompressedEndByte-entry.CompressedStartByte)))
+	if err != nil {
+		return err
+	}
+	r.bufReader = bufio.NewReader(gzipReader)
+	return nil
+}
+
+func (r *RestoreReader) copyData(writer io.Writer, num int64) (int64, error) {
 	var bytesRead int64
 	var err error
 	switch r.readerType {
 	case SEEKABLE:
 		bytesRead, err = io.CopyN(writer, r.seekReader, num)
-	case NONSEEKABLE, SUBSET:
+	case NONSEEKABLE, SUBSET, FRAMED:
 		bytesRead, err = io.CopyN(writer, r.bufReader, num)
 	}
 	return bytesRead, err
 }
 
-func doRestoreAgent() error {
-	segmentTOC := toc.NewSegmentTOC(*tocFile)
-	tocEntries := segmentTOC.DataEntries
+/*
+ * Tables are restored in the order of the oid list.  gprestore creates the
+ * pipes of the first copyWorkers tables and starts a COPY for each following
+ * table only once an earlier COPY has finished, so the pipe of each table is
+ * created as the table copyWorkers places before it in the list is taken.
+ */
+type oidQueue struct {
+	oids   []int
+	next   int
+	failed bool
+	mutex  sync.Mutex
+}
 
-	var lastByte uint64
-	var bytesRead int64
-	var start uint64
-	var end uint64
-	var errRemove error
+func newOidQueue(oids []int) *oidQueue {
+	for i := 0; i < len(oids) && i < *copyWorkers; i++ {
+		addRestorePipe(fmt.Sprintf("%s_%d", *pipeFile, oids[i]))
+	}
+	return &oidQueue{oids: oids}
+}
+
+func (queue *oidQueue) take() (int, bool, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if queue.failed || queue.next >= len(queue.oids) {
+		return 0, false, nil
+	}
+	oid := queue.oids[queue.next]
+	if ahead := queue.next + *copyWorkers; ahead < len(queue.oids) {
+		pipe := fmt.Sprintf("%s_%d", *pipeFile, queue.oids[ahead])
+		log(fmt.Sprintf("Creating pipe for oid %d: %s", queue.oids[ahead], pipe))
+		err := createPipe(pipe)
+		if err != nil {
+			return 0, false, err
+		}
+		addRestorePipe(pipe)
+	}
+	queue.next++
+	return oid, true, nil
+}
+
+// Stops the other workers after a worker has to quit
+func (queue *oidQueue) fail() {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	queue.failed = true
+}
+
+func (queue *oidQueue) hasFailed() bool {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return queue.failed
+}
+
+var (
+	restorePipes      = make(map[string]bool)
+	restorePipesMutex sync.Mutex
+)
+
+func addRestorePipe(pipe string) {
+	restorePipesMutex.Lock()
+	defer restorePipesMutex.Unlock()
+	restorePipes[pipe] = true
+}
+
+func removeRestorePipe(pipe string) error {
+	restorePipesMutex.Lock()
+	defer restorePipesMutex.Unlock()
+	delete(restorePipes, pipe)
+	return utils.RemoveFileIfExists(pipe)
+}
+
+/*
+ * A COPY may already be waiting on a pipe that the agent has not opened, so
+ * each remaining pipe is opened and closed to end its COPY before the pipe is
+ * removed.
+ */
+func cleanUpRestorePipes() error {
+	restorePipesMutex.Lock()
+	defer restorePipesMutex.Unlock()
 	var lastError error
+	for pipe := range restorePipes {
+		handle, err := os.OpenFile(pipe, os.O_WRONLY|syscall.O_NONBLOCK, os.ModeNamedPipe)
+		if err == nil {
+			_ = handle.Close()
+		}
+		err = utils.RemoveFileIfExists(pipe)
+		if err != nil {
+			lastError = err
+		}
+		delete(restorePipes, pipe)
+	}
+	return lastError
+}
+
+func doRestoreAgent() error {
+	segmentTOC := toc.NewSegmentTOC(*tocFile)
 
 	oidList, err := getOidListFromFile()
 	if err != nil {
@@ -97,23 +200,68 @@ func doRestoreAgent() error {
 		return err
 	}
 	log(fmt.Sprintf("Using reader type: %s", reader.readerType))
-	for i, oid := range oidList {
-		if wasTerminated {
-			return errors.New("Terminated due to user request")
-		}
 
-		currentPipe = fmt.Sprintf("%s_%d", *pipeFile, oidList[i])
-		if i < len(oidList)-1 {
-			nextPipe = fmt.Sprintf("%s_%d", *pipeFile, oidList[i+1])
-			log(fmt.Sprintf("Creating pipe for oid %d: %s", oidList[i+1], nextPipe))
-			err := createPipe(nextPipe)
+	// Pipes can only be served concurrently if each can seek to its own tables
+	readers := []*RestoreReader{reader}
+	if *copyWorkers > 1 && (reader.readerType == SEEKABLE || reader.readerType == FRAMED) {
+		for len(readers) < *copyWorkers {
+			reader, err = getRestoreDataReader(segmentTOC, oidList)
 			if err != nil {
-				// In the case this error is hit it means we have lost the
-				// ability to create pipes normally, so hard quit even if
-				// --on-error-continue is given
 				return err
 			}
+			readers = append(readers, reader)
+		}
+		log(fmt.Sprintf("Serving up to %d pipes concurrently", len(readers)))
+	}
+
+	queue := newOidQueue(oidList)
+	errs := make([]error, len(readers))
+	var workerPool sync.WaitGroup
+	for i, reader := range readers {
+		workerPool.Add(1)
+		go func(i int, reader *RestoreReader) {
+			defer workerPool.Done()
+			errs[i] = restoreTables(reader, queue, segmentTOC.DataEntries)
+			if errs[i] != nil {
+				queue.fail()
+			}
+		}(i, reader)
+	}
+	workerPool.Wait()
+
+	var lastError error
+	for _, err := range errs {
+		if err != nil {
+			lastError = err
 		}
+	}
+	return lastError
+}
+
+func restoreTables(reader *RestoreReader, queue *oidQueue, tocEntries map[uint]toc.SegmentDataEntry) error {
+	var writer *bufio.Writer
+	var writeHandle *os.File
+	var bytesRead int64
+	var start uint64
+	var end uint64
+	var errRemove error
+	var lastError error
+
+	for {
+		if wasTerminated {
+			return errors.New("Terminated due to user request")
+		}
+
+		oid, ok, err := queue.take()
+		if err != nil {
+			// In the case this error is hit it means we have lost the
+			// ability to create pipes normally, so hard quit even if
+			// --on-error-continue is given
+			return err
+		} else if !ok {
+			break
+		}
+		currentPipe := fmt.Sprintf("%s_%d", *pipeFile, oid)
 
 		start = tocEntries[uint(oid)].StartByte
 		end = tocEntries[uint(oid)].EndByte
@@ -135,6 +283,8 @@ func doRestoreAgent() error {
 						log(fmt.Sprintf("Skip file has been discovered for entry %d, skipping it", oid))
 						err = nil
 						goto LoopEnd
+					} else if wasTerminated || queue.hasFailed() {
+						return errors.New("Terminated while waiting for pipe to be opened")
 					} else {
 						// keep trying to open the pipe
 						time.Sleep(100 * time.Millisecond)
@@ -143,7 +293,7 @@ func doRestoreAgent() error {
 					// In the case this error is hit it means we have lost the
 					// ability to open pipes normally, so hard quit even if
 					// --on-error-continue is given
-					_ = utils.RemoveFileIfExists(currentPipe)
+					_ = removeRestorePipe(currentPipe)
 					return err
 				}
 			} else {
@@ -156,36 +306,40 @@ func doRestoreAgent() error {
 			}
 		}
 
-		log(fmt.Sprintf("Data Reader - Start Byte: %d; End Byte: %d; Last Byte: %d", start, end, lastByte))
-		err = reader.positionReader(start - lastByte)
+		log(fmt.Sprintf("Data Reader - Start Byte: %d; End Byte: %d; Last Byte: %d", start, end, reader.lastByte))
+		if reader.readerType == FRAMED {
+			err = reader.positionFrames(tocEntries[uint(oid)])
+		} else {
+			err = reader.positionReader(start - reader.lastByte)
+		}
 		if err != nil {
+			// Always hard quit if data reader has issues
+			_ = removeRestorePipe(currentPipe)
 			return err
 		}
 
 		log(fmt.Sprintf("Restoring table with oid %d", oid))
-		bytesRead, err = reader.copyData(int64(end-start))
+		bytesRead, err = reader.copyData(writer, int64(end-start))
 		if err != nil {
 			// In case COPY FROM or copyN fails in the middle of a load. We
 			// need to update the lastByte with the amount of bytes that was
 			// copied before it errored out
-			lastByte += uint64(bytesRead)
-			err = errors.Wrap(err, strings.Trim(errBuf.String(), "\x00"))
+			reader.lastByte = start + uint64(bytesRead)
 			goto LoopEnd
 		}
-		lastByte = end
+		reader.lastByte = end
 		log(fmt.Sprintf("Copied %d bytes into the pipe", bytesRead))
 
 		log(fmt.Sprintf("Closing pipe for oid %d: %s", oid, currentPipe))
-		err = flushAndCloseRestoreWriter()
+		err = flushAndCloseRestoreWriter(writer, writeHandle)
 		if err != nil {
 			goto LoopEnd
 		}
 
 	LoopEnd:
 		log(fmt.Sprintf("Removing pipe for oid %d: %s", oid, currentPipe))
-		errRemove = utils.RemoveFileIfExists(currentPipe)
+		errRemove = removeRestorePipe(currentPipe)
 		if errRemove != nil {
-			_ = utils.RemoveFileIfExists(nextPipe)
 			return errRemove
 		}
 
@@ -212,7 +366,7 @@ func getRestoreDataReader(toc *toc.SegmentTOC, oidList []int) (*RestoreReader, e
 	restoreReader := new(RestoreReader)
 
 	if *pluginConfigFile != "" {
-		readHandle, isSubset, err = startRestorePluginCommand(toc, oidList)
+		readHandle, isSubset, err = openStorageReader(toc, oidList)
 		if isSubset {
 			// Reader that operates on subset data
 			restoreReader.readerType = SUBSET
@@ -221,10 +375,16 @@ func getRestoreDataReader(toc *toc.SegmentTOC, oidList []int) (*RestoreReader, e
 			restoreReader.readerType = NONSEEKABLE
 		}
 	} else {
-		if *isFiltered && !strings.HasSuffix(*dataFile, ".gz") {
+		// Concurrent pipes seek to their tables even if the restore is not filtered
+		canSeek := *isFiltered || *copyWorkers > 1
+		if canSeek && !strings.HasSuffix(*dataFile, ".gz") {
 			// Seekable reader if backup is not compressed and filters are set
 			seekHandle, err = os.Open(*dataFile)
 			restoreReader.readerType = SEEKABLE
+		} else if canSeek && toc.HasCompressedFrames() {
+			// Seekable reader if backup is compressed in frames and filters are set
+			seekHandle, err = os.Open(*dataFile)
+			restoreReader.readerType = FRAMED
 		} else {
 			// Regular reader which doesn't support seek
 			readHandle, err = os.Open(*dataFile)
@@ -236,22 +396,19 @@ func getRestoreDataReader(toc *toc.SegmentTOC, oidList []int) (*RestoreReader, e
 	}
 
 	// Set the underlying stream reader in restoreReader
-	if restoreReader.readerType == SEEKABLE {
-		restoreReader.seekReader = seekHandle
+	if restoreReader.readerType == SEEKABLE || restoreReader.readerType == FRAMED {
+		restoreReader.seekReader = struct {
+			io.Reader
+			io.Seeker
+		}{throttleReader(seekHandle), seekHandle}
 	} else if strings.HasSuffix(*dataFile, ".gz") {
-		gzipReader, err := gzip.NewReader(readHandle)
+		gzipReader, err := gzip.NewReader(throttleReader(readHandle))
 		if err != nil {
 			return nil, err
 		}
 		restoreReader.bufReader = bufio.NewReader(gzipReader)
 	} else {
-		restoreReader.bufReader = bufio.NewReader(readHandle)
-	}
-
-	// Check that no error has occurred in plugin command
-	errMsg := strings.Trim(errBuf.String(), "\x00")
-	if len(errMsg) != 0 {
-		return nil, errors.New(errMsg)
+		restoreReader.bufReader = bufio.NewReader(throttleReader(readHandle))
 	}
 
 	return restoreReader, err
@@ -274,14 +431,18 @@ func getRestorePipeWriter(currentPipe string) (*bufio.Writer, *os.File, error) {
 	return pipeWriter, fileHandle, nil
 }
 
-func startRestorePluginCommand(toc *toc.SegmentTOC, oidList []int) (io.Reader, bool, error) {
-	isSubset := false
+func openStorageReader(toc *toc.SegmentTOC, oidList []int) (io.Reader, bool, error) {
 	pluginConfig, err := utils.ReadPluginConfig(*pluginConfigFile)
 	if err != nil {
 		return nil, false, err
 	}
-	cmdStr := ""
-	if pluginConfig.CanRestoreSubset() && *isFiltered && !strings.HasSuffix(*dataFile, ".gz") {
+	backend, err := storage.NewStorageBackend(pluginConfig)
+	if err != nil {
+		return nil, false, err
+	}
+	pluginBackend, isPlugin := backend.(*storage.PluginBackend)
+	isCompressed := strings.HasSuffix(*dataFile, ".gz")
+	if isPlugin && pluginConfig.CanRestoreSubset() && *isFiltered && (!isCompressed || toc.HasCompressedFrames()) {
 		offsetsFile, _ := ioutil.TempFile("/tmp", "gprestore_offsets_")
 		defer func() {
 			offsetsFile.Close()
@@ -290,23 +451,20 @@ func startRestorePluginCommand(toc *toc.SegmentTOC, oidList []int) (io.Reader, b
 		w.WriteString(fmt.Sprintf("%v", len(oidList)))
 
 		for _, oid := range oidList {
-			w.WriteString(fmt.Sprintf(" %v %v", toc.DataEntries[uint(oid)].StartByte, toc.DataEntries[uint(oid)].EndByte))
+			entry := toc.DataEntries[uint(oid)]
+			if isCompressed {
+				// The plugin returns the compressed frames, which are decompressed as one stream
+				w.WriteString(fmt.Sprintf(" %v %v", entry.CompressedStartByte, entry.CompressedEndByte))
+			} else {
+				w.WriteString(fmt.Sprintf(" %v %v", entry.StartByte, entry.EndByte))
+			}
 		}
 		w.Flush()
-		cmdStr = fmt.Sprintf("%s restore_data_subset %s %s %s", pluginConfig.ExecutablePath, pluginConfig.ConfigPath, *dataFile, offsetsFile.Name())
-		isSubset = true
-	} else {
-		cmdStr = fmt.Sprintf("%s restore_data %s %s", pluginConfig.ExecutablePath, pluginConfig.ConfigPath, *dataFile)
+		log(fmt.Sprintf("Restoring data subset of %s", *dataFile))
+		readHandle, err := pluginBackend.OpenSubsetReader(*dataFile, offsetsFile.Name())
+		return readHandle, true, err
 	}
-	log(fmt.Sprintf("%s", cmdStr))
-	cmd := exec.Command("bash", "-c", cmdStr)
-
-	readHandle, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, false, err
-	}
-	cmd.Stderr = &errBuf
-
-	err = cmd.Start()
-	return readHandle, isSubset, err
+	log(fmt.Sprintf("Restoring data from %s", *dataFile))
+	readHandle, err := backend.OpenReader(*dataFile)
+	return readHandle, false, err
 }
@@ -0,0 +1,67 @@
+package helper
+
+import (
+	"io"
+	"os"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/storage"
+	"github.com/greenplum-db/gpbackup/utils"
+)
+
+/*
+ * Storage command functions, which let the segments move files to and from
+ * a built-in storage backend from shell commands such as COPY ... PROGRAM.
+ */
+
+func doStorageCommand() error {
+	// Stdout may carry data, so only errors are logged to the console
+	gplog.SetVerbosity(gplog.LOGERROR)
+	pluginConfig, err := utils.ReadPluginConfig(*pluginConfigFile)
+	if err != nil {
+		return err
+	}
+	backend, err := storage.NewStorageBackend(pluginConfig)
+	if err != nil {
+		return err
+	}
+	switch {
+	case *putFile:
+		return backend.PutFile(*dataFile)
+	case *getFile:
+		return backend.GetFile(*dataFile)
+	case *putData:
+		writer, err := backend.OpenWriter(*dataFile)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(writer, os.Stdin)
+		closeErr := writer.Close()
+		if err != nil {
+			return err
+		}
+		return closeErr
+	default:
+		reader, err := backend.OpenReader(*dataFile)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(os.Stdout, reader)
+		closeErr := reader.Close()
+		if err != nil {
+			return err
+		}
+		return closeErr
+	}
+}
+
+/*
+ * The throttling stage of COPY ... PROGRAM commands, which limits the rate at
+ * which each table's data is moved to or from the backup destination.
+ */
+func doThrottle() error {
+	// Stdout carries data, so only errors are logged to the console
+	gplog.SetVerbosity(gplog.LOGERROR)
+	_, err := io.Copy(os.Stdout, throttleReader(os.Stdin))
+	return err
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/greenplum-db/gp-common-go-libs/operating"
 	"github.com/greenplum-db/gpbackup/utils"
 	"github.com/nightlyone/lockfile"
+	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 )
 
@@ -20,6 +21,32 @@ type RestorePlanEntry struct {
 	TableFQNs []string
 }
 
+// A copy of a backup made by gpbackup replicate
+type Replica struct {
+	Plugin       string
+	PluginConfig string
+	ReplicatedAt string
+}
+
+/*
+ * A run of gpbackup backup-set, which backs up several databases with one
+ * backup each; the global metadata is only backed up with one of them.
+ */
+type BackupSet struct {
+	Timestamp        string
+	EndTime          string
+	Status           string
+	GlobalsTimestamp string
+	Backups          []BackupSetEntry
+}
+
+// Timestamp is empty if the backup of the database failed before it was given one
+type BackupSetEntry struct {
+	DatabaseName string
+	Timestamp    string
+	Status       string
+}
+
 const (
 	BackupStatusSucceed = "Success"
 	BackupStatusFailed  = "Failure"
@@ -32,11 +59,13 @@ type BackupConfig struct {
 	DatabaseName          string
 	DatabaseVersion       string
 	DataOnly              bool
+	DataStreams           int `yaml:",omitempty"`
 	DateDeleted           string
 	ExcludeRelations      []string
 	ExcludeSchemaFiltered bool
 	ExcludeSchemas        []string
 	ExcludeTableFiltered  bool
+	GlobalsOnly           bool
 	IncludeRelations      []string
 	IncludeSchemaFiltered bool
 	IncludeSchemas        []string
@@ -47,23 +76,37 @@ type BackupConfig struct {
 	Plugin                string
 	PluginVersion         string
 	RestorePlan           []RestorePlanEntry
+	SessionGUCs           []utils.SessionGUC `yaml:",omitempty"`
 	SingleDataFile        bool
 	Timestamp             string
 	EndTime               string
 	WithoutGlobals        bool
 	WithStatistics        bool
 	Status                string
+	Replicas              []Replica `yaml:",omitempty"`
 }
 
 func (backup *BackupConfig) Failed() bool {
 	return backup.Status == BackupStatusFailed
 }
 
+// Single data file backups taken before data streams were added have one stream
+func (backup *BackupConfig) NumDataStreams() int {
+	if backup.DataStreams < 1 {
+		return 1
+	}
+	return backup.DataStreams
+}
+
 func ReadConfigFile(filename string) *BackupConfig {
-	config := &BackupConfig{}
 	contents, err := ioutil.ReadFile(filename)
 	gplog.FatalOnError(err)
-	err = yaml.Unmarshal(contents, config)
+	return ParseConfig(contents)
+}
+
+func ParseConfig(contents []byte) *BackupConfig {
+	config := &BackupConfig{}
+	err := yaml.Unmarshal(contents, config)
 	gplog.FatalOnError(err)
 	return config
 }
@@ -76,6 +119,7 @@ func WriteConfigFile(config *BackupConfig, configFilename string) {
 
 type History struct {
 	BackupConfigs []BackupConfig
+	BackupSets    []BackupSet `yaml:",omitempty"`
 }
 
 func NewHistory(filename string) (*History, error) {
@@ -211,3 +255,58 @@ func (history *History) FindBackupConfig(timestamp string) *BackupConfig {
 	}
 	return nil
 }
+
+func (history *History) FindBackupSet(timestamp string) *BackupSet {
+	for _, backupSet := range history.BackupSets {
+		if backupSet.Timestamp == timestamp {
+			return &backupSet
+		}
+	}
+	return nil
+}
+
+/*
+ * Backup sets are recorded after all of their backups, so the history file
+ * always exists and begins with its backup configs, which WriteBackupHistory
+ * relies on.
+ */
+func AddBackupSetToHistory(historyFilePath string, backupSet BackupSet) error {
+	lock := lockHistoryFile()
+	defer func() {
+		_ = lock.Unlock()
+	}()
+
+	history, err := NewHistory(historyFilePath)
+	if err != nil {
+		return err
+	}
+	if len(history.BackupConfigs) == 0 {
+		return errors.Errorf("History file %s contains no backups", historyFilePath)
+	}
+	history.BackupSets = append([]BackupSet{backupSet}, history.BackupSets...)
+	return history.WriteToFileAndMakeReadOnly(historyFilePath)
+}
+
+/*
+ * The history file is read while holding the lock, so that the entry of a
+ * backup finishing at the same time is not lost when the file is rewritten.
+ */
+func AddReplicaToHistory(historyFilePath string, timestamp string, replica Replica) error {
+	lock := lockHistoryFile()
+	defer func() {
+		_ = lock.Unlock()
+	}()
+
+	history, err := NewHistory(historyFilePath)
+	if err != nil {
+		return err
+	}
+	for i := range history.BackupConfigs {
+		backupConfig := &history.BackupConfigs[i]
+		if backupConfig.Timestamp == timestamp && !backupConfig.Failed() {
+			backupConfig.Replicas = append(backupConfig.Replicas, replica)
+			return history.WriteToFileAndMakeReadOnly(historyFilePath)
+		}
+	}
+	return errors.Errorf("Backup %s was not found in history file %s", timestamp, historyFilePath)
+}
@@ -260,6 +260,66 @@ var _ = Describe("backup/history tests", func() {
 			Expect(testConfig3.EndTime).To(Equal(simulatedEndTime.Format("20060102150405")))
 		})
 	})
+	Describe("AddReplicaToHistory", func() {
+		replica := history.Replica{Plugin: "gpbackup_s3_plugin", PluginConfig: "/home/gpadmin/s3_config.yaml", ReplicatedAt: "20170101020202"}
+		BeforeEach(func() {
+			for _, config := range []*history.BackupConfig{&testConfig1, &testConfig2, &testConfigFailed} {
+				err := history.WriteBackupHistory(historyFilePath, config)
+				Expect(err).ToNot(HaveOccurred())
+			}
+		})
+		It("adds the replica to the entry for the given timestamp", func() {
+			err := history.AddReplicaToHistory(historyFilePath, "timestamp1", replica)
+			Expect(err).ToNot(HaveOccurred())
+			err = history.AddReplicaToHistory(historyFilePath, "timestamp1", replica)
+			Expect(err).ToNot(HaveOccurred())
+
+			resultHistory, err := history.NewHistory(historyFilePath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resultHistory.FindBackupConfig("timestamp1").Replicas).To(Equal([]history.Replica{replica, replica}))
+			Expect(resultHistory.FindBackupConfig("timestamp2").Replicas).To(BeEmpty())
+		})
+		It("returns an error when the timestamp is not found", func() {
+			err := history.AddReplicaToHistory(historyFilePath, "foo", replica)
+			Expect(err).To(MatchError("Backup foo was not found in history file /tmp/history_file.yaml"))
+		})
+		It("returns an error when the backup failed", func() {
+			err := history.AddReplicaToHistory(historyFilePath, "timestampFailed", replica)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Describe("AddBackupSetToHistory", func() {
+		backupSet := history.BackupSet{
+			Timestamp:        "timestampSet",
+			Status:           history.BackupStatusSucceed,
+			GlobalsTimestamp: "timestamp1",
+			Backups: []history.BackupSetEntry{
+				{DatabaseName: "testdb1", Timestamp: "timestamp1", Status: history.BackupStatusSucceed},
+				{DatabaseName: "testdb2", Timestamp: "timestamp2", Status: history.BackupStatusSucceed},
+			},
+		}
+		It("records the backup set without affecting backups written later", func() {
+			for _, config := range []*history.BackupConfig{&testConfig1, &testConfig2} {
+				err := history.WriteBackupHistory(historyFilePath, config)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			err := history.AddBackupSetToHistory(historyFilePath, backupSet)
+			Expect(err).ToNot(HaveOccurred())
+			err = history.WriteBackupHistory(historyFilePath, &testConfig3)
+			Expect(err).ToNot(HaveOccurred())
+
+			resultHistory, err := history.NewHistory(historyFilePath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resultHistory.BackupConfigs).To(HaveLen(3))
+			Expect(resultHistory.BackupConfigs[0].Timestamp).To(Equal("timestamp3"))
+			Expect(resultHistory.FindBackupSet("timestampSet")).To(Equal(&backupSet))
+			Expect(resultHistory.FindBackupSet("timestamp1")).To(BeNil())
+		})
+		It("returns an error when the history file does not exist", func() {
+			err := history.AddBackupSetToHistory(historyFilePath, backupSet)
+			Expect(err).To(HaveOccurred())
+		})
+	})
 	Describe("FindBackupConfig", func() {
 		var resultHistory *history.History
 		BeforeEach(func() {
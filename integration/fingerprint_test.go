@@ -0,0 +1,43 @@
+package integration
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("utils/fingerprint integration tests", func() {
+	Describe("GetTableFingerprint", func() {
+		BeforeEach(func() {
+			testhelper.AssertQueryRuns(connectionPool, "CREATE TABLE public.fingerprint_table(i int, t timestamptz, d date, f float8)")
+			testhelper.AssertQueryRuns(connectionPool, "INSERT INTO public.fingerprint_table VALUES (1, '2020-03-01 12:30:00+00', '2020-03-01', 0.1), (2, '2020-07-15 23:45:10.5-07', '2020-07-15', 1e-10)")
+		})
+		AfterEach(func() {
+			testhelper.AssertQueryRuns(connectionPool, "DROP TABLE public.fingerprint_table")
+			testhelper.AssertQueryRuns(connectionPool, "RESET TIMEZONE")
+			testhelper.AssertQueryRuns(connectionPool, "RESET DATESTYLE")
+			testhelper.AssertQueryRuns(connectionPool, "RESET extra_float_digits")
+			if connectionPool.Version.AtLeast("6") {
+				testhelper.AssertQueryRuns(connectionPool, "RESET INTERVALSTYLE")
+			}
+		})
+		It("returns the same fingerprint for a timestamptz column in sessions with different time zones", func() {
+			testhelper.AssertQueryRuns(connectionPool, "SET TIMEZONE = 'America/Los_Angeles'")
+			testhelper.AssertQueryRuns(connectionPool, "SET DATESTYLE = 'SQL, DMY'")
+			utils.SetFingerprintSessionGUCs(connectionPool, 0)
+			firstFingerprint, err := utils.GetTableFingerprint(connectionPool, "public.fingerprint_table", 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			testhelper.AssertQueryRuns(connectionPool, "SET TIMEZONE = 'Asia/Tokyo'")
+			testhelper.AssertQueryRuns(connectionPool, "SET DATESTYLE = 'German'")
+			utils.SetFingerprintSessionGUCs(connectionPool, 0)
+			secondFingerprint, err := utils.GetTableFingerprint(connectionPool, "public.fingerprint_table", 0)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(firstFingerprint.Rows).To(Equal(int64(2)))
+			Expect(secondFingerprint).To(Equal(firstFingerprint))
+		})
+	})
+})
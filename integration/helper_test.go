@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/toc"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -166,6 +167,33 @@ var _ = Describe("gpbackup_helper end to end integration tests", func() {
 			Expect(err).ToNot(HaveOccurred())
 			assertNoErrors()
 		})
+		It("runs restore gpbackup_helper with compression and filters", func() {
+			setupRestoreFiles(true, false)
+			helperCmd := gpbackupHelper(gpbackupHelperPath, "--restore-agent", "--data-file", dataFileFullPath+".gz", "--with-filters")
+			for _, i := range []int{1, 3} {
+				contents, _ := ioutil.ReadFile(fmt.Sprintf("%s_%d", pipeFile, i))
+				Expect(string(contents)).To(Equal("here is some data\n"))
+			}
+			err := helperCmd.Wait()
+			printHelperLogOnError(err)
+			Expect(err).ToNot(HaveOccurred())
+			assertNoErrors()
+		})
+		It("runs restore gpbackup_helper serving several pipes concurrently", func() {
+			setupRestoreFiles(true, false)
+			// gprestore creates the pipes of the first COPYs, which need not be opened in order
+			err := syscall.Mkfifo(fmt.Sprintf("%s_%d", pipeFile, 3), 0777)
+			Expect(err).ToNot(HaveOccurred())
+			helperCmd := gpbackupHelper(gpbackupHelperPath, "--restore-agent", "--data-file", dataFileFullPath+".gz", "--copy-workers", "2")
+			for _, i := range []int{3, 1} {
+				contents, _ := ioutil.ReadFile(fmt.Sprintf("%s_%d", pipeFile, i))
+				Expect(string(contents)).To(Equal("here is some data\n"))
+			}
+			err = helperCmd.Wait()
+			printHelperLogOnError(err)
+			Expect(err).ToNot(HaveOccurred())
+			assertNoErrors()
+		})
 		It("runs restore gpbackup_helper without compression with plugin", func() {
 			setupRestoreFiles(false, true)
 			helperCmd := gpbackupHelper(gpbackupHelperPath, "--restore-agent", "--data-file", dataFileFullPath, "--plugin-config", pluginConfigPath)
@@ -277,20 +305,27 @@ func setupRestoreFiles(withCompression bool, withPlugin bool) {
 	}
 	f, _ := os.Create(oidFile)
 	_, _ = f.WriteString("1\n3\n")
+	tocContents := expectedTOC
 	if withCompression {
-		f, _ := os.Create(dataFile + ".gz")
-		gzipf := gzip.NewWriter(f)
-		defer func() {
+		// Write each table's data as a separate compressed frame, as the backup helper does
+		var frames bytes.Buffer
+		tocContents = "dataentries:\n"
+		for i := 1; i <= 3; i++ {
+			frameStart := frames.Len()
+			gzipf := gzip.NewWriter(&frames)
+			_, _ = gzipf.Write([]byte(defaultData))
 			_ = gzipf.Close()
-		}()
-		_, _ = gzipf.Write([]byte(expectedData))
+			tocContents += fmt.Sprintf("  %d:\n    startbyte: %d\n    endbyte: %d\n    compressedstartbyte: %d\n    compressedendbyte: %d\n",
+				i, (i-1)*len(defaultData), i*len(defaultData), frameStart, frames.Len())
+		}
+		_ = ioutil.WriteFile(dataFile+".gz", frames.Bytes(), 0644)
 	} else {
 		f, _ := os.Create(dataFile)
 		_, _ = f.WriteString(expectedData)
 	}
 
 	f, _ = os.Create(tocFile)
-	_, _ = f.WriteString(expectedTOC)
+	_, _ = f.WriteString(tocContents)
 }
 
 func assertNoErrors() {
@@ -314,20 +349,33 @@ func assertBackupArtifacts(withCompression bool, withPlugin bool) {
 		dataFile = pluginBackupPath
 	}
 	if withCompression {
-		contents, err = ioutil.ReadFile(dataFile + ".gz")
+		compressed, err := ioutil.ReadFile(dataFile + ".gz")
 		Expect(err).ToNot(HaveOccurred())
-		r, _ := gzip.NewReader(bytes.NewReader(contents))
+		r, _ := gzip.NewReader(bytes.NewReader(compressed))
 		contents, _ = ioutil.ReadAll(r)
+		Expect(string(contents)).To(Equal(expectedData))
 
+		// Each table's data must also decompress alone from its own frame
+		segmentTOC := toc.NewSegmentTOC(tocFile)
+		Expect(segmentTOC.DataEntries).To(HaveLen(3))
+		for oid, entry := range segmentTOC.DataEntries {
+			Expect(entry.StartByte).To(Equal(uint64((oid - 1) * uint(len(defaultData)))))
+			Expect(entry.EndByte).To(Equal(entry.StartByte + uint64(len(defaultData))))
+			r, err := gzip.NewReader(bytes.NewReader(compressed[entry.CompressedStartByte:entry.CompressedEndByte]))
+			Expect(err).ToNot(HaveOccurred())
+			frameContents, _ := ioutil.ReadAll(r)
+			Expect(string(frameContents)).To(Equal(defaultData))
+		}
+		Expect(segmentTOC.DataEntries[3].CompressedEndByte).To(Equal(uint64(len(compressed))))
 	} else {
 		contents, err = ioutil.ReadFile(dataFile)
 		Expect(err).ToNot(HaveOccurred())
-	}
-	Expect(string(contents)).To(Equal(expectedData))
+		Expect(string(contents)).To(Equal(expectedData))
 
-	contents, err = ioutil.ReadFile(tocFile)
-	Expect(err).ToNot(HaveOccurred())
-	Expect(string(contents)).To(Equal(expectedTOC))
+		contents, err = ioutil.ReadFile(tocFile)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(contents)).To(Equal(expectedTOC))
+	}
 	assertNoErrors()
 }
 
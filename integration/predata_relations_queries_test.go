@@ -167,6 +167,49 @@ PARTITION BY LIST (gender)
 			structmatcher.ExpectStructsToMatchIncluding(&tableFoo, &tables[0], "Name", "Schema")
 		})
 	})
+	Describe("GetTableNamesForFilterPatterns", func() {
+		BeforeEach(func() {
+			testhelper.AssertQueryRuns(connectionPool, `CREATE SCHEMA "Stg_Schema"`)
+			testhelper.AssertQueryRuns(connectionPool, `CREATE TABLE "Stg_Schema"."Stg_Table"(i int)`)
+			testhelper.AssertQueryRuns(connectionPool, `CREATE VIEW "Stg_Schema".stg_view AS SELECT 1`)
+			testhelper.AssertQueryRuns(connectionPool, `CREATE SEQUENCE "Stg_Schema".stg_sequence`)
+			testhelper.AssertQueryRuns(connectionPool, `CREATE TABLE "Stg_Schema".part(i int, j int) DISTRIBUTED BY (i) PARTITION BY RANGE (i) SUBPARTITION BY RANGE (j) SUBPARTITION TEMPLATE (START (1) END (2) EVERY (1)) (START (1) END (3) EVERY (1))`)
+		})
+		AfterEach(func() {
+			testhelper.AssertQueryRuns(connectionPool, `DROP SCHEMA "Stg_Schema" CASCADE`)
+		})
+		It("returns the unquoted names of user tables, sequences, and views, without child partitions", func() {
+			tables := backup.GetTableNamesForFilterPatterns(connectionPool)
+
+			Expect(tables).To(ContainElement(options.FqnStruct{SchemaName: "Stg_Schema", TableName: "Stg_Table"}))
+			Expect(tables).To(ContainElement(options.FqnStruct{SchemaName: "Stg_Schema", TableName: "stg_view"}))
+			Expect(tables).To(ContainElement(options.FqnStruct{SchemaName: "Stg_Schema", TableName: "stg_sequence"}))
+			Expect(tables).To(ContainElement(options.FqnStruct{SchemaName: "Stg_Schema", TableName: "part"}))
+			Expect(tables).ToNot(ContainElement(options.FqnStruct{SchemaName: "Stg_Schema", TableName: "part_1_prt_1"}))
+			Expect(tables).ToNot(ContainElement(options.FqnStruct{SchemaName: "Stg_Schema", TableName: "part_1_prt_1_2_prt_1"}))
+		})
+		It("returns leaf partitions, but not intermediate partitions, if the leaf-partition-data flag is set", func() {
+			_ = backupCmdFlags.Set(options.LEAF_PARTITION_DATA, "true")
+
+			tables := backup.GetTableNamesForFilterPatterns(connectionPool)
+
+			Expect(tables).To(ContainElement(options.FqnStruct{SchemaName: "Stg_Schema", TableName: "part"}))
+			Expect(tables).To(ContainElement(options.FqnStruct{SchemaName: "Stg_Schema", TableName: "part_1_prt_1_2_prt_1"}))
+			Expect(tables).ToNot(ContainElement(options.FqnStruct{SchemaName: "Stg_Schema", TableName: "part_1_prt_1"}))
+		})
+	})
+	Describe("GetSchemaNamesForFilterPatterns", func() {
+		It("returns the unquoted names of user schemas", func() {
+			testhelper.AssertQueryRuns(connectionPool, `CREATE SCHEMA "Stg_Schema"`)
+			defer testhelper.AssertQueryRuns(connectionPool, `DROP SCHEMA "Stg_Schema"`)
+
+			schemas := backup.GetSchemaNamesForFilterPatterns(connectionPool)
+
+			Expect(schemas).To(ContainElement("Stg_Schema"))
+			Expect(schemas).To(ContainElement("public"))
+			Expect(schemas).ToNot(ContainElement("pg_catalog"))
+		})
+	})
 	Describe("GetAllSequenceRelations", func() {
 		It("returns a slice of all sequences", func() {
 			testhelper.AssertQueryRuns(connectionPool, "CREATE SEQUENCE public.my_sequence START 10")
@@ -87,4 +87,55 @@ var _ = Describe("backup integration tests", func() {
 			structmatcher.ExpectStructsToMatchExcluding(&expectedStats, &tableTupleStats, "RelPages")
 		})
 	})
+	Describe("GetRelatedStatisticsRelations", func() {
+		It("returns the indexes of a table", func() {
+			testhelper.AssertQueryRuns(connectionPool, "CREATE INDEX foo_idx ON public.foo(i)")
+			indexOid := testutils.OidFromObjectName(connectionPool, "public", "foo_idx", backup.TYPE_RELATION)
+			fooTable := backup.Table{Relation: backup.Relation{Oid: tableOid, Schema: "public", Name: "foo"}}
+
+			relatedRelations := backup.GetRelatedStatisticsRelations(connectionPool, []backup.Table{fooTable})
+
+			Expect(relatedRelations).To(HaveLen(1))
+			Expect(relatedRelations[tableOid]).To(HaveLen(1))
+			Expect(relatedRelations[tableOid][0].Oid).To(Equal(indexOid))
+			Expect(relatedRelations[tableOid][0].FQN()).To(Equal("public.foo_idx"))
+		})
+		It("returns the child partitions of a partitioned table and their indexes", func() {
+			testhelper.AssertQueryRuns(connectionPool, `CREATE TABLE public.part (i int, j int) DISTRIBUTED BY (i)
+	PARTITION BY LIST (j) (PARTITION one VALUES (1), PARTITION two VALUES (2))`)
+			defer testhelper.AssertQueryRuns(connectionPool, "DROP TABLE public.part")
+			testhelper.AssertQueryRuns(connectionPool, "CREATE INDEX part_idx ON public.part(i)")
+			partOid := testutils.OidFromObjectName(connectionPool, "public", "part", backup.TYPE_RELATION)
+			partTable := backup.Table{Relation: backup.Relation{Oid: partOid, Schema: "public", Name: "part"}}
+
+			relatedRelations := backup.GetRelatedStatisticsRelations(connectionPool, []backup.Table{partTable})
+
+			relationNames := make([]string, 0)
+			for _, relation := range relatedRelations[partOid] {
+				relationNames = append(relationNames, relation.Name)
+			}
+			Expect(relationNames).To(ContainElement("part_1_prt_one"))
+			Expect(relationNames).To(ContainElement("part_1_prt_two"))
+			Expect(relationNames).To(ContainElement("part_idx"))
+		})
+	})
+	Describe("GetAuxiliaryStatistics", func() {
+		It("returns the statistics of the auxiliary relations of an append-optimized table", func() {
+			testhelper.AssertQueryRuns(connectionPool, "CREATE TABLE public.ao_foo (i int) WITH (appendonly=true)")
+			defer testhelper.AssertQueryRuns(connectionPool, "DROP TABLE public.ao_foo")
+			testhelper.AssertQueryRuns(connectionPool, "CREATE INDEX ao_foo_idx ON public.ao_foo(i)")
+			aoOid := testutils.OidFromObjectName(connectionPool, "public", "ao_foo", backup.TYPE_RELATION)
+			aoTable := backup.Table{Relation: backup.Relation{Oid: aoOid, Schema: "public", Name: "ao_foo"}}
+			fooTable := backup.Table{Relation: backup.Relation{Oid: tableOid, Schema: "public", Name: "foo"}}
+
+			auxStats := backup.GetAuxiliaryStatistics(connectionPool, []backup.Table{aoTable, fooTable})
+
+			Expect(auxStats).To(HaveLen(1))
+			auxColumns := make([]string, 0)
+			for _, stat := range auxStats[aoOid] {
+				auxColumns = append(auxColumns, stat.AuxColumn)
+			}
+			Expect(auxColumns).To(Equal([]string{"blkdirrelid", "segrelid", "visimaprelid"}))
+		})
+	})
 })
@@ -5,113 +5,267 @@ package options
  */
 
 import (
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
 	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
 )
 
 const (
-	BACKUP_DIR            = "backup-dir"
-	COMPRESSION_LEVEL     = "compression-level"
-	DATA_ONLY             = "data-only"
-	DBNAME                = "dbname"
-	DEBUG                 = "debug"
-	EXCLUDE_RELATION      = "exclude-table"
-	EXCLUDE_RELATION_FILE = "exclude-table-file"
-	EXCLUDE_SCHEMA        = "exclude-schema"
-	EXCLUDE_SCHEMA_FILE   = "exclude-schema-file"
-	FROM_TIMESTAMP        = "from-timestamp"
-	INCLUDE_RELATION      = "include-table"
-	INCLUDE_RELATION_FILE = "include-table-file"
-	INCLUDE_SCHEMA        = "include-schema"
-	INCLUDE_SCHEMA_FILE   = "include-schema-file"
-	INCREMENTAL           = "incremental"
-	JOBS                  = "jobs"
-	LEAF_PARTITION_DATA   = "leaf-partition-data"
-	METADATA_ONLY         = "metadata-only"
-	NO_COMPRESSION        = "no-compression"
-	PLUGIN_CONFIG         = "plugin-config"
-	QUIET                 = "quiet"
-	SINGLE_DATA_FILE      = "single-data-file"
-	VERBOSE               = "verbose"
-	WITH_STATS            = "with-stats"
-	CREATE_DB             = "create-db"
-	ON_ERROR_CONTINUE     = "on-error-continue"
-	REDIRECT_DB           = "redirect-db"
-	RUN_ANALYZE           = "run-analyze"
-	TIMESTAMP             = "timestamp"
-	WITH_GLOBALS          = "with-globals"
-	REDIRECT_SCHEMA       = "redirect-schema"
-	TRUNCATE_TABLE        = "truncate-table"
-	WITHOUT_GLOBALS       = "without-globals"
+	BACKUP_DIR               = "backup-dir"
+	COMPRESSION_LEVEL        = "compression-level"
+	DATA_ONLY                = "data-only"
+	DBNAME                   = "dbname"
+	DEBUG                    = "debug"
+	EXCLUDE_RELATION         = "exclude-table"
+	EXCLUDE_RELATION_FILE    = "exclude-table-file"
+	EXCLUDE_RELATION_PATTERN = "exclude-table-pattern"
+	EXCLUDE_SCHEMA           = "exclude-schema"
+	EXCLUDE_SCHEMA_FILE      = "exclude-schema-file"
+	EXCLUDE_SCHEMA_PATTERN   = "exclude-schema-pattern"
+	FROM_TIMESTAMP           = "from-timestamp"
+	INCLUDE_RELATION         = "include-table"
+	INCLUDE_RELATION_FILE    = "include-table-file"
+	INCLUDE_RELATION_PATTERN = "include-table-pattern"
+	INCLUDE_SCHEMA           = "include-schema"
+	INCLUDE_SCHEMA_FILE      = "include-schema-file"
+	INCLUDE_SCHEMA_PATTERN   = "include-schema-pattern"
+	INCREMENTAL              = "incremental"
+	JOBS                     = "jobs"
+	LEAF_PARTITION_DATA      = "leaf-partition-data"
+	METADATA_ONLY            = "metadata-only"
+	NO_COMPRESSION           = "no-compression"
+	PLUGIN_CONFIG            = "plugin-config"
+	QUIET                    = "quiet"
+	SINGLE_DATA_FILE         = "single-data-file"
+	VERBOSE                  = "verbose"
+	WITH_STATS               = "with-stats"
+	CREATE_DB                = "create-db"
+	ON_ERROR_CONTINUE        = "on-error-continue"
+	REDIRECT_DB              = "redirect-db"
+	RUN_ANALYZE              = "run-analyze"
+	TIMESTAMP                = "timestamp"
+	WITH_GLOBALS             = "with-globals"
+	REDIRECT_SCHEMA          = "redirect-schema"
+	TRUNCATE_TABLE           = "truncate-table"
+	WITHOUT_GLOBALS          = "without-globals"
+	SECTION                  = "section"
+	INCLUDE_OBJECT_TYPE      = "include-object-type"
+	EXCLUDE_OBJECT_TYPE      = "exclude-object-type"
+	OUTPUT_FILE              = "output-file"
+	RELATION                 = "table"
+	FORMAT                   = "format"
+	TARGET_POSTGRES          = "target-postgres"
+	RESTORE_TEST             = "restore-test"
+	WITH_FINGERPRINTS        = "with-fingerprints"
+	VERIFY_DATA              = "verify-data"
+	NUM_SEGMENTS             = "num-segments"
+	LARGE_DATA_SIZE          = "large-data-size"
+	LOCAL_DIR                = "local-dir"
+	SOURCE_PLUGIN_CONFIG     = "source-plugin-config"
+	MAX_RATE                 = "max-rate"
+	MAX_SEGMENT_RATE         = "max-segment-rate"
+	LOCK_WAIT_TIMEOUT        = "lock-wait-timeout"
+	LOCK_WAIT_STRATEGY       = "lock-wait-strategy"
+	DBNAMES                  = "dbnames"
+	ALL_DATABASES            = "all-databases"
+	GLOBALS_ONLY             = "globals-only"
+	INCLUDE_ROLE_PATTERN     = "include-role-pattern"
+	WITHOUT_RES_GROUPS       = "without-resource-groups"
+	ON_GLOBAL_CONFLICT       = "on-global-conflict"
+	DATA_STREAMS             = "data-streams"
+	STATS_ONLY               = "stats-only"
+	STATS_TOLERANCE          = "stats-tolerance"
+	SESSION_GUC_FILE         = "session-guc-file"
+	CONFIG                   = "config"
+	ROLE_MAPPING             = "role-mapping"
+	NO_OWNER                 = "no-owner"
+	NO_PRIVILEGES            = "no-privileges"
 )
 
 func SetBackupFlagDefaults(flagSet *pflag.FlagSet) {
 	flagSet.String(BACKUP_DIR, "", "The absolute path of the directory to which all backup files will be written")
 	flagSet.Int(COMPRESSION_LEVEL, 1, "Level of compression to use during data backup. Valid values are between 1 and 9.")
+	flagSet.String(CONFIG, "", "A YAML file of flag values, keyed by flag name.  Flags given on the command line override the values in the file.")
 	flagSet.Bool(DATA_ONLY, false, "Only back up data, do not back up metadata")
+	flagSet.Int(DATA_STREAMS, 1, "The number of parallel data streams per segment to use with --single-data-file, each written to its own data file")
 	flagSet.String(DBNAME, "", "The database to be backed up")
 	flagSet.Bool(DEBUG, false, "Print verbose and debug log messages")
+	flagSet.StringArray(EXCLUDE_OBJECT_TYPE, []string{}, "Back up all metadata except pre-data and post-data objects of the specified type(s). --exclude-object-type can be specified multiple times.")
 	flagSet.StringArray(EXCLUDE_SCHEMA, []string{}, "Back up all metadata except objects in the specified schema(s). --exclude-schema can be specified multiple times.")
 	flagSet.String(EXCLUDE_SCHEMA_FILE, "", "A file containing a list of schemas to be excluded from the backup")
+	flagSet.StringArray(EXCLUDE_SCHEMA_PATTERN, []string{}, "Back up all metadata except objects in the schema(s) matching the specified pattern, where * and ? are wildcards. --exclude-schema-pattern can be specified multiple times.")
 	flagSet.StringArray(EXCLUDE_RELATION, []string{}, "Back up all metadata except the specified table(s). --exclude-table can be specified multiple times.")
 	flagSet.String(EXCLUDE_RELATION_FILE, "", "A file containing a list of fully-qualified tables to be excluded from the backup")
+	flagSet.StringArray(EXCLUDE_RELATION_PATTERN, []string{}, "Back up all metadata except the relation(s) matching the specified schema.relation pattern, where * and ? are wildcards. Leaf partitions are only matched with --leaf-partition-data. --exclude-table-pattern can be specified multiple times.")
 	flagSet.String(FROM_TIMESTAMP, "", "A timestamp to use to base the current incremental backup off")
+	flagSet.Bool(GLOBALS_ONLY, false, "Only back up global metadata, such as roles, resource queues and groups, and tablespaces")
 	flagSet.Bool("help", false, "Help for gpbackup")
+	flagSet.StringArray(INCLUDE_OBJECT_TYPE, []string{}, "Back up only pre-data and post-data objects of the specified type(s), e.g. \"FUNCTION\". Table definitions are always backed up with their data. --include-object-type can be specified multiple times.")
 	flagSet.StringArray(INCLUDE_SCHEMA, []string{}, "Back up only the specified schema(s). --include-schema can be specified multiple times.")
 	flagSet.String(INCLUDE_SCHEMA_FILE, "", "A file containing a list of schema(s) to be included in the backup")
+	flagSet.StringArray(INCLUDE_SCHEMA_PATTERN, []string{}, "Back up only the schema(s) matching the specified pattern, where * and ? are wildcards. --include-schema-pattern can be specified multiple times.")
 	flagSet.StringArray(INCLUDE_RELATION, []string{}, "Back up only the specified table(s). --include-table can be specified multiple times.")
 	flagSet.String(INCLUDE_RELATION_FILE, "", "A file containing a list of fully-qualified tables to be included in the backup")
+	flagSet.StringArray(INCLUDE_RELATION_PATTERN, []string{}, "Back up only the relation(s) matching the specified schema.relation pattern, where * and ? are wildcards. Leaf partitions are only matched with --leaf-partition-data. --include-table-pattern can be specified multiple times.")
+	flagSet.String(INCLUDE_ROLE_PATTERN, "", "Back up only the roles whose entire names match the specified regular expression, with their configuration parameters and memberships")
 	flagSet.Bool(INCREMENTAL, false, "Only back up data for AO tables that have been modified since the last backup")
 	flagSet.Int(JOBS, 1, "The number of parallel connections to use when backing up data")
 	flagSet.Bool(LEAF_PARTITION_DATA, false, "For partition tables, create one data file per leaf partition instead of one data file for the whole table")
+	flagSet.Int(LOCK_WAIT_TIMEOUT, 0, "The number of seconds to wait for the lock on each table before applying --lock-wait-strategy. 0 means wait indefinitely.")
+	flagSet.String(LOCK_WAIT_STRATEGY, "fail", "What to do with a table that cannot be locked within --lock-wait-timeout: fail the backup, skip the table, or retry it after the other tables are locked. Valid values are fail, skip, and retry.")
+	flagSet.Int(MAX_RATE, 0, "The maximum total rate, in MB per second, at which all segments write data to the backup destination. 0 means no limit.")
+	flagSet.Int(MAX_SEGMENT_RATE, 0, "The maximum rate, in MB per second, at which each segment writes data to the backup destination. 0 means no limit.")
 	flagSet.Bool(METADATA_ONLY, false, "Only back up metadata, do not back up data")
 	flagSet.Bool(NO_COMPRESSION, false, "Disable compression of data files")
 	flagSet.String(PLUGIN_CONFIG, "", "The configuration file to use for a plugin")
 	flagSet.Bool("version", false, "Print version number and exit")
 	flagSet.Bool(QUIET, false, "Suppress non-warning, non-error log messages")
+	flagSet.String(SESSION_GUC_FILE, "", "A file of session settings to apply on every database connection, with one 'name = value' setting per line")
 	flagSet.Bool(SINGLE_DATA_FILE, false, "Back up all data to a single file instead of one per table")
 	flagSet.Bool(VERBOSE, false, "Print verbose log messages")
 	flagSet.Bool(WITH_STATS, false, "Back up query plan statistics")
+	flagSet.Bool(WITH_FINGERPRINTS, false, "Compute a fingerprint of the data in each table, which gprestore can use to verify restored data")
 	flagSet.Bool(WITHOUT_GLOBALS, false, "Disable backup of global metadata")
+	flagSet.Bool(WITHOUT_RES_GROUPS, false, "Disable backup of resource groups and of the resource groups assigned to roles")
 }
 
 func SetRestoreFlagDefaults(flagSet *pflag.FlagSet) {
 	flagSet.String(BACKUP_DIR, "", "The absolute path of the directory in which the backup files to be restored are located")
+	flagSet.String(CONFIG, "", "A YAML file of flag values, keyed by flag name.  Flags given on the command line override the values in the file.")
 	flagSet.Bool(CREATE_DB, false, "Create the database before metadata restore")
 	flagSet.Bool(DATA_ONLY, false, "Only restore data, do not restore metadata")
 	flagSet.Bool(DEBUG, false, "Print verbose and debug log messages")
+	flagSet.StringArray(EXCLUDE_OBJECT_TYPE, []string{}, "Restore all metadata except pre-data and post-data objects of the specified type(s). --exclude-object-type can be specified multiple times.")
 	flagSet.StringArray(EXCLUDE_SCHEMA, []string{}, "Restore all metadata except objects in the specified schema(s). --exclude-schema can be specified multiple times.")
 	flagSet.String(EXCLUDE_SCHEMA_FILE, "", "A file containing a list of schemas that will not be restored")
+	flagSet.StringArray(EXCLUDE_SCHEMA_PATTERN, []string{}, "Restore all metadata except objects in the schema(s) matching the specified pattern, where * and ? are wildcards. --exclude-schema-pattern can be specified multiple times.")
 	flagSet.StringArray(EXCLUDE_RELATION, []string{}, "Restore all metadata except the specified relation(s). --exclude-table can be specified multiple times.")
 	flagSet.String(EXCLUDE_RELATION_FILE, "", "A file containing a list of fully-qualified relation(s) that will not be restored")
+	flagSet.StringArray(EXCLUDE_RELATION_PATTERN, []string{}, "Restore all metadata except the relation(s) matching the specified schema.relation pattern, where * and ? are wildcards. --exclude-table-pattern can be specified multiple times.")
+	flagSet.Bool(GLOBALS_ONLY, false, "Only restore global metadata, such as roles, resource queues and groups, and tablespaces")
 	flagSet.Bool("help", false, "Help for gprestore")
+	flagSet.StringArray(INCLUDE_OBJECT_TYPE, []string{}, "Restore only pre-data and post-data objects of the specified type(s), e.g. \"VIEW\". Table data is only restored if TABLE is restored. --include-object-type can be specified multiple times.")
 	flagSet.StringArray(INCLUDE_SCHEMA, []string{}, "Restore only the specified schema(s). --include-schema can be specified multiple times.")
 	flagSet.String(INCLUDE_SCHEMA_FILE, "", "A file containing a list of schemas that will be restored")
+	flagSet.StringArray(INCLUDE_SCHEMA_PATTERN, []string{}, "Restore only the schema(s) matching the specified pattern, where * and ? are wildcards. --include-schema-pattern can be specified multiple times.")
 	flagSet.StringArray(INCLUDE_RELATION, []string{}, "Restore only the specified relation(s). --include-table can be specified multiple times.")
 	flagSet.String(INCLUDE_RELATION_FILE, "", "A file containing a list of fully-qualified relation(s) that will be restored")
+	flagSet.StringArray(INCLUDE_RELATION_PATTERN, []string{}, "Restore only the relation(s) matching the specified schema.relation pattern, where * and ? are wildcards. --include-table-pattern can be specified multiple times.")
 	flagSet.Bool(INCREMENTAL, false, "BETA FEATURE: Only restore data for all heap tables and only AO tables that have been modified since the last backup")
+	flagSet.Int(MAX_RATE, 0, "The maximum total rate, in MB per second, at which all segments read data from the backup destination. 0 means no limit.")
+	flagSet.Int(MAX_SEGMENT_RATE, 0, "The maximum rate, in MB per second, at which each segment reads data from the backup destination. 0 means no limit.")
 	flagSet.Bool(METADATA_ONLY, false, "Only restore metadata, do not restore data")
 	flagSet.Int(JOBS, 1, "Number of parallel connections to use when restoring table data and post-data")
+	flagSet.Bool(NO_OWNER, false, "Do not restore object ownership, leaving restored objects owned by the user running the restore")
+	flagSet.Bool(NO_PRIVILEGES, false, "Do not restore object privileges or default privileges")
 	flagSet.Bool(ON_ERROR_CONTINUE, false, "Log errors and continue restore, instead of exiting on first error")
+	flagSet.String(ON_GLOBAL_CONFLICT, "fail", "What to do with a global object that already exists: fail to create it, skip it, or alter it to match the backup where possible. Valid values are fail, skip, and alter.")
 	flagSet.String(PLUGIN_CONFIG, "", "The configuration file to use for a plugin")
 	flagSet.Bool("version", false, "Print version number and exit")
 	flagSet.Bool(QUIET, false, "Suppress non-warning, non-error log messages")
 	flagSet.String(REDIRECT_DB, "", "Restore to the specified database instead of the database that was backed up")
 	flagSet.String(REDIRECT_SCHEMA, "", "Restore to the specified schema instead of the schema that was backed up")
+	flagSet.String(ROLE_MAPPING, "", "A file mapping backed-up roles to the existing roles to use in restored ownership, privilege, role setting, and role membership statements, with one 'oldrole -> newrole' or 'oldrole -> DROP' mapping per line. Mapped roles are not created.")
+	flagSet.String(SESSION_GUC_FILE, "", "A file of session settings to apply on every database connection, with one 'name = value' setting per line")
 	flagSet.Bool(WITH_GLOBALS, false, "Restore global metadata")
 	flagSet.String(TIMESTAMP, "", "The timestamp to be restored, in the format YYYYMMDDHHMMSS")
 	flagSet.Bool(TRUNCATE_TABLE, false, "Removes data of the tables getting restored")
 	flagSet.Bool(VERBOSE, false, "Print verbose log messages")
 	flagSet.Bool(WITH_STATS, false, "Restore query plan statistics")
+	flagSet.Bool(STATS_ONLY, false, "Only restore query plan statistics onto existing tables, do not restore metadata or data")
+	flagSet.Int(STATS_TOLERANCE, 0, "With --stats-only, skip the statistics of tables whose current row count differs from the backed-up row count by more than the specified percentage")
 	flagSet.Bool(LEAF_PARTITION_DATA, false, "For partition tables, create one data file per leaf partition instead of one data file for the whole table")
 	flagSet.Bool(RUN_ANALYZE, false, "Run ANALYZE on restored tables")
+	flagSet.Bool(RESTORE_TEST, false, "Restore into a temporary database, validate the restored data against the backup, and drop the database afterward")
+	flagSet.Bool(VERIFY_DATA, false, "Verify restored data against the row counts and fingerprints recorded at backup time")
+	flagSet.Bool(TARGET_POSTGRES, false, "Restore to a PostgreSQL database, removing Greenplum-specific syntax and loading all data through the master")
 	_ = flagSet.MarkHidden(LEAF_PARTITION_DATA)
 }
 
+func SetExtractMetadataFlagDefaults(flagSet *pflag.FlagSet) {
+	flagSet.String(BACKUP_DIR, "", "The absolute path of the directory in which the backup files to be extracted are located")
+	flagSet.Bool(DEBUG, false, "Print verbose and debug log messages")
+	flagSet.StringArray(EXCLUDE_OBJECT_TYPE, []string{}, "Extract all metadata except objects of the specified type(s). --exclude-object-type can be specified multiple times.")
+	flagSet.StringArray(EXCLUDE_SCHEMA, []string{}, "Extract all metadata except objects in the specified schema(s). --exclude-schema can be specified multiple times.")
+	flagSet.String(EXCLUDE_SCHEMA_FILE, "", "A file containing a list of schemas that will not be extracted")
+	flagSet.StringArray(EXCLUDE_RELATION, []string{}, "Extract all metadata except the specified relation(s). --exclude-table can be specified multiple times.")
+	flagSet.String(EXCLUDE_RELATION_FILE, "", "A file containing a list of fully-qualified relation(s) that will not be extracted")
+	flagSet.Bool("help", false, "Help for metadata")
+	flagSet.StringArray(INCLUDE_OBJECT_TYPE, []string{}, "Extract only objects of the specified type(s), e.g. \"FUNCTION\". --include-object-type can be specified multiple times.")
+	flagSet.StringArray(INCLUDE_SCHEMA, []string{}, "Extract only the specified schema(s). --include-schema can be specified multiple times.")
+	flagSet.String(INCLUDE_SCHEMA_FILE, "", "A file containing a list of schemas that will be extracted")
+	flagSet.StringArray(INCLUDE_RELATION, []string{}, "Extract only the specified relation(s). --include-table can be specified multiple times.")
+	flagSet.String(INCLUDE_RELATION_FILE, "", "A file containing a list of fully-qualified relation(s) that will be extracted")
+	flagSet.String(OUTPUT_FILE, "", "The file to which the SQL script will be written.  If not specified, the script is written to stdout.")
+	flagSet.Bool(QUIET, false, "Suppress non-warning, non-error log messages")
+	flagSet.String(REDIRECT_SCHEMA, "", "Rewrite extracted statements to use the specified schema instead of the schema that was backed up")
+	flagSet.StringArray(SECTION, []string{"predata", "postdata"}, "The metadata section(s) to extract: global, predata, postdata, or statistics. --section can be specified multiple times.")
+	flagSet.String(TIMESTAMP, "", "The timestamp of the backup to extract from, in the format YYYYMMDDHHMMSS")
+	flagSet.Bool(VERBOSE, false, "Print verbose log messages")
+	flagSet.Bool(LEAF_PARTITION_DATA, false, "For partition tables, create one data file per leaf partition instead of one data file for the whole table")
+	_ = flagSet.MarkHidden(LEAF_PARTITION_DATA)
+}
+
+func SetExtractDataFlagDefaults(flagSet *pflag.FlagSet) {
+	flagSet.String(BACKUP_DIR, "", "The absolute path of the directory in which the backup files to be extracted are located")
+	flagSet.Bool(DEBUG, false, "Print verbose and debug log messages")
+	flagSet.String(FORMAT, "csv", "The format of the output file: csv or parquet")
+	flagSet.Bool("help", false, "Help for data")
+	flagSet.String(OUTPUT_FILE, "", "The file to which the table data will be written.  If not specified, csv data is written to stdout.")
+	flagSet.Bool(QUIET, false, "Suppress non-warning, non-error log messages")
+	flagSet.String(RELATION, "", "The fully-qualified name of the table whose data will be extracted")
+	flagSet.String(TIMESTAMP, "", "The timestamp of the backup to extract from, in the format YYYYMMDDHHMMSS")
+	flagSet.Bool(VERBOSE, false, "Print verbose log messages")
+}
+
+func SetPluginCheckFlagDefaults(flagSet *pflag.FlagSet) {
+	flagSet.Bool(DEBUG, false, "Print verbose and debug log messages")
+	flagSet.Bool("help", false, "Help for plugin-check")
+	flagSet.Int(LARGE_DATA_SIZE, 64, "The size in MB of the data streamed through the plugin by the large data check")
+	flagSet.String(LOCAL_DIR, "/tmp", "The absolute path of the directory under which local test files are created")
+	flagSet.Int(NUM_SEGMENTS, 4, "The number of segments to simulate when running plugin commands concurrently")
+	flagSet.String(PLUGIN_CONFIG, "", "The absolute path to the config file of the plugin to check")
+	flagSet.Bool(QUIET, false, "Suppress non-warning, non-error log messages")
+	flagSet.Bool(VERBOSE, false, "Print verbose log messages")
+}
+
+func SetReplicateFlagDefaults(flagSet *pflag.FlagSet) {
+	flagSet.String(BACKUP_DIR, "", "The absolute path of the directory in which the backup files to be replicated are located")
+	flagSet.Bool(DEBUG, false, "Print verbose and debug log messages")
+	flagSet.Bool("help", false, "Help for replicate")
+	flagSet.String(PLUGIN_CONFIG, "", "The absolute path to the config file of the plugin to which the backup will be replicated")
+	flagSet.Bool(QUIET, false, "Suppress non-warning, non-error log messages")
+	flagSet.String(SOURCE_PLUGIN_CONFIG, "", "The absolute path to the config file of the plugin from which the backup will be replicated")
+	flagSet.String(TIMESTAMP, "", "The timestamp of the backup to be replicated")
+	flagSet.Bool(VERBOSE, false, "Print verbose log messages")
+}
+
+// Backup sets take the gpbackup flags that do not name objects in a single database
+func SetBackupSetFlagDefaults(flagSet *pflag.FlagSet) {
+	SetBackupFlagDefaults(flagSet)
+	flagSet.Bool(ALL_DATABASES, false, "Back up all databases that allow connections, except template databases")
+	flagSet.StringSlice(DBNAMES, []string{}, "A comma-separated list of the databases to be backed up")
+	for _, flagName := range []string{DBNAME, FROM_TIMESTAMP, GLOBALS_ONLY, INCLUDE_RELATION, INCLUDE_RELATION_FILE, EXCLUDE_RELATION, EXCLUDE_RELATION_FILE} {
+		_ = flagSet.MarkHidden(flagName)
+	}
+}
+
+// Restoring a backup set takes the same gprestore flags, except that --timestamp is the timestamp of the set
+func SetRestoreSetFlagDefaults(flagSet *pflag.FlagSet) {
+	SetRestoreFlagDefaults(flagSet)
+	flagSet.StringSlice(DBNAMES, []string{}, "A comma-separated list of the databases in the backup set to be restored.  If not specified, all databases in the set are restored.")
+	for _, flagName := range []string{REDIRECT_DB, GLOBALS_ONLY, INCLUDE_RELATION, INCLUDE_RELATION_FILE, EXCLUDE_RELATION, EXCLUDE_RELATION_FILE} {
+		_ = flagSet.MarkHidden(flagName)
+	}
+}
+
 /*
  * Functions for validating whether flags are set and in what combination
  */
@@ -147,6 +301,91 @@ func HandleSingleDashes(args []string) []string {
 	return newArgs
 }
 
+/*
+ * Sets the flags in the file given with --config that were not given on the
+ * command line.  The file maps flag names to values, with a list of values
+ * for flags that can be specified multiple times:
+ *
+ *     dbname: mydb
+ *     jobs: 4
+ *     include-table:
+ *       - public.foo
+ *       - public.bar
+ *
+ * Flags set from the file count as changed, so they are validated the same
+ * way as flags given on the command line.
+ */
+func ApplyConfigFile(flagSet *pflag.FlagSet) error {
+	configFile, err := flagSet.GetString(CONFIG)
+	if err != nil || configFile == "" {
+		return nil
+	}
+	contents, err := operating.System.ReadFile(configFile)
+	if err != nil {
+		return errors.Errorf("Unable to read config file %s: %v", configFile, err)
+	}
+	config := yaml.MapSlice{}
+	err = yaml.Unmarshal(contents, &config)
+	if err != nil {
+		return errors.Errorf("Config file %s is formatted incorrectly: %v", configFile, err)
+	}
+	for _, item := range config {
+		name, _ := item.Key.(string)
+		flag := flagSet.Lookup(name)
+		if flag == nil || name == CONFIG || name == "help" || name == "version" {
+			return errors.Errorf("Unrecognized flag %v in config file %s", item.Key, configFile)
+		}
+		if flag.Changed {
+			continue
+		}
+		values, isList := item.Value.([]interface{})
+		if !isList {
+			values = []interface{}{item.Value}
+		} else if _, ok := flag.Value.(pflag.SliceValue); !ok {
+			return errors.Errorf("Flag %s in config file %s takes a single value, not a list", name, configFile)
+		}
+		for _, value := range values {
+			switch value.(type) {
+			case string, bool, int, int64, uint64, float64:
+			default:
+				return errors.Errorf("Invalid value for flag %s in config file %s", name, configFile)
+			}
+			err = flagSet.Set(name, fmt.Sprint(value))
+			if err != nil {
+				return errors.Errorf("Invalid value %v for flag %s in config file %s: %v", value, name, configFile, err)
+			}
+		}
+	}
+	return nil
+}
+
+/*
+ * Returns the flags that were set, whether on the command line or in a config
+ * file, in the format of a config file.
+ */
+func EffectiveConfig(flagSet *pflag.FlagSet) string {
+	config := yaml.MapSlice{}
+	flagSet.Visit(func(flag *pflag.Flag) {
+		if flag.Name == CONFIG {
+			return
+		}
+		var value interface{} = flag.Value.String()
+		if sliceValue, ok := flag.Value.(pflag.SliceValue); ok {
+			value = sliceValue.GetSlice()
+		} else if flag.Value.Type() == "bool" {
+			value, _ = strconv.ParseBool(flag.Value.String())
+		} else if flag.Value.Type() == "int" {
+			value, _ = strconv.Atoi(flag.Value.String())
+		}
+		config = append(config, yaml.MapItem{Key: flag.Name, Value: value})
+	})
+	if len(config) == 0 {
+		return ""
+	}
+	contents, _ := yaml.Marshal(config)
+	return string(contents)
+}
+
 func MustGetFlagString(cmdFlags *pflag.FlagSet, flagName string) string {
 	value, err := cmdFlags.GetString(flagName)
 	gplog.FatalOnError(err)
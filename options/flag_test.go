@@ -1,8 +1,10 @@
 package options_test
 
 import (
+	"errors"
 	"flag"
 
+	"github.com/greenplum-db/gp-common-go-libs/operating"
 	"github.com/greenplum-db/gp-common-go-libs/testhelper"
 	"github.com/greenplum-db/gpbackup/options"
 	"github.com/spf13/pflag"
@@ -57,5 +59,128 @@ var _ = Describe("utils/flag tests", func() {
 				Expect(result).To(Equal([]string{"-s", "some_argument"}))
 			})
 		})
+		Context("ApplyConfigFile", func() {
+			var configContents string
+			BeforeEach(func() {
+				_ = flagSet.String(options.CONFIG, "", "")
+				_ = flagSet.StringArray("arrayFlag", []string{}, "")
+				_ = flagSet.StringSlice("sliceFlag", []string{}, "")
+				operating.System.ReadFile = func(string) ([]byte, error) { return []byte(configContents), nil }
+			})
+			AfterEach(func() {
+				operating.System = operating.InitializeSystemFunctions()
+			})
+			It("does nothing without a config file", func() {
+				Expect(flagSet.Parse([]string{"--intFlag", "42"})).To(Succeed())
+
+				Expect(options.ApplyConfigFile(flagSet)).To(Succeed())
+
+				Expect(flagSet.Changed("stringFlag")).To(BeFalse())
+			})
+			It("sets the flags in the file as changed", func() {
+				configContents = `
+stringFlag: foo
+boolFlag: true
+intFlag: 42
+arrayFlag:
+  - schema.table1
+  - schema.table2
+sliceFlag: [db1, db2]
+`
+				Expect(flagSet.Parse([]string{"--config", "/tmp/config.yaml"})).To(Succeed())
+
+				Expect(options.ApplyConfigFile(flagSet)).To(Succeed())
+
+				Expect(options.MustGetFlagString(flagSet, "stringFlag")).To(Equal("foo"))
+				Expect(options.MustGetFlagBool(flagSet, "boolFlag")).To(BeTrue())
+				Expect(options.MustGetFlagInt(flagSet, "intFlag")).To(Equal(42))
+				Expect(options.MustGetFlagStringArray(flagSet, "arrayFlag")).To(Equal([]string{"schema.table1", "schema.table2"}))
+				Expect(options.MustGetFlagStringSlice(flagSet, "sliceFlag")).To(Equal([]string{"db1", "db2"}))
+				for _, name := range []string{"stringFlag", "boolFlag", "intFlag", "arrayFlag", "sliceFlag"} {
+					Expect(flagSet.Changed(name)).To(BeTrue())
+				}
+			})
+			It("does not override flags given on the command line", func() {
+				configContents = "stringFlag: foo\narrayFlag: [schema.table1]\nintFlag: 42\n"
+				Expect(flagSet.Parse([]string{"--config", "/tmp/config.yaml", "--stringFlag", "bar", "--arrayFlag", "schema.table2"})).To(Succeed())
+
+				Expect(options.ApplyConfigFile(flagSet)).To(Succeed())
+
+				Expect(options.MustGetFlagString(flagSet, "stringFlag")).To(Equal("bar"))
+				Expect(options.MustGetFlagStringArray(flagSet, "arrayFlag")).To(Equal([]string{"schema.table2"}))
+				Expect(options.MustGetFlagInt(flagSet, "intFlag")).To(Equal(42))
+			})
+			It("returns an error for an unrecognized flag", func() {
+				configContents = "notAFlag: foo\n"
+				Expect(flagSet.Parse([]string{"--config", "/tmp/config.yaml"})).To(Succeed())
+
+				err := options.ApplyConfigFile(flagSet)
+
+				Expect(err).To(MatchError("Unrecognized flag notAFlag in config file /tmp/config.yaml"))
+			})
+			It("returns an error for a config file that sets the config file", func() {
+				configContents = "config: /tmp/other.yaml\n"
+				Expect(flagSet.Parse([]string{"--config", "/tmp/config.yaml"})).To(Succeed())
+
+				err := options.ApplyConfigFile(flagSet)
+
+				Expect(err).To(MatchError("Unrecognized flag config in config file /tmp/config.yaml"))
+			})
+			It("returns an error for a list of values for a single-valued flag", func() {
+				configContents = "stringFlag: [foo, bar]\n"
+				Expect(flagSet.Parse([]string{"--config", "/tmp/config.yaml"})).To(Succeed())
+
+				err := options.ApplyConfigFile(flagSet)
+
+				Expect(err).To(MatchError("Flag stringFlag in config file /tmp/config.yaml takes a single value, not a list"))
+			})
+			It("returns an error for an invalid value", func() {
+				configContents = "intFlag: foo\n"
+				Expect(flagSet.Parse([]string{"--config", "/tmp/config.yaml"})).To(Succeed())
+
+				err := options.ApplyConfigFile(flagSet)
+
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(HavePrefix("Invalid value foo for flag intFlag in config file /tmp/config.yaml"))
+			})
+			It("returns an error for a file that is not YAML", func() {
+				configContents = "- foo\n- bar\n"
+				Expect(flagSet.Parse([]string{"--config", "/tmp/config.yaml"})).To(Succeed())
+
+				err := options.ApplyConfigFile(flagSet)
+
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(HavePrefix("Config file /tmp/config.yaml is formatted incorrectly"))
+			})
+			It("returns an error if the file cannot be read", func() {
+				operating.System.ReadFile = func(string) ([]byte, error) { return nil, errors.New("permission denied") }
+				Expect(flagSet.Parse([]string{"--config", "/tmp/config.yaml"})).To(Succeed())
+
+				err := options.ApplyConfigFile(flagSet)
+
+				Expect(err).To(MatchError("Unable to read config file /tmp/config.yaml: permission denied"))
+			})
+		})
+		Context("EffectiveConfig", func() {
+			It("returns the flags that were set in the format of a config file", func() {
+				_ = flagSet.String(options.CONFIG, "", "")
+				_ = flagSet.StringArray("arrayFlag", []string{}, "")
+				Expect(flagSet.Parse([]string{"--config", "/tmp/config.yaml", "--stringFlag", "foo", "--boolFlag", "--intFlag", "42",
+					"--arrayFlag", "schema.table1", "--arrayFlag", "schema.table2"})).To(Succeed())
+
+				config := options.EffectiveConfig(flagSet)
+
+				Expect(config).To(Equal(`arrayFlag:
+- schema.table1
+- schema.table2
+boolFlag: true
+intFlag: 42
+stringFlag: foo
+`))
+			})
+			It("returns nothing if no flags were set", func() {
+				Expect(options.EffectiveConfig(flagSet)).To(Equal(""))
+			})
+		})
 	})
 })
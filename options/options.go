@@ -22,6 +22,10 @@ type Options struct {
 	IncludedSchemas           []string
 	originalIncludedRelations []string
 	RedirectSchema            string
+	includedSchemaPatterns    []NamePattern
+	excludedSchemaPatterns    []NamePattern
+	includedRelationPatterns  []NamePattern
+	excludedRelationPatterns  []NamePattern
 }
 
 func NewOptions(initialFlags *pflag.FlagSet) (*Options, error) {
@@ -53,6 +57,26 @@ func NewOptions(initialFlags *pflag.FlagSet) (*Options, error) {
 		return nil, err
 	}
 
+	includedSchemaPatterns, err := getPatternsFromFlag(initialFlags, INCLUDE_SCHEMA_PATTERN, NewSchemaPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	excludedSchemaPatterns, err := getPatternsFromFlag(initialFlags, EXCLUDE_SCHEMA_PATTERN, NewSchemaPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	includedRelationPatterns, err := getPatternsFromFlag(initialFlags, INCLUDE_RELATION_PATTERN, NewRelationPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	excludedRelationPatterns, err := getPatternsFromFlag(initialFlags, EXCLUDE_RELATION_PATTERN, NewRelationPattern)
+	if err != nil {
+		return nil, err
+	}
+
 	leafPartitionData, err := initialFlags.GetBool(LEAF_PARTITION_DATA)
 	if err != nil {
 		return nil, err
@@ -74,6 +98,10 @@ func NewOptions(initialFlags *pflag.FlagSet) (*Options, error) {
 		isLeafPartitionData:       leafPartitionData,
 		originalIncludedRelations: includedRelations,
 		RedirectSchema:            redirectSchema,
+		includedSchemaPatterns:    includedSchemaPatterns,
+		excludedSchemaPatterns:    excludedSchemaPatterns,
+		includedRelationPatterns:  includedRelationPatterns,
+		excludedRelationPatterns:  excludedRelationPatterns,
 	}, nil
 }
 
@@ -0,0 +1,237 @@
+package options
+
+/*
+ * This file contains functions related to the schema and table patterns given
+ * with --include-schema-pattern, --exclude-schema-pattern,
+ * --include-table-pattern, and --exclude-table-pattern.
+ *
+ * Patterns follow the same rules as pg_dump and psql patterns: * matches any
+ * sequence of characters, ? matches any single character, a dot separates the
+ * schema from the table, names are folded to lower case unless double-quoted,
+ * and any other regular expression syntax, such as [0-9] or (a|b), is used as
+ * is.  A pattern must match the entire name.
+ */
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+type NamePattern struct {
+	Pattern     string
+	schemaRegex *regexp.Regexp
+	nameRegex   *regexp.Regexp
+}
+
+func NewSchemaPattern(pattern string) (NamePattern, error) {
+	parts, err := patternToRegexes(pattern)
+	if err != nil {
+		return NamePattern{}, err
+	}
+	if len(parts) != 1 {
+		return NamePattern{}, errors.Errorf(`Schema pattern %s cannot contain a dot (.) outside of double quotes`, pattern)
+	}
+	return NamePattern{Pattern: pattern, schemaRegex: parts[0]}, nil
+}
+
+func NewRelationPattern(pattern string) (NamePattern, error) {
+	parts, err := patternToRegexes(pattern)
+	if err != nil {
+		return NamePattern{}, err
+	}
+	if len(parts) != 2 {
+		return NamePattern{}, errors.Errorf(`Table pattern %s is not of the form "schema.table"`, pattern)
+	}
+	return NamePattern{Pattern: pattern, schemaRegex: parts[0], nameRegex: parts[1]}, nil
+}
+
+// Returns one anchored regular expression per dot-separated part of the pattern
+func patternToRegexes(pattern string) ([]*regexp.Regexp, error) {
+	sources := make([]string, 0)
+	var source strings.Builder
+	inQuotes := false
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '"':
+			if inQuotes && i+1 < len(runes) && runes[i+1] == '"' {
+				source.WriteRune('"')
+				i++
+			} else {
+				inQuotes = !inQuotes
+			}
+		case inQuotes:
+			source.WriteString(regexp.QuoteMeta(string(r)))
+		case r == '*':
+			source.WriteString(".*")
+		case r == '?':
+			source.WriteString(".")
+		case r == '.':
+			sources = append(sources, source.String())
+			source.Reset()
+		case r == '$':
+			source.WriteString(`\$`)
+		default:
+			source.WriteRune(unicode.ToLower(r))
+		}
+	}
+	if inQuotes {
+		return nil, errors.Errorf("Pattern %s has an unterminated double quote", pattern)
+	}
+	sources = append(sources, source.String())
+
+	regexes := make([]*regexp.Regexp, 0, len(sources))
+	for _, source := range sources {
+		if source == "" {
+			return nil, errors.Errorf("Pattern %s has an empty schema or table name", pattern)
+		}
+		regex, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", source))
+		if err != nil {
+			return nil, errors.Errorf("Pattern %s is not valid: %v", pattern, err)
+		}
+		regexes = append(regexes, regex)
+	}
+	return regexes, nil
+}
+
+// The schema and name are unquoted; the name is ignored for schema patterns
+func (p NamePattern) Matches(schema string, name string) bool {
+	if !p.schemaRegex.MatchString(schema) {
+		return false
+	}
+	return p.nameRegex == nil || p.nameRegex.MatchString(name)
+}
+
+func (p NamePattern) String() string {
+	return p.Pattern
+}
+
+func getPatternsFromFlag(initialFlags *pflag.FlagSet, patternFlag string, newPattern func(string) (NamePattern, error)) ([]NamePattern, error) {
+	patterns := make([]NamePattern, 0)
+	if initialFlags.Lookup(patternFlag) == nil {
+		return patterns, nil
+	}
+	patternStrs, err := initialFlags.GetStringArray(patternFlag)
+	if err != nil {
+		return nil, err
+	}
+	for _, patternStr := range patternStrs {
+		pattern, err := newPattern(patternStr)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+type patternCandidate struct {
+	schema string
+	name   string
+	filter string
+}
+
+/*
+ * Adds the filter of each candidate matching one of the patterns to filters
+ * and to the values of filterFlag.  An include pattern must match at least
+ * one candidate, as otherwise everything would be included.
+ */
+func expandPatterns(flags *pflag.FlagSet, patterns []NamePattern, patternFlag string, filterFlag string,
+	filters []string, candidates []patternCandidate, objectType string) ([]string, error) {
+	isInclude := strings.HasPrefix(patternFlag, "include")
+	filterSet := make(map[string]bool, len(filters))
+	for _, filter := range filters {
+		filterSet[filter] = true
+	}
+	for _, pattern := range patterns {
+		matches := make([]string, 0)
+		for _, candidate := range candidates {
+			if pattern.Matches(candidate.schema, candidate.name) {
+				matches = append(matches, candidate.filter)
+			}
+		}
+		if len(matches) == 0 {
+			if isInclude {
+				return nil, errors.Errorf("No %ss match --%s %s", objectType, patternFlag, pattern)
+			}
+			gplog.Warn("No %ss match --%s %s", objectType, patternFlag, pattern)
+			continue
+		}
+		gplog.Info("Pattern --%s %s matches %d %s(s): %s", patternFlag, pattern, len(matches), objectType, strings.Join(matches, ", "))
+		for _, match := range matches {
+			if filterSet[match] {
+				continue
+			}
+			filterSet[match] = true
+			filters = append(filters, match)
+			err := flags.Set(filterFlag, match)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return filters, nil
+}
+
+func (o Options) HasSchemaPatterns() bool {
+	return len(o.includedSchemaPatterns) > 0 || len(o.excludedSchemaPatterns) > 0
+}
+
+func (o Options) HasRelationPatterns() bool {
+	return len(o.includedRelationPatterns) > 0 || len(o.excludedRelationPatterns) > 0
+}
+
+/*
+ * Adds the schemas matching the schema patterns to the included and excluded
+ * schemas.  Schema names are given in the form in which they are filtered,
+ * which is quoted for the TOC at restore time, so quoted names are unquoted
+ * before they are matched.
+ */
+func (o *Options) ExpandSchemaPatterns(flags *pflag.FlagSet, schemas []string, quoted bool) error {
+	candidates := make([]patternCandidate, 0, len(schemas))
+	for _, schema := range schemas {
+		unquotedSchema := schema
+		if quoted {
+			unquotedSchema = utils.UnquoteIdent(schema)
+		}
+		candidates = append(candidates, patternCandidate{schema: unquotedSchema, filter: schema})
+	}
+
+	var err error
+	o.IncludedSchemas, err = expandPatterns(flags, o.includedSchemaPatterns, INCLUDE_SCHEMA_PATTERN, INCLUDE_SCHEMA, o.IncludedSchemas, candidates, "schema")
+	if err != nil {
+		return err
+	}
+	o.ExcludedSchemas, err = expandPatterns(flags, o.excludedSchemaPatterns, EXCLUDE_SCHEMA_PATTERN, EXCLUDE_SCHEMA, o.ExcludedSchemas, candidates, "schema")
+	return err
+}
+
+// Relations are given and matched the same way as schemas in ExpandSchemaPatterns
+func (o *Options) ExpandRelationPatterns(flags *pflag.FlagSet, relations []FqnStruct, quoted bool) error {
+	candidates := make([]patternCandidate, 0, len(relations))
+	for _, relation := range relations {
+		candidate := patternCandidate{schema: relation.SchemaName, name: relation.TableName, filter: utils.MakeFQN(relation.SchemaName, relation.TableName)}
+		if quoted {
+			candidate.schema = utils.UnquoteIdent(relation.SchemaName)
+			candidate.name = utils.UnquoteIdent(relation.TableName)
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	var err error
+	o.IncludedRelations, err = expandPatterns(flags, o.includedRelationPatterns, INCLUDE_RELATION_PATTERN, INCLUDE_RELATION, o.IncludedRelations, candidates, "table")
+	if err != nil {
+		return err
+	}
+	o.originalIncludedRelations = o.IncludedRelations
+	o.ExcludedRelations, err = expandPatterns(flags, o.excludedRelationPatterns, EXCLUDE_RELATION_PATTERN, EXCLUDE_RELATION, o.ExcludedRelations, candidates, "table")
+	return err
+}
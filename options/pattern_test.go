@@ -0,0 +1,191 @@
+package options_test
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/spf13/pflag"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("options/pattern tests", func() {
+	Describe("NewSchemaPattern", func() {
+		It("matches wildcards against the entire name", func() {
+			pattern, err := options.NewSchemaPattern("stg_*")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(pattern.Matches("stg_sales", "")).To(BeTrue())
+			Expect(pattern.Matches("stg_", "")).To(BeTrue())
+			Expect(pattern.Matches("old_stg_sales", "")).To(BeFalse())
+		})
+		It("matches ? against a single character", func() {
+			pattern, err := options.NewSchemaPattern("q?")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(pattern.Matches("q1", "")).To(BeTrue())
+			Expect(pattern.Matches("q10", "")).To(BeFalse())
+		})
+		It("uses other regular expression syntax as is", func() {
+			pattern, err := options.NewSchemaPattern("sales_(us|eu)[0-9]+")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(pattern.Matches("sales_us1", "")).To(BeTrue())
+			Expect(pattern.Matches("sales_eu42", "")).To(BeTrue())
+			Expect(pattern.Matches("sales_uk1", "")).To(BeFalse())
+		})
+		It("folds unquoted names to lower case", func() {
+			pattern, err := options.NewSchemaPattern("Sales*")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(pattern.Matches("sales", "")).To(BeTrue())
+			Expect(pattern.Matches("Sales", "")).To(BeFalse())
+		})
+		It("matches quoted names literally", func() {
+			pattern, err := options.NewSchemaPattern(`"Sales*.""x"""`)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(pattern.Matches(`Sales*."x"`, "")).To(BeTrue())
+			Expect(pattern.Matches(`Sales1."x"`, "")).To(BeFalse())
+		})
+		It("treats $ as a literal character", func() {
+			pattern, err := options.NewSchemaPattern("price$*")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(pattern.Matches("price$usd", "")).To(BeTrue())
+		})
+		It("returns an error for a pattern with a dot", func() {
+			_, err := options.NewSchemaPattern("sales.fact")
+
+			Expect(err).To(MatchError("Schema pattern sales.fact cannot contain a dot (.) outside of double quotes"))
+		})
+		It("returns an error for an unterminated double quote", func() {
+			_, err := options.NewSchemaPattern(`"sales`)
+
+			Expect(err).To(MatchError(`Pattern "sales has an unterminated double quote`))
+		})
+		It("returns an error for an invalid regular expression", func() {
+			_, err := options.NewSchemaPattern("sales(")
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("Pattern sales( is not valid"))
+		})
+	})
+	Describe("NewRelationPattern", func() {
+		It("matches the schema and table separately", func() {
+			pattern, err := options.NewRelationPattern("sales.fact_*")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(pattern.Matches("sales", "fact_orders")).To(BeTrue())
+			Expect(pattern.Matches("sales", "dim_date")).To(BeFalse())
+			Expect(pattern.Matches("sales_old", "fact_orders")).To(BeFalse())
+		})
+		It("matches a table in any schema", func() {
+			pattern, err := options.NewRelationPattern("*.stg_*")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(pattern.Matches("public", "stg_orders")).To(BeTrue())
+			Expect(pattern.Matches("sales", "stg_returns")).To(BeTrue())
+		})
+		It("returns an error for a pattern without a schema", func() {
+			_, err := options.NewRelationPattern("fact_*")
+
+			Expect(err).To(MatchError(`Table pattern fact_* is not of the form "schema.table"`))
+		})
+		It("returns an error for a pattern with an empty table name", func() {
+			_, err := options.NewRelationPattern("sales.")
+
+			Expect(err).To(MatchError("Pattern sales. has an empty schema or table name"))
+		})
+	})
+	Describe("Pattern expansion", func() {
+		var (
+			myflags *pflag.FlagSet
+			logfile *Buffer
+		)
+		BeforeEach(func() {
+			_, _, logfile = testhelper.SetupTestLogger()
+			myflags = &pflag.FlagSet{}
+			options.SetBackupFlagDefaults(myflags)
+		})
+		It("returns an error from NewOptions for an invalid pattern", func() {
+			err := myflags.Set(options.INCLUDE_RELATION_PATTERN, "fact_*")
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = options.NewOptions(myflags)
+
+			Expect(err).To(MatchError(`Table pattern fact_* is not of the form "schema.table"`))
+		})
+		It("adds the matching schemas to the schema filters and flags", func() {
+			Expect(myflags.Set(options.INCLUDE_SCHEMA_PATTERN, "stg_*")).To(Succeed())
+			Expect(myflags.Set(options.EXCLUDE_SCHEMA_PATTERN, "*_old")).To(Succeed())
+			opts, err := options.NewOptions(myflags)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(opts.HasSchemaPatterns()).To(BeTrue())
+			Expect(opts.HasRelationPatterns()).To(BeFalse())
+
+			err = opts.ExpandSchemaPatterns(myflags, []string{"public", "stg_a", "stg_b", "stg_b_old"}, false)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(opts.GetIncludedSchemas()).To(Equal([]string{"stg_a", "stg_b", "stg_b_old"}))
+			Expect(opts.GetExcludedSchemas()).To(Equal([]string{"stg_b_old"}))
+			Expect(myflags.GetStringArray(options.INCLUDE_SCHEMA)).To(Equal([]string{"stg_a", "stg_b", "stg_b_old"}))
+			Expect(myflags.GetStringArray(options.EXCLUDE_SCHEMA)).To(Equal([]string{"stg_b_old"}))
+		})
+		It("adds the matching tables to the table filters and flags", func() {
+			Expect(myflags.Set(options.INCLUDE_RELATION_PATTERN, "sales.fact_*")).To(Succeed())
+			Expect(myflags.Set(options.INCLUDE_RELATION_PATTERN, "sales.*_2020")).To(Succeed())
+			opts, err := options.NewOptions(myflags)
+			Expect(err).ToNot(HaveOccurred())
+			relations := []options.FqnStruct{
+				{SchemaName: "sales", TableName: "fact_orders"},
+				{SchemaName: "sales", TableName: "fact_2020"},
+				{SchemaName: "sales", TableName: "dim_date"},
+				{SchemaName: "public", TableName: "fact_orders"},
+			}
+
+			err = opts.ExpandRelationPatterns(myflags, relations, false)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(opts.GetIncludedTables()).To(Equal([]string{"sales.fact_orders", "sales.fact_2020"}))
+			Expect(opts.GetOriginalIncludedTables()).To(Equal([]string{"sales.fact_orders", "sales.fact_2020"}))
+			Expect(myflags.GetStringArray(options.INCLUDE_RELATION)).To(Equal([]string{"sales.fact_orders", "sales.fact_2020"}))
+			Expect(string(logfile.Contents())).To(ContainSubstring("Pattern --include-table-pattern sales.fact_* matches 2 table(s): sales.fact_orders, sales.fact_2020"))
+		})
+		It("matches quoted names unquoted and adds them quoted", func() {
+			Expect(myflags.Set(options.EXCLUDE_RELATION_PATTERN, `"Sales".*`)).To(Succeed())
+			opts, err := options.NewOptions(myflags)
+			Expect(err).ToNot(HaveOccurred())
+			relations := []options.FqnStruct{
+				{SchemaName: `"Sales"`, TableName: `"Fact"`},
+				{SchemaName: "sales", TableName: "fact"},
+			}
+
+			err = opts.ExpandRelationPatterns(myflags, relations, true)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(opts.GetExcludedTables()).To(Equal([]string{`"Sales"."Fact"`}))
+		})
+		It("returns an error if an include pattern matches nothing", func() {
+			Expect(myflags.Set(options.INCLUDE_SCHEMA_PATTERN, "stg_*")).To(Succeed())
+			opts, err := options.NewOptions(myflags)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = opts.ExpandSchemaPatterns(myflags, []string{"public"}, false)
+
+			Expect(err).To(MatchError("No schemas match --include-schema-pattern stg_*"))
+		})
+		It("warns if an exclude pattern matches nothing", func() {
+			Expect(myflags.Set(options.EXCLUDE_RELATION_PATTERN, "public.stg_*")).To(Succeed())
+			opts, err := options.NewOptions(myflags)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = opts.ExpandRelationPatterns(myflags, []options.FqnStruct{{SchemaName: "public", TableName: "foo"}}, false)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(opts.GetExcludedTables()).To(BeEmpty())
+			Expect(string(logfile.Contents())).To(ContainSubstring("No tables match --exclude-table-pattern public.stg_*"))
+		})
+	})
+})
@@ -0,0 +1,410 @@
+package plugincheck
+
+/*
+ * This file contains the conformance checks.  Each check calls the plugin with
+ * the same arguments gpbackup and gprestore use, then verifies the plugin's
+ * output along with its exit code and stderr semantics: commands must exit 0
+ * on success, and must exit non-zero with a message on stderr on failure.
+ */
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	path "path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	fileContents       = "this is a gpbackup plugin-check file\n"
+	smallDataSize      = 1000
+	concurrentDataSize = 1024 * 1024
+)
+
+type CheckResult struct {
+	Name    string
+	Passed  bool
+	Message string
+}
+
+type PluginChecker struct {
+	ExecutablePath string
+	ConfigPath     string
+	// Local files are created under this directory, laid out like a cluster's backup directories
+	LocalDir      string
+	NumSegments   int
+	LargeDataSize int64
+	// delete_backup is checked by deleting the first backup while the second remains
+	Timestamp        string
+	SiblingTimestamp string
+	apiVersion       semver.Version
+}
+
+func NewPluginChecker(executablePath string, configPath string, localDir string) *PluginChecker {
+	now := operating.System.Now()
+	return &PluginChecker{
+		ExecutablePath:   executablePath,
+		ConfigPath:       configPath,
+		LocalDir:         localDir,
+		NumSegments:      1,
+		LargeDataSize:    smallDataSize,
+		Timestamp:        now.Format("20060102150405"),
+		SiblingTimestamp: now.Add(time.Second).Format("20060102150405"),
+	}
+}
+
+func (checker *PluginChecker) RunChecks() []CheckResult {
+	checks := []struct {
+		name  string
+		check func() error
+	}{
+		{"plugin_api_version", checker.checkAPIVersion},
+		{"--version", checker.checkNativeVersion},
+		{"capabilities", checker.checkCapabilities},
+		{"setup_plugin_for_backup", func() error { return checker.runHooks("setup_plugin_for_backup", checker.Timestamp) }},
+		{"backup_file", checker.checkBackupFile},
+		{"setup_plugin_for_restore", func() error { return checker.runHooks("setup_plugin_for_restore", checker.Timestamp) }},
+		{"restore_file", checker.checkRestoreFile},
+		{"restore_file of a missing file", checker.checkRestoreMissingFile},
+		{"backup_data and restore_data", func() error { return checker.checkDataRoundTrip("check_data", smallDataSize) }},
+		{"backup_data and restore_data with no data", func() error { return checker.checkDataRoundTrip("check_no_data", 0) }},
+		{"backup_data and restore_data with large data", func() error { return checker.checkDataRoundTrip("check_large_data", checker.LargeDataSize) }},
+		{"concurrent backup_data and restore_data", checker.checkConcurrentData},
+		{"delete_backup", checker.checkDeleteBackup},
+		{"cleanup_plugin_for_backup", func() error { return checker.runHooks("cleanup_plugin_for_backup", checker.Timestamp) }},
+		{"cleanup_plugin_for_restore", func() error { return checker.runHooks("cleanup_plugin_for_restore", checker.Timestamp) }},
+		{"unknown command", func() error { return checker.runPluginExpectingFailure("unknown_command") }},
+	}
+
+	results := make([]CheckResult, 0, len(checks))
+	for _, check := range checks {
+		gplog.Info("Checking %s", check.name)
+		err := check.check()
+		result := CheckResult{Name: check.name, Passed: err == nil}
+		if err != nil {
+			result.Message = err.Error()
+			gplog.Verbose("Check %s failed: %s", check.name, result.Message)
+		}
+		results = append(results, result)
+	}
+	checker.removeSiblingBackup()
+	return results
+}
+
+/*
+ * Local paths mirror a cluster's backup directories, <segment dir>/backups/
+ * <date>/<timestamp>/<file>, as plugins commonly derive their storage
+ * locations from that layout.
+ */
+func (checker *PluginChecker) backupDir(timestamp string, contentID int) string {
+	return path.Join(checker.LocalDir, fmt.Sprintf("gpseg%d", contentID), "backups", timestamp[0:8], timestamp)
+}
+
+func (checker *PluginChecker) backupFilePath(timestamp string, contentID int, name string) string {
+	return path.Join(checker.backupDir(timestamp, contentID), fmt.Sprintf("gpbackup_%d_%s_%s", contentID, timestamp, name))
+}
+
+func (checker *PluginChecker) runPlugin(stdin io.Reader, stdout io.Writer, args ...string) error {
+	cmd := exec.Command(checker.ExecutablePath, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	gplog.Debug("%s %s", checker.ExecutablePath, strings.Join(args, " "))
+	err := cmd.Run()
+	if err != nil {
+		return errors.Errorf("%s failed with %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (checker *PluginChecker) runPluginExpectingFailure(args ...string) error {
+	cmd := exec.Command(checker.ExecutablePath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	gplog.Debug("%s %s", checker.ExecutablePath, strings.Join(args, " "))
+	err := cmd.Run()
+	if err == nil {
+		return errors.Errorf("%s exited 0, but should exit non-zero when it fails", args[0])
+	}
+	if strings.TrimSpace(stderr.String()) == "" {
+		return errors.Errorf("%s failed with %v, but did not write an error message to stderr", args[0], err)
+	}
+	return nil
+}
+
+func (checker *PluginChecker) checkAPIVersion() error {
+	var stdout bytes.Buffer
+	err := checker.runPlugin(nil, &stdout, "plugin_api_version")
+	if err != nil {
+		return err
+	}
+	apiVersion := strings.TrimSpace(stdout.String())
+	version, err := semver.Make(apiVersion)
+	if err != nil {
+		return errors.Errorf("Unable to parse plugin API version %q: %v", apiVersion, err)
+	}
+	requiredVersion, _ := semver.Make(utils.RequiredPluginVersion)
+	if !version.GE(requiredVersion) {
+		return errors.Errorf("Plugin API version %s is lower than the required version %s", version, requiredVersion)
+	}
+	checker.apiVersion = version
+	return nil
+}
+
+/*
+ * Plugins implementing API versions before the capabilities command was
+ * added are not expected to support it, so the check passes trivially.
+ */
+func (checker *PluginChecker) checkCapabilities() error {
+	capabilitiesVersion, _ := semver.Make(utils.CapabilitiesPluginVersion)
+	if checker.apiVersion.LT(capabilitiesVersion) {
+		gplog.Verbose("Plugin API version %s does not support the capabilities command", checker.apiVersion)
+		return nil
+	}
+	var stdout bytes.Buffer
+	err := checker.runPlugin(nil, &stdout, "capabilities")
+	if err != nil {
+		return err
+	}
+	_, err = utils.ParsePluginCapabilities(stdout.String())
+	return err
+}
+
+func (checker *PluginChecker) checkNativeVersion() error {
+	var stdout bytes.Buffer
+	err := checker.runPlugin(nil, &stdout, "--version")
+	if err != nil {
+		return err
+	}
+	parts := strings.Fields(stdout.String())
+	if len(parts) < 3 || parts[1] != "version" {
+		return errors.Errorf("--version output %q is not in the format \"[plugin_name] version [git_version]\"", strings.TrimSpace(stdout.String()))
+	}
+	return nil
+}
+
+/*
+ * Hooks are run once for the master, once for the segment host, and once for
+ * each simulated segment, with the content ID quoted as gpbackup passes it.
+ */
+func (checker *PluginChecker) runHooks(command string, timestamp string) error {
+	err := checker.runPlugin(nil, ioutil.Discard, command, checker.ConfigPath, checker.backupDir(timestamp, -1), string(utils.MASTER), `"-1"`)
+	if err != nil {
+		return err
+	}
+	err = checker.runPlugin(nil, ioutil.Discard, command, checker.ConfigPath, checker.backupDir(timestamp, 0), string(utils.SEGMENT_HOST))
+	if err != nil {
+		return err
+	}
+	for contentID := 0; contentID < checker.NumSegments; contentID++ {
+		err = checker.runPlugin(nil, ioutil.Discard, command, checker.ConfigPath, checker.backupDir(timestamp, contentID), string(utils.SEGMENT), fmt.Sprintf(`"%d"`, contentID))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (checker *PluginChecker) writeLocalFile(filename string) error {
+	err := os.MkdirAll(path.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, []byte(fileContents), 0644)
+}
+
+func (checker *PluginChecker) checkLocalFile(filename string, command string) error {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return errors.Wrapf(err, "Local file is missing after %s", command)
+	}
+	if string(contents) != fileContents {
+		return errors.Errorf("Local file %s has different contents after %s", filename, command)
+	}
+	return nil
+}
+
+func (checker *PluginChecker) checkBackupFile() error {
+	filename := checker.backupFilePath(checker.Timestamp, -1, "check_file")
+	err := checker.writeLocalFile(filename)
+	if err != nil {
+		return err
+	}
+	err = checker.runPlugin(nil, ioutil.Discard, "backup_file", checker.ConfigPath, filename)
+	if err != nil {
+		return err
+	}
+	// gpbackup expects the local copy of the file to be left in place
+	return checker.checkLocalFile(filename, "backup_file")
+}
+
+func (checker *PluginChecker) checkRestoreFile() error {
+	filename := checker.backupFilePath(checker.Timestamp, -1, "check_file")
+	err := os.Remove(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = checker.runPlugin(nil, ioutil.Discard, "restore_file", checker.ConfigPath, filename)
+	if err != nil {
+		return err
+	}
+	return checker.checkLocalFile(filename, "restore_file")
+}
+
+func (checker *PluginChecker) checkRestoreMissingFile() error {
+	filename := checker.backupFilePath(checker.Timestamp, -1, "check_missing_file")
+	return checker.runPluginExpectingFailure("restore_file", checker.ConfigPath, filename)
+}
+
+/*
+ * Data is generated from a seed and compared by checksum, so that large
+ * inputs are streamed through the plugin without being held in memory.
+ */
+func (checker *PluginChecker) backupData(filename string, size int64, seed int64) ([]byte, error) {
+	hash := sha256.New()
+	data := io.TeeReader(io.LimitReader(rand.New(rand.NewSource(seed)), size), hash)
+	err := checker.runPlugin(data, ioutil.Discard, "backup_data", checker.ConfigPath, filename)
+	if err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+type byteCounter struct {
+	count int64
+}
+
+func (counter *byteCounter) Write(p []byte) (int, error) {
+	counter.count += int64(len(p))
+	return len(p), nil
+}
+
+func (checker *PluginChecker) verifyRestoredData(filename string, size int64, checksum []byte) error {
+	hash := sha256.New()
+	counter := &byteCounter{}
+	err := checker.runPlugin(nil, io.MultiWriter(hash, counter), "restore_data", checker.ConfigPath, filename)
+	if err != nil {
+		return err
+	}
+	if counter.count != size {
+		return errors.Errorf("restore_data returned %d bytes for %s, but %d bytes were backed up", counter.count, filename, size)
+	}
+	if !bytes.Equal(hash.Sum(nil), checksum) {
+		return errors.Errorf("restore_data returned different data for %s than was backed up", filename)
+	}
+	return nil
+}
+
+func (checker *PluginChecker) roundTripData(filename string, size int64, seed int64) error {
+	checksum, err := checker.backupData(filename, size, seed)
+	if err != nil {
+		return err
+	}
+	return checker.verifyRestoredData(filename, size, checksum)
+}
+
+func (checker *PluginChecker) checkDataRoundTrip(name string, size int64) error {
+	filename := checker.backupFilePath(checker.Timestamp, 0, name)
+	err := os.MkdirAll(path.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+	return checker.roundTripData(filename, size, size)
+}
+
+// Each simulated segment streams its own file at the same time, as during a backup
+func (checker *PluginChecker) checkConcurrentData() error {
+	errs := make([]error, checker.NumSegments)
+	var wg sync.WaitGroup
+	for contentID := 0; contentID < checker.NumSegments; contentID++ {
+		filename := checker.backupFilePath(checker.Timestamp, contentID, "check_concurrent_data")
+		err := os.MkdirAll(path.Dir(filename), 0755)
+		if err != nil {
+			return err
+		}
+		wg.Add(1)
+		go func(contentID int, filename string) {
+			defer wg.Done()
+			errs[contentID] = checker.roundTripData(filename, concurrentDataSize, int64(contentID))
+		}(contentID, filename)
+	}
+	wg.Wait()
+	for contentID, err := range errs {
+		if err != nil {
+			return errors.Wrapf(err, "Segment %d", contentID)
+		}
+	}
+	return nil
+}
+
+func (checker *PluginChecker) checkDeleteBackup() error {
+	err := checker.runHooks("setup_plugin_for_backup", checker.SiblingTimestamp)
+	if err != nil {
+		return err
+	}
+	checksums := make(map[string][]byte, 2)
+	for _, timestamp := range []string{checker.Timestamp, checker.SiblingTimestamp} {
+		dataFile := checker.backupFilePath(timestamp, 0, "check_delete_data")
+		err = os.MkdirAll(path.Dir(dataFile), 0755)
+		if err != nil {
+			return err
+		}
+		checksums[timestamp], err = checker.backupData(dataFile, smallDataSize, 0)
+		if err != nil {
+			return err
+		}
+		metadataFile := checker.backupFilePath(timestamp, -1, "check_delete_file")
+		err = checker.writeLocalFile(metadataFile)
+		if err != nil {
+			return err
+		}
+		err = checker.runPlugin(nil, ioutil.Discard, "backup_file", checker.ConfigPath, metadataFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = checker.runPlugin(nil, ioutil.Discard, "delete_backup", checker.ConfigPath, checker.Timestamp)
+	if err != nil {
+		return err
+	}
+	err = checker.runPluginExpectingFailure("restore_data", checker.ConfigPath, checker.backupFilePath(checker.Timestamp, 0, "check_delete_data"))
+	if err != nil {
+		return errors.Wrap(err, "Data was not deleted")
+	}
+	err = checker.runPluginExpectingFailure("restore_file", checker.ConfigPath, checker.backupFilePath(checker.Timestamp, -1, "check_delete_file"))
+	if err != nil {
+		return errors.Wrap(err, "File was not deleted")
+	}
+	siblingDataFile := checker.backupFilePath(checker.SiblingTimestamp, 0, "check_delete_data")
+	err = checker.verifyRestoredData(siblingDataFile, smallDataSize, checksums[checker.SiblingTimestamp])
+	if err != nil {
+		return errors.Wrapf(err, "Backup %s was affected by deleting backup %s", checker.SiblingTimestamp, checker.Timestamp)
+	}
+	return nil
+}
+
+// The sibling backup is not itself part of any check, so failures are only logged
+func (checker *PluginChecker) removeSiblingBackup() {
+	err := checker.runPlugin(nil, ioutil.Discard, "delete_backup", checker.ConfigPath, checker.SiblingTimestamp)
+	if err == nil {
+		err = checker.runHooks("cleanup_plugin_for_backup", checker.SiblingTimestamp)
+	}
+	if err != nil {
+		gplog.Warn("Unable to remove backup %s created by plugin-check: %v", checker.SiblingTimestamp, err)
+	}
+}
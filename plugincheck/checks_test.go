@@ -0,0 +1,129 @@
+package plugincheck_test
+
+import (
+	"io/ioutil"
+	path "path/filepath"
+	"strings"
+
+	"github.com/greenplum-db/gpbackup/plugincheck"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+/*
+ * The fake plugin stores files under a directory named in its config file,
+ * keyed by the timestamp directory and name of each file.
+ */
+const fakePluginScript = `#!/bin/bash
+store=$(cat "$2" 2>/dev/null)
+dest() { echo "$store/$(basename "$(dirname "$1")")/$(basename "$1")"; }
+case "$1" in
+	plugin_api_version) echo "0.5.0" ;;
+	--version) echo "fake_plugin version 1.0.0" ;;
+	capabilities) printf "restore_subset: true\\ndelete_backup: true\\n" ;;
+	setup_plugin_for_backup|setup_plugin_for_restore|cleanup_plugin_for_backup|cleanup_plugin_for_restore) ;;
+	backup_file) mkdir -p "$(dirname "$(dest "$3")")" && cp "$3" "$(dest "$3")" ;;
+	restore_file) cp "$(dest "$3")" "$3" ;;
+	backup_data) mkdir -p "$(dirname "$(dest "$3")")" && cat - > "$(dest "$3")" ;;
+	restore_data) cat "$(dest "$3")" ;;
+	delete_backup) rm -rf "$store/$3" ;;
+	*) echo "unknown command $1" >&2; exit 1 ;;
+esac
+`
+
+var _ = Describe("plugincheck/checks tests", func() {
+	var checker *plugincheck.PluginChecker
+
+	writePlugin := func(script string) {
+		pluginPath := path.Join(tempDir, "fake_plugin")
+		configPath := path.Join(tempDir, "plugin_config")
+		Expect(ioutil.WriteFile(pluginPath, []byte(script), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(configPath, []byte(path.Join(tempDir, "store")), 0644)).To(Succeed())
+		checker = plugincheck.NewPluginChecker(pluginPath, configPath, path.Join(tempDir, "local"))
+		checker.NumSegments = 3
+		checker.LargeDataSize = 4 * 1024 * 1024
+		checker.Timestamp = "20170101010101"
+		checker.SiblingTimestamp = "20170101010102"
+	}
+	resultFor := func(results []plugincheck.CheckResult, name string) plugincheck.CheckResult {
+		for _, result := range results {
+			if result.Name == name {
+				return result
+			}
+		}
+		Fail("No result for check " + name)
+		return plugincheck.CheckResult{}
+	}
+
+	It("passes every check for a conforming plugin", func() {
+		writePlugin(fakePluginScript)
+		results := checker.RunChecks()
+		Expect(results).To(HaveLen(16))
+		Expect(plugincheck.CountFailedChecks(results)).To(Equal(0))
+	})
+	It("fails plugin_api_version when the version is too low", func() {
+		writePlugin(strings.Replace(fakePluginScript, `echo "0.5.0"`, `echo "0.2.0"`, 1))
+		result := resultFor(checker.RunChecks(), "plugin_api_version")
+		Expect(result.Passed).To(BeFalse())
+		Expect(result.Message).To(Equal("Plugin API version 0.2.0 is lower than the required version 0.3.0"))
+	})
+	It("fails --version when the output is not in the expected format", func() {
+		writePlugin(strings.Replace(fakePluginScript, `echo "fake_plugin version 1.0.0"`, `echo "1.0.0"`, 1))
+		result := resultFor(checker.RunChecks(), "--version")
+		Expect(result.Passed).To(BeFalse())
+		Expect(result.Message).To(ContainSubstring(`--version output "1.0.0" is not in the format`))
+	})
+	It("fails capabilities when the output is not valid YAML", func() {
+		writePlugin(strings.Replace(fakePluginScript, `printf "restore_subset: true\\ndelete_backup: true\\n"`, `echo "restore_subset: [true"`, 1))
+		result := resultFor(checker.RunChecks(), "capabilities")
+		Expect(result.Passed).To(BeFalse())
+		Expect(result.Message).To(HavePrefix("Plugin capabilities are not valid YAML"))
+	})
+	It("passes capabilities for plugins older than the capabilities command", func() {
+		script := strings.Replace(fakePluginScript, `echo "0.5.0"`, `echo "0.4.0"`, 1)
+		writePlugin(strings.Replace(script, `	capabilities) printf "restore_subset: true\\ndelete_backup: true\\n" ;;`+"\n", "", 1))
+		results := checker.RunChecks()
+		Expect(resultFor(results, "capabilities").Passed).To(BeTrue())
+		Expect(plugincheck.CountFailedChecks(results)).To(Equal(0))
+	})
+	It("fails restore_file of a missing file when the plugin exits 0", func() {
+		writePlugin(strings.Replace(fakePluginScript, `cp "$(dest "$3")" "$3" ;;`, `cp "$(dest "$3")" "$3" 2>/dev/null || true ;;`, 1))
+		result := resultFor(checker.RunChecks(), "restore_file of a missing file")
+		Expect(result.Passed).To(BeFalse())
+		Expect(result.Message).To(Equal("restore_file exited 0, but should exit non-zero when it fails"))
+	})
+	It("fails restore_file of a missing file when the plugin writes nothing to stderr", func() {
+		writePlugin(strings.Replace(fakePluginScript, `cp "$(dest "$3")" "$3" ;;`, `cp "$(dest "$3")" "$3" 2>/dev/null ;;`, 1))
+		result := resultFor(checker.RunChecks(), "restore_file of a missing file")
+		Expect(result.Passed).To(BeFalse())
+		Expect(result.Message).To(ContainSubstring("but did not write an error message to stderr"))
+	})
+	It("fails the data checks when restore_data truncates large data", func() {
+		writePlugin(strings.Replace(fakePluginScript, `restore_data) cat "$(dest "$3")" ;;`, `restore_data) head -c 1000 "$(dest "$3")" ;;`, 1))
+		results := checker.RunChecks()
+		Expect(resultFor(results, "backup_data and restore_data").Passed).To(BeTrue())
+		result := resultFor(results, "backup_data and restore_data with large data")
+		Expect(result.Passed).To(BeFalse())
+		Expect(result.Message).To(MatchRegexp(`restore_data returned 1000 bytes for .*/gpbackup_0_20170101010101_check_large_data, but 4194304 bytes were backed up`))
+		Expect(resultFor(results, "concurrent backup_data and restore_data").Passed).To(BeFalse())
+	})
+	It("fails delete_backup when the backup is not deleted", func() {
+		writePlugin(strings.Replace(fakePluginScript, `delete_backup) rm -rf "$store/$3" ;;`, `delete_backup) ;;`, 1))
+		result := resultFor(checker.RunChecks(), "delete_backup")
+		Expect(result.Passed).To(BeFalse())
+		Expect(result.Message).To(Equal("Data was not deleted: restore_data exited 0, but should exit non-zero when it fails"))
+	})
+	It("fails delete_backup when a sibling backup is also deleted", func() {
+		writePlugin(strings.Replace(fakePluginScript, `delete_backup) rm -rf "$store/$3" ;;`, `delete_backup) rm -rf "$store"/* ;;`, 1))
+		result := resultFor(checker.RunChecks(), "delete_backup")
+		Expect(result.Passed).To(BeFalse())
+		Expect(result.Message).To(HavePrefix("Backup 20170101010102 was affected by deleting backup 20170101010101: restore_data failed"))
+	})
+	It("fails unknown command when the plugin exits 0", func() {
+		writePlugin(strings.Replace(fakePluginScript, `*) echo "unknown command $1" >&2; exit 1 ;;`, `*) ;;`, 1))
+		result := resultFor(checker.RunChecks(), "unknown command")
+		Expect(result.Passed).To(BeFalse())
+		Expect(result.Message).To(Equal("unknown_command exited 0, but should exit non-zero when it fails"))
+	})
+})
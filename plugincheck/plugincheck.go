@@ -0,0 +1,127 @@
+package plugincheck
+
+/*
+ * This file contains the plugin-check subcommand of gpbackup, which runs a
+ * plugin through every command of the plugin API and reports whether it
+ * behaves as gpbackup and gprestore expect.
+ */
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime/debug"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func NewPluginCheckCommand() *cobra.Command {
+	pluginCheckCmd := &cobra.Command{
+		Use:   "plugin-check",
+		Short: "Check that a plugin conforms to the gpbackup plugin API",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer DoTeardown()
+			SetCmdFlags(cmd.Flags())
+			DoValidation()
+			DoPluginCheck()
+		},
+	}
+	options.SetPluginCheckFlagDefaults(pluginCheckCmd.Flags())
+	_ = pluginCheckCmd.MarkFlagRequired(options.PLUGIN_CONFIG)
+	return pluginCheckCmd
+}
+
+func DoValidation() {
+	SetLoggerVerbosity()
+	gplog.Verbose("Plugin Check Command: %s", os.Args)
+	options.CheckExclusiveFlags(cmdFlags, options.DEBUG, options.QUIET, options.VERBOSE)
+	gplog.FatalOnError(utils.ValidateFullPath(MustGetFlagString(options.PLUGIN_CONFIG)))
+	gplog.FatalOnError(utils.ValidateFullPath(MustGetFlagString(options.LOCAL_DIR)))
+	if MustGetFlagInt(options.NUM_SEGMENTS) < 1 {
+		gplog.Fatal(errors.Errorf("--%s must be at least 1", options.NUM_SEGMENTS), "")
+	}
+	if MustGetFlagInt(options.LARGE_DATA_SIZE) < 1 {
+		gplog.Fatal(errors.Errorf("--%s must be at least 1", options.LARGE_DATA_SIZE), "")
+	}
+}
+
+func SetLoggerVerbosity() {
+	if MustGetFlagBool(options.QUIET) {
+		gplog.SetVerbosity(gplog.LOGERROR)
+	} else if MustGetFlagBool(options.DEBUG) {
+		gplog.SetVerbosity(gplog.LOGDEBUG)
+	} else if MustGetFlagBool(options.VERBOSE) {
+		gplog.SetVerbosity(gplog.LOGVERBOSE)
+	}
+}
+
+func DoPluginCheck() {
+	configPath := MustGetFlagString(options.PLUGIN_CONFIG)
+	pluginConfig, err := utils.ReadPluginConfig(configPath)
+	gplog.FatalOnError(err)
+	if !pluginConfig.UsesExecutable() {
+		gplog.Fatal(errors.Errorf("The %s backend is built into gpbackup and cannot be checked as a plugin", pluginConfig.Backend), "")
+	}
+
+	localDir, err = ioutil.TempDir(MustGetFlagString(options.LOCAL_DIR), "gpbackup_plugin_check_")
+	gplog.FatalOnError(err)
+
+	// The plugin reads the config file in place, as there are no segment hosts to copy it to
+	checker := NewPluginChecker(pluginConfig.ExecutablePath, configPath, localDir)
+	checker.NumSegments = MustGetFlagInt(options.NUM_SEGMENTS)
+	checker.LargeDataSize = int64(MustGetFlagInt(options.LARGE_DATA_SIZE)) * 1024 * 1024
+	results := checker.RunChecks()
+
+	WriteReport(os.Stdout, pluginConfig.ExecutablePath, results)
+	numFailed := CountFailedChecks(results)
+	if numFailed > 0 {
+		gplog.Error("Plugin %s failed %d of %d conformance checks", pluginConfig.ExecutablePath, numFailed, len(results))
+	} else {
+		gplog.Info("Plugin %s passed all conformance checks", pluginConfig.ExecutablePath)
+	}
+}
+
+func DoTeardown() {
+	if err := recover(); err != nil {
+		// gplog's Fatal will cause a panic with error code 2
+		if gplog.GetErrorCode() != 2 {
+			gplog.Error(fmt.Sprintf("%v: %s", err, debug.Stack()))
+			gplog.SetErrorCode(2)
+		}
+	}
+	if localDir != "" {
+		err := os.RemoveAll(localDir)
+		if err != nil {
+			gplog.Warn("Unable to remove local test directory %s: %v", localDir, err)
+		}
+	}
+	os.Exit(gplog.GetErrorCode())
+}
+
+func WriteReport(writer io.Writer, executablePath string, results []CheckResult) {
+	_, _ = fmt.Fprintf(writer, "\nPlugin conformance report for %s\n\n", executablePath)
+	for _, result := range results {
+		if result.Passed {
+			_, _ = fmt.Fprintf(writer, "[PASSED] %s\n", result.Name)
+		} else {
+			_, _ = fmt.Fprintf(writer, "[FAILED] %s: %s\n", result.Name, result.Message)
+		}
+	}
+	_, _ = fmt.Fprintf(writer, "\n%d of %d checks passed\n", len(results)-CountFailedChecks(results), len(results))
+}
+
+func CountFailedChecks(results []CheckResult) int {
+	numFailed := 0
+	for _, result := range results {
+		if !result.Passed {
+			numFailed++
+		}
+	}
+	return numFailed
+}
@@ -0,0 +1,30 @@
+package plugincheck_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestPluginCheck(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "plugincheck tests")
+}
+
+var tempDir string
+
+var _ = BeforeEach(func() {
+	_, _, _ = testhelper.SetupTestLogger()
+	var err error
+	tempDir, err = ioutil.TempDir("", "plugincheck_test")
+	Expect(err).ToNot(HaveOccurred())
+})
+
+var _ = AfterEach(func() {
+	_ = os.RemoveAll(tempDir)
+})
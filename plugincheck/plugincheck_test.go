@@ -0,0 +1,30 @@
+package plugincheck_test
+
+import (
+	"github.com/greenplum-db/gpbackup/plugincheck"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("plugincheck/plugincheck tests", func() {
+	Describe("WriteReport", func() {
+		It("lists each check and the number of checks passed", func() {
+			buffer := gbytes.NewBuffer()
+			results := []plugincheck.CheckResult{
+				{Name: "plugin_api_version", Passed: true},
+				{Name: "restore_file", Passed: false, Message: "restore_file failed with exit status 1: file not found"},
+			}
+			plugincheck.WriteReport(buffer, "/usr/local/bin/my_plugin", results)
+			Expect(string(buffer.Contents())).To(Equal(`
+Plugin conformance report for /usr/local/bin/my_plugin
+
+[PASSED] plugin_api_version
+[FAILED] restore_file: restore_file failed with exit status 1: file not found
+
+1 of 2 checks passed
+`))
+		})
+	})
+})
@@ -91,8 +91,17 @@ delete_backup() {
 }
 
 plugin_api_version(){
-  echo "0.4.0"
-  echo "0.4.0" >> /tmp/plugin_out.txt
+  echo "0.5.0"
+  echo "0.5.0" >> /tmp/plugin_out.txt
+}
+
+capabilities(){
+  echo "restore_subset: false"
+  echo "delete_backup: true"
+  echo "list: false"
+  echo "streaming: true"
+  echo "encryption: false"
+  echo "capabilities" >> /tmp/plugin_out.txt
 }
 
 --version(){
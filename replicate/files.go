@@ -0,0 +1,222 @@
+package replicate
+
+/*
+ * This file contains the functions that copy the files of a backup to the
+ * replica and verify the copies.  Master files are copied by gpbackup
+ * itself, while each segment's files are copied on its host, in parallel.
+ */
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+/*
+ * The plugin config saved with a plugin backup holds the source's settings,
+ * such as credentials, so it is not copied to the replica.
+ */
+func GetMasterFiles() []string {
+	masterFiles := []string{globalFPInfo.GetMetadataFilePath(), globalFPInfo.GetTOCFilePath()}
+	if backupConfig.WithStatistics {
+		masterFiles = append(masterFiles, globalFPInfo.GetStatisticsFilePath())
+	}
+	return append(masterFiles, globalFPInfo.GetBackupReportFilePath(), globalFPInfo.GetConfigFilePath())
+}
+
+/*
+ * The TOC of an incremental backup only has entries for the tables whose data
+ * is in that backup, so this is the data to copy for every kind of backup.
+ */
+func GetDataOids() []string {
+	oids := make([]string, 0, len(globalTOC.DataEntries))
+	for _, entry := range globalTOC.DataEntries {
+		oids = append(oids, strconv.FormatUint(uint64(entry.Oid), 10))
+	}
+	return oids
+}
+
+func ReplicateMasterFiles(masterFiles []string) error {
+	for _, filename := range masterFiles {
+		gplog.Verbose("Replicating %s", filename)
+		if sourceBackend != nil {
+			err := sourceBackend.GetFile(filename)
+			if err != nil {
+				return err
+			}
+		}
+		err := replicaBackend.PutFile(filename)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The local copy of each master file is compared against the replica's copy
+func VerifyMasterFiles(masterFiles []string) ([]string, error) {
+	mismatches := make([]string, 0)
+	for _, filename := range masterFiles {
+		localFile, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		backupChecksum, err := checksumReader(localFile)
+		_ = localFile.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to read %s", filename)
+		}
+		replicaFile, err := replicaBackend.OpenReader(filename)
+		if err != nil {
+			return nil, err
+		}
+		replicaChecksum, err := checksumReader(replicaFile)
+		closeErr := replicaFile.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to read replica of %s", filename)
+		}
+		if closeErr != nil {
+			return nil, closeErr
+		}
+		if backupChecksum != replicaChecksum {
+			mismatches = append(mismatches, fmt.Sprintf("%s (backup checksum and size %s, replica checksum and size %s)",
+				filename, backupChecksum, replicaChecksum))
+		}
+	}
+	return mismatches, nil
+}
+
+// Returns the SHA-256 and size of the data read, in the format "checksum size"
+func checksumReader(reader io.Reader) (string, error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, reader)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x %d", hash.Sum(nil), size), nil
+}
+
+func ReplicateSegmentFiles() {
+	remoteOutput := globalCluster.GenerateAndExecuteCommand("Replicating segment data files", cluster.ON_SEGMENTS, BuildSegmentReplicateCommand)
+	globalCluster.CheckClusterError(remoteOutput, "Unable to replicate segment data files", func(contentID int) string {
+		return fmt.Sprintf("Unable to replicate data files for segment %d", contentID)
+	})
+}
+
+func VerifySegmentFiles() ([]string, error) {
+	remoteOutput := globalCluster.GenerateAndExecuteCommand("Verifying replicated segment data files", cluster.ON_SEGMENTS, BuildSegmentVerifyCommand)
+	globalCluster.CheckClusterError(remoteOutput, "Unable to verify replicated segment data files", func(contentID int) string {
+		return fmt.Sprintf("Unable to verify replicated data files for segment %d", contentID)
+	})
+	mismatches := make([]string, 0)
+	for _, command := range remoteOutput.Commands {
+		segmentMismatches, err := ParseChecksums(command.Stdout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to verify replicated data files for segment %d", command.Content)
+		}
+		mismatches = append(mismatches, segmentMismatches...)
+	}
+	return mismatches, nil
+}
+
+/*
+ * The segment commands below run a command for each data file of a segment.
+ * The data files of a backup without a single data file are named in the
+ * shell from the oids in the oid file, as the full list of file names may be
+ * too long for a command line.
+ */
+func BuildSegmentReplicateCommand(contentID int) string {
+	return buildSegmentCommand(contentID, func(filename string, isDataFile bool) string {
+		if isDataFile {
+			return copyDataCommand(filename)
+		}
+		return copyFileCommand(filename)
+	})
+}
+
+// Each file's checksums are printed in the format "filename backup_cksum backup_size replica_cksum replica_size"
+func BuildSegmentVerifyCommand(contentID int) string {
+	return buildSegmentCommand(contentID, func(filename string, isDataFile bool) string {
+		return fmt.Sprintf(`backupsum=$(%s) && replicasum=$(%s) && echo "%s $backupsum $replicasum"`,
+			backupChecksumCommand(filename, isDataFile), replicaChecksumCommand(filename), filename)
+	})
+}
+
+func buildSegmentCommand(contentID int, fileCommand func(filename string, isDataFile bool) string) string {
+	commands := []string{fmt.Sprintf("source %s/greenplum_path.sh", operating.System.Getenv("GPHOME")),
+		fmt.Sprintf("mkdir -p %s", globalFPInfo.GetDirForContent(contentID))}
+	extension := utils.GetPipeThroughProgram().Extension
+	if backupConfig.SingleDataFile {
+		for stream := 0; stream < backupConfig.NumDataStreams(); stream++ {
+			streamFPInfo := globalFPInfo.ForDataStream(stream)
+			commands = append(commands, fileCommand(streamFPInfo.GetSegmentTOCFilePath(contentID), false),
+				fileCommand(streamFPInfo.GetTableBackupFilePath(contentID, 0, extension, true), true))
+		}
+	} else {
+		dataFile := path.Join(globalFPInfo.GetDirForContent(contentID),
+			fmt.Sprintf("gpbackup_%d_%s_${oid}%s", contentID, globalFPInfo.Timestamp, extension))
+		// The oid file is read on another descriptor, so that plugins cannot consume it from stdin
+		commands = append(commands, fmt.Sprintf("while read oid <&3; do %s || exit 1; done 3< %s",
+			fileCommand(dataFile, true), globalFPInfo.GetSegmentHelperFilePath(contentID, "oid")))
+	}
+	return fmt.Sprintf("set -o pipefail; %s", strings.Join(commands, " && "))
+}
+
+/*
+ * Data files are streamed with backup_data and restore_data, as gpbackup and
+ * gprestore do, and segment TOCs are copied with backup_file and restore_file.
+ */
+func copyFileCommand(filename string) string {
+	if sourcePluginConfig != nil {
+		return fmt.Sprintf("%s && %s", sourcePluginConfig.RestoreFileCommand(filename), replicaPluginConfig.BackupFileCommand(filename))
+	}
+	return replicaPluginConfig.BackupFileCommand(filename)
+}
+
+func copyDataCommand(filename string) string {
+	if sourcePluginConfig != nil {
+		return fmt.Sprintf("%s %s | %s %s", sourcePluginConfig.RestoreDataCommand(), filename, replicaPluginConfig.BackupDataCommand(), filename)
+	}
+	return fmt.Sprintf("%s %s < %s", replicaPluginConfig.BackupDataCommand(), filename, filename)
+}
+
+func backupChecksumCommand(filename string, isDataFile bool) string {
+	if sourcePluginConfig != nil && isDataFile {
+		return fmt.Sprintf("%s %s | cksum", sourcePluginConfig.RestoreDataCommand(), filename)
+	}
+	return fmt.Sprintf("cksum < %s", filename)
+}
+
+// Every replicated file is read back with restore_data, which does not overwrite the local copy
+func replicaChecksumCommand(filename string) string {
+	return fmt.Sprintf("%s %s | cksum", replicaPluginConfig.RestoreDataCommand(), filename)
+}
+
+// Returns a description of each file whose backup and replica checksums differ
+func ParseChecksums(output string) ([]string, error) {
+	mismatches := make([]string, 0)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 5 {
+			return nil, errors.Errorf("Unexpected checksum output: %s", line)
+		}
+		if fields[1] != fields[3] || fields[2] != fields[4] {
+			mismatches = append(mismatches, fmt.Sprintf("%s (backup checksum %s and size %s, replica checksum %s and size %s)",
+				fields[0], fields[1], fields[2], fields[3], fields[4]))
+		}
+	}
+	return mismatches, nil
+}
@@ -0,0 +1,233 @@
+package replicate_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	path "path/filepath"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/replicate"
+	"github.com/greenplum-db/gpbackup/storage"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// The fake plugin stores each file in a directory named in its config file, keyed by the file's name
+const fakePluginScript = `#!/bin/bash
+store=$(cat "$2")
+mkdir -p "$store"
+case "$1" in
+	backup_file) cp "$3" "$store/$(basename "$3")" ;;
+	restore_file) cp "$store/$(basename "$3")" "$3" ;;
+	backup_data) cat - > "$store/$(basename "$3")" ;;
+	restore_data) cat "$store/$(basename "$3")" ;;
+	*) echo "unknown command $1" >&2; exit 1 ;;
+esac
+`
+
+var _ = Describe("replicate/files tests", func() {
+	var fpInfo filepath.FilePathInfo
+	var replicaConfig, sourceConfig *utils.PluginConfig
+
+	BeforeEach(func() {
+		testCluster := cluster.NewCluster([]cluster.SegConfig{
+			{ContentID: -1, Hostname: "localhost", DataDir: path.Join(tempDir, "gpseg-1")},
+			{ContentID: 0, Hostname: "localhost", DataDir: path.Join(tempDir, "gpseg0")},
+			{ContentID: 1, Hostname: "localhost", DataDir: path.Join(tempDir, "gpseg1")},
+		})
+		fpInfo = filepath.NewFilePathInfo(testCluster, "", "20170101010101", "")
+		fpInfo.PID = 1234
+		replicate.SetCluster(testCluster)
+		replicate.SetFPInfo(fpInfo)
+		replicate.SetBackupConfig(&history.BackupConfig{Timestamp: "20170101010101"})
+		replicate.SetTOC(&toc.TOC{DataEntries: []toc.MasterDataEntry{{Oid: 16384}, {Oid: 16390}}})
+		utils.InitializePipeThroughParameters(false, 0)
+		operating.System.Getenv = func(key string) string {
+			return tempDir
+		}
+
+		replicaConfig = &utils.PluginConfig{ExecutablePath: "/usr/local/bin/replica_plugin", ConfigPath: "/tmp/replica_config.yaml"}
+		sourceConfig = &utils.PluginConfig{ExecutablePath: "/usr/local/bin/source_plugin", ConfigPath: "/tmp/source_config.yaml"}
+		replicate.SetReplica(replicaConfig, nil)
+		replicate.SetSource(nil, nil)
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+
+	Describe("GetMasterFiles", func() {
+		It("lists the master files with the config file last", func() {
+			Expect(replicate.GetMasterFiles()).To(Equal([]string{
+				fpInfo.GetMetadataFilePath(),
+				fpInfo.GetTOCFilePath(),
+				fpInfo.GetBackupReportFilePath(),
+				fpInfo.GetConfigFilePath(),
+			}))
+		})
+		It("includes the statistics file when the backup has statistics", func() {
+			replicate.SetBackupConfig(&history.BackupConfig{WithStatistics: true})
+			Expect(replicate.GetMasterFiles()).To(ContainElement(fpInfo.GetStatisticsFilePath()))
+		})
+	})
+	Describe("GetDataOids", func() {
+		It("returns the oid of each table with data in the backup", func() {
+			Expect(replicate.GetDataOids()).To(Equal([]string{"16384", "16390"}))
+		})
+	})
+	Describe("BuildSegmentReplicateCommand", func() {
+		var segmentDir, oidFile string
+		BeforeEach(func() {
+			segmentDir = path.Join(tempDir, "gpseg0/backups/20170101/20170101010101")
+			oidFile = path.Join(tempDir, "gpseg0/gpbackup_0_20170101010101_oid_1234")
+		})
+		It("streams each local data file to the replica", func() {
+			Expect(replicate.BuildSegmentReplicateCommand(0)).To(Equal(fmt.Sprintf("set -o pipefail; source %[1]s/greenplum_path.sh && mkdir -p %[2]s && "+
+				"while read oid <&3; do /usr/local/bin/replica_plugin backup_data /tmp/replica_config.yaml %[2]s/gpbackup_0_20170101010101_${oid} < %[2]s/gpbackup_0_20170101010101_${oid} || exit 1; done 3< %[3]s",
+				tempDir, segmentDir, oidFile)))
+		})
+		It("streams each data file from the source plugin to the replica", func() {
+			replicate.SetSource(sourceConfig, nil)
+			utils.InitializePipeThroughParameters(true, 1)
+			Expect(replicate.BuildSegmentReplicateCommand(0)).To(ContainSubstring(fmt.Sprintf(
+				"while read oid <&3; do /usr/local/bin/source_plugin restore_data /tmp/source_config.yaml %[1]s/gpbackup_0_20170101010101_${oid}.gz | "+
+					"/usr/local/bin/replica_plugin backup_data /tmp/replica_config.yaml %[1]s/gpbackup_0_20170101010101_${oid}.gz || exit 1; done 3< %[2]s",
+				segmentDir, oidFile)))
+		})
+		It("copies the segment TOC and the single data file of a backup with a single data file", func() {
+			replicate.SetSource(sourceConfig, nil)
+			replicate.SetBackupConfig(&history.BackupConfig{SingleDataFile: true})
+			Expect(replicate.BuildSegmentReplicateCommand(1)).To(HaveSuffix(fmt.Sprintf("mkdir -p %[1]s && "+
+				"/usr/local/bin/source_plugin restore_file /tmp/source_config.yaml %[1]s/gpbackup_1_20170101010101_toc.yaml && "+
+				"/usr/local/bin/replica_plugin backup_file /tmp/replica_config.yaml %[1]s/gpbackup_1_20170101010101_toc.yaml && "+
+				"/usr/local/bin/source_plugin restore_data /tmp/source_config.yaml %[1]s/gpbackup_1_20170101010101 | "+
+				"/usr/local/bin/replica_plugin backup_data /tmp/replica_config.yaml %[1]s/gpbackup_1_20170101010101",
+				path.Join(tempDir, "gpseg1/backups/20170101/20170101010101"))))
+		})
+		It("copies the segment TOC and data file of each data stream of a backup with a single data file", func() {
+			replicate.SetBackupConfig(&history.BackupConfig{SingleDataFile: true, DataStreams: 2})
+			Expect(replicate.BuildSegmentReplicateCommand(1)).To(HaveSuffix(fmt.Sprintf(
+				"/usr/local/bin/replica_plugin backup_file /tmp/replica_config.yaml %[1]s/gpbackup_1_20170101010101_toc.yaml && "+
+					"/usr/local/bin/replica_plugin backup_data /tmp/replica_config.yaml %[1]s/gpbackup_1_20170101010101 < %[1]s/gpbackup_1_20170101010101 && "+
+					"/usr/local/bin/replica_plugin backup_file /tmp/replica_config.yaml %[1]s/gpbackup_1_20170101010101_stream1_toc.yaml && "+
+					"/usr/local/bin/replica_plugin backup_data /tmp/replica_config.yaml %[1]s/gpbackup_1_20170101010101_stream1 < %[1]s/gpbackup_1_20170101010101_stream1",
+				path.Join(tempDir, "gpseg1/backups/20170101/20170101010101"))))
+		})
+	})
+	Describe("segment commands", func() {
+		var segmentDir, storeDir string
+		runCommand := func(command string) string {
+			output, err := exec.Command("bash", "-c", command).CombinedOutput()
+			Expect(err).ToNot(HaveOccurred(), string(output))
+			return string(output)
+		}
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(path.Join(tempDir, "greenplum_path.sh"), []byte{}, 0644)).To(Succeed())
+			pluginPath := path.Join(tempDir, "fake_plugin")
+			storeDir = path.Join(tempDir, "store")
+			Expect(ioutil.WriteFile(pluginPath, []byte(fakePluginScript), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(replicaConfig.ConfigPath, []byte(storeDir), 0644)).To(Succeed())
+			replicaConfig.ExecutablePath = pluginPath
+
+			segmentDir = fpInfo.GetDirForContent(0)
+			Expect(os.MkdirAll(segmentDir, 0755)).To(Succeed())
+			for _, oid := range []uint32{16384, 16390} {
+				Expect(ioutil.WriteFile(fpInfo.GetTableBackupFilePath(0, oid, "", false), []byte(fmt.Sprintf("data for %d\n", oid)), 0644)).To(Succeed())
+			}
+			utils.WriteOidsToFile(fpInfo.GetSegmentHelperFilePath(0, "oid"), []string{"16384", "16390"})
+		})
+		AfterEach(func() {
+			_ = os.Remove(replicaConfig.ConfigPath)
+		})
+		It("replicates each data file and verifies the replicated files", func() {
+			runCommand(replicate.BuildSegmentReplicateCommand(0))
+			replicated, err := ioutil.ReadFile(path.Join(storeDir, "gpbackup_0_20170101010101_16390"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(replicated)).To(Equal("data for 16390\n"))
+
+			output := runCommand(replicate.BuildSegmentVerifyCommand(0))
+			mismatches, err := replicate.ParseChecksums(output)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mismatches).To(BeEmpty())
+		})
+		It("reports replicated files that do not match the backup", func() {
+			runCommand(replicate.BuildSegmentReplicateCommand(0))
+			Expect(ioutil.WriteFile(path.Join(storeDir, "gpbackup_0_20170101010101_16384"), []byte("truncated"), 0644)).To(Succeed())
+
+			output := runCommand(replicate.BuildSegmentVerifyCommand(0))
+			mismatches, err := replicate.ParseChecksums(output)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mismatches).To(HaveLen(1))
+			Expect(mismatches[0]).To(MatchRegexp(`^%s/gpbackup_0_20170101010101_16384 \(backup checksum \d+ and size 15, replica checksum \d+ and size 9\)$`, segmentDir))
+		})
+		It("fails when a data file cannot be replicated", func() {
+			Expect(os.Remove(fpInfo.GetTableBackupFilePath(0, 16390, "", false))).To(Succeed())
+			_, err := exec.Command("bash", "-c", replicate.BuildSegmentReplicateCommand(0)).CombinedOutput()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Describe("ParseChecksums", func() {
+		It("returns an error when the output is not in the expected format", func() {
+			_, err := replicate.ParseChecksums("/data/gpbackup_0_20170101010101_16384 1234 10\n")
+			Expect(err).To(MatchError("Unexpected checksum output: /data/gpbackup_0_20170101010101_16384 1234 10"))
+		})
+	})
+	Describe("ReplicateMasterFiles and VerifyMasterFiles", func() {
+		var masterFiles []string
+		var replicaBackend *storage.DirectoryBackend
+		BeforeEach(func() {
+			replicaBackend = &storage.DirectoryBackend{Directory: path.Join(tempDir, "replica")}
+			replicate.SetReplica(replicaConfig, replicaBackend)
+			masterFiles = replicate.GetMasterFiles()
+		})
+		writeMasterFiles := func() {
+			Expect(os.MkdirAll(fpInfo.GetDirForContent(-1), 0755)).To(Succeed())
+			for _, filename := range masterFiles {
+				Expect(ioutil.WriteFile(filename, []byte(path.Base(filename)), 0644)).To(Succeed())
+			}
+		}
+		It("copies local master files to the replica", func() {
+			writeMasterFiles()
+			Expect(replicate.ReplicateMasterFiles(masterFiles)).To(Succeed())
+
+			mismatches, err := replicate.VerifyMasterFiles(masterFiles)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mismatches).To(BeEmpty())
+		})
+		It("copies master files from the source to the replica", func() {
+			writeMasterFiles()
+			sourceBackend := &storage.DirectoryBackend{Directory: path.Join(tempDir, "source")}
+			for _, filename := range masterFiles {
+				Expect(sourceBackend.PutFile(filename)).To(Succeed())
+				Expect(os.Remove(filename)).To(Succeed())
+			}
+			replicate.SetSource(sourceConfig, sourceBackend)
+
+			Expect(replicate.ReplicateMasterFiles(masterFiles)).To(Succeed())
+
+			contents, err := ioutil.ReadFile(path.Join(replicaBackend.Directory, fpInfo.GetConfigFilePath()))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("gpbackup_20170101010101_config.yaml"))
+		})
+		It("reports master files whose replicas differ", func() {
+			writeMasterFiles()
+			Expect(replicate.ReplicateMasterFiles(masterFiles)).To(Succeed())
+			Expect(ioutil.WriteFile(path.Join(replicaBackend.Directory, fpInfo.GetTOCFilePath()), []byte("changed"), 0644)).To(Succeed())
+
+			mismatches, err := replicate.VerifyMasterFiles(masterFiles)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mismatches).To(HaveLen(1))
+			Expect(mismatches[0]).To(HavePrefix(fpInfo.GetTOCFilePath() + " (backup checksum and size "))
+		})
+		It("returns an error when a master file is missing", func() {
+			Expect(replicate.ReplicateMasterFiles(masterFiles)).ToNot(Succeed())
+		})
+	})
+})
@@ -0,0 +1,87 @@
+package replicate
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/storage"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/spf13/pflag"
+)
+
+/*
+ * This file contains global variables and setter functions for those variables
+ * used in testing.
+ */
+
+/*
+ * Non-flag variables
+ */
+
+var (
+	backupConfig        *history.BackupConfig
+	globalCluster       *cluster.Cluster
+	globalFPInfo        filepath.FilePathInfo
+	globalTOC           *toc.TOC
+	replicaBackend      storage.StorageBackend
+	replicaPluginConfig *utils.PluginConfig
+	sourceBackend       storage.StorageBackend
+	sourcePluginConfig  *utils.PluginConfig
+
+	// Teardown only undoes the setup steps that were completed
+	oidFilesWritten bool
+	replicaSetUp    bool
+	sourceSetUp     bool
+)
+
+/*
+ * Command-line flags
+ */
+var cmdFlags *pflag.FlagSet
+
+/*
+ * Setter functions
+ */
+
+func SetCmdFlags(flagSet *pflag.FlagSet) {
+	cmdFlags = flagSet
+}
+
+func SetBackupConfig(config *history.BackupConfig) {
+	backupConfig = config
+}
+
+func SetCluster(c *cluster.Cluster) {
+	globalCluster = c
+}
+
+func SetFPInfo(fpInfo filepath.FilePathInfo) {
+	globalFPInfo = fpInfo
+}
+
+func SetTOC(backupTOC *toc.TOC) {
+	globalTOC = backupTOC
+}
+
+func SetReplica(config *utils.PluginConfig, backend storage.StorageBackend) {
+	replicaPluginConfig = config
+	replicaBackend = backend
+}
+
+// A nil config means the backup is replicated from local backup directories
+func SetSource(config *utils.PluginConfig, backend storage.StorageBackend) {
+	sourcePluginConfig = config
+	sourceBackend = backend
+}
+
+// Util functions to enable ease of access to global flag values
+
+func MustGetFlagString(flagName string) string {
+	return options.MustGetFlagString(cmdFlags, flagName)
+}
+
+func MustGetFlagBool(flagName string) bool {
+	return options.MustGetFlagBool(cmdFlags, flagName)
+}
@@ -0,0 +1,211 @@
+package replicate
+
+/*
+ * This file contains the replicate subcommand of gpbackup, which copies a
+ * completed backup from local backup directories or one plugin destination to
+ * another plugin destination, such as off-site storage.
+ */
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"runtime/debug"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/storage"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func NewReplicateCommand() *cobra.Command {
+	replicateCmd := &cobra.Command{
+		Use:   "replicate",
+		Short: "Copy a completed backup to a second storage location",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer DoTeardown()
+			SetCmdFlags(cmd.Flags())
+			DoValidation()
+			DoSetup()
+			DoReplicate()
+		},
+	}
+	options.SetReplicateFlagDefaults(replicateCmd.Flags())
+	_ = replicateCmd.MarkFlagRequired(options.TIMESTAMP)
+	_ = replicateCmd.MarkFlagRequired(options.PLUGIN_CONFIG)
+	return replicateCmd
+}
+
+func DoValidation() {
+	SetLoggerVerbosity()
+	gplog.Verbose("Replicate Command: %s", os.Args)
+	options.CheckExclusiveFlags(cmdFlags, options.DEBUG, options.QUIET, options.VERBOSE)
+	options.CheckExclusiveFlags(cmdFlags, options.BACKUP_DIR, options.SOURCE_PLUGIN_CONFIG)
+	timestamp := MustGetFlagString(options.TIMESTAMP)
+	if !filepath.IsValidTimestamp(timestamp) {
+		gplog.Fatal(errors.Errorf("Timestamp %s is invalid.  Timestamps must be in the format YYYYMMDDHHMMSS.", timestamp), "")
+	}
+	for _, flagName := range []string{options.BACKUP_DIR, options.PLUGIN_CONFIG, options.SOURCE_PLUGIN_CONFIG} {
+		if MustGetFlagString(flagName) != "" {
+			gplog.FatalOnError(utils.ValidateFullPath(MustGetFlagString(flagName)))
+		}
+	}
+	if MustGetFlagString(options.PLUGIN_CONFIG) == MustGetFlagString(options.SOURCE_PLUGIN_CONFIG) {
+		gplog.Fatal(errors.Errorf("--%s and --%s must be different config files", options.PLUGIN_CONFIG, options.SOURCE_PLUGIN_CONFIG), "")
+	}
+}
+
+func SetLoggerVerbosity() {
+	if MustGetFlagBool(options.QUIET) {
+		gplog.SetVerbosity(gplog.LOGERROR)
+	} else if MustGetFlagBool(options.DEBUG) {
+		gplog.SetVerbosity(gplog.LOGDEBUG)
+	} else if MustGetFlagBool(options.VERBOSE) {
+		gplog.SetVerbosity(gplog.LOGVERBOSE)
+	}
+}
+
+func DoSetup() {
+	timestamp := MustGetFlagString(options.TIMESTAMP)
+	gplog.Info("Replicating backup %s", timestamp)
+
+	// The database is only needed to find the segments
+	connectionPool := dbconn.NewDBConnFromEnvironment("postgres")
+	connectionPool.MustConnect(1)
+	segConfig := cluster.MustGetSegmentConfiguration(connectionPool)
+	connectionPool.Close()
+	globalCluster = cluster.NewCluster(segConfig)
+
+	segPrefix, err := filepath.ParseSegPrefix(MustGetFlagString(options.BACKUP_DIR), timestamp)
+	gplog.FatalOnError(err)
+	globalFPInfo = filepath.NewFilePathInfo(globalCluster, MustGetFlagString(options.BACKUP_DIR), timestamp, segPrefix)
+
+	if MustGetFlagString(options.SOURCE_PLUGIN_CONFIG) != "" {
+		sourcePluginConfig = readPluginConfig(options.SOURCE_PLUGIN_CONFIG, "source")
+		sourcePluginConfig.CheckPluginExistsOnAllHosts(globalCluster)
+		sourcePluginConfig.SetBackupPluginVersion(timestamp, findHistoricalPluginVersion(timestamp))
+		sourcePluginConfig.CopyPluginConfigToAllHosts(globalCluster)
+		sourceSetUp = true
+		sourcePluginConfig.SetupPluginForRestore(globalCluster, globalFPInfo)
+		sourceBackend = storage.MustNewStorageBackend(sourcePluginConfig)
+		storage.MustGetFile(sourceBackend, globalFPInfo.GetConfigFilePath())
+	}
+
+	replicaPluginConfig = readPluginConfig(options.PLUGIN_CONFIG, "replica")
+	replicaPluginConfig.CheckPluginExistsOnAllHosts(globalCluster)
+	replicaPluginConfig.CopyPluginConfigToAllHosts(globalCluster)
+	replicaSetUp = true
+	replicaPluginConfig.SetupPluginForBackup(globalCluster, globalFPInfo)
+	replicaBackend = storage.MustNewStorageBackend(replicaPluginConfig)
+
+	backupConfig = history.ReadConfigFile(globalFPInfo.GetConfigFilePath())
+	if backupConfig.Failed() {
+		gplog.Fatal(errors.Errorf("Backup %s failed and cannot be replicated", timestamp), "")
+	}
+	if backupConfig.Incremental {
+		gplog.Warn("Backup %s is incremental; the backups it is based on must also be replicated in order to restore from the replica", timestamp)
+	}
+	utils.InitializePipeThroughParameters(backupConfig.Compressed, 0)
+	if !backupConfig.MetadataOnly {
+		if sourceBackend != nil {
+			storage.MustGetFile(sourceBackend, globalFPInfo.GetTOCFilePath())
+		}
+		globalTOC = toc.NewTOC(globalFPInfo.GetTOCFilePath())
+	}
+}
+
+/*
+ * Both configs are copied to the same directory on every host, so they are
+ * given distinct names.
+ */
+func readPluginConfig(flagName string, role string) *utils.PluginConfig {
+	pluginConfig, err := utils.ReadPluginConfig(MustGetFlagString(flagName))
+	gplog.FatalOnError(err)
+	configFilename := path.Base(pluginConfig.ConfigPath)
+	configDirname := path.Dir(pluginConfig.ConfigPath)
+	pluginConfig.ConfigPath = path.Join(configDirname, fmt.Sprintf("%s_%s_%s", history.CurrentTimestamp(), role, configFilename))
+	gplog.Debug("%s plugin config path: %s", role, pluginConfig.ConfigPath)
+	return pluginConfig
+}
+
+func findHistoricalPluginVersion(timestamp string) string {
+	hist, err := history.NewHistory(globalFPInfo.GetBackupHistoryFilePath())
+	if err != nil {
+		return ""
+	}
+	if backupConfig := hist.FindBackupConfig(timestamp); backupConfig != nil {
+		return backupConfig.PluginVersion
+	}
+	return ""
+}
+
+func DoReplicate() {
+	// The master files, and the config file last of all, are copied once all data has been copied
+	masterFiles := GetMasterFiles()
+	if !backupConfig.MetadataOnly {
+		oids := GetDataOids()
+		if !backupConfig.SingleDataFile {
+			utils.WriteOidListToSegments(oids, globalCluster, globalFPInfo)
+			oidFilesWritten = true
+		}
+		ReplicateSegmentFiles()
+		gplog.Info("Replicated data files for %d tables", len(oids))
+	}
+	gplog.FatalOnError(ReplicateMasterFiles(masterFiles))
+
+	gplog.Info("Verifying replicated files")
+	mismatches, err := VerifyMasterFiles(masterFiles)
+	gplog.FatalOnError(err)
+	if !backupConfig.MetadataOnly {
+		segmentMismatches, err := VerifySegmentFiles()
+		gplog.FatalOnError(err)
+		mismatches = append(mismatches, segmentMismatches...)
+	}
+	if len(mismatches) > 0 {
+		for _, mismatch := range mismatches {
+			gplog.Error("Replicated file does not match backup: %s", mismatch)
+		}
+		gplog.Fatal(errors.Errorf("%d replicated files do not match the backup", len(mismatches)), "")
+	}
+
+	replica := history.Replica{
+		Plugin:       replicaPluginConfig.Name(),
+		PluginConfig: MustGetFlagString(options.PLUGIN_CONFIG),
+		ReplicatedAt: history.CurrentTimestamp(),
+	}
+	err = history.AddReplicaToHistory(globalFPInfo.GetBackupHistoryFilePath(), globalFPInfo.Timestamp, replica)
+	if err != nil {
+		gplog.Warn("Unable to record replica in backup history: %v", err)
+	}
+	gplog.Info("Backup %s replicated to %s", globalFPInfo.Timestamp, replica.PluginConfig)
+}
+
+func DoTeardown() {
+	if err := recover(); err != nil {
+		// gplog's Fatal will cause a panic with error code 2
+		if gplog.GetErrorCode() != 2 {
+			gplog.Error(fmt.Sprintf("%v: %s", err, debug.Stack()))
+			gplog.SetErrorCode(2)
+		}
+	}
+	if oidFilesWritten {
+		utils.CleanUpHelperFilesOnAllHosts(globalCluster, globalFPInfo)
+	}
+	if replicaSetUp {
+		replicaPluginConfig.CleanupPluginForBackup(globalCluster, globalFPInfo)
+		replicaPluginConfig.DeletePluginConfigWhenEncrypting(globalCluster)
+	}
+	if sourceSetUp {
+		sourcePluginConfig.CleanupPluginForRestore(globalCluster, globalFPInfo)
+		sourcePluginConfig.DeletePluginConfigWhenEncrypting(globalCluster)
+	}
+	os.Exit(gplog.GetErrorCode())
+}
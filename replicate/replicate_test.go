@@ -0,0 +1,47 @@
+package replicate_test
+
+import (
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/replicate"
+	"github.com/spf13/pflag"
+
+	. "github.com/onsi/ginkgo"
+)
+
+var _ = Describe("replicate/replicate tests", func() {
+	Describe("DoValidation", func() {
+		var flagSet *pflag.FlagSet
+		BeforeEach(func() {
+			flagSet = pflag.NewFlagSet("replicate", pflag.ContinueOnError)
+			options.SetReplicateFlagDefaults(flagSet)
+			replicate.SetCmdFlags(flagSet)
+			_ = flagSet.Set(options.TIMESTAMP, "20170101010101")
+			_ = flagSet.Set(options.PLUGIN_CONFIG, "/tmp/replica_config.yaml")
+		})
+		It("accepts a backup directory as the source", func() {
+			_ = flagSet.Set(options.BACKUP_DIR, "/data/backups")
+			replicate.DoValidation()
+		})
+		It("accepts a plugin config as the source", func() {
+			_ = flagSet.Set(options.SOURCE_PLUGIN_CONFIG, "/tmp/source_config.yaml")
+			replicate.DoValidation()
+		})
+		It("panics when both a backup directory and a source plugin config are given", func() {
+			_ = flagSet.Set(options.BACKUP_DIR, "/data/backups")
+			_ = flagSet.Set(options.SOURCE_PLUGIN_CONFIG, "/tmp/source_config.yaml")
+			defer testhelper.ShouldPanicWithMessage("The following flags may not be specified together: backup-dir, source-plugin-config")
+			replicate.DoValidation()
+		})
+		It("panics when the timestamp is invalid", func() {
+			_ = flagSet.Set(options.TIMESTAMP, "2017")
+			defer testhelper.ShouldPanicWithMessage("Timestamp 2017 is invalid.  Timestamps must be in the format YYYYMMDDHHMMSS.")
+			replicate.DoValidation()
+		})
+		It("panics when the source and replica plugin configs are the same", func() {
+			_ = flagSet.Set(options.SOURCE_PLUGIN_CONFIG, "/tmp/replica_config.yaml")
+			defer testhelper.ShouldPanicWithMessage("--plugin-config and --source-plugin-config must be different config files")
+			replicate.DoValidation()
+		})
+	})
+})
@@ -29,9 +29,36 @@ import (
 type Report struct {
 	BackupParamsString string
 	DatabaseSize       string
+	PluginRetries      []utils.PluginRetry
+	BlockedTables      []BlockedTable
+	// The flags gpbackup ran with, from the command line and --config, in the format of a config file
+	EffectiveConfig string
 	history.BackupConfig
 }
 
+/*
+ * A table whose lock could not be acquired within the lock wait timeout,
+ * the sessions that held conflicting locks on it, and how it was handled.
+ */
+type BlockedTable struct {
+	Table    string
+	Blockers []LockHolder
+	Waited   time.Duration
+	Action   string
+}
+
+type LockHolder struct {
+	Pid   int
+	Query string
+}
+
+func (holder LockHolder) String() string {
+	if holder.Query == "" {
+		return fmt.Sprintf("pid %d", holder.Pid)
+	}
+	return fmt.Sprintf("pid %d (%s)", holder.Pid, holder.Query)
+}
+
 type LineInfo struct {
 	Key   string
 	Value string
@@ -80,9 +107,14 @@ func (report *Report) ConstructBackupParamsString() {
 	if report.MetadataOnly {
 		sectionStr = "Metadata Only"
 	}
+	if report.GlobalsOnly {
+		sectionStr = "Global Metadata Only"
+	}
 	filesStr := "Multiple Data Files Per Segment"
 	if report.MetadataOnly {
 		filesStr = "No Data Files"
+	} else if report.SingleDataFile && report.NumDataStreams() > 1 {
+		filesStr = fmt.Sprintf("Single Data File Per Data Stream, %d Data Streams Per Segment", report.NumDataStreams())
 	} else if report.SingleDataFile {
 		filesStr = "Single Data File Per Segment"
 	}
@@ -156,6 +188,8 @@ func (report *Report) WriteBackupReportFile(reportFilename string, timestamp str
 			LineInfo{},
 			LineInfo{Key: "database size:", Value: strings.ToUpper(report.DatabaseSize)})
 	}
+	appendPluginRetries(&reportInfo, report.PluginRetries)
+	appendBlockedTables(&reportInfo, report.BlockedTables)
 
 	_, err = fmt.Fprint(reportFile, "Greenplum Database Backup Report\n\n")
 	if err != nil {
@@ -164,6 +198,9 @@ func (report *Report) WriteBackupReportFile(reportFilename string, timestamp str
 	}
 
 	logOutputReport(reportFile, reportInfo)
+	printPluginRetries(reportFile, report.PluginRetries)
+	printBlockedTables(reportFile, report.BlockedTables)
+	printEffectiveConfig(reportFile, report.EffectiveConfig)
 
 	PrintObjectCounts(reportFile, objectCounts)
 
@@ -172,7 +209,98 @@ func (report *Report) WriteBackupReportFile(reportFilename string, timestamp str
 	_ = operating.System.Chmod(reportFilename, 0444)
 }
 
-func WriteRestoreReportFile(reportFilename string, backupTimestamp string, startTimestamp string, connectionPool *dbconn.DBConn, restoreVersion string, errMsg string) {
+func WriteBackupSetReportFile(reportFilename string, backupSet *history.BackupSet, backupVersion string, endtime time.Time) {
+	reportFile, err := iohelper.OpenFileForWriting(reportFilename)
+	if err != nil {
+		gplog.Error("Unable to open backup set report file %s", reportFilename)
+		return
+	}
+
+	start, end, duration := GetDurationInfo(backupSet.Timestamp, endtime)
+	globalsStr := "None"
+	if backupSet.GlobalsTimestamp != "" {
+		globalsStr = backupSet.GlobalsTimestamp
+	}
+	reportInfo := []LineInfo{
+		{Key: "set timestamp key:", Value: backupSet.Timestamp},
+		{Key: "gpbackup version:", Value: fmt.Sprintf("%s\n", backupVersion)},
+		{Key: "command line:", Value: strings.Join(os.Args, " ")},
+		{},
+		{Key: "start time:", Value: start},
+		{Key: "end time:", Value: end},
+		{Key: "duration:", Value: duration},
+		{},
+		{Key: "set status:", Value: backupSet.Status},
+		{Key: "globals backed up in:", Value: globalsStr},
+	}
+
+	_, err = fmt.Fprint(reportFile, "Greenplum Database Backup Set Report\n\n")
+	if err != nil {
+		gplog.Error("Unable to write backup set report file %s", reportFilename)
+		return
+	}
+	logOutputReport(reportFile, reportInfo)
+	printBackupSetEntries(reportFile, backupSet.Backups)
+
+	err = reportFile.Close()
+	gplog.FatalOnError(err)
+	_ = operating.System.Chmod(reportFilename, 0444)
+}
+
+func printBackupSetEntries(reportFile io.WriteCloser, entries []history.BackupSetEntry) {
+	entryStr := "\ndatabase backups:\n"
+	maxSize := 0
+	for _, entry := range entries {
+		if len(entry.DatabaseName) > maxSize {
+			maxSize = len(entry.DatabaseName)
+		}
+	}
+	for _, entry := range entries {
+		timestamp := entry.Timestamp
+		if timestamp == "" {
+			timestamp = "-"
+		}
+		entryStr += fmt.Sprintf("%-*s%-17s%s\n", maxSize+3, entry.DatabaseName, timestamp, entry.Status)
+	}
+	utils.MustPrintf(reportFile, "%s", entryStr)
+}
+
+/*
+ * This struct holds the results of validating restored table data against the
+ * values recorded at backup time, which are printed to the restore report.
+ */
+type DataValidation struct {
+	RestoreTestDatabase string
+	Completed           bool
+	Tables              []TableValidation
+}
+
+type TableValidation struct {
+	Table    string
+	Failures []string
+}
+
+// A table whose statistics gprestore --stats-only did not restore, and why
+type SkippedStatistics struct {
+	Table  string
+	Reason string
+}
+
+func (validation *DataValidation) FailedTables() []TableValidation {
+	failedTables := make([]TableValidation, 0)
+	for _, table := range validation.Tables {
+		if len(table.Failures) > 0 {
+			failedTables = append(failedTables, table)
+		}
+	}
+	return failedTables
+}
+
+func (validation *DataValidation) Passed() bool {
+	return validation.Completed && len(validation.FailedTables()) == 0
+}
+
+func WriteRestoreReportFile(reportFilename string, backupTimestamp string, startTimestamp string, connectionPool *dbconn.DBConn, restoreVersion string, errMsg string, validation *DataValidation, pluginRetries []utils.PluginRetry, skippedStatistics []SkippedStatistics, postgresVersion string) {
 	reportFile, err := iohelper.OpenFileForWriting(reportFilename)
 	if err != nil {
 		gplog.Error("Unable to open restore report file %s", reportFilename)
@@ -184,10 +312,15 @@ func WriteRestoreReportFile(reportFilename string, backupTimestamp string, start
 
 	utils.MustPrintf(reportFile, "Greenplum Database Restore Report\n\n")
 
+	// A restore with --target-postgres has no Greenplum version to report
+	versionInfo := LineInfo{Key: "gpdb version:", Value: connectionPool.Version.VersionString}
+	if postgresVersion != "" {
+		versionInfo = LineInfo{Key: "postgres version:", Value: postgresVersion}
+	}
 	reportInfo := make([]LineInfo, 0)
 	reportInfo = append(reportInfo,
 		LineInfo{Key: "timestamp key:", Value: backupTimestamp},
-		LineInfo{Key: "gpdb version:", Value: connectionPool.Version.VersionString},
+		versionInfo,
 		LineInfo{Key: "gprestore version:", Value: fmt.Sprintf("%s\n", restoreVersion)},
 		LineInfo{Key: "database name:", Value: connectionPool.DBName},
 		LineInfo{Key: "command line:", Value: fmt.Sprintf("%s\n", gprestoreCommandLine)},
@@ -213,14 +346,129 @@ func WriteRestoreReportFile(reportFilename string, backupTimestamp string, start
 			LineInfo{},
 			LineInfo{Key: "restore status:", Value: "Success"})
 	}
+	if validation != nil {
+		appendDataValidation(&reportInfo, validation)
+	}
+	appendPluginRetries(&reportInfo, pluginRetries)
+	appendSkippedStatistics(&reportInfo, skippedStatistics)
 
 	logOutputReport(reportFile, reportInfo)
+	if validation != nil {
+		printTableValidationFailures(reportFile, validation)
+	}
+	printPluginRetries(reportFile, pluginRetries)
+	printSkippedStatistics(reportFile, skippedStatistics)
 
 	err = reportFile.Close()
 	gplog.FatalOnError(err)
 	_ = operating.System.Chmod(reportFilename, 0444)
 }
 
+func appendDataValidation(reportInfo *[]LineInfo, validation *DataValidation) {
+	*reportInfo = append(*reportInfo, LineInfo{})
+	if validation.RestoreTestDatabase != "" {
+		*reportInfo = append(*reportInfo, LineInfo{Key: "restore test database:", Value: validation.RestoreTestDatabase})
+	}
+	if !validation.Completed {
+		*reportInfo = append(*reportInfo, LineInfo{Key: "data validation:", Value: "Not completed"})
+		return
+	}
+	validationStatus := "Passed"
+	if !validation.Passed() {
+		validationStatus = "Failed"
+	}
+	*reportInfo = append(*reportInfo,
+		LineInfo{Key: "data validation:", Value: validationStatus},
+		LineInfo{Key: "tables validated:", Value: fmt.Sprintf("%d", len(validation.Tables))},
+		LineInfo{Key: "tables failed:", Value: fmt.Sprintf("%d", len(validation.FailedTables()))})
+}
+
+func printTableValidationFailures(reportFile io.WriteCloser, validation *DataValidation) {
+	failedTables := validation.FailedTables()
+	if len(failedTables) == 0 {
+		return
+	}
+	failureStr := "\ntables that failed validation:\n"
+	for _, table := range failedTables {
+		failureStr += fmt.Sprintf("%s: %s\n", table.Table, strings.Join(table.Failures, "; "))
+	}
+	utils.MustPrintf(reportFile, "%s", failureStr)
+}
+
+func appendPluginRetries(reportInfo *[]LineInfo, retries []utils.PluginRetry) {
+	if len(retries) == 0 {
+		return
+	}
+	*reportInfo = append(*reportInfo,
+		LineInfo{},
+		LineInfo{Key: "plugin retries:", Value: fmt.Sprintf("%d", len(retries))})
+}
+
+func printPluginRetries(reportFile io.WriteCloser, retries []utils.PluginRetry) {
+	if len(retries) == 0 {
+		return
+	}
+	retryStr := "\nplugin commands retried:\n"
+	for _, retry := range retries {
+		retryStr += fmt.Sprintf("%s %s (attempt %d): %s\n", retry.Command, retry.Filename, retry.Attempt, strings.TrimSpace(retry.Error))
+	}
+	utils.MustPrintf(reportFile, "%s", retryStr)
+}
+
+func appendSkippedStatistics(reportInfo *[]LineInfo, skippedStatistics []SkippedStatistics) {
+	if len(skippedStatistics) == 0 {
+		return
+	}
+	*reportInfo = append(*reportInfo,
+		LineInfo{},
+		LineInfo{Key: "statistics skipped:", Value: fmt.Sprintf("%d", len(skippedStatistics))})
+}
+
+func printSkippedStatistics(reportFile io.WriteCloser, skippedStatistics []SkippedStatistics) {
+	if len(skippedStatistics) == 0 {
+		return
+	}
+	skippedStr := "\ntables whose statistics were skipped:\n"
+	for _, table := range skippedStatistics {
+		skippedStr += fmt.Sprintf("%s: %s\n", table.Table, table.Reason)
+	}
+	utils.MustPrintf(reportFile, "%s", skippedStr)
+}
+
+func appendBlockedTables(reportInfo *[]LineInfo, blockedTables []BlockedTable) {
+	if len(blockedTables) == 0 {
+		return
+	}
+	*reportInfo = append(*reportInfo,
+		LineInfo{},
+		LineInfo{Key: "tables blocked:", Value: fmt.Sprintf("%d", len(blockedTables))})
+}
+
+func printBlockedTables(reportFile io.WriteCloser, blockedTables []BlockedTable) {
+	if len(blockedTables) == 0 {
+		return
+	}
+	blockedStr := "\ntables blocked while acquiring locks:\n"
+	for _, table := range blockedTables {
+		blockers := make([]string, 0)
+		for _, holder := range table.Blockers {
+			blockers = append(blockers, holder.String())
+		}
+		if len(blockers) == 0 {
+			blockers = append(blockers, "unknown")
+		}
+		blockedStr += fmt.Sprintf("%s: waited %s, %s; blocked by %s\n", table.Table, table.Waited.Round(time.Second), table.Action, strings.Join(blockers, ", "))
+	}
+	utils.MustPrintf(reportFile, "%s", blockedStr)
+}
+
+func printEffectiveConfig(reportFile io.WriteCloser, config string) {
+	if config == "" {
+		return
+	}
+	utils.MustPrintf(reportFile, "\neffective configuration:\n%s", config)
+}
+
 func logOutputReport(reportFile io.WriteCloser, reportInfo []LineInfo) {
 	maxSize := 0
 	for _, lineInfo := range reportInfo {
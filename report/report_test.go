@@ -149,6 +149,53 @@ count of database objects in backup:
 sequences   1
 tables      42
 types       1000`))
+		})
+		It("writes a report listing plugin commands that were retried", func() {
+			backupReport.PluginRetries = []utils.PluginRetry{
+				{Command: "backup_file", Filename: "/data/gpseg-1/backups/20170101/20170101010101/gpbackup_20170101010101_toc.yaml", Attempt: 1, Error: "ERROR: Plugin failed to process file. connection reset\n"},
+				{Command: "backup_data", Filename: "public.foo", Attempt: 2, Error: "ERROR: command error message: connection reset"},
+			}
+			backupReport.WriteBackupReportFile("filename", timestamp, endtime, objectCounts, "")
+			Expect(buffer).To(Say(`backup status:         Success
+
+database size:         42 MB
+
+plugin retries:        2
+
+plugin commands retried:
+backup_file /data/gpseg-1/backups/20170101/20170101010101/gpbackup_20170101010101_toc.yaml \(attempt 1\): ERROR: Plugin failed to process file. connection reset
+backup_data public.foo \(attempt 2\): ERROR: command error message: connection reset
+
+count of database objects in backup:`))
+		})
+		It("writes a report listing tables that were blocked while acquiring locks", func() {
+			backupReport.BlockedTables = []BlockedTable{
+				{Table: "public.foo", Blockers: []LockHolder{{Pid: 1234, Query: "ALTER TABLE public.foo ADD COLUMN j int"}}, Waited: 30 * time.Second, Action: "skipped"},
+				{Table: "public.bar", Waited: 90 * time.Second, Action: "locked after retrying"},
+			}
+			backupReport.WriteBackupReportFile("filename", timestamp, endtime, objectCounts, "")
+			Expect(buffer).To(Say(`backup status:         Success
+
+database size:         42 MB
+
+tables blocked:        2
+
+tables blocked while acquiring locks:
+public.foo: waited 30s, skipped; blocked by pid 1234 \(ALTER TABLE public.foo ADD COLUMN j int\)
+public.bar: waited 1m30s, locked after retrying; blocked by unknown
+
+count of database objects in backup:`))
+		})
+		It("writes a report with the effective configuration", func() {
+			backupReport.EffectiveConfig = "dbname: testdb\njobs: 4\n"
+			backupReport.WriteBackupReportFile("filename", timestamp, endtime, objectCounts, "")
+			Expect(buffer).To(Say(`database size:         42 MB
+
+effective configuration:
+dbname: testdb
+jobs: 4
+
+count of database objects in backup:`))
 		})
 		It("writes a report without database size information", func() {
 			backupReport.DatabaseSize = ""
@@ -179,6 +226,46 @@ tables      42
 types       1000`))
 		})
 	})
+	Describe("WriteBackupSetReportFile", func() {
+		It("writes a report listing the backup of each database in the set", func() {
+			operating.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+				return buffer, nil
+			}
+			operating.System.Chmod = func(name string, mode os.FileMode) error {
+				return nil
+			}
+			backupSet := &history.BackupSet{
+				Timestamp:        "20170101010101",
+				Status:           history.BackupStatusFailed,
+				GlobalsTimestamp: "20170101010102",
+				Backups: []history.BackupSetEntry{
+					{DatabaseName: "testdb", Timestamp: "20170101010102", Status: history.BackupStatusSucceed},
+					{DatabaseName: `"Test DB"`, Status: history.BackupStatusFailed},
+				},
+			}
+			endtime := time.Date(2017, 1, 1, 2, 3, 4, 0, time.Local)
+
+			WriteBackupSetReportFile("filename", backupSet, "0.1.0", endtime)
+
+			Expect(buffer).To(Say(`Greenplum Database Backup Set Report
+
+set timestamp key:      20170101010101
+gpbackup version:       0\.1\.0
+
+command line:           .*
+
+start time:             Sun Jan 01 2017 01:01:01
+end time:               Sun Jan 01 2017 02:03:04
+duration:               1:02:03
+
+set status:             Failure
+globals backed up in:   20170101010102
+
+database backups:
+testdb      20170101010102   Success
+"Test DB"   -                Failure`))
+		})
+	})
 	Describe("AppendBackupParams", func() {
 		It("correctly parses the string and appends to the LineInfo array", func() {
 			testParamsStr := `compression: exampleStr
@@ -239,7 +326,7 @@ incremental backup set:
 
 		It("writes a report for a failed restore", func() {
 			gplog.SetErrorCode(2)
-			WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, "Cannot access /tmp/backups: Permission denied")
+			WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, "Cannot access /tmp/backups: Permission denied", nil, nil, nil, "")
 			Expect(buffer).To(Say(`Greenplum Database Restore Report
 
 timestamp key:       20170101010101
@@ -258,7 +345,7 @@ restore error:       Cannot access /tmp/backups: Permission denied`))
 		})
 		It("writes a report for a successful restore", func() {
 			gplog.SetErrorCode(0)
-			WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, "")
+			WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, "", nil, nil, nil, "")
 			Expect(buffer).To(Say(`Greenplum Database Restore Report
 
 timestamp key:       20170101010101
@@ -273,10 +360,19 @@ end time:            Sun Jan 01 2017 05:04:03
 duration:            4:03:01
 
 restore status:      Success`))
+		})
+		It("writes a report for a restore to a PostgreSQL database", func() {
+			gplog.SetErrorCode(0)
+			WriteRestoreReportFile("filename", timestamp, restoreStartTime, &dbconn.DBConn{DBName: "testdb"}, restoreVersion, "", nil, nil, nil, "PostgreSQL 14.5")
+			Expect(buffer).To(Say(`Greenplum Database Restore Report
+
+timestamp key:       20170101010101
+postgres version:    PostgreSQL 14\.5
+gprestore version:   0\.1\.0`))
 		})
 		It("writes a report for a successful restore with errors", func() {
 			gplog.SetErrorCode(1)
-			WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, "")
+			WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, "", nil, nil, nil, "")
 			Expect(buffer).To(Say(`Greenplum Database Restore Report
 
 timestamp key:       20170101010101
@@ -292,6 +388,72 @@ duration:            4:03:01
 
 restore status:      Success but non-fatal errors occurred. See log file .+ for details.`))
 		})
+		It("writes a report for a restore test that passed validation", func() {
+			gplog.SetErrorCode(0)
+			validation := &DataValidation{
+				RestoreTestDatabase: "gprestore_test_20170101010101_20170101010102",
+				Completed:           true,
+				Tables:              []TableValidation{{Table: "public.foo"}, {Table: "public.bar"}},
+			}
+			WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, "", validation, nil, nil, "")
+			Expect(buffer).To(Say(`restore status:          Success
+
+restore test database:   gprestore_test_20170101010101_20170101010102
+data validation:         Passed
+tables validated:        2
+tables failed:           0`))
+			Expect(buffer).ToNot(Say("tables that failed validation"))
+		})
+		It("writes a report for a restore test that failed validation", func() {
+			gplog.SetErrorCode(1)
+			validation := &DataValidation{
+				RestoreTestDatabase: "gprestore_test_20170101010101_20170101010102",
+				Completed:           true,
+				Tables: []TableValidation{
+					{Table: "public.foo", Failures: []string{"expected 10 rows, found 9"}},
+					{Table: "public.bar"},
+				},
+			}
+			WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, "", validation, nil, nil, "")
+			Expect(buffer).To(Say(`data validation:         Failed
+tables validated:        2
+tables failed:           1
+
+tables that failed validation:
+public.foo: expected 10 rows, found 9`))
+		})
+		It("writes a report for a restore test that did not complete", func() {
+			gplog.SetErrorCode(2)
+			validation := &DataValidation{RestoreTestDatabase: "gprestore_test_20170101010101_20170101010102"}
+			WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, "Error loading data into table public.foo", validation, nil, nil, "")
+			Expect(buffer).To(Say(`restore status:          Failure
+restore error:           Error loading data into table public.foo
+
+restore test database:   gprestore_test_20170101010101_20170101010102
+data validation:         Not completed`))
+		})
+		It("writes a report for a statistics restore that skipped tables", func() {
+			gplog.SetErrorCode(0)
+			skippedStatistics := []SkippedStatistics{
+				{Table: "public.foo", Reason: "table does not exist"},
+				{Table: "public.bar", Reason: "stale, with 1500 rows instead of the 1000 rows backed up"},
+			}
+			WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, "", nil, nil, skippedStatistics, "")
+			Expect(buffer).To(Say(`restore status:       Success
+
+statistics skipped:   2
+
+tables whose statistics were skipped:
+public.foo: table does not exist
+public.bar: stale, with 1500 rows instead of the 1000 rows backed up`))
+		})
+		Describe("DataValidation", func() {
+			It("passes only if validation completed with no failed tables", func() {
+				Expect((&DataValidation{Completed: true, Tables: []TableValidation{{Table: "public.foo"}}}).Passed()).To(BeTrue())
+				Expect((&DataValidation{Completed: false, Tables: []TableValidation{{Table: "public.foo"}}}).Passed()).To(BeFalse())
+				Expect((&DataValidation{Completed: true, Tables: []TableValidation{{Table: "public.foo", Failures: []string{"mismatch"}}}}).Passed()).To(BeFalse())
+			})
+		})
 	})
 	Describe("SetBackupParamFromFlags", func() {
 		AfterEach(func() {
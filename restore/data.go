@@ -6,12 +6,15 @@ package restore
 
 import (
 	"fmt"
+	"io"
+	"sort"
 	"sync"
 	"sync/atomic"
 
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
 	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
 	"github.com/greenplum-db/gpbackup/options"
 	"github.com/greenplum-db/gpbackup/toc"
 	"github.com/greenplum-db/gpbackup/utils"
@@ -33,8 +36,13 @@ func CopyTableIn(connectionPool *dbconn.DBConn, tableName string, tableAttribute
 	if singleDataFile {
 		//helper.go handles compression, so we don't want to set it here
 		customPipeThroughCommand = "cat -"
-	} else if MustGetFlagString(options.PLUGIN_CONFIG) != "" {
-		readFromDestinationCommand = fmt.Sprintf("%s restore_data %s", pluginConfig.ExecutablePath, pluginConfig.ConfigPath)
+	} else {
+		if MustGetFlagString(options.PLUGIN_CONFIG) != "" {
+			readFromDestinationCommand = pluginConfig.RestoreDataCommand()
+		}
+		if throttleCommand := utils.GetThrottleCommand(); throttleCommand != "" {
+			customPipeThroughCommand = fmt.Sprintf("%s | %s", throttleCommand, customPipeThroughCommand)
+		}
 	}
 
 	copyCommand = fmt.Sprintf("PROGRAM '%s %s | %s'", readFromDestinationCommand, destinationToRead, customPipeThroughCommand)
@@ -56,14 +64,33 @@ func CopyTableIn(connectionPool *dbconn.DBConn, tableName string, tableAttribute
 	return numRows, err
 }
 
+/*
+ * When restoring from one file per table with a plugin, a COPY that fails can
+ * be re-run if the plugin config allows restore_data to be retried.  A failed
+ * COPY loads no rows, so it can simply be run again.
+ */
+func CopyTableInWithRetries(tableName string, tableAttributes string, destinationToRead string, whichConn int) (int64, error) {
+	if backupConfig.SingleDataFile || pluginConfig == nil || !pluginConfig.CanRetry("restore_data") {
+		return CopyTableIn(connectionPool, tableName, tableAttributes, destinationToRead, backupConfig.SingleDataFile, whichConn)
+	}
+	var numRowsRestored int64
+	err := pluginConfig.WithRetries("restore_data", tableName, func() error {
+		var err error
+		numRowsRestored, err = CopyTableIn(connectionPool, tableName, tableAttributes, destinationToRead, false, whichConn)
+		return err
+	})
+	return numRowsRestored, err
+}
+
 func restoreSingleTableData(fpInfo *filepath.FilePathInfo, entry toc.MasterDataEntry, tableName string, whichConn int) error {
 	destinationToRead := ""
 	if backupConfig.SingleDataFile {
-		destinationToRead = fmt.Sprintf("%s_%d", fpInfo.GetSegmentPipePathForCopyCommand(), entry.Oid)
+		streamFPInfo := fpInfo.ForDataStream(entry.DataStream)
+		destinationToRead = fmt.Sprintf("%s_%d", streamFPInfo.GetSegmentPipePathForCopyCommand(), entry.Oid)
 	} else {
 		destinationToRead = fpInfo.GetTableBackupFilePathForCopyCommand(entry.Oid, utils.GetPipeThroughProgram().Extension, backupConfig.SingleDataFile)
 	}
-	numRowsRestored, err := CopyTableIn(connectionPool, tableName, entry.AttributeString, destinationToRead, backupConfig.SingleDataFile, whichConn)
+	numRowsRestored, err := CopyTableInWithRetries(tableName, entry.AttributeString, destinationToRead, whichConn)
 	if err != nil {
 		return err
 	}
@@ -75,6 +102,32 @@ func restoreSingleTableData(fpInfo *filepath.FilePathInfo, entry toc.MasterDataE
 	return nil
 }
 
+/*
+ * Opens the data of a single table on a single segment for reading, from the
+ * table's own data file or from its byte range in the segment's single data
+ * file, so that the data can be read without gpbackup_helper.
+ */
+func OpenSegmentTableData(fpInfo filepath.FilePathInfo, backupConfig *history.BackupConfig, contentID int, oid uint32) (io.ReadCloser, error) {
+	extension := ""
+	if backupConfig.Compressed {
+		extension = ".gz"
+	}
+	if !backupConfig.SingleDataFile {
+		return utils.OpenDataFileForReading(fpInfo.GetTableBackupFilePath(contentID, oid, extension, false))
+	}
+
+	segmentTOC := toc.NewSegmentTOC(fpInfo.GetSegmentTOCFilePath(contentID))
+	segmentEntry, ok := segmentTOC.DataEntries[uint(oid)]
+	if !ok {
+		return nil, errors.Errorf("No data for table with oid %d in segment TOC for segment %d", oid, contentID)
+	}
+	dataFilename := fpInfo.GetTableBackupFilePath(contentID, 0, extension, true)
+	if backupConfig.Compressed && segmentTOC.HasCompressedFrames() {
+		return utils.OpenDataFileFramesForReading(dataFilename, segmentEntry.CompressedStartByte, segmentEntry.CompressedEndByte)
+	}
+	return utils.OpenDataFileRangeForReading(dataFilename, segmentEntry.StartByte, segmentEntry.EndByte)
+}
+
 func CheckRowsRestored(rowsRestored int64, rowsBackedUp int64, tableName string) error {
 	if rowsRestored != rowsBackedUp {
 		rowsErrMsg := fmt.Sprintf("Expected to restore %d rows to table %s, but restored %d instead", rowsBackedUp, tableName, rowsRestored)
@@ -83,6 +136,47 @@ func CheckRowsRestored(rowsRestored int64, rowsBackedUp int64, tableName string)
 	return nil
 }
 
+/*
+ * Each data stream is restored by its own workers, which take its tables in
+ * turn so that its gpbackup_helper can serve them at once.  When there are
+ * fewer workers than data streams, each worker restores several data streams,
+ * one after another.
+ */
+func AssignWorkersToDataStreams(numStreams int, numWorkers int) [][]int {
+	workerStreams := make([][]int, numWorkers)
+	if numStreams >= numWorkers {
+		for stream := 0; stream < numStreams; stream++ {
+			worker := stream % numWorkers
+			workerStreams[worker] = append(workerStreams[worker], stream)
+		}
+	} else {
+		for worker := range workerStreams {
+			workerStreams[worker] = []int{worker % numStreams}
+		}
+	}
+	return workerStreams
+}
+
+/*
+ * Each data stream's gpbackup_helper takes the tables of its oid list in oid
+ * order, so the entries of each stream are restored in that order.
+ */
+func GroupDataEntriesByStream(dataEntries []toc.MasterDataEntry) [][]toc.MasterDataEntry {
+	dataStreams := make([][]toc.MasterDataEntry, 0)
+	for _, entry := range dataEntries {
+		for len(dataStreams) <= entry.DataStream {
+			dataStreams = append(dataStreams, make([]toc.MasterDataEntry, 0))
+		}
+		dataStreams[entry.DataStream] = append(dataStreams[entry.DataStream], entry)
+	}
+	for _, streamEntries := range dataStreams {
+		sort.SliceStable(streamEntries, func(i int, j int) bool {
+			return streamEntries[i].Oid < streamEntries[j].Oid
+		})
+	}
+	return dataStreams
+}
+
 func restoreDataFromTimestamp(fpInfo filepath.FilePathInfo, dataEntries []toc.MasterDataEntry,
 	gucStatements []toc.StatementWithType, dataProgressBar utils.ProgressBar) int32 {
 	totalTables := len(dataEntries)
@@ -90,25 +184,58 @@ func restoreDataFromTimestamp(fpInfo filepath.FilePathInfo, dataEntries []toc.Ma
 		gplog.Verbose("No data to restore for timestamp = %s", fpInfo.Timestamp)
 		return 0
 	}
+	if MustGetFlagBool(options.TARGET_POSTGRES) {
+		return restoreDataToPostgres(fpInfo, dataEntries, gucStatements, dataProgressBar)
+	}
 
+	streams := [][]toc.MasterDataEntry{dataEntries}
 	if backupConfig.SingleDataFile {
-		gplog.Verbose("Initializing pipes and gpbackup_helper on segments for single data file restore")
-		utils.VerifyHelperVersionOnSegments(version, globalCluster)
-		filteredOids := make([]string, totalTables)
-		for i, entry := range dataEntries {
-			filteredOids[i] = fmt.Sprintf("%d", entry.Oid)
+		streams = make([][]toc.MasterDataEntry, 0)
+		dataStreams := GroupDataEntriesByStream(dataEntries)
+		if len(dataStreams) > numHelperDataStreams {
+			numHelperDataStreams = len(dataStreams)
 		}
-		utils.WriteOidListToSegments(filteredOids, globalCluster, fpInfo)
-		firstOid := fmt.Sprintf("%d", dataEntries[0].Oid)
-		utils.CreateFirstSegmentPipeOnAllHosts(firstOid, globalCluster, fpInfo)
-		if wasTerminated {
-			return 0
+		for _, streamEntries := range dataStreams {
+			if len(streamEntries) == 0 {
+				// No tables are restored from this stream's data file
+				continue
+			}
+			streams = append(streams, streamEntries)
 		}
+	}
+	workerStreams := AssignWorkersToDataStreams(len(streams), connectionPool.NumConns)
+
+	if backupConfig.SingleDataFile {
+		gplog.Verbose("Initializing pipes and gpbackup_helper on segments for single data file restore")
+		utils.VerifyHelperVersionOnSegments(version, globalCluster)
 		isFilter := false
 		if len(opts.IncludedRelations) > 0 || len(opts.ExcludedRelations) > 0 || len(opts.IncludedSchemas) > 0 || len(opts.ExcludedSchemas) > 0 {
 			isFilter = true
 		}
-		utils.StartGpbackupHelpers(globalCluster, fpInfo, "--restore-agent", MustGetFlagString(options.PLUGIN_CONFIG), "", MustGetFlagBool(options.ON_ERROR_CONTINUE), isFilter, &wasTerminated)
+		copyWorkers := make([]int, len(streams))
+		for _, assignedStreams := range workerStreams {
+			for _, stream := range assignedStreams {
+				copyWorkers[stream]++
+			}
+		}
+		for i, streamEntries := range streams {
+			streamFPInfo := fpInfo.ForDataStream(streamEntries[0].DataStream)
+			filteredOids := make([]string, len(streamEntries))
+			for j, entry := range streamEntries {
+				filteredOids[j] = fmt.Sprintf("%d", entry.Oid)
+			}
+			utils.WriteOidListToSegments(filteredOids, globalCluster, streamFPInfo)
+			// gpbackup_helper creates the later pipes as it takes tables, so the pipes of the stream's first COPYs are created here
+			numFirstPipes := copyWorkers[i]
+			if numFirstPipes > len(filteredOids) {
+				numFirstPipes = len(filteredOids)
+			}
+			utils.CreateFirstSegmentPipesOnAllHosts(filteredOids[:numFirstPipes], globalCluster, streamFPInfo)
+			if wasTerminated {
+				return 0
+			}
+			utils.StartGpbackupHelpers(globalCluster, streamFPInfo, "--restore-agent", MustGetFlagString(options.PLUGIN_CONFIG), "", MustGetFlagBool(options.ON_ERROR_CONTINUE), isFilter, copyWorkers[i], &wasTerminated)
+		}
 	}
 	/*
 	 * We break when an interrupt is received and rely on
@@ -116,7 +243,14 @@ func restoreDataFromTimestamp(fpInfo filepath.FilePathInfo, dataEntries []toc.Ma
 	 * statements in progress if they don't finish on their own.
 	 */
 	var tableNum int64 = 0
-	tasks := make(chan toc.MasterDataEntry, totalTables)
+	taskQueues := make([]chan toc.MasterDataEntry, len(streams))
+	for i, streamEntries := range streams {
+		taskQueues[i] = make(chan toc.MasterDataEntry, len(streamEntries))
+		for _, entry := range streamEntries {
+			taskQueues[i] <- entry
+		}
+		close(taskQueues[i])
+	}
 	var workerPool sync.WaitGroup
 	var numErrors int32
 	var mutex = &sync.Mutex{}
@@ -127,63 +261,61 @@ func restoreDataFromTimestamp(fpInfo filepath.FilePathInfo, dataEntries []toc.Ma
 			defer workerPool.Done()
 
 			setGUCsForConnection(gucStatements, whichConn)
-			for entry := range tasks {
-				if wasTerminated {
-					dataProgressBar.(*pb.ProgressBar).NotPrint = true
-					return
-				}
-				tableName := utils.MakeFQN(entry.Schema, entry.Name)
-				if opts.RedirectSchema != "" {
-					tableName = utils.MakeFQN(opts.RedirectSchema, entry.Name)
-				}
-				// Truncate table before restore, if needed
-				var err error
-				if MustGetFlagBool(options.INCREMENTAL) || MustGetFlagBool(options.TRUNCATE_TABLE) {
-					err = TruncateTable(tableName, whichConn)
-				}
-				if err == nil {
-					err = restoreSingleTableData(&fpInfo, entry, tableName, whichConn)
-
-					atomic.AddInt64(&tableNum, 1)
-					if gplog.GetVerbosity() > gplog.LOGINFO {
-						// No progress bar at this log level, so we note table count here
-						gplog.Verbose("Restored data to table %s from file (table %d of %d)", tableName, tableNum, totalTables)
-					} else {
-						gplog.Verbose("Restored data to table %s from file", tableName)
-					}
-				}
-
-				if err != nil {
-					gplog.Error(err.Error())
-					atomic.AddInt32(&numErrors, 1)
-					if !MustGetFlagBool(options.ON_ERROR_CONTINUE) {
+			for _, stream := range workerStreams[whichConn] {
+				for entry := range taskQueues[stream] {
+					if wasTerminated {
 						dataProgressBar.(*pb.ProgressBar).NotPrint = true
 						return
-					} else if connectionPool.Version.AtLeast("6") && backupConfig.SingleDataFile {
-						// inform segment helpers to skip this entry
-						utils.CreateSkipFileOnSegments(fmt.Sprintf("%d", entry.Oid), tableName, globalCluster, globalFPInfo)
 					}
-					mutex.Lock()
-					errorTablesData[tableName] = Empty{}
-					mutex.Unlock()
-				}
+					tableName := utils.MakeFQN(entry.Schema, entry.Name)
+					if opts.RedirectSchema != "" {
+						tableName = utils.MakeFQN(opts.RedirectSchema, entry.Name)
+					}
+					// Truncate table before restore, if needed
+					var err error
+					if MustGetFlagBool(options.INCREMENTAL) || MustGetFlagBool(options.TRUNCATE_TABLE) {
+						err = TruncateTable(tableName, whichConn)
+					}
+					if err == nil {
+						err = restoreSingleTableData(&fpInfo, entry, tableName, whichConn)
 
-				if backupConfig.SingleDataFile {
-					agentErr := utils.CheckAgentErrorsOnSegments(globalCluster, globalFPInfo)
-					if agentErr != nil {
-						gplog.Error(agentErr.Error())
-						return
+						atomic.AddInt64(&tableNum, 1)
+						if gplog.GetVerbosity() > gplog.LOGINFO {
+							// No progress bar at this log level, so we note table count here
+							gplog.Verbose("Restored data to table %s from file (table %d of %d)", tableName, tableNum, totalTables)
+						} else {
+							gplog.Verbose("Restored data to table %s from file", tableName)
+						}
+					}
+
+					if err != nil {
+						gplog.Error(err.Error())
+						atomic.AddInt32(&numErrors, 1)
+						if !MustGetFlagBool(options.ON_ERROR_CONTINUE) {
+							dataProgressBar.(*pb.ProgressBar).NotPrint = true
+							return
+						} else if connectionPool.Version.AtLeast("6") && backupConfig.SingleDataFile {
+							// inform segment helpers to skip this entry
+							utils.CreateSkipFileOnSegments(fmt.Sprintf("%d", entry.Oid), tableName, globalCluster, fpInfo.ForDataStream(entry.DataStream))
+						}
+						mutex.Lock()
+						errorTablesData[tableName] = Empty{}
+						mutex.Unlock()
+					}
+
+					if backupConfig.SingleDataFile {
+						agentErr := utils.CheckAgentErrorsOnSegments(globalCluster, fpInfo.ForDataStream(entry.DataStream))
+						if agentErr != nil {
+							gplog.Error(agentErr.Error())
+							return
+						}
 					}
-				}
 
-				dataProgressBar.Increment()
+					dataProgressBar.Increment()
+				}
 			}
 		}(i)
 	}
-	for _, entry := range dataEntries {
-		tasks <- entry
-	}
-	close(tasks)
 	workerPool.Wait()
 
 	if numErrors > 0 {
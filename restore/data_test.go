@@ -1,12 +1,22 @@
 package restore_test
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	path "path/filepath"
 	"regexp"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
 	"github.com/greenplum-db/gpbackup/backup"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
 	"github.com/greenplum-db/gpbackup/options"
 	"github.com/greenplum-db/gpbackup/restore"
+	"github.com/greenplum-db/gpbackup/testutils"
+	"github.com/greenplum-db/gpbackup/toc"
 	"github.com/greenplum-db/gpbackup/utils"
 	"github.com/jackc/pgconn"
 
@@ -38,6 +48,20 @@ var _ = Describe("restore/data tests", func() {
 
 			Expect(err).ShouldNot(HaveOccurred())
 		})
+		It("will restore a table from its own file through a throttling stage when data is rate limited", func() {
+			testCluster := testutils.SetupTestCluster()
+			testCluster.Executor = &testhelper.TestExecutor{ClusterOutput: &cluster.RemoteOutput{}}
+			fpInfo := filepath.NewFilePathInfo(testCluster, "", "20170101010101", "gpseg")
+			utils.StartRateController(testCluster, fpInfo, path.Join(os.TempDir(), "gprestore_test_rate_limit.yaml"), utils.RateLimit{MaxRate: 10}, 1)
+			defer utils.StopRateController()
+			utils.SetPipeThroughProgram(utils.PipeThroughProgram{Name: "gzip", OutputCommand: "gzip -c -1", InputCommand: "gzip -d -c", Extension: ".gz"})
+			execStr := regexp.QuoteMeta(fmt.Sprintf("COPY public.foo(i,j) FROM PROGRAM 'cat <SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz | gpbackup_helper --throttle --rate-file <SEG_DATA_DIR>/gpbackup_<SEGID>_20170101010101_rate_%d | gzip -d -c' WITH CSV DELIMITER ',' ON SEGMENT;", fpInfo.PID))
+			mock.ExpectExec(execStr).WillReturnResult(sqlmock.NewResult(10, 0))
+			filename := "<SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz"
+			_, err := restore.CopyTableIn(connectionPool, "public.foo", "(i,j)", filename, false, 0)
+
+			Expect(err).ShouldNot(HaveOccurred())
+		})
 		It("will restore a table from a single data file", func() {
 			execStr := regexp.QuoteMeta("COPY public.foo(i,j) FROM PROGRAM 'cat <SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_pipe_3456 | cat -' WITH CSV DELIMITER ',' ON SEGMENT;")
 			mock.ExpectExec(execStr).WillReturnResult(sqlmock.NewResult(10, 0))
@@ -89,6 +113,77 @@ var _ = Describe("restore/data tests", func() {
 				"ERROR: value of distribution key doesn't belong to segment with ID 0, it belongs to segment with ID 1 (SQLSTATE 22P04)"))
 		})
 	})
+	Describe("CopyTableInWithRetries", func() {
+		filename := "<SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456"
+		var pluginConfig utils.PluginConfig
+		BeforeEach(func() {
+			utils.SetPipeThroughProgram(utils.PipeThroughProgram{Name: "cat", OutputCommand: "cat -", InputCommand: "cat -", Extension: ""})
+			_ = cmdFlags.Set(options.PLUGIN_CONFIG, "/tmp/plugin_config")
+			pluginConfig = utils.PluginConfig{ExecutablePath: "/tmp/fake-plugin.sh", ConfigPath: "/tmp/plugin_config", Retry: &utils.RetryPolicy{MaxAttempts: 3}}
+			restore.SetPluginConfig(&pluginConfig)
+			restore.SetBackupConfig(&history.BackupConfig{})
+		})
+		AfterEach(func() {
+			restore.SetPluginConfig(nil)
+		})
+		It("re-runs a failed COPY", func() {
+			mock.ExpectExec("COPY public.foo(.*) FROM (.*)").WillReturnError(errors.New("plugin failed"))
+			mock.ExpectExec("COPY public.foo(.*) FROM (.*)").WillReturnResult(sqlmock.NewResult(0, 10))
+
+			rowsRestored, err := restore.CopyTableInWithRetries("public.foo", "(i,j)", filename, 0)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rowsRestored).To(Equal(int64(10)))
+			Expect(pluginConfig.Retries()).To(Equal([]utils.PluginRetry{{Command: "restore_data", Filename: "public.foo", Attempt: 1, Error: "Error loading data into table public.foo: plugin failed"}}))
+		})
+		It("does not retry a COPY from a single data file", func() {
+			restore.SetBackupConfig(&history.BackupConfig{SingleDataFile: true})
+			mock.ExpectExec("COPY public.foo(.*) FROM (.*)").WillReturnError(errors.New("plugin failed"))
+
+			_, err := restore.CopyTableInWithRetries("public.foo", "(i,j)", filename, 0)
+
+			Expect(err).To(MatchError("Error loading data into table public.foo: plugin failed"))
+			Expect(pluginConfig.Retries()).To(BeEmpty())
+		})
+	})
+	Describe("GroupDataEntriesByStream", func() {
+		It("groups the entries by data stream, in oid order", func() {
+			entries := []toc.MasterDataEntry{
+				{Schema: "public", Name: "t3", Oid: 3},
+				{Schema: "public", Name: "t2", Oid: 2, DataStream: 1},
+				{Schema: "public", Name: "t1", Oid: 1},
+				{Schema: "public", Name: "t4", Oid: 4, DataStream: 1},
+			}
+
+			dataStreams := restore.GroupDataEntriesByStream(entries)
+
+			Expect(dataStreams).To(Equal([][]toc.MasterDataEntry{{entries[2], entries[0]}, {entries[1], entries[3]}}))
+		})
+		It("returns an empty group for a stream with no entries to restore", func() {
+			entries := []toc.MasterDataEntry{{Schema: "public", Name: "t2", Oid: 2, DataStream: 1}}
+
+			dataStreams := restore.GroupDataEntriesByStream(entries)
+
+			Expect(dataStreams).To(Equal([][]toc.MasterDataEntry{{}, {entries[0]}}))
+		})
+	})
+	Describe("AssignWorkersToDataStreams", func() {
+		It("assigns all workers to a single data stream", func() {
+			workerStreams := restore.AssignWorkersToDataStreams(1, 3)
+
+			Expect(workerStreams).To(Equal([][]int{{0}, {0}, {0}}))
+		})
+		It("shares the workers between the data streams", func() {
+			workerStreams := restore.AssignWorkersToDataStreams(2, 5)
+
+			Expect(workerStreams).To(Equal([][]int{{0}, {1}, {0}, {1}, {0}}))
+		})
+		It("assigns several data streams to a worker when there are fewer workers than data streams", func() {
+			workerStreams := restore.AssignWorkersToDataStreams(3, 2)
+
+			Expect(workerStreams).To(Equal([][]int{{0, 2}, {1}}))
+		})
+	})
 	Describe("CheckRowsRestored", func() {
 		var (
 			expectedRows int64 = 10
@@ -0,0 +1,200 @@
+package restore
+
+/*
+ * This file contains functions related to validating restored table data
+ * against the row counts and fingerprints recorded in the TOC at backup time,
+ * and to managing the temporary database used by --restore-test.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/report"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+)
+
+func GetRestoreTestDatabaseName(backupTimestamp string, restoreTimestamp string) string {
+	return fmt.Sprintf("gprestore_test_%s_%s", backupTimestamp, restoreTimestamp)
+}
+
+func DropRestoreTestDatabase() {
+	restoreTestDatabase := MustGetFlagString(options.REDIRECT_DB)
+	gplog.Info("Dropping restore test database %s", restoreTestDatabase)
+	restoreTestDatabaseCreated = false
+
+	conn := dbconn.NewDBConnFromEnvironment("postgres")
+	var err error
+	if MustGetFlagBool(options.TARGET_POSTGRES) {
+		_, err = ConnectToPostgres(conn, 1)
+	} else {
+		err = conn.Connect(1)
+	}
+	if err == nil {
+		defer conn.Close()
+		_, err = conn.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s", utils.QuoteIdent(conn, restoreTestDatabase)))
+	}
+	if err != nil {
+		gplog.Warn("Unable to drop restore test database %s: %v", restoreTestDatabase, err)
+	}
+}
+
+/*
+ * Returns the tables into which the given entries were restored, along with
+ * the entries restored into each table.  This is one table per entry, except
+ * with --target-postgres where leaf partitions are restored into their roots.
+ */
+func GetTablesToValidate(dataEntries []toc.MasterDataEntry) ([]string, map[string][]toc.MasterDataEntry) {
+	if MustGetFlagBool(options.TARGET_POSTGRES) {
+		return GetPostgresTargetTables(dataEntries, opts.RedirectSchema)
+	}
+	tableNames := make([]string, 0)
+	entriesByTable := make(map[string][]toc.MasterDataEntry)
+	for _, entry := range dataEntries {
+		schema := entry.Schema
+		if opts.RedirectSchema != "" {
+			schema = opts.RedirectSchema
+		}
+		tableName := utils.MakeFQN(schema, entry.Name)
+		if _, ok := entriesByTable[tableName]; !ok {
+			tableNames = append(tableNames, tableName)
+		}
+		entriesByTable[tableName] = append(entriesByTable[tableName], entry)
+	}
+	return tableNames, entriesByTable
+}
+
+func ValidateRestoredData(filteredDataEntries map[string][]toc.MasterDataEntry) {
+	if wasTerminated {
+		return
+	}
+	gplog.Info("Validating restored data")
+
+	timestamps := make([]string, 0, len(filteredDataEntries))
+	for timestamp := range filteredDataEntries {
+		timestamps = append(timestamps, timestamp)
+	}
+	sort.Strings(timestamps)
+	dataEntries := make([]toc.MasterDataEntry, 0)
+	for _, timestamp := range timestamps {
+		dataEntries = append(dataEntries, filteredDataEntries[timestamp]...)
+	}
+	tableNames, entriesByTable := GetTablesToValidate(dataEntries)
+	for _, entry := range dataEntries {
+		if entry.Fingerprint != "" {
+			for i := 0; i < connectionPool.NumConns; i++ {
+				utils.SetFingerprintSessionGUCs(connectionPool, i)
+				// connectionPool.Version is not set for PostgreSQL, all supported versions of which have IntervalStyle
+				if MustGetFlagBool(options.TARGET_POSTGRES) {
+					connectionPool.MustExec("SET INTERVALSTYLE = POSTGRES", i)
+				}
+			}
+			break
+		}
+	}
+
+	results := make([]report.TableValidation, len(tableNames))
+	tasks := make(chan int, len(tableNames))
+	var workerPool sync.WaitGroup
+	progressBar := utils.NewProgressBar(len(tableNames), "Tables validated: ", utils.PB_INFO)
+	progressBar.Start()
+	for i := 0; i < connectionPool.NumConns; i++ {
+		workerPool.Add(1)
+		go func(whichConn int) {
+			defer workerPool.Done()
+			for index := range tasks {
+				if wasTerminated {
+					return
+				}
+				results[index] = ValidateTableData(tableNames[index], entriesByTable[tableNames[index]], whichConn)
+				progressBar.Increment()
+			}
+		}(i)
+	}
+	for index := range tableNames {
+		tasks <- index
+	}
+	close(tasks)
+	workerPool.Wait()
+	progressBar.Finish()
+
+	if wasTerminated {
+		gplog.Info("Data validation incomplete")
+		return
+	}
+	dataValidation.Tables = results
+	dataValidation.Completed = true
+	failedTables := dataValidation.FailedTables()
+	for _, table := range failedTables {
+		gplog.Verbose("Data validation failed for table %s: %s", table.Table, table.Failures)
+	}
+	if len(failedTables) > 0 {
+		gplog.Error("Data validation failed for %d of %d table(s); see the restore report for details.", len(failedTables), len(results))
+	} else {
+		gplog.Info("Data validation passed for %d table(s)", len(results))
+	}
+}
+
+func ValidateTableData(tableName string, entries []toc.MasterDataEntry, whichConn int) report.TableValidation {
+	validation := report.TableValidation{Table: tableName, Failures: make([]string, 0)}
+	expected, hasFingerprint, err := GetExpectedFingerprint(entries)
+	if err != nil {
+		validation.Failures = append(validation.Failures, err.Error())
+		return validation
+	}
+	if !hasFingerprint {
+		var restoredRows int64
+		err = connectionPool.Get(&restoredRows, fmt.Sprintf("SELECT count(*) FROM %s", tableName), whichConn)
+		if err != nil {
+			validation.Failures = append(validation.Failures, fmt.Sprintf("unable to count rows: %v", err))
+		} else if restoredRows != expected.Rows {
+			validation.Failures = append(validation.Failures, fmt.Sprintf("expected %d rows, found %d", expected.Rows, restoredRows))
+		}
+		return validation
+	}
+	restored, err := utils.GetTableFingerprint(connectionPool, tableName, whichConn)
+	if err != nil {
+		validation.Failures = append(validation.Failures, fmt.Sprintf("unable to compute fingerprint: %v", err))
+		return validation
+	}
+	if restored.Rows != expected.Rows {
+		validation.Failures = append(validation.Failures, fmt.Sprintf("expected %d rows, found %d", expected.Rows, restored.Rows))
+	}
+	if restored.Hash != expected.Hash {
+		validation.Failures = append(validation.Failures, fmt.Sprintf("expected fingerprint %s, found %s", expected, restored))
+	}
+	return validation
+}
+
+/*
+ * Returns the combined row count and fingerprint of the given entries.  If
+ * any entry was backed up without a fingerprint, only the row count can be
+ * compared.
+ */
+func GetExpectedFingerprint(entries []toc.MasterDataEntry) (utils.TableFingerprint, bool, error) {
+	expected := utils.TableFingerprint{}
+	hasFingerprint := len(entries) > 0
+	for _, entry := range entries {
+		expected.Rows += entry.RowsCopied
+		if entry.Fingerprint == "" {
+			hasFingerprint = false
+		}
+	}
+	if !hasFingerprint {
+		return expected, false, nil
+	}
+	expected = utils.TableFingerprint{}
+	for _, entry := range entries {
+		fingerprint, err := utils.ParseTableFingerprint(entry.Fingerprint)
+		if err != nil {
+			return expected, false, err
+		}
+		expected = expected.Add(fingerprint)
+	}
+	return expected, true, nil
+}
@@ -0,0 +1,86 @@
+package restore_test
+
+import (
+	"errors"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gpbackup/restore"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("restore/data_validation tests", func() {
+	Describe("GetRestoreTestDatabaseName", func() {
+		It("includes the backup and restore timestamps in the database name", func() {
+			name := restore.GetRestoreTestDatabaseName("20170101010101", "20170101010102")
+			Expect(name).To(Equal("gprestore_test_20170101010101_20170101010102"))
+		})
+	})
+	Describe("GetExpectedFingerprint", func() {
+		It("adds up the fingerprints of all entries", func() {
+			entries := []toc.MasterDataEntry{
+				{Schema: "public", Name: "foo_1_prt_1", RowsCopied: 4, Fingerprint: "4:100"},
+				{Schema: "public", Name: "foo_1_prt_2", RowsCopied: 6, Fingerprint: "6:-300"},
+			}
+			expected, hasFingerprint, err := restore.GetExpectedFingerprint(entries)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hasFingerprint).To(BeTrue())
+			Expect(expected).To(Equal(utils.TableFingerprint{Rows: 10, Hash: -200}))
+		})
+		It("returns only the row count when an entry has no fingerprint", func() {
+			entries := []toc.MasterDataEntry{
+				{Schema: "public", Name: "foo_1_prt_1", RowsCopied: 4, Fingerprint: "4:100"},
+				{Schema: "public", Name: "foo_1_prt_2", RowsCopied: 6},
+			}
+			expected, hasFingerprint, err := restore.GetExpectedFingerprint(entries)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hasFingerprint).To(BeFalse())
+			Expect(expected.Rows).To(Equal(int64(10)))
+		})
+		It("returns an error for an invalid fingerprint", func() {
+			entries := []toc.MasterDataEntry{{Schema: "public", Name: "foo", RowsCopied: 4, Fingerprint: "bad"}}
+			_, _, err := restore.GetExpectedFingerprint(entries)
+			Expect(err).To(MatchError("Invalid table fingerprint bad"))
+		})
+	})
+	Describe("ValidateTableData", func() {
+		entries := []toc.MasterDataEntry{
+			{Schema: "public", Name: "foo_1_prt_1", RowsCopied: 4},
+			{Schema: "public", Name: "foo_1_prt_2", RowsCopied: 6},
+		}
+		It("passes when the restored row count matches the rows backed up", func() {
+			mock.ExpectQuery("SELECT count\\(\\*\\) FROM public.foo").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+			validation := restore.ValidateTableData("public.foo", entries, 0)
+			Expect(validation.Table).To(Equal("public.foo"))
+			Expect(validation.Failures).To(BeEmpty())
+		})
+		It("fails when the restored row count differs from the rows backed up", func() {
+			mock.ExpectQuery("SELECT count\\(\\*\\) FROM public.foo").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(9))
+			validation := restore.ValidateTableData("public.foo", entries, 0)
+			Expect(validation.Failures).To(Equal([]string{"expected 10 rows, found 9"}))
+		})
+		It("passes when the restored fingerprint matches the fingerprint backed up", func() {
+			entries := []toc.MasterDataEntry{
+				{Schema: "public", Name: "foo_1_prt_1", RowsCopied: 4, Fingerprint: "4:100"},
+				{Schema: "public", Name: "foo_1_prt_2", RowsCopied: 6, Fingerprint: "6:-300"},
+			}
+			mock.ExpectQuery("SELECT count(.*) FROM public.foo t").WillReturnRows(sqlmock.NewRows([]string{"rowcount", "hashsum"}).AddRow(10, -200))
+			validation := restore.ValidateTableData("public.foo", entries, 0)
+			Expect(validation.Failures).To(BeEmpty())
+		})
+		It("fails when the restored fingerprint differs from the fingerprint backed up", func() {
+			entries := []toc.MasterDataEntry{{Schema: "public", Name: "foo", RowsCopied: 10, Fingerprint: "10:-200"}}
+			mock.ExpectQuery("SELECT count(.*) FROM public.foo t").WillReturnRows(sqlmock.NewRows([]string{"rowcount", "hashsum"}).AddRow(10, 500))
+			validation := restore.ValidateTableData("public.foo", entries, 0)
+			Expect(validation.Failures).To(Equal([]string{"expected fingerprint 10:-200, found 10:500"}))
+		})
+		It("fails when the restored rows cannot be counted", func() {
+			mock.ExpectQuery("SELECT count\\(\\*\\) FROM public.foo").WillReturnError(errors.New("relation does not exist"))
+			validation := restore.ValidateTableData("public.foo", entries, 0)
+			Expect(validation.Failures).To(Equal([]string{"unable to count rows: relation does not exist"}))
+		})
+	})
+})
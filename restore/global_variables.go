@@ -8,6 +8,8 @@ import (
 	"github.com/greenplum-db/gpbackup/filepath"
 	"github.com/greenplum-db/gpbackup/history"
 	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/report"
+	"github.com/greenplum-db/gpbackup/storage"
 	"github.com/greenplum-db/gpbackup/toc"
 	"github.com/greenplum-db/gpbackup/utils"
 	"github.com/spf13/pflag"
@@ -34,12 +36,27 @@ var (
 	globalFPInfo        filepath.FilePathInfo
 	globalTOC           *toc.TOC
 	pluginConfig        *utils.PluginConfig
+	storageBackend      storage.StorageBackend
 	restoreStartTime    string
 	version             string
 	wasTerminated       bool
 	errorTablesMetadata map[string]Empty
 	errorTablesData     map[string]Empty
 	opts                *options.Options
+	dataValidation      *report.DataValidation
+	sessionGUCs         []utils.SessionGUC
+	// Set instead of connectionPool.Version when restoring with --target-postgres
+	postgresVersion PostgresVersion
+	// Tables whose statistics --stats-only did not restore, for the restore report
+	skippedStatistics []report.SkippedStatistics
+	// Maps quoted backed-up role names to quoted role names, or to "" for dropped roles
+	roleMapping map[string]string
+	// Set once a restore test database may exist, so that cleanup knows to drop it
+	restoreTestDatabaseCreated bool
+	// Set when metadata files are read from storageBackend instead of the master's backup directory
+	streamMetadata bool
+	// The most data streams per segment for which gpbackup_helper agents were started
+	numHelperDataStreams = 1
 	/*
 	 * Used for synchronizing DoCleanup.  In DoInit() we increment the group
 	 * and then wait for at least one DoCleanup to finish, either in DoTeardown
@@ -88,6 +105,14 @@ func SetPluginConfig(config *utils.PluginConfig) {
 	pluginConfig = config
 }
 
+func SetStorageBackend(backend storage.StorageBackend) {
+	storageBackend = backend
+}
+
+func SetStreamMetadata(stream bool) {
+	streamMetadata = stream
+}
+
 func SetTOC(toc *toc.TOC) {
 	globalTOC = toc
 }
@@ -0,0 +1,407 @@
+package restore
+
+/*
+ * This file contains structs and functions related to restoring a backup into
+ * a plain PostgreSQL database with --target-postgres.  PostgreSQL has no
+ * segments and does not understand Greenplum-specific syntax, so metadata
+ * statements are rewritten before they are executed and the data from every
+ * segment is streamed through the master with COPY FROM STDIN.
+ */
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/blang/semver"
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/options"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gopkg.in/cheggaaa/pb.v1"
+)
+
+var (
+	// Object types that only exist in Greenplum and are skipped entirely
+	greenplumOnlyObjectTypes = []string{"RESOURCE QUEUE", "RESOURCE GROUP", "PROTOCOL", "EXCHANGE PARTITION"}
+
+	// Storage options that are only valid for append-optimized tables
+	greenplumOnlyStorageOptions = []string{"appendonly", "appendoptimized", "orientation", "compresstype", "compresslevel", "blocksize", "checksum"}
+
+	externalTableRegex        = regexp.MustCompile(`^\s*CREATE (READABLE |WRITABLE )?(WEB )?EXTERNAL `)
+	subpartitionTemplateRegex = regexp.MustCompile(`(?s)\nALTER TABLE [^;]*?SET SUBPARTITION TEMPLATE[^;]*;`)
+	distributionPolicyRegex   = regexp.MustCompile(`\s*DISTRIBUTED (BY \([^)]*\)|RANDOMLY|REPLICATED)`)
+	storageOptionsRegex       = regexp.MustCompile(`\s*WITH \(([^)]*)\)`)
+	columnEncodingRegex       = regexp.MustCompile(` ENCODING \([^)]*\)`)
+	typeEncodingRegex         = regexp.MustCompile(`(?s)\nALTER TYPE [^;]*?SET DEFAULT ENCODING \([^)]*\);`)
+	bitmapIndexRegex          = regexp.MustCompile(`(?i) USING bitmap \(`)
+	roleAttributeRegex        = regexp.MustCompile(` (RESOURCE QUEUE [^\s;]+|RESOURCE GROUP [^\s;]+|(NO)?CREATEEXTTABLE \([^)]*\))`)
+	functionModifierRegex     = regexp.MustCompile(` (CONTAINS SQL|MODIFIES SQL DATA|NO SQL|READS SQL DATA|EXECUTE ON (ANY|MASTER|ALL SEGMENTS|INITPLAN))`)
+	greenplumGUCRegex         = regexp.MustCompile(`(?m)^[^\n]*\bSET "?gp_[^\n]*\n?`)
+)
+
+/*
+ * Setup functions
+ */
+
+/*
+ * dbconn.Connect cannot be used here because it expects a Greenplum version
+ * string, so the connections are opened the same way it opens them.  The
+ * PostgreSQL version is returned rather than set as connectionPool.Version, so
+ * that no Greenplum version check passes or fails against a PostgreSQL server.
+ */
+func ConnectToPostgres(connectionPool *dbconn.DBConn, numConns int) (PostgresVersion, error) {
+	connStr := fmt.Sprintf("postgres://%s@%s:%d/%s?sslmode=disable&statement_cache_capacity=0", connectionPool.User, connectionPool.Host, connectionPool.Port, connectionPool.DBName)
+	connectionPool.ConnPool = make([]*sqlx.DB, numConns)
+	for i := 0; i < numConns; i++ {
+		conn, err := connectionPool.Driver.Connect("pgx", connStr)
+		if err != nil {
+			return PostgresVersion{}, errors.Errorf("%v (%s:%d)", err, connectionPool.Host, connectionPool.Port)
+		}
+		conn.SetMaxOpenConns(1)
+		conn.SetMaxIdleConns(1)
+		connectionPool.ConnPool[i] = conn
+	}
+	connectionPool.Tx = make([]*sqlx.Tx, numConns)
+	connectionPool.NumConns = numConns
+	version, err := GetPostgresVersion(connectionPool)
+	if err != nil {
+		return PostgresVersion{}, errors.Wrap(err, "Failed to determine database version")
+	}
+	return version, nil
+}
+
+type PostgresVersion struct {
+	VersionString string
+	SemVer        semver.Version
+}
+
+func GetPostgresVersion(connectionPool *dbconn.DBConn) (PostgresVersion, error) {
+	result := struct {
+		VersionString string
+		VersionNum    uint64
+	}{}
+	query := "SELECT pg_catalog.version() AS versionstring, current_setting('server_version_num')::int AS versionnum"
+	err := connectionPool.Get(&result, query)
+	if err != nil {
+		return PostgresVersion{}, err
+	}
+	if strings.Contains(result.VersionString, "(Greenplum Database ") {
+		return PostgresVersion{}, errors.New("Cannot use --target-postgres to restore to a Greenplum database")
+	}
+
+	// Starting with PostgreSQL 10, server_version_num no longer has a separate patch component
+	version := semver.Version{Major: result.VersionNum / 10000, Minor: (result.VersionNum / 100) % 100, Patch: result.VersionNum % 100}
+	if version.Major >= 10 {
+		version.Minor = result.VersionNum % 10000
+		version.Patch = 0
+	}
+	return PostgresVersion{VersionString: result.VersionString, SemVer: version}, nil
+}
+
+func InitializePostgresConnectionPool(backupTimestamp string, restoreTimestamp string) {
+	setupQuery := fmt.Sprintf("SET application_name TO 'gprestore_%s_%s';", backupTimestamp, restoreTimestamp)
+	setupQuery += `
+SET search_path TO pg_catalog;
+SET statement_timeout = 0;
+SET lock_timeout = 0;
+SET check_function_bodies = false;
+SET client_min_messages = error;
+SET standard_conforming_strings = on;
+SET default_transaction_read_only = off;
+SET xmloption = content;
+`
+	for i := 0; i < connectionPool.NumConns; i++ {
+		connectionPool.MustExec(setupQuery, i)
+	}
+}
+
+/*
+ * Metadata conversion functions
+ */
+
+func ConvertStatementsForPostgres(statements []toc.StatementWithType) []toc.StatementWithType {
+	convertedStatements := make([]toc.StatementWithType, 0, len(statements))
+	for _, statement := range statements {
+		convertedStatement, ok := ConvertStatementForPostgres(statement)
+		if ok {
+			convertedStatements = append(convertedStatements, convertedStatement)
+		} else {
+			gplog.Verbose("Skipping Greenplum-specific %s %s", strings.ToLower(statement.ObjectType), statement.Name)
+		}
+	}
+	return convertedStatements
+}
+
+/*
+ * Returns the statement with any Greenplum-specific syntax removed, or false if
+ * the object it creates cannot exist in PostgreSQL at all.
+ */
+func ConvertStatementForPostgres(statement toc.StatementWithType) (toc.StatementWithType, bool) {
+	if utils.Exists(greenplumOnlyObjectTypes, statement.ObjectType) {
+		return statement, false
+	}
+	switch statement.ObjectType {
+	case "TABLE":
+		if externalTableRegex.MatchString(statement.Statement) {
+			return statement, false
+		}
+		statement.Statement = ConvertCreateTableStatementForPostgres(statement.Statement)
+	case "INDEX":
+		statement.Statement = bitmapIndexRegex.ReplaceAllString(statement.Statement, " USING btree (")
+	case "ROLE":
+		statement.Statement = roleAttributeRegex.ReplaceAllString(statement.Statement, "")
+	case "FUNCTION":
+		statement.Statement = ConvertFunctionStatementForPostgres(statement.Statement)
+	case "TYPE":
+		statement.Statement = typeEncodingRegex.ReplaceAllString(statement.Statement, "")
+	case "SESSION GUCS", "DATABASE GUC", "ROLE GUCS":
+		statement.Statement = greenplumGUCRegex.ReplaceAllString(statement.Statement, "")
+		if strings.TrimSpace(statement.Statement) == "" {
+			return statement, false
+		}
+	}
+	return statement, true
+}
+
+/*
+ * The distribution policy, append-optimized storage options, and partition
+ * definition all follow the closing parenthesis of the column list, so only
+ * that part of the statement is rewritten.  Partitioned tables are restored as
+ * regular tables that hold the data of all of their leaf partitions.
+ */
+func ConvertCreateTableStatementForPostgres(statement string) string {
+	statement = subpartitionTemplateRegex.ReplaceAllString(statement, "")
+	clauseStart := strings.Index(statement, "\n) ")
+	if clauseStart == -1 {
+		return statement
+	}
+	clauseStart += len("\n)")
+	clauseEnd := strings.Index(statement[clauseStart:], ";")
+	if clauseEnd == -1 {
+		return statement
+	}
+	clauseEnd += clauseStart
+
+	columns := columnEncodingRegex.ReplaceAllString(statement[:clauseStart], "")
+	clauses := statement[clauseStart:clauseEnd]
+	if partitionStart := strings.Index(clauses, " PARTITION BY "); partitionStart != -1 {
+		clauses = clauses[:partitionStart]
+	}
+	clauses = distributionPolicyRegex.ReplaceAllString(clauses, "")
+	clauses = storageOptionsRegex.ReplaceAllStringFunc(clauses, func(withClause string) string {
+		postgresOptions := make([]string, 0)
+		for _, option := range strings.Split(storageOptionsRegex.FindStringSubmatch(withClause)[1], ",") {
+			option = strings.TrimSpace(option)
+			optionName := strings.ToLower(strings.TrimSpace(strings.Split(option, "=")[0]))
+			if option != "" && !utils.Exists(greenplumOnlyStorageOptions, optionName) {
+				postgresOptions = append(postgresOptions, option)
+			}
+		}
+		if len(postgresOptions) == 0 {
+			return ""
+		}
+		return fmt.Sprintf(" WITH (%s)", strings.Join(postgresOptions, ", "))
+	})
+	return columns + strings.TrimRight(clauses, " \t\n") + statement[clauseEnd:]
+}
+
+// Function modifiers are printed after the function body, so the body itself is never changed
+func ConvertFunctionStatementForPostgres(statement string) string {
+	modifierStart := strings.LastIndex(statement, "\nLANGUAGE ")
+	if modifierStart == -1 {
+		return statement
+	}
+	return statement[:modifierStart] + functionModifierRegex.ReplaceAllString(statement[modifierStart:], "")
+}
+
+/*
+ * Data restore functions
+ */
+
+// Returns the schema and name of the table into which a data entry is restored
+func GetPostgresTargetTable(entry toc.MasterDataEntry, redirectSchema string) (string, string) {
+	schema := entry.Schema
+	if redirectSchema != "" {
+		schema = redirectSchema
+	}
+	name := entry.Name
+	if entry.PartitionRoot != "" {
+		name = entry.PartitionRoot
+	}
+	return schema, name
+}
+
+/*
+ * The data for each leaf partition is loaded into its partition root, and all
+ * entries for a given table are restored by the same connection so that it is
+ * only truncated once.
+ */
+func GetPostgresTargetTables(dataEntries []toc.MasterDataEntry, redirectSchema string) ([]string, map[string][]toc.MasterDataEntry) {
+	tableNames := make([]string, 0)
+	entriesByTable := make(map[string][]toc.MasterDataEntry)
+	for _, entry := range dataEntries {
+		tableName := utils.MakeFQN(GetPostgresTargetTable(entry, redirectSchema))
+		if _, ok := entriesByTable[tableName]; !ok {
+			tableNames = append(tableNames, tableName)
+		}
+		entriesByTable[tableName] = append(entriesByTable[tableName], entry)
+	}
+	return tableNames, entriesByTable
+}
+
+func restoreDataToPostgres(fpInfo filepath.FilePathInfo, dataEntries []toc.MasterDataEntry,
+	gucStatements []toc.StatementWithType, dataProgressBar utils.ProgressBar) int32 {
+	if len(dataEntries) == 0 {
+		gplog.Verbose("No data to restore for timestamp = %s", fpInfo.Timestamp)
+		return 0
+	}
+	contentIDs, err := filepath.ParseContentIDs(fpInfo.UserSpecifiedBackupDir, fpInfo.UserSpecifiedSegPrefix, fpInfo.Timestamp)
+	gplog.FatalOnError(err)
+	gplog.Verbose("Restoring data from %d segment(s) through the master", len(contentIDs))
+
+	tableNames, entriesByTable := GetPostgresTargetTables(dataEntries, opts.RedirectSchema)
+	tasks := make(chan string, len(tableNames))
+	var workerPool sync.WaitGroup
+	var numErrors int32
+	var mutex = &sync.Mutex{}
+
+	for i := 0; i < connectionPool.NumConns; i++ {
+		workerPool.Add(1)
+		go func(whichConn int) {
+			defer workerPool.Done()
+
+			setGUCsForConnection(gucStatements, whichConn)
+			for tableName := range tasks {
+				if wasTerminated {
+					dataProgressBar.(*pb.ProgressBar).NotPrint = true
+					return
+				}
+				var err error
+				if MustGetFlagBool(options.TRUNCATE_TABLE) {
+					err = TruncateTable(tableName, whichConn)
+				}
+				for _, entry := range entriesByTable[tableName] {
+					if err != nil {
+						break
+					}
+					err = restoreSingleTableDataToPostgres(fpInfo, entry, tableName, contentIDs, whichConn)
+					if err == nil {
+						gplog.Verbose("Restored data to table %s from file", tableName)
+						dataProgressBar.Increment()
+					}
+				}
+
+				if err != nil {
+					gplog.Error(err.Error())
+					atomic.AddInt32(&numErrors, 1)
+					if !MustGetFlagBool(options.ON_ERROR_CONTINUE) {
+						dataProgressBar.(*pb.ProgressBar).NotPrint = true
+						return
+					}
+					mutex.Lock()
+					errorTablesData[tableName] = Empty{}
+					mutex.Unlock()
+				}
+			}
+		}(i)
+	}
+	for _, tableName := range tableNames {
+		tasks <- tableName
+	}
+	close(tasks)
+	workerPool.Wait()
+
+	if numErrors > 0 {
+		fmt.Println("")
+		gplog.Error("Encountered %d error(s) during table data restore; see log file %s for a list of table errors.", numErrors, gplog.GetLogFilePath())
+	}
+	return numErrors
+}
+
+func restoreSingleTableDataToPostgres(fpInfo filepath.FilePathInfo, entry toc.MasterDataEntry, tableName string, contentIDs []int, whichConn int) error {
+	reader := NewSegmentTableDataReader(fpInfo.ForDataStream(entry.DataStream), entry.Oid, contentIDs)
+	defer reader.Close()
+	numRowsRestored, err := CopyTableInFromReader(connectionPool, tableName, entry.AttributeString, reader, whichConn)
+	if err != nil {
+		return err
+	}
+	return CheckRowsRestored(numRowsRestored, entry.RowsCopied, tableName)
+}
+
+func CopyTableInFromReader(connectionPool *dbconn.DBConn, tableName string, tableAttributes string, reader io.Reader, whichConn int) (int64, error) {
+	whichConn = connectionPool.ValidateConnNum(whichConn)
+	query := fmt.Sprintf("COPY %s%s FROM STDIN WITH CSV DELIMITER '%s';", tableName, tableAttributes, tableDelim)
+	gplog.Verbose(query)
+
+	db := connectionPool.ConnPool[whichConn].DB
+	conn, err := stdlib.AcquireConn(db)
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("Error loading data into table %s", tableName))
+	}
+	defer stdlib.ReleaseConn(db, conn)
+	result, err := conn.PgConn().CopyFrom(context.Background(), reader, query)
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("Error loading data into table %s", tableName))
+	}
+	return result.RowsAffected(), nil
+}
+
+/*
+ * Reads the data of a single table from the data file of each segment in turn,
+ * so that the data from all segments can be loaded with a single COPY.
+ */
+type SegmentTableDataReader struct {
+	fpInfo     filepath.FilePathInfo
+	oid        uint32
+	contentIDs []int
+	current    io.ReadCloser
+}
+
+func NewSegmentTableDataReader(fpInfo filepath.FilePathInfo, oid uint32, contentIDs []int) *SegmentTableDataReader {
+	return &SegmentTableDataReader{fpInfo: fpInfo, oid: oid, contentIDs: contentIDs}
+}
+
+func (r *SegmentTableDataReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.contentIDs) == 0 {
+				return 0, io.EOF
+			}
+			reader, err := OpenSegmentTableData(r.fpInfo, backupConfig, r.contentIDs[0], r.oid)
+			if err != nil {
+				return 0, err
+			}
+			r.current = reader
+			r.contentIDs = r.contentIDs[1:]
+		}
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			_ = r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *SegmentTableDataReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
@@ -0,0 +1,247 @@
+package restore_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	path "path/filepath"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/history"
+	"github.com/greenplum-db/gpbackup/restore"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("restore/postgres tests", func() {
+	Describe("GetPostgresVersion", func() {
+		It("parses a PostgreSQL 10+ version", func() {
+			versionRows := sqlmock.NewRows([]string{"versionstring", "versionnum"}).AddRow("PostgreSQL 14.5 on x86_64-pc-linux-gnu", 140005)
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(versionRows)
+
+			version, err := restore.GetPostgresVersion(connectionPool)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(version.VersionString).To(Equal("PostgreSQL 14.5 on x86_64-pc-linux-gnu"))
+			Expect(version.SemVer.String()).To(Equal("14.5.0"))
+		})
+		It("parses a PostgreSQL 9.x version", func() {
+			versionRows := sqlmock.NewRows([]string{"versionstring", "versionnum"}).AddRow("PostgreSQL 9.6.24 on x86_64-pc-linux-gnu", 90624)
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(versionRows)
+
+			version, err := restore.GetPostgresVersion(connectionPool)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(version.SemVer.String()).To(Equal("9.6.24"))
+		})
+		It("returns an error for a Greenplum database", func() {
+			versionRows := sqlmock.NewRows([]string{"versionstring", "versionnum"}).AddRow("PostgreSQL 9.4.24 (Greenplum Database 6.10.0 build commit:abc)", 90424)
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(versionRows)
+
+			_, err := restore.GetPostgresVersion(connectionPool)
+
+			Expect(err).To(MatchError("Cannot use --target-postgres to restore to a Greenplum database"))
+		})
+	})
+	Describe("ConvertCreateTableStatementForPostgres", func() {
+		DescribeTable("removes Greenplum-specific table clauses",
+			func(statement string, expected string) {
+				Expect(restore.ConvertCreateTableStatementForPostgres(statement)).To(Equal(expected))
+			},
+			Entry("distribution policy", "\n\nCREATE TABLE public.foo (\n\ti integer\n) DISTRIBUTED BY (i);\n",
+				"\n\nCREATE TABLE public.foo (\n\ti integer\n);\n"),
+			Entry("random distribution", "\n\nCREATE TABLE public.foo (\n\ti integer\n) DISTRIBUTED RANDOMLY;\n",
+				"\n\nCREATE TABLE public.foo (\n\ti integer\n);\n"),
+			Entry("replicated distribution", "\n\nCREATE TABLE public.foo (\n\ti integer\n) DISTRIBUTED REPLICATED;\n",
+				"\n\nCREATE TABLE public.foo (\n\ti integer\n);\n"),
+			Entry("append-optimized storage options", "\n\nCREATE TABLE public.foo (\n\ti integer\n) WITH (appendonly=true, orientation=column, compresstype=zlib) DISTRIBUTED BY (i);\n",
+				"\n\nCREATE TABLE public.foo (\n\ti integer\n);\n"),
+			Entry("storage options valid in PostgreSQL", "\n\nCREATE TABLE public.foo (\n\ti integer\n) WITH (appendonly=false, fillfactor=50) TABLESPACE test_tablespace DISTRIBUTED BY (i);\n",
+				"\n\nCREATE TABLE public.foo (\n\ti integer\n) WITH (fillfactor=50) TABLESPACE test_tablespace;\n"),
+			Entry("column encodings", "\n\nCREATE TABLE public.foo (\n\ti integer ENCODING (compresstype=zlib,blocksize=32768),\n\tj text NOT NULL ENCODING (compresstype=none)\n) WITH (appendonly=true, orientation=column) DISTRIBUTED RANDOMLY;\n",
+				"\n\nCREATE TABLE public.foo (\n\ti integer,\n\tj text NOT NULL\n);\n"),
+			Entry("partition definition and subpartition template", `
+
+CREATE TABLE public.part (
+	id integer,
+	region text
+) DISTRIBUTED BY (id) PARTITION BY LIST(region)
+          SUBPARTITION BY RANGE(id)
+          (
+          PARTITION usa VALUES('usa') WITH (tablename='part_1_prt_usa', appendonly=false )
+          );
+ALTER TABLE public.part
+SET SUBPARTITION TEMPLATE
+          (
+          START (1) END (10) EVERY (5) WITH (tablename='part')
+          );
+ALTER TABLE ONLY public.part ALTER COLUMN id SET STATISTICS 10;`, `
+
+CREATE TABLE public.part (
+	id integer,
+	region text
+);
+ALTER TABLE ONLY public.part ALTER COLUMN id SET STATISTICS 10;`),
+		)
+	})
+	Describe("ConvertStatementForPostgres", func() {
+		DescribeTable("removes Greenplum-specific syntax",
+			func(objectType string, statement string, expected string) {
+				converted, ok := restore.ConvertStatementForPostgres(toc.StatementWithType{ObjectType: objectType, Statement: statement})
+				Expect(ok).To(BeTrue())
+				Expect(converted.Statement).To(Equal(expected))
+			},
+			Entry("bitmap index", "INDEX", "\n\nCREATE INDEX foo_idx ON public.foo USING bitmap (i);", "\n\nCREATE INDEX foo_idx ON public.foo USING btree (i);"),
+			Entry("role resource queue and group", "ROLE",
+				"\n\nCREATE ROLE testrole;\nALTER ROLE testrole WITH NOSUPERUSER LOGIN RESOURCE QUEUE pg_default RESOURCE GROUP default_group CREATEEXTTABLE (protocol='gpfdist', type='readable');",
+				"\n\nCREATE ROLE testrole;\nALTER ROLE testrole WITH NOSUPERUSER LOGIN;"),
+			Entry("function data access and execute location", "FUNCTION",
+				"\n\nCREATE FUNCTION public.f() RETURNS integer AS\n$$SELECT 1 -- NO SQL$$\nLANGUAGE sql NO SQL IMMUTABLE EXECUTE ON MASTER;",
+				"\n\nCREATE FUNCTION public.f() RETURNS integer AS\n$$SELECT 1 -- NO SQL$$\nLANGUAGE sql IMMUTABLE;"),
+			Entry("type default encoding", "TYPE",
+				"\n\nCREATE TYPE public.t (\n\tINPUT = public.t_in,\n\tOUTPUT = public.t_out\n);\nALTER TYPE public.t\n\tSET DEFAULT ENCODING (compresstype=zlib);",
+				"\n\nCREATE TYPE public.t (\n\tINPUT = public.t_in,\n\tOUTPUT = public.t_out\n);"),
+			Entry("role GUCs", "ROLE GUCS",
+				"\n\nALTER ROLE testrole SET search_path TO public;\nALTER ROLE testrole SET gp_default_storage_options TO 'appendonly=true';\n",
+				"\n\nALTER ROLE testrole SET search_path TO public;\n"),
+			Entry("regular table", "TABLE", "\n\nCREATE TABLE public.foo (\n\ti integer\n) DISTRIBUTED BY (i);", "\n\nCREATE TABLE public.foo (\n\ti integer\n);"),
+		)
+		DescribeTable("skips objects that cannot exist in PostgreSQL",
+			func(objectType string, statement string) {
+				_, ok := restore.ConvertStatementForPostgres(toc.StatementWithType{ObjectType: objectType, Statement: statement})
+				Expect(ok).To(BeFalse())
+			},
+			Entry("resource queue", "RESOURCE QUEUE", "\n\nCREATE RESOURCE QUEUE test_queue WITH (ACTIVE_STATEMENTS=5);"),
+			Entry("resource group", "RESOURCE GROUP", "\n\nCREATE RESOURCE GROUP test_group WITH (CPU_RATE_LIMIT=10, MEMORY_LIMIT=10);"),
+			Entry("protocol", "PROTOCOL", "\n\nCREATE TRUSTED PROTOCOL s3 (readfunc = public.read_from_s3);"),
+			Entry("external table", "TABLE", "\n\nCREATE READABLE EXTERNAL WEB TABLE public.ext (\n\ti integer\n) EXECUTE 'echo 1' ON ALL\nFORMAT 'TEXT';"),
+			Entry("database GUC with only Greenplum GUCs", "DATABASE GUC", "\n\nALTER DATABASE testdb SET gp_autostats_mode TO 'none';"),
+		)
+	})
+	Describe("GetPostgresTargetTable", func() {
+		It("returns the partition root of a leaf partition in the redirect schema", func() {
+			entry := toc.MasterDataEntry{Schema: "public", Name: "part_1_prt_1", Oid: 2, PartitionRoot: "part"}
+
+			schema, name := restore.GetPostgresTargetTable(entry, "newschema")
+
+			Expect(schema).To(Equal("newschema"))
+			Expect(name).To(Equal("part"))
+		})
+		It("returns the table's own schema and name", func() {
+			entry := toc.MasterDataEntry{Schema: "public", Name: "foo", Oid: 1}
+
+			schema, name := restore.GetPostgresTargetTable(entry, "")
+
+			Expect(schema).To(Equal("public"))
+			Expect(name).To(Equal("foo"))
+		})
+	})
+	Describe("GetPostgresTargetTables", func() {
+		It("groups leaf partitions under their partition root", func() {
+			entries := []toc.MasterDataEntry{
+				{Schema: "public", Name: "foo", Oid: 1},
+				{Schema: "public", Name: "part_1_prt_1", Oid: 2, PartitionRoot: "part"},
+				{Schema: "public", Name: "part_1_prt_2", Oid: 3, PartitionRoot: "part"},
+			}
+
+			tableNames, entriesByTable := restore.GetPostgresTargetTables(entries, "")
+
+			Expect(tableNames).To(Equal([]string{"public.foo", "public.part"}))
+			Expect(entriesByTable["public.foo"]).To(Equal(entries[:1]))
+			Expect(entriesByTable["public.part"]).To(Equal(entries[1:]))
+		})
+		It("uses the redirect schema", func() {
+			entries := []toc.MasterDataEntry{{Schema: "public", Name: "foo", Oid: 1}}
+
+			tableNames, _ := restore.GetPostgresTargetTables(entries, "newschema")
+
+			Expect(tableNames).To(Equal([]string{"newschema.foo"}))
+		})
+	})
+	Describe("SegmentTableDataReader", func() {
+		var (
+			backupDir string
+			fpInfo    filepath.FilePathInfo
+		)
+		timestamp := "20170101010101"
+		segmentDir := func(contentID int) string {
+			return path.Join(backupDir, fmt.Sprintf("gpseg%d", contentID), "backups", "20170101", timestamp)
+		}
+		BeforeEach(func() {
+			var err error
+			backupDir, err = ioutil.TempDir("", "target_postgres")
+			Expect(err).ToNot(HaveOccurred())
+			for _, contentID := range []int{0, 1} {
+				Expect(os.MkdirAll(segmentDir(contentID), 0755)).To(Succeed())
+			}
+			fpInfo = filepath.NewFilePathInfo(&cluster.Cluster{}, backupDir, timestamp, "gpseg")
+			utils.SetPipeThroughProgram(utils.PipeThroughProgram{Name: "cat", OutputCommand: "cat -", InputCommand: "cat -", Extension: ""})
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(backupDir)
+		})
+		It("reads a table's data from each segment's file in turn", func() {
+			restore.SetBackupConfig(&history.BackupConfig{})
+			Expect(ioutil.WriteFile(path.Join(segmentDir(0), "gpbackup_0_20170101010101_3456"), []byte("1,a\n2,b\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(path.Join(segmentDir(1), "gpbackup_1_20170101010101_3456"), []byte("3,c\n"), 0644)).To(Succeed())
+
+			reader := restore.NewSegmentTableDataReader(fpInfo, 3456, []int{0, 1})
+			contents, err := ioutil.ReadAll(reader)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("1,a\n2,b\n3,c\n"))
+			Expect(reader.Close()).To(Succeed())
+		})
+		It("reads a table's data from each segment's single data file", func() {
+			restore.SetBackupConfig(&history.BackupConfig{SingleDataFile: true})
+			for contentID, data := range []string{"1,a\n2,b\n3,c\n", "4,d\n5,e\n"} {
+				Expect(ioutil.WriteFile(path.Join(segmentDir(contentID), fmt.Sprintf("gpbackup_%d_20170101010101", contentID)), []byte(data), 0644)).To(Succeed())
+			}
+			Expect(ioutil.WriteFile(path.Join(segmentDir(0), "gpbackup_0_20170101010101_toc.yaml"), []byte("dataentries:\n  3456:\n    startbyte: 4\n    endbyte: 12\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(path.Join(segmentDir(1), "gpbackup_1_20170101010101_toc.yaml"), []byte("dataentries:\n  3456:\n    startbyte: 0\n    endbyte: 4\n"), 0644)).To(Succeed())
+
+			reader := restore.NewSegmentTableDataReader(fpInfo, 3456, []int{0, 1})
+			contents, err := ioutil.ReadAll(reader)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("2,b\n3,c\n4,d\n"))
+		})
+		It("decompresses only a table's own frames from a compressed single data file", func() {
+			restore.SetBackupConfig(&history.BackupConfig{SingleDataFile: true, Compressed: true})
+			// The bytes before the table's frame are not gzip data, so decompressing from the start of the file would fail
+			dataFile := bytes.NewBufferString("not gzip data")
+			frameStart := dataFile.Len()
+			gzipWriter := gzip.NewWriter(dataFile)
+			_, _ = gzipWriter.Write([]byte("2,b\n3,c\n"))
+			_ = gzipWriter.Close()
+			frameEnd := dataFile.Len()
+			Expect(ioutil.WriteFile(path.Join(segmentDir(0), "gpbackup_0_20170101010101.gz"), dataFile.Bytes(), 0644)).To(Succeed())
+			segmentTOC := fmt.Sprintf("dataentries:\n  3456:\n    startbyte: 4\n    endbyte: 12\n    compressedstartbyte: %d\n    compressedendbyte: %d\n", frameStart, frameEnd)
+			Expect(ioutil.WriteFile(path.Join(segmentDir(0), "gpbackup_0_20170101010101_toc.yaml"), []byte(segmentTOC), 0644)).To(Succeed())
+
+			reader := restore.NewSegmentTableDataReader(fpInfo, 3456, []int{0})
+			contents, err := ioutil.ReadAll(reader)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("2,b\n3,c\n"))
+		})
+		It("returns an error if a segment has no data for the table", func() {
+			restore.SetBackupConfig(&history.BackupConfig{SingleDataFile: true})
+			Expect(ioutil.WriteFile(path.Join(segmentDir(0), "gpbackup_0_20170101010101"), []byte("1,a\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(path.Join(segmentDir(0), "gpbackup_0_20170101010101_toc.yaml"), []byte("dataentries:\n  1234:\n    startbyte: 0\n    endbyte: 4\n"), 0644)).To(Succeed())
+
+			reader := restore.NewSegmentTableDataReader(fpInfo, 3456, []int{0})
+			_, err := ioutil.ReadAll(reader)
+
+			Expect(err).To(MatchError("No data for table with oid 3456 in segment TOC for segment 0"))
+		})
+	})
+})
@@ -50,7 +50,14 @@ func VerifyBackupFileCountOnSegments(fileCount int) {
 	}
 }
 
+/*
+ * Streamed metadata files are not on the master, and any that are missing are
+ * reported when they are read.
+ */
 func VerifyMetadataFilePaths(withStats bool) {
+	if streamMetadata {
+		return
+	}
 	filetypes := []string{"config", "table of contents", "metadata"}
 	missing := false
 	for _, filetype := range filetypes {
@@ -28,6 +28,10 @@ func DoInit(cmd *cobra.Command) {
 	CleanupGroup.Add(1)
 	gplog.InitializeLogging("gprestore", "")
 	SetCmdFlags(cmd.Flags())
+	// The config file is applied before cobra checks for required flags, which it may set
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		return options.ApplyConfigFile(cmd.Flags())
+	}
 	_ = cmd.MarkFlagRequired(options.TIMESTAMP)
 	utils.InitializeSignalHandler(DoCleanup, "restore process", &wasTerminated)
 }
@@ -45,6 +49,15 @@ func DoValidation(cmd *cobra.Command) {
 	if !filepath.IsValidTimestamp(MustGetFlagString(options.TIMESTAMP)) {
 		gplog.Fatal(errors.Errorf("Timestamp %s is invalid.  Timestamps must be in the format YYYYMMDDHHMMSS.", MustGetFlagString(options.TIMESTAMP)), "")
 	}
+	rateLimit := utils.RateLimit{MaxRate: MustGetFlagInt(options.MAX_RATE), MaxSegmentRate: MustGetFlagInt(options.MAX_SEGMENT_RATE)}
+	gplog.FatalOnError(rateLimit.Validate())
+	gplog.FatalOnError(ValidateGlobalConflictAction(MustGetFlagString(options.ON_GLOBAL_CONFLICT)))
+	if MustGetFlagInt(options.STATS_TOLERANCE) < 0 {
+		gplog.Fatal(errors.Errorf("--stats-tolerance must be a non-negative percentage"), "")
+	}
+	validObjectTypes := append(append([]string{}, toc.PredataObjectTypes...), toc.PostdataObjectTypes...)
+	gplog.FatalOnError(toc.ValidateObjectTypes(MustGetFlagStringArray(options.INCLUDE_OBJECT_TYPE), validObjectTypes))
+	gplog.FatalOnError(toc.ValidateObjectTypes(MustGetFlagStringArray(options.EXCLUDE_OBJECT_TYPE), validObjectTypes))
 }
 
 // This function handles setup that must be done after parsing flags.
@@ -52,12 +65,30 @@ func DoSetup() {
 	SetLoggerVerbosity()
 	gplog.Verbose("Restore Command: %s", os.Args)
 
-	utils.CheckGpexpandRunning(utils.RestorePreventedByGpexpandMessage)
+	if !MustGetFlagBool(options.TARGET_POSTGRES) {
+		utils.CheckGpexpandRunning(utils.RestorePreventedByGpexpandMessage)
+	}
 	restoreStartTime = history.CurrentTimestamp()
 	backupTimestamp := MustGetFlagString(options.TIMESTAMP)
 	gplog.Info("Restore Key = %s", backupTimestamp)
+	if MustGetFlagBool(options.RESTORE_TEST) {
+		restoreTestDatabase := GetRestoreTestDatabaseName(backupTimestamp, restoreStartTime)
+		gplog.Info("Restore test database = %s", restoreTestDatabase)
+		_ = cmdFlags.Set(options.REDIRECT_DB, restoreTestDatabase)
+		_ = cmdFlags.Set(options.CREATE_DB, "true")
+		dataValidation = &report.DataValidation{RestoreTestDatabase: restoreTestDatabase}
+	} else if MustGetFlagBool(options.VERIFY_DATA) {
+		dataValidation = &report.DataValidation{}
+	}
+	if MustGetFlagString(options.SESSION_GUC_FILE) != "" {
+		var err error
+		sessionGUCs, err = utils.ReadSessionGUCFile(MustGetFlagString(options.SESSION_GUC_FILE))
+		gplog.FatalOnError(err)
+	}
 
 	CreateConnectionPool("postgres")
+	// Settings such as role also apply to the queries and CREATE DATABASE run before the restore database is connected to
+	setSessionGUCsFromFile()
 
 	var segPrefix string
 	var err error
@@ -70,8 +101,17 @@ func DoSetup() {
 	err = opts.QuoteExcludeRelations(connectionPool)
 	gplog.FatalOnError(err)
 
-	segConfig := cluster.MustGetSegmentConfiguration(connectionPool)
-	globalCluster = cluster.NewCluster(segConfig)
+	if MustGetFlagString(options.ROLE_MAPPING) != "" {
+		InitializeRoleMapping(MustGetFlagString(options.ROLE_MAPPING))
+	}
+
+	if MustGetFlagBool(options.TARGET_POSTGRES) {
+		// There are no segments, so all backup files are read directly from the backup directory
+		globalCluster = cluster.NewCluster([]cluster.SegConfig{})
+	} else {
+		segConfig := cluster.MustGetSegmentConfiguration(connectionPool)
+		globalCluster = cluster.NewCluster(segConfig)
+	}
 	segPrefix, err = filepath.ParseSegPrefix(MustGetFlagString(options.BACKUP_DIR), backupTimestamp)
 	gplog.FatalOnError(err)
 	globalFPInfo = filepath.NewFilePathInfo(globalCluster, MustGetFlagString(options.BACKUP_DIR), backupTimestamp, segPrefix)
@@ -85,7 +125,11 @@ func DoSetup() {
 
 	gplog.Info("gpbackup version = %s", backupConfig.BackupVersion)
 	gplog.Info("gprestore version = %s", GetVersion())
-	gplog.Info("Greenplum Database Version = %s", connectionPool.Version.VersionString)
+	if MustGetFlagBool(options.TARGET_POSTGRES) {
+		gplog.Info("PostgreSQL Version = %s", postgresVersion.VersionString)
+	} else {
+		gplog.Info("Greenplum Database Version = %s", connectionPool.Version.VersionString)
+	}
 
 	BackupConfigurationValidation()
 	metadataFilename := globalFPInfo.GetMetadataFilePath()
@@ -96,9 +140,15 @@ func DoSetup() {
 	if MustGetFlagString(options.REDIRECT_DB) != "" {
 		unquotedRestoreDatabase = MustGetFlagString(options.REDIRECT_DB)
 	}
+	if MustGetFlagBool(options.GLOBALS_ONLY) {
+		restoreGlobalsOnly(metadataFilename, unquotedRestoreDatabase)
+		return
+	}
 	ValidateDatabaseExistence(unquotedRestoreDatabase, MustGetFlagBool(options.CREATE_DB), backupConfig.IncludeTableFiltered || backupConfig.DataOnly)
+	// The database was just verified not to exist, so anything by that name from here on was created by this restore
+	restoreTestDatabaseCreated = MustGetFlagBool(options.RESTORE_TEST)
 	if MustGetFlagBool(options.WITH_GLOBALS) {
-		restoreGlobal(metadataFilename)
+		restoreGlobal(metadataFilename, true)
 	} else if MustGetFlagBool(options.CREATE_DB) {
 		createDatabase(metadataFilename)
 	}
@@ -112,8 +162,10 @@ func DoSetup() {
 	 * should not error out for validation reasons once the restore database exists.
 	 * For on-error-continue, we will see the same errors later when we try to run SQL,
 	 * but since they will not stop the restore, it is not necessary to log them twice.
+	 * If tables are filtered out by object type, no table definitions or data are restored.
 	 */
-	if !MustGetFlagBool(options.CREATE_DB) && !MustGetFlagBool(options.ON_ERROR_CONTINUE) && !MustGetFlagBool(options.INCREMENTAL) {
+	if !MustGetFlagBool(options.CREATE_DB) && !MustGetFlagBool(options.ON_ERROR_CONTINUE) && !MustGetFlagBool(options.INCREMENTAL) &&
+		!MustGetFlagBool(options.STATS_ONLY) && IsObjectTypeRestored("TABLE") {
 		relationsToRestore := GenerateRestoreRelationList(*opts)
 		if opts.RedirectSchema != "" {
 			fqns, err := options.SeparateSchemaAndTable(relationsToRestore)
@@ -133,6 +185,13 @@ func DoSetup() {
 }
 
 func DoRestore() {
+	if MustGetFlagBool(options.GLOBALS_ONLY) {
+		return
+	}
+	if MustGetFlagBool(options.STATS_ONLY) {
+		restoreStatistics()
+		return
+	}
 	var filteredDataEntries map[string][]toc.MasterDataEntry
 	metadataFilename := globalFPInfo.GetMetadataFilePath()
 	isDataOnly := backupConfig.DataOnly || MustGetFlagBool(options.DATA_ONLY)
@@ -152,10 +211,16 @@ func DoRestore() {
 		restoreSequenceValues(metadataFilename)
 	}
 
+	if !isMetadataOnly && !IsObjectTypeRestored("TABLE") {
+		gplog.Info("Skipping data restore, as tables are not among the object types being restored")
+		isMetadataOnly = true
+	}
+
 	totalTablesRestored := 0
 	if !isMetadataOnly {
-		if MustGetFlagString(options.PLUGIN_CONFIG) == "" {
-			backupFileCount := 2 // 1 for the actual data file, 1 for the segment TOC file
+		if MustGetFlagString(options.PLUGIN_CONFIG) == "" && !MustGetFlagBool(options.TARGET_POSTGRES) {
+			// 1 for the actual data file, 1 for the segment TOC file, for each data stream
+			backupFileCount := 2 * backupConfig.NumDataStreams()
 			if !backupConfig.SingleDataFile {
 				backupFileCount = len(globalTOC.DataEntries)
 			}
@@ -173,6 +238,10 @@ func DoRestore() {
 	} else if MustGetFlagBool(options.RUN_ANALYZE) && totalTablesRestored > 0 {
 		runAnalyze(filteredDataEntries)
 	}
+
+	if MustGetFlagBool(options.RESTORE_TEST) || MustGetFlagBool(options.VERIFY_DATA) {
+		ValidateRestoredData(filteredDataEntries)
+	}
 }
 
 func createDatabase(metadataFilename string) {
@@ -194,8 +263,11 @@ func createDatabase(metadataFilename string) {
 	}
 }
 
-func restoreGlobal(metadataFilename string) {
-	objectTypes := []string{"SESSION GUCS", "DATABASE GUC", "DATABASE METADATA", "RESOURCE QUEUE", "RESOURCE GROUP", "ROLE", "ROLE GUCS", "ROLE GRANT", "TABLESPACE"}
+func restoreGlobal(metadataFilename string, withDatabaseMetadata bool) {
+	objectTypes := []string{"SESSION GUCS", "RESOURCE QUEUE", "RESOURCE GROUP", "ROLE", "ROLE GUCS", "ROLE GRANT", "TABLESPACE"}
+	if withDatabaseMetadata {
+		objectTypes = append(objectTypes, "DATABASE GUC", "DATABASE METADATA")
+	}
 	if MustGetFlagBool(options.CREATE_DB) {
 		objectTypes = append(objectTypes, "DATABASE")
 	}
@@ -206,6 +278,7 @@ func restoreGlobal(metadataFilename string) {
 		statements = toc.SubstituteRedirectDatabaseInStatements(statements, backupConfig.DatabaseName, quotedDBName)
 	}
 	statements = toc.RemoveActiveRole(connectionPool.User, statements)
+	statements = resolveGlobalConflicts(statements)
 	numErrors := ExecuteRestoreMetadataStatements(statements, "Global objects", nil, utils.PB_VERBOSE, false)
 
 	if numErrors > 0 {
@@ -215,6 +288,23 @@ func restoreGlobal(metadataFilename string) {
 	}
 }
 
+/*
+ * Global metadata is restored on its own without restoring the database it was
+ * backed up with, so the database only needs to exist if its configuration
+ * parameters and privileges are to be restored; with --create-db, the
+ * database is created with them.
+ */
+func restoreGlobalsOnly(metadataFilename string, unquotedRestoreDatabase string) {
+	withDatabaseMetadata := true
+	if MustGetFlagBool(options.CREATE_DB) {
+		ValidateDatabaseExistence(unquotedRestoreDatabase, true, false)
+	} else if !DatabaseExists(unquotedRestoreDatabase) {
+		gplog.Info(`Database "%s" does not exist, so its configuration parameters and privileges will not be restored`, unquotedRestoreDatabase)
+		withDatabaseMetadata = false
+	}
+	restoreGlobal(metadataFilename, withDatabaseMetadata)
+}
+
 func verifyIncrementalState() {
 	lastRestorePlanEntry := backupConfig.RestorePlan[len(backupConfig.RestorePlan)-1]
 	tableFQNsToRestore := lastRestorePlanEntry.TableFQNs
@@ -281,11 +371,13 @@ func restorePredata(metadataFilename string) {
 	gplog.Info("Restoring pre-data metadata")
 	// if not incremental restore - assume database is empty and just filter based on user input
 	filters := NewFilters(opts.IncludedSchemas, opts.ExcludedSchemas, opts.IncludedRelations, opts.ExcludedRelations)
-	var schemaStatements []toc.StatementWithType
-	if opts.RedirectSchema == "" {
+	var schemaStatements, statements []toc.StatementWithType
+	if opts.RedirectSchema == "" && IsObjectTypeRestored("SCHEMA") {
 		schemaStatements = GetRestoreMetadataStatementsFiltered("predata", metadataFilename, []string{"SCHEMA"}, []string{}, filters)
 	}
-	statements := GetRestoreMetadataStatementsFiltered("predata", metadataFilename, []string{}, []string{"SCHEMA"}, filters)
+	if includeObjectTypes, excludeObjectTypes, ok := GetObjectTypeFilters("SCHEMA"); ok {
+		statements = GetRestoreMetadataStatementsFiltered("predata", metadataFilename, includeObjectTypes, excludeObjectTypes, filters)
+	}
 
 	editStatementsRedirectSchema(statements, opts.RedirectSchema)
 	progressBar := utils.NewProgressBar(len(schemaStatements)+len(statements), "Pre-data objects restored: ", utils.PB_VERBOSE)
@@ -345,20 +437,7 @@ func restoreSequenceValues(metadataFilename string) {
 }
 
 func editStatementsRedirectSchema(statements []toc.StatementWithType, redirectSchema string) {
-	if redirectSchema == "" {
-		return
-	}
-
-	for i, statement := range statements {
-		oldSchema := fmt.Sprintf("%s.", statement.Schema)
-		newSchema := fmt.Sprintf("%s.", redirectSchema)
-		statements[i].Schema = redirectSchema
-		statements[i].Statement = strings.Replace(statement.Statement, oldSchema, newSchema, 1)
-		// only postdata will have a reference object
-		if statement.ReferenceObject != "" {
-			statements[i].ReferenceObject = strings.Replace(statement.ReferenceObject, oldSchema, newSchema, 1)
-		}
-	}
+	toc.SubstituteRedirectSchemaInStatements(statements, redirectSchema)
 }
 
 func restoreData() (int, map[string][]toc.MasterDataEntry) {
@@ -380,13 +459,24 @@ func restoreData() (int, map[string][]toc.MasterDataEntry) {
 	filteredDataEntries := make(map[string][]toc.MasterDataEntry)
 	for _, entry := range restorePlanEntries {
 		fpInfo := GetBackupFPInfoForTimestamp(entry.Timestamp)
-		tocfile := toc.NewTOC(fpInfo.GetTOCFilePath())
+		tocfile := ReadTOC(fpInfo.GetTOCFilePath())
 		restorePlanTableFQNs := entry.TableFQNs
 		filteredDataEntriesForTimestamp := tocfile.GetDataEntriesMatching(opts.IncludedSchemas,
 			opts.ExcludedSchemas, opts.IncludedRelations, opts.ExcludedRelations, restorePlanTableFQNs)
 		filteredDataEntries[entry.Timestamp] = filteredDataEntriesForTimestamp
 		totalTables += len(filteredDataEntriesForTimestamp)
 	}
+	rateLimit := utils.RateLimit{MaxRate: MustGetFlagInt(options.MAX_RATE), MaxSegmentRate: MustGetFlagInt(options.MAX_SEGMENT_RATE)}
+	if rateLimit.IsLimited() && !MustGetFlagBool(options.TARGET_POSTGRES) {
+		// With a single data file, each segment's data is read by one gpbackup_helper agent per data stream
+		streamsPerSegment := MustGetFlagInt(options.JOBS)
+		if backupConfig.SingleDataFile {
+			streamsPerSegment = backupConfig.NumDataStreams()
+		}
+		utils.StartRateController(globalCluster, globalFPInfo, globalFPInfo.GetRestoreRateLimitFilePath(restoreStartTime), rateLimit, streamsPerSegment)
+		defer utils.StopRateController()
+	}
+
 	dataProgressBar := utils.NewProgressBar(totalTables, "Tables restored: ", utils.PB_INFO)
 	dataProgressBar.Start()
 
@@ -417,7 +507,8 @@ func restorePostdata(metadataFilename string) {
 
 	filters := NewFilters(opts.IncludedSchemas, opts.ExcludedSchemas, opts.IncludedRelations, opts.ExcludedRelations)
 
-	statements := GetRestoreMetadataStatementsFiltered("postdata", metadataFilename, []string{}, []string{}, filters)
+	includeObjectTypes, excludeObjectTypes, _ := GetObjectTypeFilters()
+	statements := GetRestoreMetadataStatementsFiltered("postdata", metadataFilename, includeObjectTypes, excludeObjectTypes, filters)
 	editStatementsRedirectSchema(statements, opts.RedirectSchema)
 	firstBatch, secondBatch, thirdBatch := BatchPostdataStatements(statements)
 	progressBar := utils.NewProgressBar(len(statements), "Post-data objects restored: ", utils.PB_VERBOSE)
@@ -448,6 +539,9 @@ func restoreStatistics() {
 
 	statements := GetRestoreMetadataStatementsFiltered("statistics", statisticsFilename, []string{}, []string{}, filters)
 	editStatementsRedirectSchema(statements, opts.RedirectSchema)
+	if MustGetFlagBool(options.STATS_ONLY) {
+		statements, skippedStatistics = FilterStatisticsForExistingTables(statements, cmdFlags.Changed(options.STATS_TOLERANCE), MustGetFlagInt(options.STATS_TOLERANCE))
+	}
 	numErrors := ExecuteRestoreMetadataStatements(statements, "Table statistics", nil, utils.PB_VERBOSE, false)
 
 	if numErrors > 0 {
@@ -464,21 +558,36 @@ func runAnalyze(filteredDataEntries map[string][]toc.MasterDataEntry) {
 	gplog.Info("Running ANALYZE on restored tables")
 
 	var analyzeStatements []toc.StatementWithType
-	for _, dataEntries := range filteredDataEntries {
-		for _, entry := range dataEntries {
-			tableSchema := entry.Schema
-			if opts.RedirectSchema != "" {
-				tableSchema = opts.RedirectSchema
+	if MustGetFlagBool(options.TARGET_POSTGRES) {
+		// Leaf partition data was restored to the partition roots, so those are the tables to analyze
+		for _, dataEntries := range filteredDataEntries {
+			tableNames, entriesByTable := GetPostgresTargetTables(dataEntries, opts.RedirectSchema)
+			for _, tableName := range tableNames {
+				tableSchema, name := GetPostgresTargetTable(entriesByTable[tableName][0], opts.RedirectSchema)
+				analyzeStatements = append(analyzeStatements, toc.StatementWithType{
+					Schema:    tableSchema,
+					Name:      name,
+					Statement: fmt.Sprintf("ANALYZE %s", tableName),
+				})
 			}
-			tableFQN := utils.MakeFQN(tableSchema, entry.Name)
-			analyzeCommand := fmt.Sprintf("ANALYZE %s", tableFQN)
+		}
+	} else {
+		for _, dataEntries := range filteredDataEntries {
+			for _, entry := range dataEntries {
+				tableSchema := entry.Schema
+				if opts.RedirectSchema != "" {
+					tableSchema = opts.RedirectSchema
+				}
+				tableFQN := utils.MakeFQN(tableSchema, entry.Name)
+				analyzeCommand := fmt.Sprintf("ANALYZE %s", tableFQN)
 
-			newAnalyzeStatement := toc.StatementWithType{
-				Schema:    tableSchema,
-				Name:      entry.Name,
-				Statement: analyzeCommand,
+				newAnalyzeStatement := toc.StatementWithType{
+					Schema:    tableSchema,
+					Name:      entry.Name,
+					Statement: analyzeCommand,
+				}
+				analyzeStatements = append(analyzeStatements, newAnalyzeStatement)
 			}
-			analyzeStatements = append(analyzeStatements, newAnalyzeStatement)
 		}
 	}
 
@@ -578,7 +687,11 @@ func DoTeardown() {
 			return
 		}
 		reportFilename := globalFPInfo.GetRestoreReportFilePath(restoreStartTime)
-		report.WriteRestoreReportFile(reportFilename, globalFPInfo.Timestamp, restoreStartTime, connectionPool, version, errMsg)
+		var pluginRetries []utils.PluginRetry
+		if pluginConfig != nil {
+			pluginRetries = pluginConfig.Retries()
+		}
+		report.WriteRestoreReportFile(reportFilename, globalFPInfo.Timestamp, restoreStartTime, connectionPool, version, errMsg, dataValidation, pluginRetries, skippedStatistics, postgresVersion.VersionString)
 		report.EmailReport(globalCluster, globalFPInfo.Timestamp, reportFilename, "gprestore", !restoreFailed)
 		if pluginConfig != nil {
 			pluginConfig.CleanupPluginForRestore(globalCluster, globalFPInfo)
@@ -638,15 +751,19 @@ func DoCleanup(restoreFailed bool) {
 	}()
 
 	gplog.Verbose("Beginning cleanup")
-	if backupConfig != nil && backupConfig.SingleDataFile {
+	utils.StopRateController()
+	if backupConfig != nil && backupConfig.SingleDataFile && !MustGetFlagBool(options.TARGET_POSTGRES) {
 		fpInfoList := GetBackupFPInfoListFromRestorePlan()
 		for _, fpInfo := range fpInfoList {
-			if restoreFailed {
-				utils.CleanUpSegmentHelperProcesses(globalCluster, fpInfo, "restore")
-			}
-			utils.CleanUpHelperFilesOnAllHosts(globalCluster, fpInfo)
-			if wasTerminated { // These should all end on their own in a successful restore
-				utils.TerminateHangingCopySessions(connectionPool, fpInfo, "gprestore")
+			for stream := 0; stream < numHelperDataStreams; stream++ {
+				streamFPInfo := fpInfo.ForDataStream(stream)
+				if restoreFailed {
+					utils.CleanUpSegmentHelperProcesses(globalCluster, streamFPInfo, "restore")
+				}
+				utils.CleanUpHelperFilesOnAllHosts(globalCluster, streamFPInfo)
+				if wasTerminated { // These should all end on their own in a successful restore
+					utils.TerminateHangingCopySessions(connectionPool, streamFPInfo, "gprestore")
+				}
 			}
 		}
 	}
@@ -654,4 +771,7 @@ func DoCleanup(restoreFailed bool) {
 	if connectionPool != nil {
 		connectionPool.Close()
 	}
+	if restoreTestDatabaseCreated {
+		DropRestoreTestDatabase()
+	}
 }
@@ -54,5 +54,22 @@ var _ = Describe("restore internal tests", func() {
 			}
 			Expect(statements).To(Equal(expectedStatements))
 		})
+		It("changes the schema of both the statistics object and its table in extended statistics", func() {
+			statements := []toc.StatementWithType{
+				{
+					Schema: "foo", Name: "bar", ObjectType: "EXTENDED STATISTICS",
+					Statement: "\n\nCREATE STATISTICS foo.stats (dependencies) ON i, j FROM foo.bar;\n",
+				},
+				{
+					Schema: "foo", Name: "bar", ObjectType: "STATISTICS",
+					Statement: "\n\nUPDATE pg_class\nSET\n\trelpages = 1::int,\n\treltuples = 1.000000::real\nWHERE oid = (SELECT segrelid FROM pg_appendonly WHERE relid = 'foo.bar'::regclass::oid);\n",
+				},
+			}
+
+			editStatementsRedirectSchema(statements, "foo2")
+
+			Expect(statements[0].Statement).To(Equal("\n\nCREATE STATISTICS foo2.stats (dependencies) ON i, j FROM foo2.bar;\n"))
+			Expect(statements[1].Statement).To(Equal("\n\nUPDATE pg_class\nSET\n\trelpages = 1::int,\n\treltuples = 1.000000::real\nWHERE oid = (SELECT segrelid FROM pg_appendonly WHERE relid = 'foo2.bar'::regclass::oid);\n"))
+		})
 	})
 })
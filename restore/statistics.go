@@ -0,0 +1,159 @@
+package restore
+
+/*
+ * This file contains functions related to restoring query planner statistics
+ * onto existing tables with --stats-only.
+ */
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/report"
+	"github.com/greenplum-db/gpbackup/toc"
+	"github.com/greenplum-db/gpbackup/utils"
+)
+
+var tupleStatisticsRegex = regexp.MustCompile(`^UPDATE pg_class\nSET\n\trelpages = -?\d+::int,\n\treltuples = (\S+)::real\nWHERE oid = '(.*)'::regclass::oid;$`)
+
+/*
+ * Returns the tables that the given statistics statements belong to, in the
+ * order in which they first appear.  The statistics of a table's indexes,
+ * partitions, and auxiliary relations belong to the table itself.
+ */
+func GetStatisticsTables(statements []toc.StatementWithType) []string {
+	tableNames := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, statement := range statements {
+		tableName := utils.MakeFQN(statement.Schema, statement.Name)
+		if !seen[tableName] {
+			seen[tableName] = true
+			tableNames = append(tableNames, tableName)
+		}
+	}
+	return tableNames
+}
+
+/*
+ * Returns the row count recorded at backup time for each table, which is
+ * the reltuples value set by the statement that updates the table's own
+ * pg_class row.
+ */
+func GetBackedUpRowCounts(statements []toc.StatementWithType) map[string]float64 {
+	rowCounts := make(map[string]float64)
+	for _, statement := range statements {
+		if statement.ObjectType != "STATISTICS" {
+			continue
+		}
+		// Statements in the metadata file are surrounded by blank lines
+		match := tupleStatisticsRegex.FindStringSubmatch(strings.TrimSpace(statement.Statement))
+		tableName := utils.MakeFQN(statement.Schema, statement.Name)
+		if match == nil || match[2] != utils.EscapeSingleQuotes(tableName) {
+			continue
+		}
+		rowCount, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			continue
+		}
+		rowCounts[tableName] = rowCount
+	}
+	return rowCounts
+}
+
+/*
+ * Statistics are stale if the table's current row count differs from the
+ * backed-up row count by more than tolerancePercent of the backed-up count.
+ */
+func IsStatisticsStale(currentRows int64, backedUpRows float64, tolerancePercent int) bool {
+	// GPDB 7 records a reltuples of -1 for tables that were never analyzed
+	backedUpRows = math.Max(backedUpRows, 0)
+	difference := math.Abs(float64(currentRows) - backedUpRows)
+	return difference > backedUpRows*float64(tolerancePercent)/100
+}
+
+func RemoveStatisticsForTables(statements []toc.StatementWithType, skippedTables map[string]bool) []toc.StatementWithType {
+	if len(skippedTables) == 0 {
+		return statements
+	}
+	filteredStatements := make([]toc.StatementWithType, 0, len(statements))
+	for _, statement := range statements {
+		if !skippedTables[utils.MakeFQN(statement.Schema, statement.Name)] {
+			filteredStatements = append(filteredStatements, statement)
+		}
+	}
+	return filteredStatements
+}
+
+func GetExistingTables(tableNames []string) map[string]bool {
+	existingTables := make(map[string]bool)
+	if len(tableNames) == 0 {
+		return existingTables
+	}
+	query := fmt.Sprintf(`
+SELECT
+	quote_ident(n.nspname) || '.' || quote_ident(c.relname) AS string
+FROM pg_namespace n
+JOIN pg_class c ON n.oid = c.relnamespace
+WHERE quote_ident(n.nspname) || '.' || quote_ident(c.relname) IN (%s)`, utils.SliceToQuotedString(tableNames))
+	for _, tableName := range dbconn.MustSelectStringSlice(connectionPool, query) {
+		existingTables[tableName] = true
+	}
+	return existingTables
+}
+
+/*
+ * With --stats-only, statistics are restored onto tables that already exist,
+ * so skip the statistics of tables that do not exist and, if a tolerance is
+ * given, of tables whose data no longer matches the backed-up statistics.
+ */
+func FilterStatisticsForExistingTables(statements []toc.StatementWithType, checkStaleness bool, tolerancePercent int) ([]toc.StatementWithType, []report.SkippedStatistics) {
+	tableNames := GetStatisticsTables(statements)
+	existingTables := GetExistingTables(tableNames)
+	backedUpRowCounts := GetBackedUpRowCounts(statements)
+	if checkStaleness {
+		// Counting rows reads every table in full, which can take a long time for large tables
+		gplog.Info("Counting the rows of %d table(s) to check for stale statistics", len(existingTables))
+	}
+
+	skippedTables := make(map[string]bool)
+	skippedStatistics := make([]report.SkippedStatistics, 0)
+	numMissing, numStale := 0, 0
+	for _, tableName := range tableNames {
+		if wasTerminated {
+			return []toc.StatementWithType{}, skippedStatistics
+		}
+		if !existingTables[tableName] {
+			gplog.Warn("Skipping statistics of table %s, as it does not exist", tableName)
+			skippedTables[tableName] = true
+			skippedStatistics = append(skippedStatistics, report.SkippedStatistics{Table: tableName, Reason: "table does not exist"})
+			numMissing++
+			continue
+		}
+		backedUpRows, ok := backedUpRowCounts[tableName]
+		if !checkStaleness || !ok {
+			continue
+		}
+		var currentRows int64
+		err := connectionPool.Get(&currentRows, fmt.Sprintf("SELECT count(*) FROM %s", tableName))
+		gplog.FatalOnError(err)
+		if IsStatisticsStale(currentRows, backedUpRows, tolerancePercent) {
+			gplog.Warn("Skipping statistics of table %s as stale: it has %d rows, but had %.0f rows when backed up", tableName, currentRows, backedUpRows)
+			skippedTables[tableName] = true
+			skippedStatistics = append(skippedStatistics, report.SkippedStatistics{Table: tableName,
+				Reason: fmt.Sprintf("stale, with %d rows instead of the %.0f rows backed up", currentRows, backedUpRows)})
+			numStale++
+		}
+	}
+	if numMissing > 0 {
+		gplog.Info("Skipped statistics of %d table(s) that do not exist", numMissing)
+	}
+	if checkStaleness {
+		gplog.Info("Skipped statistics of %d stale table(s) whose row count differs by more than %d%%", numStale, tolerancePercent)
+	}
+	return RemoveStatisticsForTables(statements, skippedTables), skippedStatistics
+}
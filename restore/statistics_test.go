@@ -0,0 +1,93 @@
+package restore_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gpbackup/report"
+	"github.com/greenplum-db/gpbackup/restore"
+	"github.com/greenplum-db/gpbackup/toc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("restore/statistics tests", func() {
+	fooTuples := toc.StatementWithType{Schema: "public", Name: "foo", ObjectType: "STATISTICS", Statement: "\n\n" + `UPDATE pg_class
+SET
+	relpages = 3::int,
+	reltuples = 1000.000000::real
+WHERE oid = 'public.foo'::regclass::oid;` + "\n"}
+	fooIndexTuples := toc.StatementWithType{Schema: "public", Name: "foo", ObjectType: "STATISTICS", Statement: "\n\n" + `UPDATE pg_class
+SET
+	relpages = 1::int,
+	reltuples = 10.000000::real
+WHERE oid = 'public.foo_idx'::regclass::oid;` + "\n"}
+	fooAttribute := toc.StatementWithType{Schema: "public", Name: "foo", ObjectType: "STATISTICS", Statement: "\n\nDELETE FROM pg_statistic WHERE starelid = 'public.foo'::regclass::oid AND staattnum = 1;\n"}
+	barTuples := toc.StatementWithType{Schema: `"Bar's"`, Name: "bar", ObjectType: "STATISTICS", Statement: "\n\n" + `UPDATE pg_class
+SET
+	relpages = 0::int,
+	reltuples = 0.000000::real
+WHERE oid = '"Bar''s".bar'::regclass::oid;` + "\n"}
+	statements := []toc.StatementWithType{fooTuples, fooIndexTuples, fooAttribute, barTuples}
+
+	Describe("GetStatisticsTables", func() {
+		It("returns each table once, in order", func() {
+			Expect(restore.GetStatisticsTables(statements)).To(Equal([]string{"public.foo", `"Bar's".bar`}))
+		})
+	})
+	Describe("GetBackedUpRowCounts", func() {
+		It("returns the row count of each table from the statistics of the table itself", func() {
+			rowCounts := restore.GetBackedUpRowCounts(statements)
+
+			Expect(rowCounts).To(Equal(map[string]float64{"public.foo": 1000, `"Bar's".bar`: 0}))
+		})
+	})
+	Describe("IsStatisticsStale", func() {
+		It("is not stale if the row count is within the tolerance", func() {
+			Expect(restore.IsStatisticsStale(1100, 1000, 10)).To(BeFalse())
+			Expect(restore.IsStatisticsStale(900, 1000, 10)).To(BeFalse())
+		})
+		It("is stale if the row count is outside the tolerance", func() {
+			Expect(restore.IsStatisticsStale(1101, 1000, 10)).To(BeTrue())
+			Expect(restore.IsStatisticsStale(899, 1000, 10)).To(BeTrue())
+		})
+		It("requires an exact match with a tolerance of 0", func() {
+			Expect(restore.IsStatisticsStale(1000, 1000, 0)).To(BeFalse())
+			Expect(restore.IsStatisticsStale(1001, 1000, 0)).To(BeTrue())
+		})
+		It("treats a negative backed-up row count as an empty table", func() {
+			Expect(restore.IsStatisticsStale(0, -1, 10)).To(BeFalse())
+			Expect(restore.IsStatisticsStale(1, -1, 10)).To(BeTrue())
+		})
+	})
+	Describe("RemoveStatisticsForTables", func() {
+		It("removes all statistics of the given tables", func() {
+			filtered := restore.RemoveStatisticsForTables(statements, map[string]bool{"public.foo": true})
+
+			Expect(filtered).To(Equal([]toc.StatementWithType{barTuples}))
+		})
+	})
+	Describe("FilterStatisticsForExistingTables", func() {
+		It("skips the statistics of tables that do not exist", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows([]string{"string"}).AddRow("public.foo"))
+
+			filtered, skipped := restore.FilterStatisticsForExistingTables(statements, false, 0)
+
+			Expect(filtered).To(Equal([]toc.StatementWithType{fooTuples, fooIndexTuples, fooAttribute}))
+			Expect(skipped).To(Equal([]report.SkippedStatistics{{Table: `"Bar's".bar`, Reason: "table does not exist"}}))
+			Expect(string(logfile.Contents())).To(ContainSubstring(`Skipping statistics of table "Bar's".bar, as it does not exist`))
+		})
+		It("skips the statistics of tables whose row count is outside the tolerance", func() {
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(sqlmock.NewRows([]string{"string"}).AddRow("public.foo").AddRow(`"Bar's".bar`))
+			mock.ExpectQuery(`SELECT count\(\*\) FROM public.foo`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1500))
+			mock.ExpectQuery(`SELECT count\(\*\) FROM "Bar's".bar`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+			filtered, skipped := restore.FilterStatisticsForExistingTables(statements, true, 20)
+
+			Expect(filtered).To(Equal([]toc.StatementWithType{barTuples}))
+			Expect(skipped).To(Equal([]report.SkippedStatistics{{Table: "public.foo", Reason: "stale, with 1500 rows instead of the 1000 rows backed up"}}))
+			Expect(string(logfile.Contents())).To(ContainSubstring("Counting the rows of 2 table(s) to check for stale statistics"))
+			Expect(string(logfile.Contents())).To(ContainSubstring("Skipping statistics of table public.foo as stale: it has 1500 rows, but had 1000 rows when backed up"))
+			Expect(string(logfile.Contents())).To(ContainSubstring("Skipped statistics of 1 stale table(s) whose row count differs by more than 20%"))
+		})
+	})
+})
@@ -77,6 +77,50 @@ func getFilterSchemasInBackupSet(schemaList []string) []string {
 	return keys
 }
 
+// Returns the schemas in the backup set, in the quoted form used by the TOC
+func GetSchemasInBackupSet() []string {
+	schemas := make([]string, 0)
+	seen := make(map[string]bool)
+	addSchema := func(schema string) {
+		if schema != "" && !seen[schema] {
+			seen[schema] = true
+			schemas = append(schemas, schema)
+		}
+	}
+	if !backupConfig.DataOnly {
+		for _, entry := range globalTOC.PredataEntries {
+			addSchema(entry.Schema)
+		}
+	} else {
+		for _, entry := range globalTOC.DataEntries {
+			addSchema(entry.Schema)
+		}
+	}
+	return schemas
+}
+
+// Returns the relations in the backup set, in the quoted form used by the TOC
+func GetRelationsInBackupSet() []options.FqnStruct {
+	relations := make([]options.FqnStruct, 0)
+	seen := make(map[string]bool)
+	addRelation := func(schema string, name string) {
+		fqn := utils.MakeFQN(schema, name)
+		if !seen[fqn] {
+			seen[fqn] = true
+			relations = append(relations, options.FqnStruct{SchemaName: schema, TableName: name})
+		}
+	}
+	for _, entry := range globalTOC.PredataEntries {
+		if entry.ObjectType == "TABLE" || entry.ObjectType == "SEQUENCE" || entry.ObjectType == "VIEW" || entry.ObjectType == "MATERIALIZED VIEW" {
+			addRelation(entry.Schema, entry.Name)
+		}
+	}
+	for _, entry := range globalTOC.DataEntries {
+		addRelation(entry.Schema, entry.Name)
+	}
+	return relations
+}
+
 func GenerateRestoreRelationList(opts options.Options) []string {
 	includeRelations := opts.IncludedRelations
 	if len(includeRelations) > 0 {
@@ -205,7 +249,7 @@ func getFilterRelationsInBackupSet(relationList []string) []string {
 	return keys
 }
 
-func ValidateDatabaseExistence(unquotedDBName string, createDatabase bool, isFiltered bool) {
+func DatabaseExists(unquotedDBName string) bool {
 	qry := fmt.Sprintf(`
 SELECT CASE
 	WHEN EXISTS (SELECT 1 FROM pg_database WHERE datname='%s') THEN 'true'
@@ -213,8 +257,14 @@ SELECT CASE
 END AS string;`, utils.EscapeSingleQuotes(unquotedDBName))
 	databaseExists, err := strconv.ParseBool(dbconn.MustSelectString(connectionPool, qry))
 	gplog.FatalOnError(err)
-	if !databaseExists {
-		if isFiltered {
+	return databaseExists
+}
+
+func ValidateDatabaseExistence(unquotedDBName string, createDatabase bool, isFiltered bool) {
+	if !DatabaseExists(unquotedDBName) {
+		if MustGetFlagBool(options.STATS_ONLY) {
+			gplog.Fatal(errors.Errorf(`Database "%s" must exist to restore statistics onto its tables.`, unquotedDBName), "")
+		} else if isFiltered {
 			gplog.Fatal(errors.Errorf(`Database "%s" must be created manually to restore table-filtered or data-only backups.`, unquotedDBName), "")
 		} else if !createDatabase {
 			gplog.Fatal(errors.Errorf(`Database "%s" does not exist. Use the --create-db flag to create "%s" as part of the restore process.`, unquotedDBName, unquotedDBName), "")
@@ -224,13 +274,33 @@ END AS string;`, utils.EscapeSingleQuotes(unquotedDBName))
 	}
 }
 
-func ValidateBackupFlagCombinations() {
-	if backupConfig.SingleDataFile && MustGetFlagInt(options.JOBS) != 1 {
-		gplog.Fatal(errors.Errorf("Cannot use jobs flag when restoring backups with a single data file per segment."), "")
+const (
+	GlobalConflictFail  = "fail"
+	GlobalConflictSkip  = "skip"
+	GlobalConflictAlter = "alter"
+)
+
+func ValidateGlobalConflictAction(action string) error {
+	switch action {
+	case GlobalConflictFail, GlobalConflictSkip, GlobalConflictAlter:
+		return nil
 	}
-	if (backupConfig.IncludeTableFiltered || backupConfig.DataOnly) && MustGetFlagBool(options.WITH_GLOBALS) {
+	return errors.Errorf("Invalid global conflict action %s.  Valid values are %s, %s, and %s.", action, GlobalConflictFail, GlobalConflictSkip, GlobalConflictAlter)
+}
+
+func ValidateBackupFlagCombinations() {
+	if (backupConfig.IncludeTableFiltered || backupConfig.DataOnly) && (MustGetFlagBool(options.WITH_GLOBALS) || MustGetFlagBool(options.GLOBALS_ONLY)) {
 		gplog.Fatal(errors.Errorf("Global metadata is not backed up in table-filtered or data-only backups."), "")
 	}
+	if backupConfig.WithoutGlobals && MustGetFlagBool(options.GLOBALS_ONLY) {
+		gplog.Fatal(errors.Errorf("Cannot use globals-only flag when restoring a backup taken without global metadata"), "")
+	}
+	if backupConfig.GlobalsOnly && !MustGetFlagBool(options.GLOBALS_ONLY) {
+		gplog.Fatal(errors.Errorf("Backup %s contains only global metadata. Use the --globals-only flag to restore it.", backupConfig.Timestamp), "")
+	}
+	if !backupConfig.WithStatistics && MustGetFlagBool(options.STATS_ONLY) {
+		gplog.Fatal(errors.Errorf("Cannot use stats-only flag when restoring a backup taken without --with-stats"), "")
+	}
 	if backupConfig.MetadataOnly && MustGetFlagBool(options.DATA_ONLY) {
 		gplog.Fatal(errors.Errorf("Cannot use data-only flag when restoring metadata-only backup"), "")
 	}
@@ -253,34 +323,67 @@ func ValidateFlagCombinations(flags *pflag.FlagSet) {
 	options.CheckExclusiveFlags(flags, options.DATA_ONLY, options.CREATE_DB)
 	options.CheckExclusiveFlags(flags, options.DEBUG, options.QUIET, options.VERBOSE)
 
-	options.CheckExclusiveFlags(flags, options.INCLUDE_SCHEMA, options.INCLUDE_RELATION, options.INCLUDE_RELATION_FILE)
-	options.CheckExclusiveFlags(flags, options.EXCLUDE_SCHEMA, options.INCLUDE_SCHEMA)
-	options.CheckExclusiveFlags(flags, options.EXCLUDE_SCHEMA, options.EXCLUDE_RELATION, options.INCLUDE_RELATION, options.EXCLUDE_RELATION_FILE, options.INCLUDE_RELATION_FILE)
+	options.CheckExclusiveFlags(flags, options.INCLUDE_SCHEMA, options.INCLUDE_SCHEMA_PATTERN, options.INCLUDE_RELATION, options.INCLUDE_RELATION_FILE, options.INCLUDE_RELATION_PATTERN)
+	options.CheckExclusiveFlags(flags, options.EXCLUDE_SCHEMA, options.EXCLUDE_SCHEMA_PATTERN, options.INCLUDE_SCHEMA, options.INCLUDE_SCHEMA_PATTERN)
+	options.CheckExclusiveFlags(flags, options.EXCLUDE_SCHEMA, options.EXCLUDE_SCHEMA_PATTERN, options.EXCLUDE_RELATION, options.INCLUDE_RELATION, options.EXCLUDE_RELATION_FILE, options.INCLUDE_RELATION_FILE,
+		options.EXCLUDE_RELATION_PATTERN, options.INCLUDE_RELATION_PATTERN)
 
 	options.CheckExclusiveFlags(flags, options.METADATA_ONLY, options.DATA_ONLY)
 	options.CheckExclusiveFlags(flags, options.PLUGIN_CONFIG, options.BACKUP_DIR)
 	options.CheckExclusiveFlags(flags, options.TRUNCATE_TABLE, options.METADATA_ONLY, options.INCREMENTAL)
 	options.CheckExclusiveFlags(flags, options.TRUNCATE_TABLE, options.REDIRECT_SCHEMA)
+	options.CheckExclusiveFlags(flags, options.INCLUDE_OBJECT_TYPE, options.EXCLUDE_OBJECT_TYPE, options.DATA_ONLY)
+	for _, flagName := range []string{options.ROLE_MAPPING, options.NO_OWNER, options.NO_PRIVILEGES} {
+		options.CheckExclusiveFlags(flags, options.DATA_ONLY, flagName)
+	}
 
 	if flags.Changed(options.REDIRECT_SCHEMA) {
 		// Redirect schema not compatible with any exclude flags
-		if flags.Changed(options.EXCLUDE_SCHEMA) || flags.Changed(options.EXCLUDE_SCHEMA_FILE) ||
-			flags.Changed(options.EXCLUDE_RELATION) || flags.Changed(options.EXCLUDE_RELATION_FILE) {
+		if flags.Changed(options.EXCLUDE_SCHEMA) || flags.Changed(options.EXCLUDE_SCHEMA_FILE) || flags.Changed(options.EXCLUDE_SCHEMA_PATTERN) ||
+			flags.Changed(options.EXCLUDE_RELATION) || flags.Changed(options.EXCLUDE_RELATION_FILE) || flags.Changed(options.EXCLUDE_RELATION_PATTERN) {
 			gplog.Fatal(errors.Errorf("Cannot use --redirect-schema with exclude flags"), "")
 		}
 		// Redirect schema requires an include flag
-		if !(flags.Changed(options.INCLUDE_RELATION) || flags.Changed(options.INCLUDE_RELATION_FILE) ||
-			flags.Changed(options.INCLUDE_SCHEMA) || flags.Changed(options.INCLUDE_SCHEMA_FILE)) {
-			gplog.Fatal(errors.Errorf("Cannot use --redirect-schema without --include-table, --include-table-file, --include-schema, or --include-schema-file"), "")
+		if !(flags.Changed(options.INCLUDE_RELATION) || flags.Changed(options.INCLUDE_RELATION_FILE) || flags.Changed(options.INCLUDE_RELATION_PATTERN) ||
+			flags.Changed(options.INCLUDE_SCHEMA) || flags.Changed(options.INCLUDE_SCHEMA_FILE) || flags.Changed(options.INCLUDE_SCHEMA_PATTERN)) {
+			gplog.Fatal(errors.Errorf("Cannot use --redirect-schema without --include-table, --include-table-file, --include-table-pattern, --include-schema, --include-schema-file, or --include-schema-pattern"), "")
 		}
 	}
 	if flags.Changed(options.TRUNCATE_TABLE) &&
-		!(flags.Changed(options.INCLUDE_RELATION) || flags.Changed(options.INCLUDE_RELATION_FILE)) &&
+		!(flags.Changed(options.INCLUDE_RELATION) || flags.Changed(options.INCLUDE_RELATION_FILE) || flags.Changed(options.INCLUDE_RELATION_PATTERN)) &&
 		!flags.Changed(options.DATA_ONLY) {
-		gplog.Fatal(errors.Errorf("Cannot use --truncate-table without --include-table or --include-table-file and without --data-only"), "")
+		gplog.Fatal(errors.Errorf("Cannot use --truncate-table without --include-table, --include-table-file, or --include-table-pattern and without --data-only"), "")
 	}
 	if flags.Changed(options.INCREMENTAL) && !flags.Changed(options.DATA_ONLY) {
 		gplog.Fatal(errors.Errorf("Cannot use --incremental without --data-only"), "")
 	}
 	options.CheckExclusiveFlags(flags, options.RUN_ANALYZE, options.WITH_STATS)
+	options.CheckExclusiveFlags(flags, options.TARGET_POSTGRES, options.INCREMENTAL)
+	options.CheckExclusiveFlags(flags, options.TARGET_POSTGRES, options.WITH_STATS)
+	for _, flagName := range []string{options.REDIRECT_DB, options.CREATE_DB, options.WITH_GLOBALS, options.METADATA_ONLY, options.DATA_ONLY, options.INCREMENTAL, options.TRUNCATE_TABLE} {
+		options.CheckExclusiveFlags(flags, options.RESTORE_TEST, flagName)
+	}
+	options.CheckExclusiveFlags(flags, options.VERIFY_DATA, options.METADATA_ONLY)
+	for _, flagName := range []string{options.WITH_GLOBALS, options.METADATA_ONLY, options.DATA_ONLY, options.INCREMENTAL, options.TRUNCATE_TABLE,
+		options.INCLUDE_SCHEMA, options.INCLUDE_SCHEMA_FILE, options.EXCLUDE_SCHEMA, options.EXCLUDE_SCHEMA_FILE,
+		options.INCLUDE_RELATION, options.INCLUDE_RELATION_FILE, options.EXCLUDE_RELATION, options.EXCLUDE_RELATION_FILE,
+		options.INCLUDE_SCHEMA_PATTERN, options.EXCLUDE_SCHEMA_PATTERN, options.INCLUDE_RELATION_PATTERN, options.EXCLUDE_RELATION_PATTERN,
+		options.INCLUDE_OBJECT_TYPE, options.EXCLUDE_OBJECT_TYPE, options.REDIRECT_SCHEMA, options.RUN_ANALYZE, options.WITH_STATS, options.STATS_ONLY,
+		options.RESTORE_TEST, options.VERIFY_DATA} {
+		options.CheckExclusiveFlags(flags, options.GLOBALS_ONLY, flagName)
+	}
+	for _, flagName := range []string{options.CREATE_DB, options.WITH_GLOBALS, options.METADATA_ONLY, options.DATA_ONLY, options.INCREMENTAL,
+		options.TRUNCATE_TABLE, options.INCLUDE_OBJECT_TYPE, options.EXCLUDE_OBJECT_TYPE, options.RUN_ANALYZE, options.WITH_STATS, options.RESTORE_TEST,
+		options.VERIFY_DATA, options.TARGET_POSTGRES, options.ROLE_MAPPING, options.NO_OWNER, options.NO_PRIVILEGES} {
+		options.CheckExclusiveFlags(flags, options.STATS_ONLY, flagName)
+	}
+	if flags.Changed(options.STATS_TOLERANCE) && !flags.Changed(options.STATS_ONLY) {
+		gplog.Fatal(errors.Errorf("Cannot use --stats-tolerance without --stats-only"), "")
+	}
+	if flags.Changed(options.ON_GLOBAL_CONFLICT) && !(flags.Changed(options.WITH_GLOBALS) || flags.Changed(options.GLOBALS_ONLY)) {
+		gplog.Fatal(errors.Errorf("Cannot use --on-global-conflict without --with-globals or --globals-only"), "")
+	}
+	if flags.Changed(options.TARGET_POSTGRES) && !flags.Changed(options.BACKUP_DIR) {
+		gplog.Fatal(errors.Errorf("Cannot use --target-postgres without --backup-dir"), "")
+	}
 }
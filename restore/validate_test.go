@@ -36,10 +36,10 @@ var _ = Describe("restore/validate tests", func() {
 			tocfile, backupfile = testutils.InitializeTestTOC(buffer, "predata")
 			backupfile.ByteCount = table1Len
 			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema1", Name: "table1", ObjectType: "TABLE"}, 0, backupfile.ByteCount)
-			tocfile.AddMasterDataEntry("schema1", "table1", 1, "(i)", 0, "")
+			tocfile.AddMasterDataEntry("schema1", "table1", 1, "(i)", 0, "", "", 0)
 			backupfile.ByteCount += table2Len
 			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema2", Name: "table2", ObjectType: "TABLE"}, table1Len, backupfile.ByteCount)
-			tocfile.AddMasterDataEntry("schema2", "table2", 2, "(j)", 0, "")
+			tocfile.AddMasterDataEntry("schema2", "table2", 2, "(j)", 0, "", "", 0)
 			backupfile.ByteCount += sequenceLen
 			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema", Name: "somesequence", ObjectType: "SEQUENCE"}, table1Len+table2Len, backupfile.ByteCount)
 			restore.SetTOC(tocfile)
@@ -74,14 +74,45 @@ var _ = Describe("restore/validate tests", func() {
 			testhelper.ExpectRegexp(logfile, "[WARNING]:-Could not find the following excluded schema(s) in the backup set: schema3")
 		})
 	})
+	Describe("GetSchemasInBackupSet and GetRelationsInBackupSet", func() {
+		BeforeEach(func() {
+			tocfile, backupfile := testutils.InitializeTestTOC(buffer, "predata")
+			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "", Name: "schema1", ObjectType: "SCHEMA"}, 0, backupfile.ByteCount)
+			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema1", Name: "table1", ObjectType: "TABLE"}, 0, backupfile.ByteCount)
+			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema1", Name: "somefunction", ObjectType: "FUNCTION"}, 0, backupfile.ByteCount)
+			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: `"Schema2"`, Name: "someview", ObjectType: "VIEW"}, 0, backupfile.ByteCount)
+			tocfile.AddMasterDataEntry("schema1", "table1", 1, "(i)", 0, "", "", 0)
+			tocfile.AddMasterDataEntry("schema3", "table3", 2, "(j)", 0, "", "", 0)
+			restore.SetTOC(tocfile)
+		})
+		It("returns the schemas of the metadata in the backup set", func() {
+			restore.SetBackupConfig(&history.BackupConfig{})
+
+			Expect(restore.GetSchemasInBackupSet()).To(Equal([]string{"schema1", `"Schema2"`}))
+		})
+		It("returns the schemas of the data in a data-only backup set", func() {
+			restore.SetBackupConfig(&history.BackupConfig{DataOnly: true})
+
+			Expect(restore.GetSchemasInBackupSet()).To(Equal([]string{"schema1", "schema3"}))
+		})
+		It("returns each relation in the metadata and data of the backup set once", func() {
+			restore.SetBackupConfig(&history.BackupConfig{})
+
+			Expect(restore.GetRelationsInBackupSet()).To(Equal([]options.FqnStruct{
+				{SchemaName: "schema1", TableName: "table1"},
+				{SchemaName: `"Schema2"`, TableName: "someview"},
+				{SchemaName: "schema3", TableName: "table3"},
+			}))
+		})
+	})
 	Describe("GenerateRestoreRelationList", func() {
 		var opts *options.Options
 		BeforeEach(func() {
 			tocfile, _ = testutils.InitializeTestTOC(buffer, "metadata")
-			tocfile.AddMasterDataEntry("s1", "table1", 1, "(j)", 0, "")
-			tocfile.AddMasterDataEntry("s1", "table2", 2, "(j)", 0, "")
-			tocfile.AddMasterDataEntry("s2", "table1", 3, "(j)", 0, "")
-			tocfile.AddMasterDataEntry("s2", "table2", 4, "(j)", 0, "")
+			tocfile.AddMasterDataEntry("s1", "table1", 1, "(j)", 0, "", "", 0)
+			tocfile.AddMasterDataEntry("s1", "table2", 2, "(j)", 0, "", "", 0)
+			tocfile.AddMasterDataEntry("s2", "table1", 3, "(j)", 0, "", "", 0)
+			tocfile.AddMasterDataEntry("s2", "table2", 4, "(j)", 0, "", "", 0)
 			restore.SetTOC(tocfile)
 
 			opts = &options.Options{}
@@ -199,10 +230,10 @@ var _ = Describe("restore/validate tests", func() {
 		BeforeEach(func() {
 			tocfile, backupfile = testutils.InitializeTestTOC(buffer, "predata")
 			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema1", Name: "table1", ObjectType: "TABLE"}, 0, backupfile.ByteCount)
-			tocfile.AddMasterDataEntry("schema1", "table1", 1, "(i)", 0, "")
+			tocfile.AddMasterDataEntry("schema1", "table1", 1, "(i)", 0, "", "", 0)
 
 			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema2", Name: "table2", ObjectType: "TABLE"}, 0, backupfile.ByteCount)
-			tocfile.AddMasterDataEntry("schema2", "table2", 2, "(j)", 0, "")
+			tocfile.AddMasterDataEntry("schema2", "table2", 2, "(j)", 0, "", "", 0)
 
 			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema1", Name: "somesequence", ObjectType: "SEQUENCE"}, 0, backupfile.ByteCount)
 			tocfile.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema1", Name: "someview", ObjectType: "VIEW"}, 0, backupfile.ByteCount)
@@ -294,6 +325,14 @@ var _ = Describe("restore/validate tests", func() {
 			defer testhelper.ShouldPanicWithMessage(`Database "testdb" does not exist. Use the --create-db flag`)
 			restore.ValidateDatabaseExistence("testdb", false, false)
 		})
+		It("panics and tells user the db must exist when db does not exist and --stats-only passed", func() {
+			_ = cmdFlags.Set(options.STATS_ONLY, "true")
+			dbExists := sqlmock.NewRows([]string{"string"}).
+				AddRow("false")
+			mock.ExpectQuery("SELECT (.*)").WillReturnRows(dbExists)
+			defer testhelper.ShouldPanicWithMessage(`Database "testdb" must exist to restore statistics onto its tables.`)
+			restore.ValidateDatabaseExistence("testdb", false, false)
+		})
 	})
 	Describe("Validate various flag combinations that are required or exclusive", func() {
 		DescribeTable("Validate various flag combinations that are required or exclusive",
@@ -366,6 +405,16 @@ var _ = Describe("restore/validate tests", func() {
 			Entry("--include-table combos", "--include-table schema.table --include-table schema.table2", true),
 			Entry("--include-table combos", "--include-table schema.table --include-table-file /tmp/file2", false),
 
+			// pattern combinations with other filters
+			Entry("pattern combos", "--include-table-pattern schema.fact_* --include-table-pattern schema.dim_*", true),
+			Entry("pattern combos", "--include-table-pattern schema.fact_* --include-table schema.table2", false),
+			Entry("pattern combos", "--include-table-pattern schema.fact_* --include-schema-pattern stg_*", false),
+			Entry("pattern combos", "--include-table-pattern schema.fact_* --exclude-table-pattern schema.fact_old", false),
+			Entry("pattern combos", "--include-schema-pattern stg_* --exclude-schema-pattern *_old", false),
+			Entry("pattern combos", "--include-schema-pattern stg_* --exclude-table-pattern stg_a.foo", true),
+			Entry("pattern combos", "--exclude-schema-pattern stg_* --exclude-table schema.table2", false),
+			Entry("pattern combos", "--exclude-table-pattern schema.stg_* --exclude-table-pattern schema.tmp_*", true),
+
 			/*
 			 * Below are various different incremental combinations
 			 */
@@ -378,6 +427,7 @@ var _ = Describe("restore/validate tests", func() {
 			Entry("truncate combos", "--truncate-table", false),
 			Entry("truncate combos", "--truncate-table --include-table schema.table2", true),
 			Entry("truncate combos", "--truncate-table --include-table-file /tmp/file2", true),
+			Entry("truncate combos", "--truncate-table --include-table-pattern schema.fact_*", true),
 			Entry("truncate combos", "--truncate-table --include-table schema.table2 --redirect-db foodb", true),
 			Entry("truncate combos", "--truncate-table --include-table schema.table2 --redirect-schema schema2", false),
 
@@ -389,12 +439,111 @@ var _ = Describe("restore/validate tests", func() {
 			Entry("--redirect-schema combos", "--redirect-schema schema1 --include-table-file /tmp/file2", true),
 			Entry("--redirect-schema combos", "--redirect-schema schema1 --include-schema schema2", true),
 			Entry("--redirect-schema combos", "--redirect-schema schema1 --include-schema-file /tmp/file2", true),
+			Entry("--redirect-schema combos", "--redirect-schema schema1 --include-table-pattern schema.fact_*", true),
+			Entry("--redirect-schema combos", "--redirect-schema schema1 --include-schema-pattern stg_*", true),
+			Entry("--redirect-schema combos", "--redirect-schema schema1 --include-schema schema2 --exclude-table-pattern schema2.stg_*", false),
 			Entry("--redirect-schema combos", "--redirect-schema schema1 --exclude-table schema.table2", false),
 			Entry("--redirect-schema combos", "--redirect-schema schema1 --exclude-table-file /tmp/file2", false),
 			Entry("--redirect-schema combos", "--redirect-schema schema1 --exclude-schema schema2", false),
 			Entry("--redirect-schema combos", "--redirect-schema schema1 --exclude-schema-file /tmp/file2", false),
 			Entry("--redirect-schema combos", "--redirect-schema schema1 --include-table schema.table2 --metadata-only", true),
 			Entry("--redirect-schema combos", "--redirect-schema schema1 --include-table schema.table2 --data-only", true),
+
+			/*
+			 * Below are various different target-postgres combinations
+			 */
+			Entry("--target-postgres combos", "--target-postgres", false),
+			Entry("--target-postgres combos", "--target-postgres --backup-dir /tmp", true),
+			Entry("--target-postgres combos", "--target-postgres --backup-dir /tmp --run-analyze", true),
+			Entry("--target-postgres combos", "--target-postgres --backup-dir /tmp --with-stats", false),
+			Entry("--target-postgres combos", "--target-postgres --backup-dir /tmp --incremental --data-only", false),
+
+			/*
+			 * Below are various different restore-test combinations
+			 */
+			Entry("--restore-test combos", "--restore-test", true),
+			Entry("--restore-test combos", "--restore-test --include-schema schema1", true),
+			Entry("--restore-test combos", "--restore-test --redirect-db db1", false),
+			Entry("--restore-test combos", "--restore-test --create-db", false),
+			Entry("--restore-test combos", "--restore-test --with-globals", false),
+			Entry("--restore-test combos", "--restore-test --metadata-only", false),
+			Entry("--restore-test combos", "--restore-test --data-only", false),
+			Entry("--restore-test combos", "--restore-test --truncate-table", false),
+
+			/*
+			 * Below are various different verify-data combinations
+			 */
+			Entry("--verify-data combos", "--verify-data", true),
+			Entry("--verify-data combos", "--verify-data --data-only", true),
+			Entry("--verify-data combos", "--verify-data --metadata-only", false),
+
+			/*
+			 * Below are various different globals-only and global conflict combinations
+			 */
+			Entry("--globals-only combos", "--globals-only", true),
+			Entry("--globals-only combos", "--globals-only --create-db --redirect-db db1", true),
+			Entry("--globals-only combos", "--globals-only --on-global-conflict skip", true),
+			Entry("--globals-only combos", "--globals-only --with-globals", false),
+			Entry("--globals-only combos", "--globals-only --metadata-only", false),
+			Entry("--globals-only combos", "--globals-only --data-only", false),
+			Entry("--globals-only combos", "--globals-only --include-schema schema1", false),
+			Entry("--globals-only combos", "--globals-only --exclude-table schema.table", false),
+			Entry("--globals-only combos", "--globals-only --restore-test", false),
+			Entry("--on-global-conflict combos", "--on-global-conflict alter --with-globals", true),
+			Entry("--on-global-conflict combos", "--on-global-conflict alter", false),
+
+			/*
+			 * Below are various different stats-only combinations
+			 */
+			Entry("--stats-only combos", "--stats-only", true),
+			Entry("--stats-only combos", "--stats-only --include-table schema.table --redirect-schema schema2", true),
+			Entry("--stats-only combos", "--stats-only --stats-tolerance 10", true),
+			Entry("--stats-only combos", "--stats-only --redirect-db db1", true),
+			Entry("--stats-only combos", "--stats-only --with-stats", false),
+			Entry("--stats-only combos", "--stats-only --create-db", false),
+			Entry("--stats-only combos", "--stats-only --data-only", false),
+			Entry("--stats-only combos", "--stats-only --metadata-only", false),
+			Entry("--stats-only combos", "--stats-only --run-analyze", false),
+			Entry("--stats-only combos", "--stats-only --globals-only", false),
+			Entry("--stats-only combos", "--stats-only --restore-test", false),
+			Entry("--stats-tolerance combos", "--stats-tolerance 10", false),
+
+			/*
+			 * Below are various different object type combinations
+			 */
+			Entry("--include-object-type combos", "--include-object-type VIEW --include-object-type FUNCTION", true),
+			Entry("--include-object-type combos", "--include-object-type VIEW --include-schema schema1 --metadata-only", true),
+			Entry("--include-object-type combos", "--include-object-type VIEW --exclude-object-type FUNCTION", false),
+			Entry("--include-object-type combos", "--include-object-type VIEW --data-only", false),
+			Entry("--include-object-type combos", "--include-object-type VIEW --globals-only", false),
+			Entry("--include-object-type combos", "--include-object-type VIEW --stats-only", false),
+			Entry("--exclude-object-type combos", "--exclude-object-type TRIGGER --exclude-object-type RULE", true),
+			Entry("--exclude-object-type combos", "--exclude-object-type TRIGGER --data-only", false),
+			Entry("--exclude-object-type combos", "--exclude-object-type TRIGGER --stats-only", false),
+
+			/*
+			 * Below are various different role mapping, owner, and privilege combinations
+			 */
+			Entry("--role-mapping combos", "--role-mapping /tmp/roles.txt --no-privileges", true),
+			Entry("--role-mapping combos", "--role-mapping /tmp/roles.txt --globals-only", true),
+			Entry("--role-mapping combos", "--role-mapping /tmp/roles.txt --data-only", false),
+			Entry("--role-mapping combos", "--role-mapping /tmp/roles.txt --stats-only", false),
+			Entry("--no-owner combos", "--no-owner --no-privileges --metadata-only", true),
+			Entry("--no-owner combos", "--no-owner --data-only", false),
+			Entry("--no-owner combos", "--no-owner --stats-only", false),
+			Entry("--no-privileges combos", "--no-privileges --data-only", false),
+			Entry("--no-privileges combos", "--no-privileges --stats-only", false),
 		)
 	})
+	Describe("ValidateGlobalConflictAction", func() {
+		It("accepts valid actions", func() {
+			for _, action := range []string{"fail", "skip", "alter"} {
+				Expect(restore.ValidateGlobalConflictAction(action)).To(Succeed())
+			}
+		})
+		It("rejects an invalid action", func() {
+			err := restore.ValidateGlobalConflictAction("replace")
+			Expect(err).To(MatchError("Invalid global conflict action replace.  Valid values are fail, skip, and alter."))
+		})
+	})
 })
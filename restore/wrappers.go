@@ -2,6 +2,8 @@ package restore
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	path "path/filepath"
 	"strconv"
 	"strings"
@@ -9,10 +11,12 @@ import (
 	"github.com/greenplum-db/gp-common-go-libs/dbconn"
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
 	"github.com/greenplum-db/gp-common-go-libs/iohelper"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
 	"github.com/greenplum-db/gpbackup/filepath"
 	"github.com/greenplum-db/gpbackup/history"
 	"github.com/greenplum-db/gpbackup/options"
 	"github.com/greenplum-db/gpbackup/report"
+	"github.com/greenplum-db/gpbackup/storage"
 	"github.com/greenplum-db/gpbackup/toc"
 	"github.com/greenplum-db/gpbackup/utils"
 )
@@ -63,12 +67,28 @@ func SetLoggerVerbosity() {
 
 func CreateConnectionPool(unquotedDBName string) {
 	connectionPool = dbconn.NewDBConnFromEnvironment(unquotedDBName)
-	connectionPool.MustConnect(MustGetFlagInt(options.JOBS))
+	if MustGetFlagBool(options.TARGET_POSTGRES) {
+		var err error
+		postgresVersion, err = ConnectToPostgres(connectionPool, MustGetFlagInt(options.JOBS))
+		gplog.FatalOnError(err)
+		return
+	}
+	// Each data stream of a single data file backup is read by at least one connection of its own
+	numConns := MustGetFlagInt(options.JOBS)
+	if backupConfig != nil && backupConfig.SingleDataFile && backupConfig.NumDataStreams() > numConns {
+		numConns = backupConfig.NumDataStreams()
+	}
+	connectionPool.MustConnect(numConns)
 	utils.ValidateGPDBVersionCompatibility(connectionPool)
 }
 
 func InitializeConnectionPool(backupTimestamp string, restoreTimestamp string, unquotedDBName string) {
 	CreateConnectionPool(unquotedDBName)
+	if MustGetFlagBool(options.TARGET_POSTGRES) {
+		InitializePostgresConnectionPool(backupTimestamp, restoreTimestamp)
+		setSessionGUCsFromFile()
+		return
+	}
 	setupQuery := fmt.Sprintf("SET application_name TO 'gprestore_%s_%s';", backupTimestamp, restoreTimestamp)
 	setupQuery += `
 SET search_path TO pg_catalog;
@@ -114,6 +134,16 @@ SET default_with_oids = off;
 	for i := 0; i < connectionPool.NumConns; i++ {
 		connectionPool.MustExec(setupQuery, i)
 	}
+	setSessionGUCsFromFile()
+}
+
+// Settings from --session-guc-file are applied last so that they override the ones above
+func setSessionGUCsFromFile() {
+	for i := 0; i < connectionPool.NumConns; i++ {
+		for _, guc := range sessionGUCs {
+			connectionPool.MustExec(guc.SetStatement(), i)
+		}
+	}
 }
 
 func SetMaxCsvLineLengthQuery(connectionPool *dbconn.DBConn) string {
@@ -134,22 +164,24 @@ func SetMaxCsvLineLengthQuery(connectionPool *dbconn.DBConn) string {
 }
 
 func InitializeBackupConfig() {
-	backupConfig = history.ReadConfigFile(globalFPInfo.GetConfigFilePath())
+	backupConfig = history.ParseConfig(ReadMetadataFile(globalFPInfo.GetConfigFilePath()))
 	utils.InitializePipeThroughParameters(backupConfig.Compressed, 0)
 	report.EnsureBackupVersionCompatibility(backupConfig.BackupVersion, version)
-	report.EnsureDatabaseVersionCompatibility(backupConfig.DatabaseVersion, connectionPool.Version)
+	// Greenplum catalog compatibility does not apply to a PostgreSQL database
+	if !MustGetFlagBool(options.TARGET_POSTGRES) {
+		report.EnsureDatabaseVersionCompatibility(backupConfig.DatabaseVersion, connectionPool.Version)
+	}
 }
 
 func BackupConfigurationValidation() {
-	if !backupConfig.MetadataOnly {
+	if !backupConfig.MetadataOnly && !MustGetFlagBool(options.STATS_ONLY) {
 		gplog.Verbose("Gathering information on backup directories")
 		VerifyBackupDirectoriesExistOnAllHosts()
 	}
 
-	VerifyMetadataFilePaths(MustGetFlagBool(options.WITH_STATS))
+	VerifyMetadataFilePaths(MustGetFlagBool(options.WITH_STATS) || MustGetFlagBool(options.STATS_ONLY))
 
-	tocFilename := globalFPInfo.GetTOCFilePath()
-	globalTOC = toc.NewTOC(tocFilename)
+	globalTOC = ReadTOC(globalFPInfo.GetTOCFilePath())
 	globalTOC.InitializeMetadataEntryMap()
 
 	// Legacy backups prior to the incremental feature would have no restoreplan yaml element
@@ -159,9 +191,25 @@ func BackupConfigurationValidation() {
 
 	ValidateBackupFlagCombinations()
 
+	expandFilterPatternsInBackupSet()
 	validateFilterListsInBackupSet()
 }
 
+/*
+ * Schema and table patterns are expanded against the TOC into the schema and
+ * table filters, so the restore only considers objects in the backup set.
+ */
+func expandFilterPatternsInBackupSet() {
+	if opts.HasSchemaPatterns() {
+		err := opts.ExpandSchemaPatterns(cmdFlags, GetSchemasInBackupSet(), true)
+		gplog.FatalOnError(err)
+	}
+	if opts.HasRelationPatterns() {
+		err := opts.ExpandRelationPatterns(cmdFlags, GetRelationsInBackupSet(), true)
+		gplog.FatalOnError(err)
+	}
+}
+
 func SetRestorePlanForLegacyBackup(toc *toc.TOC, backupTimestamp string, backupConfig *history.BackupConfig) {
 	tableFQNs := make([]string, 0, len(toc.DataEntries))
 	for _, entry := range toc.DataEntries {
@@ -177,6 +225,7 @@ func RecoverMetadataFilesUsingPlugin() {
 	var err error
 	pluginConfig, err = utils.ReadPluginConfig(MustGetFlagString(options.PLUGIN_CONFIG))
 	gplog.FatalOnError(err)
+	storageBackend = storage.MustNewStorageBackend(pluginConfig)
 	configFilename := path.Base(pluginConfig.ConfigPath)
 	configDirname := path.Dir(pluginConfig.ConfigPath)
 	pluginConfig.ConfigPath = path.Join(configDirname, history.CurrentTimestamp()+"_"+configFilename)
@@ -192,13 +241,21 @@ func RecoverMetadataFilesUsingPlugin() {
 	pluginConfig.CopyPluginConfigToAllHosts(globalCluster)
 	pluginConfig.SetupPluginForRestore(globalCluster, globalFPInfo)
 
-	metadataFiles := []string{globalFPInfo.GetConfigFilePath(), globalFPInfo.GetMetadataFilePath(),
-		globalFPInfo.GetBackupReportFilePath()}
-	if MustGetFlagBool(options.WITH_STATS) {
-		metadataFiles = append(metadataFiles, globalFPInfo.GetStatisticsFilePath())
-	}
-	for _, filename := range metadataFiles {
-		pluginConfig.MustRestoreFile(filename)
+	streamMetadata = pluginConfig.CanStreamMetadata()
+	if streamMetadata {
+		gplog.Verbose("Reading metadata files directly from the backup destination")
+		// The restore report and error table files are still written to the master's backup directory
+		err = operating.System.MkdirAll(globalFPInfo.GetDirForContent(-1), 0755)
+		gplog.FatalOnError(err)
+	} else {
+		metadataFiles := []string{globalFPInfo.GetConfigFilePath(), globalFPInfo.GetMetadataFilePath(),
+			globalFPInfo.GetBackupReportFilePath()}
+		if MustGetFlagBool(options.WITH_STATS) || MustGetFlagBool(options.STATS_ONLY) {
+			metadataFiles = append(metadataFiles, globalFPInfo.GetStatisticsFilePath())
+		}
+		for _, filename := range metadataFiles {
+			storage.MustGetFile(storageBackend, filename)
+		}
 	}
 
 	InitializeBackupConfig()
@@ -211,9 +268,14 @@ func RecoverMetadataFilesUsingPlugin() {
 	}
 
 	for _, fpInfo := range fpInfoList {
-		pluginConfig.MustRestoreFile(fpInfo.GetTOCFilePath())
+		if !streamMetadata {
+			storage.MustGetFile(storageBackend, fpInfo.GetTOCFilePath())
+		}
 		if backupConfig.SingleDataFile {
-			pluginConfig.RestoreSegmentTOCs(globalCluster, fpInfo)
+			numStreams := ReadTOC(fpInfo.GetTOCFilePath()).NumDataStreams()
+			for stream := 0; stream < numStreams; stream++ {
+				pluginConfig.RestoreSegmentTOCs(globalCluster, fpInfo.ForDataStream(stream))
+			}
 		}
 	}
 }
@@ -236,6 +298,42 @@ func FindHistoricalPluginVersion(timestamp string) string {
 	return historicalPluginVersion
 }
 
+/*
+ * Metadata files are read from the master's backup directory, or when they are
+ * streamed, directly from the backup destination.  Statements are read from a
+ * streamed metadata file in TOC order, which allows each section of the file
+ * to be read in a single pass, with ranged reads where the backend supports
+ * them.
+ */
+
+func ReadMetadataFile(filename string) []byte {
+	var contents []byte
+	var err error
+	if streamMetadata {
+		contents, err = storage.ReadFile(storageBackend, filename)
+	} else {
+		contents, err = ioutil.ReadFile(filename)
+	}
+	gplog.FatalOnError(err)
+	return contents
+}
+
+func ReadTOC(filename string) *toc.TOC {
+	return toc.ParseTOC(ReadMetadataFile(filename))
+}
+
+type metadataFileReader interface {
+	io.ReaderAt
+	io.Closer
+}
+
+func openMetadataFile(filename string) metadataFileReader {
+	if streamMetadata {
+		return storage.NewReaderAt(storageBackend, filename)
+	}
+	return iohelper.MustOpenFileForReading(filename)
+}
+
 /*
  * Metadata and/or data restore wrapper functions
  */
@@ -247,7 +345,8 @@ func GetRestoreMetadataStatements(section string, filename string, includeObject
 }
 
 func GetRestoreMetadataStatementsFiltered(section string, filename string, includeObjectTypes []string, excludeObjectTypes []string, filters Filters) []toc.StatementWithType {
-	metadataFile := iohelper.MustOpenFileForReading(filename)
+	metadataFile := openMetadataFile(filename)
+	defer metadataFile.Close()
 	var statements []toc.StatementWithType
 	var inSchemas, exSchemas, inRelations, exRelations []string
 	if !filtersEmpty(filters) {
@@ -257,8 +356,7 @@ func GetRestoreMetadataStatementsFiltered(section string, filename string, inclu
 		exRelations = filters.excludeRelations
 		fpInfoList := GetBackupFPInfoListFromRestorePlan()
 		for _, fpInfo := range fpInfoList {
-			tocFilename := fpInfo.GetTOCFilePath()
-			tocfile := toc.NewTOC(tocFilename)
+			tocfile := ReadTOC(fpInfo.GetTOCFilePath())
 			inRelations = append(inRelations, toc.GetIncludedPartitionRoots(tocfile.DataEntries, inRelations)...)
 		}
 		// Update include schemas for schema restore if include table is set
@@ -275,9 +373,61 @@ func GetRestoreMetadataStatementsFiltered(section string, filename string, inclu
 		}
 	}
 	statements = globalTOC.GetSQLStatementForObjectTypes(section, metadataFile, includeObjectTypes, excludeObjectTypes, inSchemas, exSchemas, inRelations, exRelations)
+	if MustGetFlagBool(options.TARGET_POSTGRES) {
+		statements = ConvertStatementsForPostgres(statements)
+	}
+	if len(roleMapping) > 0 || MustGetFlagBool(options.NO_OWNER) || MustGetFlagBool(options.NO_PRIVILEGES) {
+		statements = toc.RemapRolesInStatements(statements, roleMapping, MustGetFlagBool(options.NO_OWNER), MustGetFlagBool(options.NO_PRIVILEGES))
+	}
 	return statements
 }
 
+/*
+ * Role names in restored statements are quoted, so the names in the role
+ * mapping file are quoted the same way before any statements are rewritten.
+ */
+func InitializeRoleMapping(filename string) {
+	unquotedRoleMapping, err := utils.ReadRoleMappingFile(filename)
+	gplog.FatalOnError(err)
+	roleMapping = make(map[string]string, len(unquotedRoleMapping))
+	for oldRole, newRole := range unquotedRoleMapping {
+		if newRole != "" {
+			newRole = utils.QuoteIdent(connectionPool, newRole)
+		}
+		roleMapping[utils.QuoteIdent(connectionPool, oldRole)] = newRole
+	}
+}
+
+func IsObjectTypeRestored(objectType string) bool {
+	includeObjectTypes := MustGetFlagStringArray(options.INCLUDE_OBJECT_TYPE)
+	if len(includeObjectTypes) > 0 {
+		return utils.Exists(includeObjectTypes, objectType)
+	}
+	return !utils.Exists(MustGetFlagStringArray(options.EXCLUDE_OBJECT_TYPE), objectType)
+}
+
+/*
+ * Combines --include-object-type and --exclude-object-type with the object
+ * types that the caller restores separately, returning the object types to
+ * pass to GetRestoreMetadataStatementsFiltered.  Returns false if only object
+ * types that are restored separately were included, as otherwise an empty
+ * include list would restore everything.
+ */
+func GetObjectTypeFilters(restoredSeparately ...string) ([]string, []string, bool) {
+	userIncludeObjectTypes := MustGetFlagStringArray(options.INCLUDE_OBJECT_TYPE)
+	includeObjectTypes := make([]string, 0)
+	for _, objectType := range userIncludeObjectTypes {
+		if !utils.Exists(restoredSeparately, objectType) {
+			includeObjectTypes = append(includeObjectTypes, objectType)
+		}
+	}
+	if len(userIncludeObjectTypes) > 0 && len(includeObjectTypes) == 0 {
+		return nil, nil, false
+	}
+	excludeObjectTypes := append(append([]string{}, MustGetFlagStringArray(options.EXCLUDE_OBJECT_TYPE)...), restoredSeparately...)
+	return includeObjectTypes, excludeObjectTypes, true
+}
+
 func ExecuteRestoreMetadataStatements(statements []toc.StatementWithType, objectsTitle string, progressBar utils.ProgressBar, showProgressBar int, executeInParallel bool) int32 {
 	var numErrors int32
 	if progressBar == nil {
@@ -378,6 +528,65 @@ func GetExistingSchemas() ([]string, error) {
 	return existingSchemas, err
 }
 
+/*
+ * Returns the quoted names of the roles, resource queues, resource groups, and
+ * tablespaces that already exist in the cluster, keyed by object type.
+ */
+func GetExistingGlobals() (map[string]map[string]bool, error) {
+	type globalQuery struct {
+		objectType string
+		query      string
+	}
+	queries := []globalQuery{
+		{"ROLE", `SELECT quote_ident(rolname) FROM pg_roles`},
+		{"TABLESPACE", `SELECT quote_ident(spcname) FROM pg_tablespace`},
+	}
+	if !MustGetFlagBool(options.TARGET_POSTGRES) {
+		queries = append(queries, globalQuery{"RESOURCE QUEUE", `SELECT quote_ident(rsqname) FROM pg_resqueue`})
+		if connectionPool.Version.AtLeast("5") {
+			queries = append(queries, globalQuery{"RESOURCE GROUP", `SELECT quote_ident(rsgname) FROM pg_resgroup`})
+		}
+	}
+
+	existingGlobals := make(map[string]map[string]bool)
+	for _, query := range queries {
+		names := make([]string, 0)
+		err := connectionPool.Select(&names, query.query)
+		if err != nil {
+			return nil, err
+		}
+		existingGlobals[query.objectType] = make(map[string]bool)
+		for _, name := range names {
+			existingGlobals[query.objectType][name] = true
+		}
+	}
+	return existingGlobals, nil
+}
+
+/*
+ * Applies --on-global-conflict to the global metadata statements, so that a
+ * restore into a cluster that already has some of the global objects does not
+ * fail on creating them.
+ */
+func resolveGlobalConflicts(statements []toc.StatementWithType) []toc.StatementWithType {
+	action := MustGetFlagString(options.ON_GLOBAL_CONFLICT)
+	if action == GlobalConflictFail {
+		return statements
+	}
+	existingGlobals, err := GetExistingGlobals()
+	gplog.FatalOnError(err)
+	var resolvedStatements []toc.StatementWithType
+	if action == GlobalConflictSkip {
+		resolvedStatements = toc.RemoveExistingGlobals(statements, existingGlobals)
+	} else {
+		resolvedStatements = toc.AlterExistingGlobals(statements, existingGlobals)
+	}
+	if numSkipped := len(statements) - len(resolvedStatements); numSkipped > 0 {
+		gplog.Info("Skipping %d global metadata statements for objects that already exist", numSkipped)
+	}
+	return resolvedStatements
+}
+
 func TruncateTable(tableFQN string, whichConn int) error {
 	gplog.Verbose("Truncating table %s prior to restoring data", tableFQN)
 	_, err := connectionPool.Exec(`TRUNCATE `+tableFQN, whichConn)
@@ -1,6 +1,7 @@
 package restore_test
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -11,10 +12,12 @@ import (
 	"github.com/greenplum-db/gpbackup/history"
 	"github.com/greenplum-db/gpbackup/options"
 	"github.com/greenplum-db/gpbackup/restore"
+	"github.com/greenplum-db/gpbackup/storage"
 	"github.com/greenplum-db/gpbackup/testutils"
 	"github.com/greenplum-db/gpbackup/toc"
 	"github.com/greenplum-db/gpbackup/utils"
 	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -126,6 +129,134 @@ var _ = Describe("wrapper tests", func() {
 		})
 
 	})
+	Describe("object type filters", func() {
+		It("restores all object types by default", func() {
+			includeObjectTypes, excludeObjectTypes, ok := restore.GetObjectTypeFilters("SCHEMA")
+
+			Expect(ok).To(BeTrue())
+			Expect(includeObjectTypes).To(BeEmpty())
+			Expect(excludeObjectTypes).To(Equal([]string{"SCHEMA"}))
+			Expect(restore.IsObjectTypeRestored("TABLE")).To(BeTrue())
+		})
+		It("restores only the included object types that are not restored separately", func() {
+			_ = cmdFlags.Set(options.INCLUDE_OBJECT_TYPE, "VIEW")
+			_ = cmdFlags.Set(options.INCLUDE_OBJECT_TYPE, "SCHEMA")
+
+			includeObjectTypes, _, ok := restore.GetObjectTypeFilters("SCHEMA")
+
+			Expect(ok).To(BeTrue())
+			Expect(includeObjectTypes).To(Equal([]string{"VIEW"}))
+			Expect(restore.IsObjectTypeRestored("SCHEMA")).To(BeTrue())
+			Expect(restore.IsObjectTypeRestored("TABLE")).To(BeFalse())
+		})
+		It("restores nothing else if only object types that are restored separately are included", func() {
+			_ = cmdFlags.Set(options.INCLUDE_OBJECT_TYPE, "SCHEMA")
+
+			_, _, ok := restore.GetObjectTypeFilters("SCHEMA")
+
+			Expect(ok).To(BeFalse())
+		})
+		It("adds the object types that are restored separately to the excluded object types", func() {
+			_ = cmdFlags.Set(options.EXCLUDE_OBJECT_TYPE, "FUNCTION")
+
+			includeObjectTypes, excludeObjectTypes, ok := restore.GetObjectTypeFilters("SCHEMA")
+
+			Expect(ok).To(BeTrue())
+			Expect(includeObjectTypes).To(BeEmpty())
+			Expect(excludeObjectTypes).To(Equal([]string{"FUNCTION", "SCHEMA"}))
+			Expect(restore.IsObjectTypeRestored("FUNCTION")).To(BeFalse())
+			Expect(restore.IsObjectTypeRestored("SCHEMA")).To(BeTrue())
+		})
+	})
+	Describe("GetExistingGlobals", func() {
+		It("returns the existing global objects by type", func() {
+			mock.ExpectQuery("SELECT quote_ident\\(rolname\\) FROM pg_roles").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("gpadmin").AddRow(`"Role1"`))
+			mock.ExpectQuery("SELECT quote_ident\\(spcname\\) FROM pg_tablespace").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("pg_default"))
+			mock.ExpectQuery("SELECT quote_ident\\(rsqname\\) FROM pg_resqueue").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("pg_default"))
+			mock.ExpectQuery("SELECT quote_ident\\(rsgname\\) FROM pg_resgroup").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("admin_group"))
+
+			existingGlobals, err := restore.GetExistingGlobals()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(existingGlobals).To(Equal(map[string]map[string]bool{
+				"ROLE":           {"gpadmin": true, `"Role1"`: true},
+				"TABLESPACE":     {"pg_default": true},
+				"RESOURCE QUEUE": {"pg_default": true},
+				"RESOURCE GROUP": {"admin_group": true},
+			}))
+		})
+		It("does not query resource queues or groups when restoring to PostgreSQL", func() {
+			_ = cmdFlags.Set(options.TARGET_POSTGRES, "true")
+			mock.ExpectQuery("SELECT quote_ident\\(rolname\\) FROM pg_roles").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("postgres"))
+			mock.ExpectQuery("SELECT quote_ident\\(spcname\\) FROM pg_tablespace").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("pg_default"))
+
+			existingGlobals, err := restore.GetExistingGlobals()
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(existingGlobals).To(HaveLen(2))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+	Describe("streamed metadata files", func() {
+		var backendDir string
+		var localDir string
+		var metadataFilename string
+		var tocFilename string
+		metadataContents := "CREATE SCHEMA schema1;\nCREATE SCHEMA schema2;\n"
+
+		BeforeEach(func() {
+			var err error
+			backendDir, err = ioutil.TempDir("", "backend")
+			Expect(err).ToNot(HaveOccurred())
+			localDir, err = ioutil.TempDir("", "local")
+			Expect(err).ToNot(HaveOccurred())
+			metadataFilename = filepath.Join(localDir, "gpbackup_20170101010101_metadata.sql")
+			tocFilename = filepath.Join(localDir, "gpbackup_20170101010101_toc.yaml")
+
+			backupTOC := &toc.TOC{}
+			backupTOC.InitializeMetadataEntryMap()
+			backupTOC.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema1", Name: "schema1", ObjectType: "SCHEMA"}, 0, 23)
+			backupTOC.AddMetadataEntry("predata", toc.MetadataEntry{Schema: "schema2", Name: "schema2", ObjectType: "SCHEMA"}, 23, 46)
+			tocContents, err := yaml.Marshal(backupTOC)
+			Expect(err).ToNot(HaveOccurred())
+
+			// The files are only written to the backend, not to their local paths
+			backend, err := storage.NewDirectoryBackend(map[string]string{"directory": backendDir})
+			Expect(err).ToNot(HaveOccurred())
+			for filename, contents := range map[string]string{metadataFilename: metadataContents, tocFilename: string(tocContents)} {
+				err = os.MkdirAll(filepath.Dir(filepath.Join(backendDir, filename)), 0755)
+				Expect(err).ToNot(HaveOccurred())
+				err = ioutil.WriteFile(filepath.Join(backendDir, filename), []byte(contents), 0644)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			restore.SetStorageBackend(backend)
+			restore.SetStreamMetadata(true)
+		})
+		AfterEach(func() {
+			restore.SetStorageBackend(nil)
+			restore.SetStreamMetadata(false)
+			_ = os.RemoveAll(backendDir)
+			_ = os.RemoveAll(localDir)
+		})
+		It("reads a TOC from the backend", func() {
+			backupTOC := restore.ReadTOC(tocFilename)
+			Expect(backupTOC.PredataEntries).To(HaveLen(2))
+			Expect(backupTOC.PredataEntries[1].Name).To(Equal("schema2"))
+		})
+		It("reads metadata statements from the backend", func() {
+			backupTOC := restore.ReadTOC(tocFilename)
+			backupTOC.InitializeMetadataEntryMap()
+			restore.SetTOC(backupTOC)
+			statements := restore.GetRestoreMetadataStatements("predata", metadataFilename, []string{}, []string{})
+			Expect(statements).To(HaveLen(2))
+			Expect(statements[0].Statement).To(Equal("CREATE SCHEMA schema1;\n"))
+			Expect(statements[1].Statement).To(Equal("CREATE SCHEMA schema2;\n"))
+			Expect(metadataFilename).ToNot(BeAnExistingFile())
+		})
+		It("panics when a metadata file is missing from the backend", func() {
+			Expect(func() { restore.ReadMetadataFile(filepath.Join(localDir, "missing.yaml")) }).To(Panic())
+		})
+	})
 	Describe("restore history tests", func() {
 		sampleConfigContents := `
 executablepath: /bin/echo
@@ -285,6 +416,14 @@ withstatistics: false
 			err = ioutil.WriteFile(configPath, []byte(sampleBackupConfig), 0777)
 			Expect(err).ToNot(HaveOccurred())
 
+			// The plugin is mocked, so write the TOC files it would have restored
+			for _, timestamp := range []string{"20180415154238", "20170415154408"} {
+				tocDir := filepath.Join(mdd, "backups", timestamp[0:8], timestamp)
+				_ = os.MkdirAll(tocDir, 0777)
+				err = ioutil.WriteFile(filepath.Join(tocDir, fmt.Sprintf("gpbackup_%s_toc.yaml", timestamp)), []byte("dataentries: []\n"), 0777)
+				Expect(err).ToNot(HaveOccurred())
+			}
+
 			restore.SetVersion("1.11.0+dev.28.g10571fd")
 		})
 		AfterEach(func() {
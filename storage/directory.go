@@ -0,0 +1,106 @@
+package storage
+
+/*
+ * This file contains the built-in StorageBackend that stores backup files
+ * under a directory, such as an NFS mount shared by all hosts.
+ */
+
+import (
+	"io"
+	"os"
+	path "path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+type DirectoryBackend struct {
+	Directory string
+}
+
+func NewDirectoryBackend(options map[string]string) (*DirectoryBackend, error) {
+	directory := options["directory"]
+	if directory == "" {
+		return nil, errors.New("The directory option is required for the directory backend")
+	}
+	err := utils.ValidateFullPath(directory)
+	if err != nil {
+		return nil, err
+	}
+	return &DirectoryBackend{Directory: path.Clean(directory)}, nil
+}
+
+// Files are stored under the backend directory at their full local path
+func (backend *DirectoryBackend) storagePath(filename string) string {
+	return path.Join(backend.Directory, filename)
+}
+
+func (backend *DirectoryBackend) PutFile(filename string) error {
+	return putFileWithWriter(backend, filename)
+}
+
+func (backend *DirectoryBackend) GetFile(filename string) error {
+	return getFileWithReader(backend, filename)
+}
+
+func (backend *DirectoryBackend) OpenWriter(filename string) (io.WriteCloser, error) {
+	storagePath := backend.storagePath(filename)
+	err := os.MkdirAll(path.Dir(storagePath), 0755)
+	if err != nil {
+		return nil, err
+	}
+	return os.Create(storagePath)
+}
+
+func (backend *DirectoryBackend) OpenReader(filename string) (io.ReadCloser, error) {
+	return os.Open(backend.storagePath(filename))
+}
+
+func (backend *DirectoryBackend) OpenRangeReader(filename string, offset int64) (io.ReadCloser, error) {
+	file, err := os.Open(backend.storagePath(filename))
+	if err != nil {
+		return nil, err
+	}
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+func (backend *DirectoryBackend) Delete(filename string) error {
+	return os.Remove(backend.storagePath(filename))
+}
+
+func (backend *DirectoryBackend) List(prefix string) ([]string, error) {
+	filenames := make([]string, 0)
+	// Only the directory containing the prefix can hold matching files
+	root := backend.storagePath(prefix)
+	if !strings.HasSuffix(prefix, "/") {
+		root = path.Dir(root)
+	}
+	err := path.Walk(root, func(storagePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		filename := strings.TrimPrefix(storagePath, backend.Directory)
+		if strings.HasPrefix(filename, prefix) {
+			filenames = append(filenames, filename)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(filenames)
+	return filenames, nil
+}
@@ -0,0 +1,87 @@
+package storage_test
+
+import (
+	"io/ioutil"
+	"os"
+	path "path/filepath"
+
+	"github.com/greenplum-db/gpbackup/storage"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("storage/directory tests", func() {
+	var (
+		backend   *storage.DirectoryBackend
+		localDir  string
+		localFile string
+	)
+	BeforeEach(func() {
+		var err error
+		backend, err = storage.NewDirectoryBackend(map[string]string{"directory": path.Join(tempDir, "store")})
+		Expect(err).ToNot(HaveOccurred())
+		localDir = path.Join(tempDir, "local", "backups", "20170101", "20170101010101")
+		localFile = path.Join(localDir, "gpbackup_20170101010101_metadata.sql")
+		Expect(os.MkdirAll(localDir, 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(localFile, []byte("CREATE TABLE foo (i int);"), 0644)).To(Succeed())
+	})
+	Describe("NewDirectoryBackend", func() {
+		It("requires a directory", func() {
+			_, err := storage.NewDirectoryBackend(map[string]string{})
+			Expect(err).To(MatchError("The directory option is required for the directory backend"))
+		})
+		It("requires an absolute directory", func() {
+			_, err := storage.NewDirectoryBackend(map[string]string{"directory": "relative/path"})
+			Expect(err).To(MatchError("relative/path is not an absolute path."))
+		})
+	})
+	It("stores a file under its full path and retrieves it", func() {
+		Expect(backend.PutFile(localFile)).To(Succeed())
+		Expect(path.Join(tempDir, "store", localFile)).To(BeAnExistingFile())
+
+		Expect(os.RemoveAll(path.Join(tempDir, "local"))).To(Succeed())
+		Expect(backend.GetFile(localFile)).To(Succeed())
+		contents, err := ioutil.ReadFile(localFile)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(contents)).To(Equal("CREATE TABLE foo (i int);"))
+	})
+	It("streams data through a writer and a reader", func() {
+		filename := path.Join(localDir, "gpbackup_0_20170101010101_3456")
+		writer, err := backend.OpenWriter(filename)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = writer.Write([]byte("1,2\n3,4\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+
+		reader, err := backend.OpenReader(filename)
+		Expect(err).ToNot(HaveOccurred())
+		contents, err := ioutil.ReadAll(reader)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reader.Close()).To(Succeed())
+		Expect(string(contents)).To(Equal("1,2\n3,4\n"))
+	})
+	It("lists and deletes files", func() {
+		otherFile := path.Join(localDir, "gpbackup_20170101010101_toc.yaml")
+		Expect(ioutil.WriteFile(otherFile, []byte("toc"), 0644)).To(Succeed())
+		Expect(backend.PutFile(localFile)).To(Succeed())
+		Expect(backend.PutFile(otherFile)).To(Succeed())
+
+		filenames, err := backend.List(localDir + "/")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(filenames).To(Equal([]string{localFile, otherFile}))
+		filenames, err = backend.List(path.Join(localDir, "gpbackup_20170101010101_toc"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(filenames).To(Equal([]string{otherFile}))
+
+		Expect(backend.Delete(localFile)).To(Succeed())
+		filenames, err = backend.List(localDir + "/")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(filenames).To(Equal([]string{otherFile}))
+	})
+	It("lists no files under a prefix that does not exist", func() {
+		filenames, err := backend.List("/does/not/exist/")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(filenames).To(BeEmpty())
+	})
+})
@@ -0,0 +1,145 @@
+package storage
+
+/*
+ * This file contains the StorageBackend adapter for executable plugins, which
+ * are driven through the existing plugin command protocol.
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+type PluginBackend struct {
+	Config *utils.PluginConfig
+}
+
+func NewPluginBackend(config *utils.PluginConfig) *PluginBackend {
+	return &PluginBackend{Config: config}
+}
+
+func (backend *PluginBackend) PutFile(filename string) error {
+	return backend.Config.BackupFile(filename)
+}
+
+func (backend *PluginBackend) GetFile(filename string) error {
+	return backend.Config.RestoreFile(filename)
+}
+
+func (backend *PluginBackend) OpenWriter(filename string) (io.WriteCloser, error) {
+	cmd := exec.Command("bash", "-c", fmt.Sprintf("%s %s", backend.Config.BackupDataCommand(), filename))
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	writer := &commandWriter{WriteCloser: stdin, cmd: cmd, filename: filename}
+	cmd.Stderr = &writer.stderr
+	gplog.Debug("%s", strings.Join(cmd.Args, " "))
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (backend *PluginBackend) OpenReader(filename string) (io.ReadCloser, error) {
+	return backend.startReadCommand(fmt.Sprintf("%s %s", backend.Config.RestoreDataCommand(), filename), filename)
+}
+
+/*
+ * Reads only the byte ranges of filename listed in offsetsFile, using the
+ * restore_data_subset command supported by some plugins.
+ */
+func (backend *PluginBackend) OpenSubsetReader(filename string, offsetsFile string) (io.ReadCloser, error) {
+	command := fmt.Sprintf("%s restore_data_subset %s %s %s", backend.Config.ExecutablePath, backend.Config.ConfigPath, filename, offsetsFile)
+	return backend.startReadCommand(command, filename)
+}
+
+func (backend *PluginBackend) startReadCommand(command string, filename string) (io.ReadCloser, error) {
+	cmd := exec.Command("bash", "-c", command)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	reader := &commandReader{ReadCloser: stdout, cmd: cmd, filename: filename}
+	cmd.Stderr = &reader.stderr
+	gplog.Debug("%s", command)
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+func (backend *PluginBackend) Delete(filename string) error {
+	return errors.Errorf("Plugin %s does not support deleting individual files", backend.Config.ExecutablePath)
+}
+
+func (backend *PluginBackend) List(prefix string) ([]string, error) {
+	return nil, errors.Errorf("Plugin %s does not support listing files", backend.Config.ExecutablePath)
+}
+
+type commandWriter struct {
+	io.WriteCloser
+	cmd      *exec.Cmd
+	stderr   bytes.Buffer
+	filename string
+}
+
+// Closing the writer waits for the plugin to finish storing the data
+func (writer *commandWriter) Close() error {
+	_ = writer.WriteCloser.Close()
+	err := writer.cmd.Wait()
+	if err != nil {
+		return errors.Wrapf(err, "ERROR: Plugin failed to process %s. %s", writer.filename, strings.Trim(writer.stderr.String(), "\x00"))
+	}
+	return nil
+}
+
+type commandReader struct {
+	io.ReadCloser
+	cmd      *exec.Cmd
+	stderr   bytes.Buffer
+	filename string
+}
+
+/*
+ * Read errors are reported along with the plugin's output, as a plugin that
+ * fails usually just closes its output early.
+ */
+func (reader *commandReader) Read(p []byte) (int, error) {
+	n, err := reader.ReadCloser.Read(p)
+	if err == io.EOF {
+		waitErr := reader.wait()
+		if waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+func (reader *commandReader) Close() error {
+	_ = reader.ReadCloser.Close()
+	return reader.wait()
+}
+
+func (reader *commandReader) wait() error {
+	if reader.cmd.ProcessState != nil {
+		if !reader.cmd.ProcessState.Success() {
+			return errors.Errorf("ERROR: Plugin failed to process %s. %s", reader.filename, strings.Trim(reader.stderr.String(), "\x00"))
+		}
+		return nil
+	}
+	err := reader.cmd.Wait()
+	if err != nil {
+		return errors.Wrapf(err, "ERROR: Plugin failed to process %s. %s", reader.filename, strings.Trim(reader.stderr.String(), "\x00"))
+	}
+	return nil
+}
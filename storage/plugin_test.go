@@ -0,0 +1,84 @@
+package storage_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	path "path/filepath"
+
+	"github.com/greenplum-db/gpbackup/storage"
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+/*
+ * The fake plugin stores files under a directory named in its config file,
+ * implementing just enough of the plugin protocol for these tests.
+ */
+const fakePluginScript = `#!/bin/bash
+store=$(cat "$2")
+case "$1" in
+	backup_file) mkdir -p "$store$(dirname "$3")" && cp "$3" "$store$3" ;;
+	restore_file) cp "$store$3" "$3" ;;
+	backup_data) mkdir -p "$store$(dirname "$3")" && cat - > "$store$3" ;;
+	restore_data) cat "$store$3" ;;
+	*) echo "unsupported command $1" >&2; exit 1 ;;
+esac
+`
+
+var _ = Describe("storage/plugin tests", func() {
+	var (
+		backend  *storage.PluginBackend
+		storeDir string
+	)
+	BeforeEach(func() {
+		storeDir = path.Join(tempDir, "store")
+		pluginPath := path.Join(tempDir, "fake_plugin")
+		configPath := path.Join(tempDir, "plugin_config")
+		Expect(ioutil.WriteFile(pluginPath, []byte(fakePluginScript), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(configPath, []byte(storeDir), 0644)).To(Succeed())
+		backend = storage.NewPluginBackend(&utils.PluginConfig{ExecutablePath: pluginPath, ConfigPath: configPath})
+	})
+	It("stores and retrieves files with backup_file and restore_file", func() {
+		filename := path.Join(tempDir, "gpbackup_20170101010101_metadata.sql")
+		Expect(ioutil.WriteFile(filename, []byte("metadata"), 0644)).To(Succeed())
+		Expect(backend.PutFile(filename)).To(Succeed())
+		Expect(os.Remove(filename)).To(Succeed())
+
+		Expect(backend.GetFile(filename)).To(Succeed())
+		contents, err := ioutil.ReadFile(filename)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(contents)).To(Equal("metadata"))
+	})
+	It("streams data with backup_data and restore_data", func() {
+		filename := path.Join(tempDir, "gpbackup_0_20170101010101_pipe_3456")
+		writer, err := backend.OpenWriter(filename)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = fmt.Fprint(writer, "1,2\n3,4\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+		Expect(path.Join(storeDir, filename)).To(BeAnExistingFile())
+
+		reader, err := backend.OpenReader(filename)
+		Expect(err).ToNot(HaveOccurred())
+		contents, err := ioutil.ReadAll(reader)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reader.Close()).To(Succeed())
+		Expect(string(contents)).To(Equal("1,2\n3,4\n"))
+	})
+	It("returns the plugin output when restore_data fails", func() {
+		reader, err := backend.OpenReader(path.Join(tempDir, "missing"))
+		Expect(err).ToNot(HaveOccurred())
+		_, err = ioutil.ReadAll(reader)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Plugin failed to process"))
+		Expect(err.Error()).To(ContainSubstring("No such file or directory"))
+	})
+	It("does not support deleting or listing files", func() {
+		Expect(backend.Delete("/tmp/file")).ToNot(Succeed())
+		_, err := backend.List("/tmp/")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,119 @@
+package storage
+
+/*
+ * This file contains functions for reading backup files directly from a
+ * backend, without first copying them to local disk.
+ */
+
+import (
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Implemented by backends that can start reading a file partway through
+type RangeReader interface {
+	// Returns a reader for the contents of filename from offset to the end
+	OpenRangeReader(filename string, offset int64) (io.ReadCloser, error)
+}
+
+func ReadFile(backend StorageBackend, filename string) ([]byte, error) {
+	reader, err := backend.OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := ioutil.ReadAll(reader)
+	closeErr := reader.Close()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read file %s", filename)
+	}
+	return contents, closeErr
+}
+
+/*
+ * Reads within skipThreshold bytes ahead of the current position are served
+ * by reading on through the stream, while reads further ahead use a ranged
+ * read if the backend supports one.
+ */
+const skipThreshold = 1024 * 1024
+
+/*
+ * ReaderAt streams a file from a backend to serve reads at arbitrary offsets.
+ * Reads are expected to be mostly in increasing order of offset, as when
+ * reading the statements of a section of the metadata file in TOC order, so
+ * each read continues the current stream where possible.  A read behind the
+ * current position reopens the file, using a ranged read if supported.
+ */
+type ReaderAt struct {
+	backend  StorageBackend
+	filename string
+	reader   io.ReadCloser
+	offset   int64
+	mutex    sync.Mutex
+}
+
+func NewReaderAt(backend StorageBackend, filename string) *ReaderAt {
+	return &ReaderAt{backend: backend, filename: filename}
+}
+
+func (readerAt *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	readerAt.mutex.Lock()
+	defer readerAt.mutex.Unlock()
+	_, canReadRange := readerAt.backend.(RangeReader)
+	if readerAt.reader == nil || off < readerAt.offset || (canReadRange && off-readerAt.offset > skipThreshold) {
+		err := readerAt.open(off)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if off > readerAt.offset {
+		skipped, err := io.CopyN(ioutil.Discard, readerAt.reader, off-readerAt.offset)
+		readerAt.offset += skipped
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := io.ReadFull(readerAt.reader, p)
+	readerAt.offset += int64(n)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (readerAt *ReaderAt) open(off int64) error {
+	readerAt.closeReader()
+	var err error
+	if rangeReader, ok := readerAt.backend.(RangeReader); ok {
+		readerAt.reader, err = rangeReader.OpenRangeReader(readerAt.filename, off)
+		readerAt.offset = off
+	} else {
+		readerAt.reader, err = readerAt.backend.OpenReader(readerAt.filename)
+		readerAt.offset = 0
+	}
+	if err != nil {
+		readerAt.reader = nil
+	}
+	return err
+}
+
+/*
+ * A stream abandoned before its end may report an error when closed, such as
+ * a plugin exiting on a broken pipe, so errors on closing are ignored.  Any
+ * error reading the data is returned by ReadAt.
+ */
+func (readerAt *ReaderAt) closeReader() {
+	if readerAt.reader != nil {
+		_ = readerAt.reader.Close()
+		readerAt.reader = nil
+	}
+}
+
+func (readerAt *ReaderAt) Close() error {
+	readerAt.mutex.Lock()
+	defer readerAt.mutex.Unlock()
+	readerAt.closeReader()
+	return nil
+}
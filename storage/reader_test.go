@@ -0,0 +1,88 @@
+package storage_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	path "path/filepath"
+
+	"github.com/greenplum-db/gpbackup/storage"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// Hides the ranged reads of the wrapped backend, and counts the files it opens
+type streamingBackend struct {
+	storage.StorageBackend
+	numOpened int
+}
+
+func (backend *streamingBackend) OpenReader(filename string) (io.ReadCloser, error) {
+	backend.numOpened++
+	return backend.StorageBackend.OpenReader(filename)
+}
+
+var _ = Describe("storage/reader tests", func() {
+	const filename = "/data/gpbackup_20170101010101_metadata.sql"
+	var directoryBackend *storage.DirectoryBackend
+
+	BeforeEach(func() {
+		directoryBackend = &storage.DirectoryBackend{Directory: tempDir}
+		Expect(os.MkdirAll(path.Join(tempDir, "data"), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(path.Join(tempDir, filename), []byte("0123456789abcdefghij"), 0644)).To(Succeed())
+	})
+	readAt := func(readerAt io.ReaderAt, offset int64, length int) string {
+		contents := make([]byte, length)
+		_, err := readerAt.ReadAt(contents, offset)
+		Expect(err).ToNot(HaveOccurred())
+		return string(contents)
+	}
+
+	Describe("ReadFile", func() {
+		It("returns the contents of the file", func() {
+			contents, err := storage.ReadFile(directoryBackend, filename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("0123456789abcdefghij"))
+		})
+		It("returns an error when the file does not exist", func() {
+			_, err := storage.ReadFile(directoryBackend, "/data/missing")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Describe("ReaderAt", func() {
+		It("reads forward through a single stream", func() {
+			backend := &streamingBackend{StorageBackend: directoryBackend}
+			readerAt := storage.NewReaderAt(backend, filename)
+			Expect(readAt(readerAt, 0, 3)).To(Equal("012"))
+			Expect(readAt(readerAt, 5, 5)).To(Equal("56789"))
+			Expect(readAt(readerAt, 10, 10)).To(Equal("abcdefghij"))
+			Expect(readerAt.Close()).To(Succeed())
+			Expect(backend.numOpened).To(Equal(1))
+		})
+		It("reopens the file to read behind the current position", func() {
+			backend := &streamingBackend{StorageBackend: directoryBackend}
+			readerAt := storage.NewReaderAt(backend, filename)
+			Expect(readAt(readerAt, 10, 3)).To(Equal("abc"))
+			Expect(readAt(readerAt, 2, 3)).To(Equal("234"))
+			Expect(backend.numOpened).To(Equal(2))
+		})
+		It("uses ranged reads when the backend supports them", func() {
+			readerAt := storage.NewReaderAt(directoryBackend, filename)
+			Expect(readAt(readerAt, 15, 5)).To(Equal("fghij"))
+			Expect(readAt(readerAt, 1, 2)).To(Equal("12"))
+		})
+		It("returns EOF when reading past the end of the file", func() {
+			readerAt := storage.NewReaderAt(directoryBackend, filename)
+			contents := make([]byte, 10)
+			n, err := readerAt.ReadAt(contents, 15)
+			Expect(err).To(Equal(io.EOF))
+			Expect(string(contents[:n])).To(Equal("fghij"))
+		})
+		It("returns an error when the file does not exist", func() {
+			readerAt := storage.NewReaderAt(directoryBackend, "/data/missing")
+			_, err := readerAt.ReadAt(make([]byte, 1), 0)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,426 @@
+package storage
+
+/*
+ * This file contains the built-in StorageBackend for S3-compatible object
+ * stores.  Requests are signed with AWS Signature Version 4 and use path-style
+ * addressing, which S3-compatible stores such as MinIO also accept.
+ */
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultS3Region   = "us-east-1"
+	DefaultS3PartSize = 16 * 1024 * 1024
+	// S3 rejects multipart upload parts smaller than this, except for the last
+	MinimumS3PartSize = 5 * 1024 * 1024
+	emptyPayloadHash  = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+type S3Backend struct {
+	Endpoint        string
+	Bucket          string
+	Region          string
+	Folder          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PartSize        int
+	Client          *http.Client
+}
+
+func NewS3Backend(options map[string]string) (*S3Backend, error) {
+	backend := &S3Backend{
+		Endpoint:        strings.TrimSuffix(options["endpoint"], "/"),
+		Bucket:          options["bucket"],
+		Region:          options["region"],
+		Folder:          strings.Trim(options["folder"], "/"),
+		AccessKeyID:     options["aws_access_key_id"],
+		SecretAccessKey: options["aws_secret_access_key"],
+		PartSize:        DefaultS3PartSize,
+		Client:          http.DefaultClient,
+	}
+	if backend.Endpoint == "" {
+		return nil, errors.New("The endpoint option is required for the s3 backend")
+	}
+	if _, err := url.Parse(backend.Endpoint); err != nil {
+		return nil, errors.Errorf("Invalid s3 endpoint %s: %v", backend.Endpoint, err)
+	}
+	if backend.Bucket == "" {
+		return nil, errors.New("The bucket option is required for the s3 backend")
+	}
+	if backend.Region == "" {
+		backend.Region = DefaultS3Region
+	}
+	if (backend.AccessKeyID == "") != (backend.SecretAccessKey == "") {
+		return nil, errors.New("The aws_access_key_id and aws_secret_access_key options must be used together")
+	}
+	if partSizeStr, ok := options["part_size_mb"]; ok {
+		partSizeMB, err := strconv.Atoi(partSizeStr)
+		if err != nil || partSizeMB*1024*1024 < MinimumS3PartSize {
+			return nil, errors.Errorf("Invalid part_size_mb %s; the minimum part size is 5 MB", partSizeStr)
+		}
+		backend.PartSize = partSizeMB * 1024 * 1024
+	}
+	return backend, nil
+}
+
+/*
+ * Object keys are the file's local path, without the leading slash, under
+ * the configured folder.
+ */
+func (backend *S3Backend) objectKey(filename string) string {
+	key := strings.TrimPrefix(filename, "/")
+	if backend.Folder != "" {
+		key = backend.Folder + "/" + key
+	}
+	return key
+}
+
+func (backend *S3Backend) filenameForKey(key string) string {
+	if backend.Folder != "" {
+		key = strings.TrimPrefix(key, backend.Folder+"/")
+	}
+	return "/" + key
+}
+
+func (backend *S3Backend) PutFile(filename string) error {
+	return putFileWithWriter(backend, filename)
+}
+
+func (backend *S3Backend) GetFile(filename string) error {
+	return getFileWithReader(backend, filename)
+}
+
+func (backend *S3Backend) OpenWriter(filename string) (io.WriteCloser, error) {
+	return &s3Writer{backend: backend, key: backend.objectKey(filename)}, nil
+}
+
+func (backend *S3Backend) OpenReader(filename string) (io.ReadCloser, error) {
+	resp, err := backend.do("GET", backend.objectKey(filename), nil, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (backend *S3Backend) OpenRangeReader(filename string, offset int64) (io.ReadCloser, error) {
+	header := http.Header{"Range": {fmt.Sprintf("bytes=%d-", offset)}}
+	resp, err := backend.doWithHeader("GET", backend.objectKey(filename), nil, header, nil, http.StatusPartialContent)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (backend *S3Backend) Delete(filename string) error {
+	resp, err := backend.do("DELETE", backend.objectKey(filename), nil, nil, http.StatusNoContent, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (backend *S3Backend) List(prefix string) ([]string, error) {
+	filenames := make([]string, 0)
+	query := url.Values{"list-type": {"2"}, "prefix": {backend.objectKey(prefix)}}
+	for {
+		resp, err := backend.do("GET", "", query, nil, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		result := listBucketResult{}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to parse s3 object listing")
+		}
+		for _, object := range result.Contents {
+			filenames = append(filenames, backend.filenameForKey(object.Key))
+		}
+		if !result.IsTruncated {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+	sort.Strings(filenames)
+	return filenames, nil
+}
+
+/*
+ * Sends a request for the given object key, or for the bucket if the key is
+ * empty, and returns an error unless the response has one of the expected
+ * status codes.  The caller must close the body of a successful response.
+ */
+func (backend *S3Backend) do(method string, key string, query url.Values, body []byte, expectedStatus ...int) (*http.Response, error) {
+	return backend.doWithHeader(method, key, query, nil, body, expectedStatus...)
+}
+
+// Headers set here, such as Range, are signed along with the request
+func (backend *S3Backend) doWithHeader(method string, key string, query url.Values, header http.Header, body []byte, expectedStatus ...int) (*http.Response, error) {
+	requestURL := fmt.Sprintf("%s/%s", backend.Endpoint, uriEncode(backend.Bucket, false))
+	if key != "" {
+		requestURL += "/" + uriEncode(key, false)
+	}
+	if len(query) > 0 {
+		requestURL += "?" + canonicalQueryString(query)
+	}
+	var bodyReader io.Reader
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+		payloadHash = hashHex(body)
+	}
+	req, err := http.NewRequest(method, requestURL, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	backend.SignRequest(req, payloadHash, time.Now())
+	resp, err := backend.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range expectedStatus {
+		if resp.StatusCode == status {
+			return resp, nil
+		}
+	}
+	message, _ := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	return nil, errors.Errorf("S3 request %s %s failed with status %s: %s", method, req.URL.Path, resp.Status, strings.TrimSpace(string(message)))
+}
+
+/*
+ * Adds AWS Signature Version 4 headers to the request, signing the host
+ * header and any headers already set on the request.  Requests are sent
+ * unsigned if no credentials are configured.
+ */
+func (backend *S3Backend) SignRequest(req *http.Request, payloadHash string, signTime time.Time) {
+	amzDate := signTime.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if backend.AccessKeyID == "" {
+		return
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	headerNames := make([]string, 0, len(headers))
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+	canonicalHeaders := ""
+	for _, name := range headerNames {
+		canonicalHeaders += fmt.Sprintf("%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalURI := req.URL.EscapedPath()
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+	canonicalRequest := strings.Join([]string{req.Method, canonicalURI, canonicalQueryString(req.URL.Query()),
+		canonicalHeaders, signedHeaders, payloadHash}, "\n")
+
+	date := amzDate[:8]
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, backend.Region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+	signingKey := hmacSHA256([]byte("AWS4"+backend.SecretAccessKey), date)
+	for _, part := range []string{backend.Region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		backend.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// Percent-encodes all bytes except unreserved characters, as SigV4 requires
+func uriEncode(str string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(str) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '~' || (b == '/' && !encodeSlash) {
+			encoded.WriteByte(b)
+		} else {
+			encoded.WriteString(fmt.Sprintf("%%%02X", b))
+		}
+	}
+	return encoded.String()
+}
+
+func canonicalQueryString(query url.Values) string {
+	params := make([]string, 0)
+	for key, values := range query {
+		for _, value := range values {
+			params = append(params, fmt.Sprintf("%s=%s", uriEncode(key, true), uriEncode(value, true)))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+/*
+ * Data is buffered into parts of PartSize bytes and sent as a multipart
+ * upload, so that files of any size can be streamed without knowing their
+ * length in advance.  Files smaller than one part are sent in a single PUT.
+ */
+type s3Writer struct {
+	backend  *S3Backend
+	key      string
+	buffer   bytes.Buffer
+	uploadID string
+	parts    []completedPart
+	err      error
+}
+
+type completedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+func (writer *s3Writer) Write(p []byte) (int, error) {
+	if writer.err != nil {
+		return 0, writer.err
+	}
+	n, _ := writer.buffer.Write(p)
+	for writer.buffer.Len() >= writer.backend.PartSize {
+		writer.err = writer.uploadPart(writer.buffer.Next(writer.backend.PartSize))
+		if writer.err != nil {
+			writer.abort()
+			return n, writer.err
+		}
+	}
+	return n, nil
+}
+
+func (writer *s3Writer) Close() error {
+	if writer.err != nil {
+		return writer.err
+	}
+	if writer.uploadID == "" {
+		resp, err := writer.backend.do("PUT", writer.key, nil, writer.buffer.Bytes(), http.StatusOK)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	err := writer.uploadPart(writer.buffer.Bytes())
+	if err == nil {
+		err = writer.completeUpload()
+	}
+	if err != nil {
+		writer.abort()
+	}
+	return err
+}
+
+func (writer *s3Writer) uploadPart(data []byte) error {
+	if writer.uploadID == "" {
+		err := writer.initiateUpload()
+		if err != nil {
+			return err
+		}
+	}
+	partNumber := len(writer.parts) + 1
+	query := url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {writer.uploadID}}
+	resp, err := writer.backend.do("PUT", writer.key, query, data, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	writer.parts = append(writer.parts, completedPart{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+	return nil
+}
+
+func (writer *s3Writer) initiateUpload() error {
+	resp, err := writer.backend.do("POST", writer.key, url.Values{"uploads": {""}}, nil, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	result := struct {
+		UploadId string
+	}{}
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+	if err != nil || result.UploadId == "" {
+		return errors.Errorf("Unable to start multipart upload of %s", writer.key)
+	}
+	writer.uploadID = result.UploadId
+	return nil
+}
+
+func (writer *s3Writer) completeUpload() error {
+	request := struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: writer.parts}
+	body, err := xml.Marshal(request)
+	if err != nil {
+		return err
+	}
+	resp, err := writer.backend.do("POST", writer.key, url.Values{"uploadId": {writer.uploadID}}, body, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	// S3 can report a failed completion in the body of a successful response
+	defer resp.Body.Close()
+	message, _ := ioutil.ReadAll(resp.Body)
+	if strings.Contains(string(message), "<Error>") {
+		return errors.Errorf("Unable to complete multipart upload of %s: %s", writer.key, message)
+	}
+	return nil
+}
+
+func (writer *s3Writer) abort() {
+	if writer.uploadID == "" {
+		return
+	}
+	resp, err := writer.backend.do("DELETE", writer.key, url.Values{"uploadId": {writer.uploadID}}, nil, http.StatusNoContent, http.StatusOK)
+	if err == nil {
+		_ = resp.Body.Close()
+	}
+}
@@ -0,0 +1,107 @@
+package storage
+
+/*
+ * This file contains the StorageBackend interface through which backup files
+ * are moved to and from their final destination, and functions shared by the
+ * backend implementations.
+ */
+
+import (
+	"io"
+	"os"
+	path "path/filepath"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+/*
+ * Files are identified by the same absolute paths that gpbackup uses for them
+ * locally, which is also how the plugin protocol identifies them, so a file
+ * put from a path can be got back to that same path.
+ */
+type StorageBackend interface {
+	// Copies the local file at filename to the backend
+	PutFile(filename string) error
+	// Copies the file from the backend to the local path filename
+	GetFile(filename string) error
+	// The returned writer's Close must be checked, as it finishes the upload
+	OpenWriter(filename string) (io.WriteCloser, error)
+	OpenReader(filename string) (io.ReadCloser, error)
+	Delete(filename string) error
+	// Returns the names of all files whose names start with prefix
+	List(prefix string) ([]string, error)
+}
+
+func NewStorageBackend(config *utils.PluginConfig) (StorageBackend, error) {
+	switch config.Backend {
+	case "":
+		return NewPluginBackend(config), nil
+	case utils.DIRECTORY_BACKEND:
+		return NewDirectoryBackend(config.Options)
+	case utils.S3_BACKEND:
+		return NewS3Backend(config.Options)
+	}
+	return nil, errors.Errorf("Unrecognized storage backend %s", config.Backend)
+}
+
+func MustNewStorageBackend(config *utils.PluginConfig) StorageBackend {
+	backend, err := NewStorageBackend(config)
+	gplog.FatalOnError(err)
+	return backend
+}
+
+func MustPutFile(backend StorageBackend, filename string) {
+	err := backend.PutFile(filename)
+	gplog.FatalOnError(err)
+}
+
+func MustGetFile(backend StorageBackend, filename string) {
+	err := backend.GetFile(filename)
+	gplog.FatalOnError(err)
+}
+
+/*
+ * PutFile and GetFile for backends that can stream files are built on
+ * OpenWriter and OpenReader.
+ */
+func putFileWithWriter(backend StorageBackend, filename string) error {
+	reader, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	writer, err := backend.OpenWriter(filename)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, reader)
+	closeErr := writer.Close()
+	if err != nil {
+		return errors.Wrapf(err, "Unable to store file %s", filename)
+	}
+	return closeErr
+}
+
+func getFileWithReader(backend StorageBackend, filename string) error {
+	reader, err := backend.OpenReader(filename)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	err = os.MkdirAll(path.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+	writer, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, reader)
+	closeErr := writer.Close()
+	if err != nil {
+		return errors.Wrapf(err, "Unable to retrieve file %s", filename)
+	}
+	return closeErr
+}
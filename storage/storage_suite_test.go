@@ -0,0 +1,30 @@
+package storage_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestStorage(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "storage tests")
+}
+
+var tempDir string
+
+var _ = BeforeEach(func() {
+	_, _, _ = testhelper.SetupTestLogger()
+	var err error
+	tempDir, err = ioutil.TempDir("", "storage_test")
+	Expect(err).ToNot(HaveOccurred())
+})
+
+var _ = AfterEach(func() {
+	_ = os.RemoveAll(tempDir)
+})
@@ -0,0 +1,37 @@
+package storage_test
+
+import (
+	"github.com/greenplum-db/gpbackup/storage"
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("storage/storage tests", func() {
+	Describe("NewStorageBackend", func() {
+		It("returns a plugin backend for a config with an executable", func() {
+			config := &utils.PluginConfig{ExecutablePath: "/usr/local/bin/plugin", ConfigPath: "/tmp/config.yaml"}
+			backend, err := storage.NewStorageBackend(config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(backend).To(Equal(&storage.PluginBackend{Config: config}))
+		})
+		It("returns a directory backend", func() {
+			config := &utils.PluginConfig{Backend: "directory", Options: map[string]string{"directory": "/mnt/backups"}}
+			backend, err := storage.NewStorageBackend(config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(backend).To(Equal(&storage.DirectoryBackend{Directory: "/mnt/backups"}))
+		})
+		It("returns an s3 backend", func() {
+			config := &utils.PluginConfig{Backend: "s3", Options: map[string]string{"endpoint": "http://localhost:9000", "bucket": "backups"}}
+			backend, err := storage.NewStorageBackend(config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(backend).To(BeAssignableToTypeOf(&storage.S3Backend{}))
+		})
+		It("returns an error for an unrecognized backend", func() {
+			config := &utils.PluginConfig{Backend: "tape"}
+			_, err := storage.NewStorageBackend(config)
+			Expect(err).To(MatchError("Unrecognized storage backend tape"))
+		})
+	})
+})
@@ -5,9 +5,11 @@ import (
 	"io"
 	"io/ioutil"
 	"regexp"
+	"strings"
 
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
 	"github.com/greenplum-db/gpbackup/utils"
+	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 )
 
@@ -41,11 +43,16 @@ type MasterDataEntry struct {
 	AttributeString string
 	RowsCopied      int64
 	PartitionRoot   string
+	Fingerprint     string
+	DataStream      int `yaml:",omitempty"`
 }
 
 type SegmentDataEntry struct {
 	StartByte uint64
 	EndByte   uint64
+	// The byte range of the table's compressed frames, in compressed data files
+	CompressedStartByte uint64 `yaml:",omitempty"`
+	CompressedEndByte   uint64 `yaml:",omitempty"`
 }
 
 type IncrementalEntries struct {
@@ -58,10 +65,14 @@ type AOEntry struct {
 }
 
 func NewTOC(filename string) *TOC {
-	toc := &TOC{}
 	contents, err := ioutil.ReadFile(filename)
 	gplog.FatalOnError(err)
-	err = yaml.Unmarshal(contents, toc)
+	return ParseTOC(contents)
+}
+
+func ParseTOC(contents []byte) *TOC {
+	toc := &TOC{}
+	err := yaml.Unmarshal(contents, toc)
 	gplog.FatalOnError(err)
 	return toc
 }
@@ -98,6 +109,27 @@ type StatementWithType struct {
 	Statement       string
 }
 
+// The object types of the entries in each metadata section of the TOC
+var (
+	GlobalObjectTypes = []string{"SESSION GUCS", "DATABASE GUC", "DATABASE", "DATABASE METADATA", "RESOURCE QUEUE", "RESOURCE GROUP",
+		"ROLE", "ROLE GUCS", "ROLE GRANT", "TABLESPACE"}
+	PredataObjectTypes = []string{"AGGREGATE", "CAST", "COLLATION", "CONSTRAINT", "CONVERSION", "DOMAIN", "EXCHANGE PARTITION", "EXTENSION",
+		"FOREIGN DATA WRAPPER", "FOREIGN SERVER", "FOREIGN TABLE", "FUNCTION", "LANGUAGE", "MATERIALIZED VIEW", "OPERATOR",
+		"OPERATOR CLASS", "OPERATOR FAMILY", "PROTOCOL", "SCHEMA", "SEQUENCE", "SEQUENCE OWNER", "TABLE", "TEXT SEARCH CONFIGURATION",
+		"TEXT SEARCH DICTIONARY", "TEXT SEARCH PARSER", "TEXT SEARCH TEMPLATE", "TYPE", "USER MAPPING", "VIEW"}
+	PostdataObjectTypes   = []string{"DEFAULT PRIVILEGES", "EVENT TRIGGER", "INDEX", "RULE", "TRIGGER"}
+	StatisticsObjectTypes = []string{"STATISTICS", "EXTENDED STATISTICS"}
+)
+
+func ValidateObjectTypes(objectTypes []string, validObjectTypes []string) error {
+	for _, objectType := range objectTypes {
+		if !utils.Exists(validObjectTypes, objectType) {
+			return errors.Errorf("Invalid object type %s.  Valid object types are: %s", objectType, strings.Join(validObjectTypes, ", "))
+		}
+	}
+	return nil
+}
+
 func GetIncludedPartitionRoots(tocDataEntries []MasterDataEntry, includeRelations []string) []string {
 	if len(includeRelations) == 0 {
 		return []string{}
@@ -160,8 +192,8 @@ func shouldIncludeStatement(entry MetadataEntry, objectSet *utils.FilterSet, sch
 	shouldIncludeObject := objectSet.MatchesFilter(entry.ObjectType)
 	shouldIncludeSchema := schemaSet.MatchesFilter(entry.Schema)
 	relationFQN := utils.MakeFQN(entry.Schema, entry.Name)
-	shouldIncludeRelation := (relationSet.IsExclude && entry.ObjectType != "TABLE" && entry.ObjectType != "VIEW" && entry.ObjectType != "MATERIALIZED VIEW" && entry.ObjectType != "SEQUENCE" && entry.ObjectType != "STATISTICS" && entry.ReferenceObject == "") ||
-		((entry.ObjectType == "TABLE" || entry.ObjectType == "VIEW" || entry.ObjectType == "MATERIALIZED VIEW" || entry.ObjectType == "SEQUENCE" || entry.ObjectType == "STATISTICS") && relationSet.MatchesFilter(relationFQN) && entry.ReferenceObject == "") || // Relations should match the filter
+	shouldIncludeRelation := (relationSet.IsExclude && entry.ObjectType != "TABLE" && entry.ObjectType != "VIEW" && entry.ObjectType != "MATERIALIZED VIEW" && entry.ObjectType != "SEQUENCE" && entry.ObjectType != "STATISTICS" && entry.ObjectType != "EXTENDED STATISTICS" && entry.ReferenceObject == "") ||
+		((entry.ObjectType == "TABLE" || entry.ObjectType == "VIEW" || entry.ObjectType == "MATERIALIZED VIEW" || entry.ObjectType == "SEQUENCE" || entry.ObjectType == "STATISTICS" || entry.ObjectType == "EXTENDED STATISTICS") && relationSet.MatchesFilter(relationFQN) && entry.ReferenceObject == "") || // Relations should match the filter
 		(entry.ObjectType != "SEQUENCE OWNER" && entry.ReferenceObject != "" && relationSet.MatchesFilter(entry.ReferenceObject)) || // Include relations that filtered tables depend on
 		(entry.ObjectType == "SEQUENCE OWNER" && relationSet.MatchesFilter(relationFQN) && relationSet.MatchesFilter(entry.ReferenceObject)) //Include sequence owners if both table and sequence are being restored
 
@@ -231,6 +263,28 @@ func SubstituteRedirectDatabaseInStatements(statements []StatementWithType, oldQ
 	return statements
 }
 
+func SubstituteRedirectSchemaInStatements(statements []StatementWithType, redirectSchema string) []StatementWithType {
+	if redirectSchema == "" {
+		return statements
+	}
+
+	for i, statement := range statements {
+		oldSchema := fmt.Sprintf("%s.", statement.Schema)
+		newSchema := fmt.Sprintf("%s.", redirectSchema)
+		statements[i].Schema = redirectSchema
+		statements[i].Statement = strings.Replace(statement.Statement, oldSchema, newSchema, 1)
+		if statement.ObjectType == "EXTENDED STATISTICS" {
+			// An extended statistics object is named before the table it is on
+			statements[i].Statement = strings.Replace(statement.Statement, oldSchema, newSchema, -1)
+		}
+		// only postdata will have a reference object
+		if statement.ReferenceObject != "" {
+			statements[i].ReferenceObject = strings.Replace(statement.ReferenceObject, oldSchema, newSchema, 1)
+		}
+	}
+	return statements
+}
+
 func RemoveActiveRole(activeUser string, statements []StatementWithType) []StatementWithType {
 	newStatements := make([]StatementWithType, 0)
 	for _, statement := range statements {
@@ -242,6 +296,184 @@ func RemoveActiveRole(activeUser string, statements []StatementWithType) []State
 	return newStatements
 }
 
+// The global object types that can already exist in a cluster, mapped to the type of the object they belong to
+var existingGlobalTypes = map[string]string{
+	"RESOURCE QUEUE": "RESOURCE QUEUE",
+	"RESOURCE GROUP": "RESOURCE GROUP",
+	"ROLE":           "ROLE",
+	"ROLE GUCS":      "ROLE",
+	"TABLESPACE":     "TABLESPACE",
+}
+
+/*
+ * Removes every statement for a global object that already exists, so that
+ * existing objects are left as they are.  existingGlobals maps each object
+ * type to the quoted names of the existing objects of that type.
+ */
+func RemoveExistingGlobals(statements []StatementWithType, existingGlobals map[string]map[string]bool) []StatementWithType {
+	newStatements := make([]StatementWithType, 0)
+	for _, statement := range statements {
+		objectType, ok := existingGlobalTypes[statement.ObjectType]
+		if ok && existingGlobals[objectType][statement.Name] {
+			continue
+		}
+		newStatements = append(newStatements, statement)
+	}
+	return newStatements
+}
+
+/*
+ * Changes the statements for global objects that already exist to alter the
+ * existing objects rather than create them.  Roles and resource queues are
+ * given the attributes they had when backed up; resource groups and
+ * tablespaces cannot be altered to match, so they are not created, but their
+ * comments, owners, and privileges are still restored.
+ */
+func AlterExistingGlobals(statements []StatementWithType, existingGlobals map[string]map[string]bool) []StatementWithType {
+	newStatements := make([]StatementWithType, 0)
+	for _, statement := range statements {
+		if !existingGlobals[statement.ObjectType][statement.Name] {
+			newStatements = append(newStatements, statement)
+			continue
+		}
+		createStatement := fmt.Sprintf("CREATE %s %s", statement.ObjectType, statement.Name)
+		switch statement.ObjectType {
+		case "ROLE":
+			// CREATE ROLE is followed by an ALTER ROLE that sets the attributes of the role
+			statement.Statement = strings.Replace(statement.Statement, createStatement+";\n", "", 1)
+		case "RESOURCE QUEUE":
+			statement.Statement = strings.Replace(statement.Statement, createStatement+" ", fmt.Sprintf("ALTER %s %s ", statement.ObjectType, statement.Name), 1)
+		default:
+			if strings.Contains(statement.Statement, createStatement+" ") {
+				continue
+			}
+		}
+		newStatements = append(newStatements, statement)
+	}
+	return newStatements
+}
+
+// A role name as printed by quote_ident
+const roleIdentifierPattern = `("(?:[^"]|"")+"|[^\s";]+)`
+
+var (
+	ownerStatementRegex      = regexp.MustCompile(`^(ALTER .+ OWNER TO )` + roleIdentifierPattern + `(;)$`)
+	privilegeStatementRegex  = regexp.MustCompile(`^((?:ALTER DEFAULT PRIVILEGES .*)?(?:GRANT|REVOKE) .+ (?:TO|FROM) )` + roleIdentifierPattern + `((?: WITH GRANT OPTION)?;)$`)
+	defaultPrivilegeForRegex = regexp.MustCompile(`^(ALTER DEFAULT PRIVILEGES FOR ROLE )` + roleIdentifierPattern + `( .*)$`)
+	roleGUCStatementRegex    = regexp.MustCompile(`^(ALTER ROLE )` + roleIdentifierPattern + `( .*)$`)
+	roleMembershipRegex      = regexp.MustCompile(`^GRANT ` + roleIdentifierPattern + ` TO ` + roleIdentifierPattern + `( WITH ADMIN OPTION)?(?: GRANTED BY ` + roleIdentifierPattern + `)?;$`)
+)
+
+/*
+ * Rewrites the roles in ownership, privilege, default privilege, role GUC, and
+ * role membership statements.  roleMapping maps quoted role names to the
+ * quoted names of the roles replacing them, or to an empty string to drop the
+ * statements for a role.  With removeOwners or removePrivileges, ownership or
+ * object privilege statements are dropped altogether; role memberships are
+ * not object privileges, so they are kept.
+ *
+ * A mapped role is replaced by a role that is expected to exist already, so
+ * the statements that create and alter the mapped role itself are dropped.  A
+ * role membership is dropped if either role is dropped, and a dropped grantor
+ * is left out, so that the restoring user is recorded as the grantor instead.
+ *
+ * Only statements starting with ALTER, GRANT, or REVOKE are rewritten, one
+ * line at a time, so that function bodies and other object definitions are
+ * never changed.  Statements left with no lines are dropped.
+ */
+func RemapRolesInStatements(statements []StatementWithType, roleMapping map[string]string, removeOwners bool, removePrivileges bool) []StatementWithType {
+	// Returns the line with its role replaced, or false if the line is to be dropped
+	remapRole := func(line string, regex *regexp.Regexp) (string, bool) {
+		match := regex.FindStringSubmatch(line)
+		newRole, ok := roleMapping[match[2]]
+		if !ok {
+			return line, true
+		}
+		if newRole == "" {
+			return "", false
+		}
+		return match[1] + newRole + match[3], true
+	}
+	// Returns the role to use in place of role, or false if the role is dropped
+	mapRole := func(role string) (string, bool) {
+		newRole, ok := roleMapping[role]
+		if !ok {
+			return role, true
+		}
+		return newRole, newRole != ""
+	}
+	remapRoleMembership := func(line string) (string, bool) {
+		match := roleMembershipRegex.FindStringSubmatch(line)
+		role, roleKept := mapRole(match[1])
+		member, memberKept := mapRole(match[2])
+		if !roleKept || !memberKept {
+			return "", false
+		}
+		newLine := fmt.Sprintf("GRANT %s TO %s%s", role, member, match[3])
+		if grantor, grantorKept := mapRole(match[4]); match[4] != "" && grantorKept {
+			newLine += fmt.Sprintf(" GRANTED BY %s", grantor)
+		}
+		return newLine + ";", true
+	}
+
+	newStatements := make([]StatementWithType, 0)
+	for _, statement := range statements {
+		if _, ok := roleMapping[statement.Name]; ok && statement.ObjectType == "ROLE" {
+			continue
+		}
+		trimmed := strings.TrimSpace(statement.Statement)
+		if !(strings.HasPrefix(trimmed, "ALTER ") || strings.HasPrefix(trimmed, "GRANT ") || strings.HasPrefix(trimmed, "REVOKE ")) {
+			newStatements = append(newStatements, statement)
+			continue
+		}
+
+		lines := strings.Split(statement.Statement, "\n")
+		newLines := make([]string, 0, len(lines))
+		hasStatement := false
+		for _, line := range lines {
+			keep := true
+			switch {
+			case statement.ObjectType == "ROLE GRANT":
+				if roleMembershipRegex.MatchString(line) {
+					line, keep = remapRoleMembership(line)
+				}
+			case statement.ObjectType == "ROLE GUCS" && roleGUCStatementRegex.MatchString(line):
+				if newRole := roleMapping[roleGUCStatementRegex.FindStringSubmatch(line)[2]]; newRole != "" {
+					statement.Name = newRole
+				}
+				line, keep = remapRole(line, roleGUCStatementRegex)
+			case ownerStatementRegex.MatchString(line):
+				if removeOwners {
+					keep = false
+				} else {
+					line, keep = remapRole(line, ownerStatementRegex)
+				}
+			case privilegeStatementRegex.MatchString(line):
+				if removePrivileges {
+					keep = false
+					break
+				}
+				line, keep = remapRole(line, privilegeStatementRegex)
+				if keep && defaultPrivilegeForRegex.MatchString(line) {
+					line, keep = remapRole(line, defaultPrivilegeForRegex)
+				}
+			}
+			if !keep {
+				continue
+			}
+			if strings.TrimSpace(line) != "" {
+				hasStatement = true
+			}
+			newLines = append(newLines, line)
+		}
+		if hasStatement {
+			statement.Statement = strings.Join(newLines, "\n")
+			newStatements = append(newStatements, statement)
+		}
+	}
+	return newStatements
+}
+
 func (toc *TOC) InitializeMetadataEntryMap() {
 	toc.metadataEntryMap = make(map[string]*[]MetadataEntry, 4)
 	toc.metadataEntryMap["global"] = &toc.GlobalEntries
@@ -265,11 +497,44 @@ func (toc *TOC) AddMetadataEntry(section string, entry MetadataEntry, start, end
 	*toc.metadataEntryMap[section] = append(*toc.metadataEntryMap[section], entry)
 }
 
-func (toc *TOC) AddMasterDataEntry(schema string, name string, oid uint32, attributeString string, rowsCopied int64, PartitionRoot string) {
-	toc.DataEntries = append(toc.DataEntries, MasterDataEntry{schema, name, oid, attributeString, rowsCopied, PartitionRoot})
+func (toc *TOC) AddMasterDataEntry(schema string, name string, oid uint32, attributeString string, rowsCopied int64, PartitionRoot string, fingerprint string, dataStream int) {
+	toc.DataEntries = append(toc.DataEntries, MasterDataEntry{schema, name, oid, attributeString, rowsCopied, PartitionRoot, fingerprint, dataStream})
+}
+
+// The number of data streams of a single data file backup, which is 1 for older backups
+func (toc *TOC) NumDataStreams() int {
+	numStreams := 1
+	for _, entry := range toc.DataEntries {
+		if entry.DataStream >= numStreams {
+			numStreams = entry.DataStream + 1
+		}
+	}
+	return numStreams
 }
 
 func (toc *SegmentTOC) AddSegmentDataEntry(oid uint, startByte uint64, endByte uint64) {
 	// We use uint for oid since the flags package does not have a uint32 flag
-	toc.DataEntries[oid] = SegmentDataEntry{startByte, endByte}
+	toc.DataEntries[oid] = SegmentDataEntry{StartByte: startByte, EndByte: endByte}
+}
+
+func (toc *SegmentTOC) AddCompressedSegmentDataEntry(oid uint, startByte uint64, endByte uint64, compressedStartByte uint64, compressedEndByte uint64) {
+	toc.DataEntries[oid] = SegmentDataEntry{StartByte: startByte, EndByte: endByte,
+		CompressedStartByte: compressedStartByte, CompressedEndByte: compressedEndByte}
+}
+
+/*
+ * Compressed data files written by older versions of gpbackup_helper are a
+ * single compressed stream, and so have no frame offsets.  A frame is never
+ * empty, so a compressed end byte of 0 means the entry has no frames.
+ */
+func (toc *SegmentTOC) HasCompressedFrames() bool {
+	if len(toc.DataEntries) == 0 {
+		return false
+	}
+	for _, entry := range toc.DataEntries {
+		if entry.CompressedEndByte == 0 {
+			return false
+		}
+	}
+	return true
 }
@@ -123,6 +123,19 @@ var _ = Describe("utils/toc tests", func() {
 
 			Expect(statements).To(Equal([]toc.StatementWithType{tableCaps}))
 		})
+		It("returns statistics and extended statistics statements of an included table", func() {
+			tableStats := toc.StatementWithType{Schema: "schema", Name: "table1", ObjectType: "STATISTICS", Statement: "UPDATE pg_class"}
+			extStats := toc.StatementWithType{Schema: "schema", Name: "table1", ObjectType: "EXTENDED STATISTICS", Statement: "CREATE STATISTICS"}
+			otherStats := toc.StatementWithType{Schema: "schema2", Name: "table2", ObjectType: "EXTENDED STATISTICS", Statement: "DROP STATISTICS"}
+			tocfile.AddMetadataEntry("statistics", toc.MetadataEntry{Schema: "schema", Name: "table1", ObjectType: "STATISTICS"}, 0, 15)
+			tocfile.AddMetadataEntry("statistics", toc.MetadataEntry{Schema: "schema", Name: "table1", ObjectType: "EXTENDED STATISTICS"}, 15, 32)
+			tocfile.AddMetadataEntry("statistics", toc.MetadataEntry{Schema: "schema2", Name: "table2", ObjectType: "EXTENDED STATISTICS"}, 32, 47)
+			statisticsFile := bytes.NewReader([]byte(tableStats.Statement + extStats.Statement + otherStats.Statement))
+
+			statements := tocfile.GetSQLStatementForObjectTypes("statistics", statisticsFile, noInObj, noExObj, noInSchema, noExSchema, []string{"schema.table1"}, noExRelation)
+
+			Expect(statements).To(Equal([]toc.StatementWithType{tableStats, extStats}))
+		})
 		It("returns statement for a view matching an included view", func() {
 			statements := tocfile.GetSQLStatementForObjectTypes("predata", metadataFile, noInObj, noExObj, noInSchema, noExSchema, []string{"schema.view"}, noExRelation)
 
@@ -195,11 +208,11 @@ var _ = Describe("utils/toc tests", func() {
 	})
 	Describe("GetDataEntriesMatching", func() {
 		BeforeEach(func() {
-			tocfile.AddMasterDataEntry("schema1", "table1", 1, "(i)", 0, "")
-			tocfile.AddMasterDataEntry("schema2", "table2", 1, "(i)", 0, "")
-			tocfile.AddMasterDataEntry("schema3", "table3", 1, "(i)", 0, "")
-			tocfile.AddMasterDataEntry("schema3", "table3_partition1", 1, "(i)", 0, "table3")
-			tocfile.AddMasterDataEntry("schema3", "table3_partition2", 1, "(i)", 0, "table3")
+			tocfile.AddMasterDataEntry("schema1", "table1", 1, "(i)", 0, "", "", 0)
+			tocfile.AddMasterDataEntry("schema2", "table2", 1, "(i)", 0, "", "", 0)
+			tocfile.AddMasterDataEntry("schema3", "table3", 1, "(i)", 0, "", "", 0)
+			tocfile.AddMasterDataEntry("schema3", "table3_partition1", 1, "(i)", 0, "table3", "", 0)
+			tocfile.AddMasterDataEntry("schema3", "table3_partition2", 1, "(i)", 0, "table3", "", 0)
 		})
 		Context("Non-empty restore plan", func() {
 			restorePlanTableFQNs := []string{"schema1.table1", "schema2.table2", "schema3.table3", "schema3.table3_partition1", "schema3.table3_partition2"}
@@ -398,24 +411,202 @@ COMMENT ON DATABASE "db-special-chär$" IS 'this is a database comment';`}
 			Expect(resultStatements).To(Equal([]toc.StatementWithType{user1, user2}))
 		})
 	})
+	Describe("existing global objects", func() {
+		createRole := toc.StatementWithType{Name: "role1", ObjectType: "ROLE", Statement: "\n\nCREATE ROLE role1;\nALTER ROLE role1 WITH NOSUPERUSER LOGIN;"}
+		commentRole := toc.StatementWithType{Name: "role1", ObjectType: "ROLE", Statement: "\n\nCOMMENT ON ROLE role1 IS 'a role';"}
+		roleGUC := toc.StatementWithType{Name: "role1", ObjectType: "ROLE GUCS", Statement: "\n\nALTER ROLE role1 SET search_path TO public;"}
+		roleGrant := toc.StatementWithType{Name: "role1", ObjectType: "ROLE GRANT", Statement: "\n\nGRANT group1 TO role1;"}
+		createNewRole := toc.StatementWithType{Name: "role2", ObjectType: "ROLE", Statement: "\n\nCREATE ROLE role2;\nALTER ROLE role2 WITH NOSUPERUSER LOGIN;"}
+		createQueue := toc.StatementWithType{Name: "queue1", ObjectType: "RESOURCE QUEUE", Statement: "\n\nCREATE RESOURCE QUEUE queue1 WITH (ACTIVE_STATEMENTS=5);"}
+		createGroup := toc.StatementWithType{Name: "group1", ObjectType: "RESOURCE GROUP", Statement: "\n\nCREATE RESOURCE GROUP group1 WITH (CPU_RATE_LIMIT=10, MEMORY_LIMIT=20);"}
+		alterGroup := toc.StatementWithType{Name: "admin_group", ObjectType: "RESOURCE GROUP", Statement: "\n\nALTER RESOURCE GROUP admin_group SET CONCURRENCY 10;"}
+		createTablespace := toc.StatementWithType{Name: "space1", ObjectType: "TABLESPACE", Statement: "\n\nCREATE TABLESPACE space1 LOCATION '/data/space1';"}
+		ownerTablespace := toc.StatementWithType{Name: "space1", ObjectType: "TABLESPACE", Statement: "\n\nALTER TABLESPACE space1 OWNER TO role1;"}
+		statements := []toc.StatementWithType{createQueue, createGroup, alterGroup, createRole, commentRole, createNewRole, roleGrant, createTablespace, ownerTablespace, roleGUC}
+		existingGlobals := map[string]map[string]bool{
+			"ROLE":           {"role1": true},
+			"RESOURCE QUEUE": {"queue1": true},
+			"RESOURCE GROUP": {"group1": true, "admin_group": true},
+			"TABLESPACE":     {"space1": true},
+		}
+		Describe("RemoveExistingGlobals", func() {
+			It("removes every statement for existing objects and keeps role grants", func() {
+				resultStatements := toc.RemoveExistingGlobals(statements, existingGlobals)
+
+				Expect(resultStatements).To(Equal([]toc.StatementWithType{createNewRole, roleGrant}))
+			})
+			It("returns the same list if no objects exist", func() {
+				resultStatements := toc.RemoveExistingGlobals(statements, map[string]map[string]bool{})
+
+				Expect(resultStatements).To(Equal(statements))
+			})
+		})
+		Describe("AlterExistingGlobals", func() {
+			It("alters existing roles and resource queues and does not create other existing objects", func() {
+				resultStatements := toc.AlterExistingGlobals(statements, existingGlobals)
+
+				alterRole := createRole
+				alterRole.Statement = "\n\nALTER ROLE role1 WITH NOSUPERUSER LOGIN;"
+				alterQueue := createQueue
+				alterQueue.Statement = "\n\nALTER RESOURCE QUEUE queue1 WITH (ACTIVE_STATEMENTS=5);"
+				Expect(resultStatements).To(Equal([]toc.StatementWithType{alterQueue, alterGroup, alterRole, commentRole, createNewRole, roleGrant, ownerTablespace, roleGUC}))
+			})
+			It("returns the same list if no objects exist", func() {
+				resultStatements := toc.AlterExistingGlobals(statements, map[string]map[string]bool{})
+
+				Expect(resultStatements).To(Equal(statements))
+			})
+		})
+	})
+	Describe("RemapRolesInStatements", func() {
+		tableOwner := toc.StatementWithType{Schema: "public", Name: "foo", ObjectType: "TABLE", Statement: "\n\nALTER TABLE public.foo OWNER TO etl_owner;\n"}
+		tablePrivileges := toc.StatementWithType{Schema: "public", Name: "foo", ObjectType: "TABLE", Statement: "\n\nREVOKE ALL ON TABLE public.foo FROM PUBLIC;\nREVOKE ALL ON TABLE public.foo FROM etl_owner;\nGRANT ALL ON TABLE public.foo TO etl_owner;\nGRANT SELECT ON TABLE public.foo TO \"Report Users\" WITH GRANT OPTION;\nGRANT SELECT ON TABLE public.foo TO old_reporting;\n"}
+		defaultPrivileges := toc.StatementWithType{Schema: "public", Name: "", ObjectType: "DEFAULT PRIVILEGES", Statement: "\n\nALTER DEFAULT PRIVILEGES FOR ROLE etl_owner IN SCHEMA public REVOKE ALL ON TABLES FROM PUBLIC;\nALTER DEFAULT PRIVILEGES FOR ROLE etl_owner IN SCHEMA public GRANT SELECT ON TABLES TO old_reporting;\n"}
+		roleGUC := toc.StatementWithType{Name: "etl_owner", ObjectType: "ROLE GUCS", Statement: "\n\nALTER ROLE etl_owner SET search_path TO public;"}
+		function := toc.StatementWithType{Schema: "public", Name: "bar", ObjectType: "FUNCTION", Statement: "\n\nCREATE FUNCTION public.bar() RETURNS void AS $$\nALTER TABLE public.foo OWNER TO etl_owner;\n$$ LANGUAGE sql;\n"}
+		statements := []toc.StatementWithType{tableOwner, tablePrivileges, defaultPrivileges, roleGUC, function}
+		roleMapping := map[string]string{"etl_owner": "etl_admin", `"Report Users"`: "reporting", "old_reporting": ""}
+		It("replaces mapped roles and drops statements for dropped roles", func() {
+			resultStatements := toc.RemapRolesInStatements(statements, roleMapping, false, false)
+
+			newTableOwner := tableOwner
+			newTableOwner.Statement = "\n\nALTER TABLE public.foo OWNER TO etl_admin;\n"
+			newTablePrivileges := tablePrivileges
+			newTablePrivileges.Statement = "\n\nREVOKE ALL ON TABLE public.foo FROM PUBLIC;\nREVOKE ALL ON TABLE public.foo FROM etl_admin;\nGRANT ALL ON TABLE public.foo TO etl_admin;\nGRANT SELECT ON TABLE public.foo TO reporting WITH GRANT OPTION;\n"
+			newDefaultPrivileges := defaultPrivileges
+			newDefaultPrivileges.Statement = "\n\nALTER DEFAULT PRIVILEGES FOR ROLE etl_admin IN SCHEMA public REVOKE ALL ON TABLES FROM PUBLIC;\n"
+			newRoleGUC := roleGUC
+			newRoleGUC.Name = "etl_admin"
+			newRoleGUC.Statement = "\n\nALTER ROLE etl_admin SET search_path TO public;"
+			Expect(resultStatements).To(Equal([]toc.StatementWithType{newTableOwner, newTablePrivileges, newDefaultPrivileges, newRoleGUC, function}))
+		})
+		It("drops the statements of a role that is dropped", func() {
+			resultStatements := toc.RemapRolesInStatements(statements, map[string]string{"etl_owner": ""}, false, false)
+
+			newTablePrivileges := tablePrivileges
+			newTablePrivileges.Statement = "\n\nREVOKE ALL ON TABLE public.foo FROM PUBLIC;\nGRANT SELECT ON TABLE public.foo TO \"Report Users\" WITH GRANT OPTION;\nGRANT SELECT ON TABLE public.foo TO old_reporting;\n"
+			Expect(resultStatements).To(Equal([]toc.StatementWithType{newTablePrivileges, function}))
+		})
+		It("drops ownership statements when removing owners", func() {
+			resultStatements := toc.RemapRolesInStatements(statements, map[string]string{}, true, false)
+
+			Expect(resultStatements).To(Equal([]toc.StatementWithType{tablePrivileges, defaultPrivileges, roleGUC, function}))
+		})
+		It("drops privilege and default privilege statements when removing privileges", func() {
+			resultStatements := toc.RemapRolesInStatements(statements, map[string]string{}, false, true)
+
+			Expect(resultStatements).To(Equal([]toc.StatementWithType{tableOwner, roleGUC, function}))
+		})
+		It("returns the same list if no roles are mapped", func() {
+			resultStatements := toc.RemapRolesInStatements(statements, map[string]string{}, false, false)
+
+			Expect(resultStatements).To(Equal(statements))
+		})
+		Describe("global role statements", func() {
+			createRole := toc.StatementWithType{Name: "etl_owner", ObjectType: "ROLE", Statement: "\n\nCREATE ROLE etl_owner;\nALTER ROLE etl_owner WITH NOSUPERUSER LOGIN;"}
+			commentRole := toc.StatementWithType{Name: "etl_owner", ObjectType: "ROLE", Statement: "\n\nCOMMENT ON ROLE etl_owner IS 'ETL jobs';"}
+			createOtherRole := toc.StatementWithType{Name: "loader", ObjectType: "ROLE", Statement: "\n\nCREATE ROLE loader;\nALTER ROLE loader WITH NOSUPERUSER LOGIN;"}
+			membership := toc.StatementWithType{Name: "loader", ObjectType: "ROLE GRANT", Statement: "\nGRANT etl_owner TO loader WITH ADMIN OPTION GRANTED BY \"Report Users\";"}
+			plainMembership := toc.StatementWithType{Name: "etl_owner", ObjectType: "ROLE GRANT", Statement: "\nGRANT loader TO etl_owner;"}
+			roleStatements := []toc.StatementWithType{createRole, commentRole, createOtherRole, membership, plainMembership}
+			It("drops the statements that create a mapped role and remaps both roles and the grantor of memberships", func() {
+				resultStatements := toc.RemapRolesInStatements(roleStatements, roleMapping, false, false)
+
+				newMembership := membership
+				newMembership.Statement = "\nGRANT etl_admin TO loader WITH ADMIN OPTION GRANTED BY reporting;"
+				newPlainMembership := plainMembership
+				newPlainMembership.Statement = "\nGRANT loader TO etl_admin;"
+				Expect(resultStatements).To(Equal([]toc.StatementWithType{createOtherRole, newMembership, newPlainMembership}))
+			})
+			It("drops a membership if either role is dropped", func() {
+				resultStatements := toc.RemapRolesInStatements(roleStatements, map[string]string{"loader": ""}, false, false)
+
+				Expect(resultStatements).To(Equal([]toc.StatementWithType{createRole, commentRole}))
+			})
+			It("leaves out a dropped grantor", func() {
+				resultStatements := toc.RemapRolesInStatements(roleStatements, map[string]string{`"Report Users"`: ""}, false, false)
+
+				newMembership := membership
+				newMembership.Statement = "\nGRANT etl_owner TO loader WITH ADMIN OPTION;"
+				Expect(resultStatements).To(Equal([]toc.StatementWithType{createRole, commentRole, createOtherRole, newMembership, plainMembership}))
+			})
+			It("keeps memberships when removing owners and privileges", func() {
+				resultStatements := toc.RemapRolesInStatements(roleStatements, map[string]string{}, true, true)
+
+				Expect(resultStatements).To(Equal(roleStatements))
+			})
+		})
+	})
+	Describe("ValidateObjectTypes", func() {
+		It("accepts object types in the list of valid object types", func() {
+			err := toc.ValidateObjectTypes([]string{"VIEW", "FUNCTION"}, toc.PredataObjectTypes)
+
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("returns an error for an object type that is not in the list", func() {
+			err := toc.ValidateObjectTypes([]string{"VIEW", "INDEX"}, []string{"TABLE", "VIEW"})
+
+			Expect(err).To(MatchError("Invalid object type INDEX.  Valid object types are: TABLE, VIEW"))
+		})
+		It("is case sensitive", func() {
+			err := toc.ValidateObjectTypes([]string{"view"}, toc.PredataObjectTypes)
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Describe("NumDataStreams", func() {
+		It("returns 1 for a backup without data streams", func() {
+			tocfile.AddMasterDataEntry("schema", "table1", 1, "", 0, "", "", 0)
+
+			Expect(tocfile.NumDataStreams()).To(Equal(1))
+		})
+		It("returns the number of data streams used by the data entries", func() {
+			tocfile.AddMasterDataEntry("schema", "table1", 1, "", 0, "", "", 0)
+			tocfile.AddMasterDataEntry("schema", "table2", 2, "", 0, "", "", 2)
+			tocfile.AddMasterDataEntry("schema", "table3", 3, "", 0, "", "", 1)
+
+			Expect(tocfile.NumDataStreams()).To(Equal(3))
+		})
+	})
+	Describe("HasCompressedFrames", func() {
+		var segmentTOC *toc.SegmentTOC
+		BeforeEach(func() {
+			segmentTOC = &toc.SegmentTOC{DataEntries: make(map[uint]toc.SegmentDataEntry)}
+		})
+		It("returns true if every entry has compressed frame offsets", func() {
+			segmentTOC.AddCompressedSegmentDataEntry(1, 0, 18, 0, 38)
+			segmentTOC.AddCompressedSegmentDataEntry(2, 18, 36, 38, 76)
+
+			Expect(segmentTOC.HasCompressedFrames()).To(BeTrue())
+		})
+		It("returns false if the entries have no compressed frame offsets", func() {
+			segmentTOC.AddSegmentDataEntry(1, 0, 18)
+			segmentTOC.AddSegmentDataEntry(2, 18, 36)
+
+			Expect(segmentTOC.HasCompressedFrames()).To(BeFalse())
+		})
+		It("returns false if there are no entries", func() {
+			Expect(segmentTOC.HasCompressedFrames()).To(BeFalse())
+		})
+	})
 	Describe("GetIncludedPartitionRoots", func() {
 		It("does not return anything if relations are not leaf partitions", func() {
-			tocfile.AddMasterDataEntry("schema0", "name0", 0, "attribute0", 1, "")
-			tocfile.AddMasterDataEntry("schema1", "name1", 1, "attribute0", 1, "")
+			tocfile.AddMasterDataEntry("schema0", "name0", 0, "attribute0", 1, "", "", 0)
+			tocfile.AddMasterDataEntry("schema1", "name1", 1, "attribute0", 1, "", "", 0)
 			roots := toc.GetIncludedPartitionRoots(tocfile.DataEntries, []string{"schema0.name0", "schema1.name1"})
 			Expect(roots).To(BeEmpty())
 		})
 		It("returns root parition of leaf partitions", func() {
-			tocfile.AddMasterDataEntry("schema0", "name0", 2, "attribute0", 1, "root0")
-			tocfile.AddMasterDataEntry("schema1", "name1", 3, "attribute0", 1, "root1")
+			tocfile.AddMasterDataEntry("schema0", "name0", 2, "attribute0", 1, "root0", "", 0)
+			tocfile.AddMasterDataEntry("schema1", "name1", 3, "attribute0", 1, "root1", "", 0)
 			roots := toc.GetIncludedPartitionRoots(tocfile.DataEntries, []string{"schema0.name0", "schema1.name1"})
 			Expect(roots).To(ConsistOf("schema0.root0", "schema1.root1"))
 		})
 		It("only returns root partitions of leaf partitions", func() {
-			tocfile.AddMasterDataEntry("schema0", "name0", 0, "attribute0", 1, "")
-			tocfile.AddMasterDataEntry("schema1", "name1", 1, "attribute0", 1, "")
-			tocfile.AddMasterDataEntry("schema2", "name2", 2, "attribute0", 1, "root2")
-			tocfile.AddMasterDataEntry("schema3", "name3", 3, "attribute0", 1, "root3")
+			tocfile.AddMasterDataEntry("schema0", "name0", 0, "attribute0", 1, "", "", 0)
+			tocfile.AddMasterDataEntry("schema1", "name1", 1, "attribute0", 1, "", "", 0)
+			tocfile.AddMasterDataEntry("schema2", "name2", 2, "attribute0", 1, "root2", "", 0)
+			tocfile.AddMasterDataEntry("schema3", "name3", 3, "attribute0", 1, "root3", "", 0)
 			roots := toc.GetIncludedPartitionRoots(tocfile.DataEntries, []string{"schema2.name2", "schema3.name3"})
 			Expect(roots).To(ConsistOf("schema2.root2", "schema3.root3"))
 		})
@@ -424,18 +615,18 @@ COMMENT ON DATABASE "db-special-chär$" IS 'this is a database comment';`}
 			Expect(roots).To(BeEmpty())
 		})
 		It("returns nothing if relation is not part of TOC data entries", func() {
-			tocfile.AddMasterDataEntry("schema0", "name0", 0, "attribute0", 1, "")
-			tocfile.AddMasterDataEntry("schema1", "name1", 1, "attribute0", 1, "")
-			tocfile.AddMasterDataEntry("schema2", "name2", 2, "attribute0", 1, "root2")
-			tocfile.AddMasterDataEntry("schema3", "name3", 3, "attribute0", 1, "root3")
+			tocfile.AddMasterDataEntry("schema0", "name0", 0, "attribute0", 1, "", "", 0)
+			tocfile.AddMasterDataEntry("schema1", "name1", 1, "attribute0", 1, "", "", 0)
+			tocfile.AddMasterDataEntry("schema2", "name2", 2, "attribute0", 1, "root2", "", 0)
+			tocfile.AddMasterDataEntry("schema3", "name3", 3, "attribute0", 1, "root3", "", 0)
 			roots := toc.GetIncludedPartitionRoots(tocfile.DataEntries, []string{"schema4.name4", "schema5.name5"})
 			Expect(roots).To(BeEmpty())
 		})
 		It("returns empty if no relations are passed in", func() {
-			tocfile.AddMasterDataEntry("schema0", "name0", 0, "attribute0", 1, "")
-			tocfile.AddMasterDataEntry("schema1", "name1", 1, "attribute0", 1, "")
-			tocfile.AddMasterDataEntry("schema2", "name2", 2, "attribute0", 1, "root2")
-			tocfile.AddMasterDataEntry("schema3", "name3", 3, "attribute0", 1, "root3")
+			tocfile.AddMasterDataEntry("schema0", "name0", 0, "attribute0", 1, "", "", 0)
+			tocfile.AddMasterDataEntry("schema1", "name1", 1, "attribute0", 1, "", "", 0)
+			tocfile.AddMasterDataEntry("schema2", "name2", 2, "attribute0", 1, "root2", "", 0)
+			tocfile.AddMasterDataEntry("schema3", "name3", 3, "attribute0", 1, "root3", "", 0)
 			roots := toc.GetIncludedPartitionRoots(tocfile.DataEntries, []string{})
 			Expect(roots).To(BeEmpty())
 		})
@@ -126,11 +126,36 @@ var _ = Describe("agent remote", func() {
 	Describe("StartGpbackupHelpers()", func() {
 		It("Correctly propagates --on-error-continue flag to gpbackup_helper", func() {
 			wasTerminated := false
-			utils.StartGpbackupHelpers(testCluster, fpInfo, "operation", "/tmp/pluginConfigFile.yml", " compressStr", true, false, &wasTerminated)
+			utils.StartGpbackupHelpers(testCluster, fpInfo, "operation", "/tmp/pluginConfigFile.yml", " compressStr", true, false, 1, &wasTerminated)
 
 			cc := testExecutor.ClusterCommands[0]
 			Expect(cc[1].CommandString).To(ContainSubstring(" --on-error-continue"))
 		})
+		It("passes the number of pipes to serve concurrently to gpbackup_helper", func() {
+			wasTerminated := false
+			utils.StartGpbackupHelpers(testCluster, fpInfo, "--restore-agent", "", "", false, false, 3, &wasTerminated)
+
+			cc := testExecutor.ClusterCommands[0]
+			Expect(cc[1].CommandString).To(ContainSubstring(" --copy-workers 3"))
+		})
+		It("does not pass the number of pipes to serve concurrently to gpbackup_helper when it serves one at a time", func() {
+			wasTerminated := false
+			utils.StartGpbackupHelpers(testCluster, fpInfo, "--restore-agent", "", "", false, false, 1, &wasTerminated)
+
+			cc := testExecutor.ClusterCommands[0]
+			Expect(cc[1].CommandString).ToNot(ContainSubstring("--copy-workers"))
+		})
+	})
+	Describe("CreateFirstSegmentPipesOnAllHosts()", func() {
+		It("creates the pipes of each given oid on each segment", func() {
+			utils.CreateFirstSegmentPipesOnAllHosts([]string{"1", "2"}, testCluster, fpInfo)
+
+			cc := testExecutor.ClusterCommands[0]
+			pipe0 := fmt.Sprintf("/data/gpseg0/gpbackup_0_11112233445566_pipe_%d", fpInfo.PID)
+			Expect(cc[0].CommandString).To(ContainSubstring(fmt.Sprintf("mkfifo %[1]s_1 %[1]s_2", pipe0)))
+			pipe1 := fmt.Sprintf("/data/gpseg1/gpbackup_1_11112233445566_pipe_%d", fpInfo.PID)
+			Expect(cc[1].CommandString).To(ContainSubstring(fmt.Sprintf("mkfifo %[1]s_1 %[1]s_2", pipe1)))
+		})
 	})
 	Describe("CheckAgentErrorsOnSegments", func() {
 		It("constructs the correct ssh call to check for the existance of an error file on each segment", func() {
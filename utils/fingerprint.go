@@ -0,0 +1,90 @@
+package utils
+
+/*
+ * This file contains functions for computing and comparing table data
+ * fingerprints, which let gprestore verify that restored data matches the
+ * data that was backed up.
+ */
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/dbconn"
+	"github.com/pkg/errors"
+)
+
+/*
+ * A fingerprint is a row count plus the sum of a hash of each row's text
+ * representation.  The sum does not depend on row order or on how rows are
+ * distributed, so the aggregate is computed on the segments in parallel and
+ * the fingerprints of leaf partitions add up to that of their root.
+ */
+type TableFingerprint struct {
+	Rows int64
+	Hash int64
+}
+
+func (fingerprint TableFingerprint) String() string {
+	return fmt.Sprintf("%d:%d", fingerprint.Rows, fingerprint.Hash)
+}
+
+func (fingerprint TableFingerprint) Add(other TableFingerprint) TableFingerprint {
+	return TableFingerprint{Rows: fingerprint.Rows + other.Rows, Hash: fingerprint.Hash + other.Hash}
+}
+
+func ParseTableFingerprint(fingerprintStr string) (TableFingerprint, error) {
+	parts := strings.Split(fingerprintStr, ":")
+	if len(parts) != 2 {
+		return TableFingerprint{}, errors.Errorf("Invalid table fingerprint %s", fingerprintStr)
+	}
+	rows, rowsErr := strconv.ParseInt(parts[0], 10, 64)
+	hash, hashErr := strconv.ParseInt(parts[1], 10, 64)
+	if rowsErr != nil || hashErr != nil {
+		return TableFingerprint{}, errors.Errorf("Invalid table fingerprint %s", fingerprintStr)
+	}
+	return TableFingerprint{Rows: rows, Hash: hash}, nil
+}
+
+/*
+ * record_out is used instead of a cast to text because GPDB 4.3 does not
+ * support casting a row to text.  Row output depends on the DateStyle,
+ * IntervalStyle, TimeZone, and extra_float_digits settings, so fingerprints
+ * are only comparable between sessions that use the same settings, as set by
+ * SetFingerprintSessionGUCs.
+ */
+func GetTableFingerprintQuery(tableFQN string) string {
+	return fmt.Sprintf(`SELECT count(*) AS rowcount,
+	coalesce(sum(('x' || substr(md5(textin(record_out(t))), 1, 8))::bit(32)::int), 0) AS hashsum
+FROM %s t`, tableFQN)
+}
+
+func GetTableFingerprint(connectionPool *dbconn.DBConn, tableFQN string, connNum int) (TableFingerprint, error) {
+	results := make([]struct {
+		RowCount int64
+		HashSum  int64
+	}, 0)
+	err := connectionPool.Select(&results, GetTableFingerprintQuery(tableFQN), connNum)
+	if err != nil {
+		return TableFingerprint{}, err
+	}
+	if len(results) != 1 {
+		return TableFingerprint{}, errors.Errorf("Unable to compute fingerprint for table %s", tableFQN)
+	}
+	return TableFingerprint{Rows: results[0].RowCount, Hash: results[0].HashSum}, nil
+}
+
+/*
+ * Both gpbackup and gprestore compute fingerprints with these settings, so
+ * that the output of date, time, and float columns does not depend on the
+ * defaults of either cluster, database, or role.
+ */
+func SetFingerprintSessionGUCs(connectionPool *dbconn.DBConn, connNum int) {
+	connectionPool.MustExec("SET DATESTYLE = ISO", connNum)
+	connectionPool.MustExec("SET TIMEZONE = 'UTC'", connNum)
+	connectionPool.MustExec("SELECT set_config('extra_float_digits', (SELECT max_val FROM pg_settings WHERE name = 'extra_float_digits'), false)", connNum)
+	if connectionPool.Version.AtLeast("6") {
+		connectionPool.MustExec("SET INTERVALSTYLE = POSTGRES", connNum)
+	}
+}
@@ -0,0 +1,58 @@
+package utils_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("utils/fingerprint tests", func() {
+	Describe("ParseTableFingerprint", func() {
+		It("parses a fingerprint written by String", func() {
+			fingerprint := utils.TableFingerprint{Rows: 10, Hash: -12345}
+			parsed, err := utils.ParseTableFingerprint(fingerprint.String())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsed).To(Equal(fingerprint))
+		})
+		It("returns an error for a fingerprint without a hash", func() {
+			_, err := utils.ParseTableFingerprint("10")
+			Expect(err).To(MatchError("Invalid table fingerprint 10"))
+		})
+		It("returns an error for a fingerprint with non-numeric values", func() {
+			_, err := utils.ParseTableFingerprint("10:abc")
+			Expect(err).To(MatchError("Invalid table fingerprint 10:abc"))
+		})
+	})
+	Describe("TableFingerprint.Add", func() {
+		It("adds both the row counts and the hashes", func() {
+			sum := utils.TableFingerprint{Rows: 4, Hash: 100}.Add(utils.TableFingerprint{Rows: 6, Hash: -300})
+			Expect(sum).To(Equal(utils.TableFingerprint{Rows: 10, Hash: -200}))
+		})
+	})
+	Describe("GetTableFingerprint", func() {
+		It("returns the row count and hash aggregate of a table", func() {
+			mock.ExpectQuery("SELECT count(.*) FROM public.foo t").WillReturnRows(sqlmock.NewRows([]string{"rowcount", "hashsum"}).AddRow(10, -12345))
+			fingerprint, err := utils.GetTableFingerprint(connectionPool, "public.foo", 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fingerprint).To(Equal(utils.TableFingerprint{Rows: 10, Hash: -12345}))
+		})
+	})
+	Describe("SetFingerprintSessionGUCs", func() {
+		It("sets the date, time zone, float, and interval output settings", func() {
+			originalVersion := connectionPool.Version
+			defer func() { connectionPool.Version = originalVersion }()
+			testhelper.SetDBVersion(connectionPool, "6.0.0")
+			mock.ExpectExec("SET DATESTYLE = ISO").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("SET TIMEZONE = 'UTC'").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("SELECT set_config\\('extra_float_digits'").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("SET INTERVALSTYLE = POSTGRES").WillReturnResult(sqlmock.NewResult(0, 0))
+
+			utils.SetFingerprintSessionGUCs(connectionPool, 0)
+
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
@@ -6,10 +6,13 @@ package utils
  */
 
 import (
+	"bufio"
+	"compress/gzip"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/greenplum-db/gp-common-go-libs/gplog"
 )
@@ -99,3 +102,85 @@ func CopyFile(src, dest string) error {
 
 	return err
 }
+
+/*
+ * Functions for reading backed-up table data directly from data files, for use
+ * when gpbackup_helper is not available to do so
+ */
+
+type dataFileReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (reader *dataFileReader) Close() error {
+	var firstErr error
+	for _, closer := range reader.closers {
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// As with gpbackup_helper, whether the file is compressed is determined by its extension
+func OpenDataFileForReading(filename string) (io.ReadCloser, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(filename, ".gz") {
+		return file, nil
+	}
+	gzipReader, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &dataFileReader{gzipReader, []io.Closer{gzipReader, file}}, nil
+}
+
+/*
+ * Single-data-file backups record each table's location as a byte range of the
+ * uncompressed data, so compressed files must be read up to the start of the
+ * range while uncompressed files can seek directly to it.
+ */
+func OpenDataFileRangeForReading(filename string, startByte uint64, endByte uint64) (io.ReadCloser, error) {
+	reader, err := OpenDataFileForReading(filename)
+	if err != nil {
+		return nil, err
+	}
+	if seeker, ok := reader.(io.Seeker); ok {
+		_, err = seeker.Seek(int64(startByte), io.SeekStart)
+	} else {
+		_, err = io.CopyN(ioutil.Discard, reader, int64(startByte))
+	}
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	return &dataFileReader{io.LimitReader(reader, int64(endByte-startByte)), []io.Closer{reader}}, nil
+}
+
+/*
+ * Compressed data files with frame offsets in their segment TOC compress each
+ * table separately, so a table's frames can be decompressed on their own
+ * without decompressing the data of the tables before it.
+ */
+func OpenDataFileFramesForReading(filename string, compressedStartByte uint64, compressedEndByte uint64) (io.ReadCloser, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	_, err = file.Seek(int64(compressedStartByte), io.SeekStart)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	gzipReader, err := gzip.NewReader(bufio.NewReader(io.LimitReader(file, int64(compressedEndByte-compressedStartByte))))
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &dataFileReader{gzipReader, []io.Closer{gzipReader, file}}, nil
+}
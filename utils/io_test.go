@@ -1,6 +1,8 @@
 package utils_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"io/ioutil"
 	"os"
 
@@ -85,4 +87,72 @@ var _ = Describe("utils/io tests", func() {
 			Expect(err).To(HaveOccurred())
 		})
 	})
+	Describe("OpenDataFileRangeForReading", func() {
+		var dataFilePath = "/tmp/test_data_file"
+		var contents = "1,one\n2,two\n3,three\n"
+
+		AfterEach(func() {
+			_ = os.Remove(dataFilePath)
+			_ = os.Remove(dataFilePath + ".gz")
+		})
+		It("reads the whole contents of an uncompressed file", func() {
+			_ = ioutil.WriteFile(dataFilePath, []byte(contents), 0644)
+
+			reader, err := utils.OpenDataFileForReading(dataFilePath)
+			Expect(err).ToNot(HaveOccurred())
+			defer reader.Close()
+			result, _ := ioutil.ReadAll(reader)
+			Expect(string(result)).To(Equal(contents))
+		})
+		It("reads a byte range of an uncompressed file", func() {
+			_ = ioutil.WriteFile(dataFilePath, []byte(contents), 0644)
+
+			reader, err := utils.OpenDataFileRangeForReading(dataFilePath, 6, 12)
+			Expect(err).ToNot(HaveOccurred())
+			defer reader.Close()
+			result, _ := ioutil.ReadAll(reader)
+			Expect(string(result)).To(Equal("2,two\n"))
+		})
+		It("reads a byte range of the uncompressed contents of a gzipped file", func() {
+			var compressed bytes.Buffer
+			gzipWriter := gzip.NewWriter(&compressed)
+			_, _ = gzipWriter.Write([]byte(contents))
+			_ = gzipWriter.Close()
+			_ = ioutil.WriteFile(dataFilePath+".gz", compressed.Bytes(), 0644)
+
+			reader, err := utils.OpenDataFileRangeForReading(dataFilePath+".gz", 12, 20)
+			Expect(err).ToNot(HaveOccurred())
+			defer reader.Close()
+			result, _ := ioutil.ReadAll(reader)
+			Expect(string(result)).To(Equal("3,three\n"))
+		})
+		It("returns an error when the file does not exist", func() {
+			_, err := utils.OpenDataFileRangeForReading(dataFilePath, 0, 6)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Describe("OpenDataFileFramesForReading", func() {
+		var dataFilePath = "/tmp/test_data_file.gz"
+
+		AfterEach(func() {
+			_ = os.Remove(dataFilePath)
+		})
+		It("decompresses only the frames of a single table", func() {
+			var compressed bytes.Buffer
+			frameOffsets := []int{0}
+			for _, tableData := range []string{"1,one\n", "2,two\n", "3,three\n"} {
+				gzipWriter := gzip.NewWriter(&compressed)
+				_, _ = gzipWriter.Write([]byte(tableData))
+				_ = gzipWriter.Close()
+				frameOffsets = append(frameOffsets, compressed.Len())
+			}
+			_ = ioutil.WriteFile(dataFilePath, compressed.Bytes(), 0644)
+
+			reader, err := utils.OpenDataFileFramesForReading(dataFilePath, uint64(frameOffsets[1]), uint64(frameOffsets[2]))
+			Expect(err).ToNot(HaveOccurred())
+			defer reader.Close()
+			result, _ := ioutil.ReadAll(reader)
+			Expect(string(result)).To(Equal("2,two\n"))
+		})
+	})
 })
@@ -7,6 +7,7 @@ import (
 	path "path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/blang/semver"
@@ -20,13 +21,42 @@ import (
 )
 
 const RequiredPluginVersion = "0.3.0"
+
+// Plugins report their capabilities from this API version onward
+const CapabilitiesPluginVersion = "0.5.0"
 const SecretKeyFile = ".encrypt"
 
+/*
+ * Names of the storage backends built into gpbackup, which can be used in
+ * place of a plugin executable by setting "backend" in the plugin config.
+ */
+const (
+	DIRECTORY_BACKEND = "directory"
+	S3_BACKEND        = "s3"
+)
+
 type PluginConfig struct {
-	ExecutablePath      string            `yaml:"executablepath"`
-	ConfigPath          string            `yaml:"-"`
-	Options             map[string]string `yaml:"options"`
-	backupPluginVersion string            `yaml:"-"`
+	ExecutablePath      string              `yaml:"executablepath,omitempty"`
+	Backend             string              `yaml:"backend,omitempty"`
+	ConfigPath          string              `yaml:"-"`
+	Options             map[string]string   `yaml:"options"`
+	Retry               *RetryPolicy        `yaml:"retry,omitempty"`
+	Capabilities        *PluginCapabilities `yaml:"capabilities,omitempty"`
+	backupPluginVersion string              `yaml:"-"`
+	retries             []PluginRetry       `yaml:"-"`
+}
+
+/*
+ * Optional features of a plugin, as reported by its capabilities command.
+ * They are negotiated once on all hosts and written to the config file copied
+ * to each host, so gpbackup_helper sees the same capabilities.
+ */
+type PluginCapabilities struct {
+	RestoreSubset bool `yaml:"restore_subset"`
+	DeleteBackup  bool `yaml:"delete_backup"`
+	List          bool `yaml:"list"`
+	Streaming     bool `yaml:"streaming"`
+	Encryption    bool `yaml:"encryption"`
 }
 
 type PluginScope string
@@ -48,16 +78,31 @@ func ReadPluginConfig(configFile string) (*PluginConfig, error) {
 	if err != nil {
 		return nil, errors.New("plugin config file is formatted incorrectly")
 	}
-	if config.ExecutablePath == "" {
-		return nil, errors.New("executablepath is required in config file")
-	}
 	if config.Options == nil {
 		config.Options = make(map[string]string)
 	}
-	config.ExecutablePath = os.ExpandEnv(config.ExecutablePath)
-	err = ValidateFullPath(config.ExecutablePath)
-	if err != nil {
-		return nil, err
+	if config.Backend != "" {
+		if config.Backend != DIRECTORY_BACKEND && config.Backend != S3_BACKEND {
+			return nil, errors.Errorf("Unrecognized backend %s in config file; valid backends are %s and %s", config.Backend, DIRECTORY_BACKEND, S3_BACKEND)
+		}
+		if config.ExecutablePath != "" {
+			return nil, errors.New("executablepath cannot be used with a built-in backend")
+		}
+	} else {
+		if config.ExecutablePath == "" {
+			return nil, errors.New("executablepath is required in config file")
+		}
+		config.ExecutablePath = os.ExpandEnv(config.ExecutablePath)
+		err = ValidateFullPath(config.ExecutablePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if config.Retry != nil {
+		err = config.Retry.validate()
+		if err != nil {
+			return nil, err
+		}
 	}
 	configFilename := path.Base(configFile)
 	config.ConfigPath = path.Join("/tmp", configFilename)
@@ -66,10 +111,16 @@ func ReadPluginConfig(configFile string) (*PluginConfig, error) {
 
 func (plugin *PluginConfig) BackupFile(filenamePath string) error {
 	command := fmt.Sprintf("%s backup_file %s %s", plugin.ExecutablePath, plugin.ConfigPath, filenamePath)
-	gplog.Debug("%s", command)
-	output, err := exec.Command("bash", "-c", command).CombinedOutput()
+	err := plugin.WithRetries("backup_file", filenamePath, func() error {
+		gplog.Debug("%s", command)
+		output, err := exec.Command("bash", "-c", command).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("ERROR: Plugin failed to process %s. %s", filenamePath, string(output))
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("ERROR: Plugin failed to process %s. %s", filenamePath, string(output))
+		return err
 	}
 	err = operating.System.Chmod(filenamePath, 0755)
 	return err
@@ -80,23 +131,88 @@ func (plugin *PluginConfig) MustBackupFile(filenamePath string) {
 	gplog.FatalOnError(err)
 }
 
-func (plugin *PluginConfig) MustRestoreFile(filenamePath string) {
+func (plugin *PluginConfig) RestoreFile(filenamePath string) error {
 	directory, _ := path.Split(filenamePath)
 	err := operating.System.MkdirAll(directory, 0755)
-	gplog.FatalOnError(err)
+	if err != nil {
+		return err
+	}
 	command := fmt.Sprintf("%s restore_file %s %s", plugin.ExecutablePath, plugin.ConfigPath, filenamePath)
-	gplog.Debug("%s", command)
-	output, err := exec.Command("bash", "-c", command).CombinedOutput()
-	gplog.FatalOnError(err, string(output))
+	return plugin.WithRetries("restore_file", filenamePath, func() error {
+		gplog.Debug("%s", command)
+		output, err := exec.Command("bash", "-c", command).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("ERROR: Plugin failed to process %s. %s", filenamePath, string(output))
+		}
+		return nil
+	})
 }
 
-func (plugin *PluginConfig) CheckPluginExistsOnAllHosts(c *cluster.Cluster) string {
-	plugin.checkPluginAPIVersion(c)
+func (plugin *PluginConfig) MustRestoreFile(filenamePath string) {
+	err := plugin.RestoreFile(filenamePath)
+	gplog.FatalOnError(err)
+}
 
-	return plugin.getPluginNativeVersion(c)
+// Returns true if the config names a plugin executable rather than a built-in backend
+func (plugin *PluginConfig) UsesExecutable() bool {
+	return plugin.Backend == ""
 }
 
-func (plugin *PluginConfig) checkPluginAPIVersion(c *cluster.Cluster) {
+/*
+ * The functions below return the shell commands used on the segments to move
+ * data to and from the backup destination.  Built-in backends are driven
+ * through gpbackup_helper, which reads the same plugin config.  The data
+ * commands expect the file name to be appended.
+ */
+func (plugin *PluginConfig) BackupDataCommand() string {
+	if plugin.UsesExecutable() {
+		return fmt.Sprintf("%s backup_data %s", plugin.ExecutablePath, plugin.ConfigPath)
+	}
+	return fmt.Sprintf("gpbackup_helper --put-data --plugin-config %s --data-file", plugin.ConfigPath)
+}
+
+func (plugin *PluginConfig) RestoreDataCommand() string {
+	if plugin.UsesExecutable() {
+		return fmt.Sprintf("%s restore_data %s", plugin.ExecutablePath, plugin.ConfigPath)
+	}
+	return fmt.Sprintf("gpbackup_helper --get-data --plugin-config %s --data-file", plugin.ConfigPath)
+}
+
+func (plugin *PluginConfig) BackupFileCommand(filenamePath string) string {
+	if plugin.UsesExecutable() {
+		return fmt.Sprintf("%s backup_file %s %s", plugin.ExecutablePath, plugin.ConfigPath, filenamePath)
+	}
+	return fmt.Sprintf("gpbackup_helper --put-file --plugin-config %s --data-file %s", plugin.ConfigPath, filenamePath)
+}
+
+func (plugin *PluginConfig) RestoreFileCommand(filenamePath string) string {
+	if plugin.UsesExecutable() {
+		return fmt.Sprintf("%s restore_file %s %s", plugin.ExecutablePath, plugin.ConfigPath, filenamePath)
+	}
+	return fmt.Sprintf("gpbackup_helper --get-file --plugin-config %s --data-file %s", plugin.ConfigPath, filenamePath)
+}
+
+// Returns the name recorded in the backup history for this plugin or backend
+func (plugin *PluginConfig) Name() string {
+	if plugin.UsesExecutable() {
+		_, name := path.Split(plugin.ExecutablePath)
+		return name
+	}
+	return plugin.Backend
+}
+
+func (plugin *PluginConfig) CheckPluginExistsOnAllHosts(c *cluster.Cluster) string {
+	if !plugin.UsesExecutable() {
+		// Built-in backends are part of gpbackup_helper, whose version is checked separately
+		return ""
+	}
+	apiVersion := plugin.checkPluginAPIVersion(c)
+	nativeVersion := plugin.getPluginNativeVersion(c)
+	plugin.negotiateCapabilities(c, apiVersion)
+	return nativeVersion
+}
+
+func (plugin *PluginConfig) checkPluginAPIVersion(c *cluster.Cluster) semver.Version {
 	command := fmt.Sprintf("source %s/greenplum_path.sh && %s plugin_api_version",
 		operating.System.Getenv("GPHOME"), plugin.ExecutablePath)
 	remoteOutput := c.GenerateAndExecuteCommand(
@@ -150,6 +266,7 @@ func (plugin *PluginConfig) checkPluginAPIVersion(c *cluster.Cluster) {
 		cluster.LogFatalClusterError("Plugin API version incorrect",
 			cluster.ON_HOSTS|cluster.INCLUDE_MASTER, numIncorrect)
 	}
+	return version
 }
 
 func (plugin *PluginConfig) getPluginNativeVersion(c *cluster.Cluster) string {
@@ -202,6 +319,70 @@ func (plugin *PluginConfig) getPluginNativeVersion(c *cluster.Cluster) string {
 	return parts[2]
 }
 
+/*
+ * Plugins older than CapabilitiesPluginVersion have no capabilities command,
+ * so their capabilities are left unset and inferred from the plugin config.
+ */
+func (plugin *PluginConfig) negotiateCapabilities(c *cluster.Cluster, apiVersion semver.Version) {
+	plugin.Capabilities = nil
+	capabilitiesVersion, _ := semver.Make(CapabilitiesPluginVersion)
+	if apiVersion.LT(capabilitiesVersion) {
+		gplog.Verbose("Plugin %s API version %s does not report capabilities; "+
+			"inferring them from the plugin config", plugin.ExecutablePath, apiVersion)
+		return
+	}
+	command := fmt.Sprintf("source %s/greenplum_path.sh && %s capabilities",
+		operating.System.Getenv("GPHOME"), plugin.ExecutablePath)
+	remoteOutput := c.GenerateAndExecuteCommand(
+		"Checking plugin capabilities on all hosts",
+		cluster.ON_HOSTS|cluster.INCLUDE_MASTER,
+		func(contentID int) string {
+			return command
+		})
+	gplog.Debug("%s", command)
+	errMsg := fmt.Sprintf("Unable to get capabilities of plugin %s", plugin.ExecutablePath)
+	c.CheckClusterError(
+		remoteOutput,
+		errMsg,
+		func(contentID int) string {
+			return errMsg
+		})
+	var capabilities *PluginCapabilities
+	for _, cmd := range remoteOutput.Commands {
+		hostCapabilities, err := ParsePluginCapabilities(cmd.Stdout)
+		if err != nil {
+			gplog.Fatal(errors.Wrapf(err, "Unable to parse capabilities of plugin %s on content %d", plugin.ExecutablePath, cmd.Content), "")
+		}
+		if capabilities != nil && *capabilities != hostCapabilities {
+			gplog.Verbose("Plugin %s on content ID %d has capabilities %+v, which are not consistent "+
+				"with capabilities %+v on another segment", plugin.ExecutablePath, cmd.Content, hostCapabilities, *capabilities)
+			cluster.LogFatalClusterError("Plugin capabilities are inconsistent "+
+				"across segments; please reinstall plugin across segments",
+				cluster.ON_HOSTS|cluster.INCLUDE_MASTER, 1)
+		}
+		capabilities = &hostCapabilities
+	}
+	plugin.Capabilities = capabilities
+	if capabilities != nil {
+		gplog.Verbose("Plugin %s capabilities: %+v", plugin.ExecutablePath, *capabilities)
+		// Data must not be written unencrypted when the config asks for encryption
+		if !capabilities.Encryption && plugin.UsesEncryption() {
+			gplog.Fatal(errors.Errorf("Plugin %s does not support encryption, but encryption is enabled in plugin config %s",
+				plugin.ExecutablePath, plugin.ConfigPath), "")
+		}
+	}
+}
+
+// Unknown capabilities are ignored, so plugins may report capabilities added in later API versions
+func ParsePluginCapabilities(output string) (PluginCapabilities, error) {
+	capabilities := PluginCapabilities{}
+	err := yaml.Unmarshal([]byte(output), &capabilities)
+	if err != nil {
+		return PluginCapabilities{}, errors.Errorf("Plugin capabilities are not valid YAML: %v", err)
+	}
+	return capabilities, nil
+}
+
 /*-----------------------------Hooks------------------------------------------*/
 
 func (plugin *PluginConfig) SetupPluginForBackup(c *cluster.Cluster, fpInfo filepath.FilePathInfo) {
@@ -230,6 +411,9 @@ func (plugin *PluginConfig) CleanupPluginForRestore(c *cluster.Cluster, fpInfo f
 
 func (plugin *PluginConfig) executeHook(c *cluster.Cluster, verboseCommandMsg string,
 	command string, fpInfo filepath.FilePathInfo, noFatal bool) {
+	if !plugin.UsesExecutable() {
+		return
+	}
 
 	// Execute command once on master
 	scope := MASTER
@@ -416,8 +600,8 @@ func (plugin *PluginConfig) BackupSegmentTOCs(c *cluster.Cluster, fpInfo filepat
 	remoteOutput = c.GenerateAndExecuteCommand("Processing segment TOC files with plugin", cluster.ON_SEGMENTS,
 		func(contentID int) string {
 			tocFile := fpInfo.GetSegmentTOCFilePath(contentID)
-			return fmt.Sprintf("source %s/greenplum_path.sh && %s backup_file %s %s && "+
-				"chmod 0755 %s", operating.System.Getenv("GPHOME"), plugin.ExecutablePath, plugin.ConfigPath, tocFile, tocFile)
+			return fmt.Sprintf("source %s/greenplum_path.sh && %s && "+
+				"chmod 0755 %s", operating.System.Getenv("GPHOME"), plugin.BackupFileCommand(tocFile), tocFile)
 		})
 	c.CheckClusterError(remoteOutput, "Unable to process segment TOC files using plugin", func(contentID int) string {
 		return "See gpAdminLog for gpbackup_helper on segment host for details: Error occurred with plugin"
@@ -428,9 +612,9 @@ func (plugin *PluginConfig) RestoreSegmentTOCs(c *cluster.Cluster, fpInfo filepa
 	var command string
 	remoteOutput := c.GenerateAndExecuteCommand("Processing segment TOC files with plugin", cluster.ON_SEGMENTS, func(contentID int) string {
 		tocFile := fpInfo.GetSegmentTOCFilePath(contentID)
-		command = fmt.Sprintf("mkdir -p %s && source %s/greenplum_path.sh && %s restore_file %s %s",
+		command = fmt.Sprintf("mkdir -p %s && source %s/greenplum_path.sh && %s",
 			fpInfo.GetDirForContent(contentID), operating.System.Getenv("GPHOME"),
-			plugin.ExecutablePath, plugin.ConfigPath, tocFile)
+			plugin.RestoreFileCommand(tocFile))
 		return command
 	})
 	gplog.Debug("%s", command)
@@ -440,8 +624,8 @@ func (plugin *PluginConfig) RestoreSegmentTOCs(c *cluster.Cluster, fpInfo filepa
 }
 
 func (plugin *PluginConfig) UsesEncryption() bool {
-	return plugin.Options["password_encryption"] == "on" ||
-		(plugin.Options["replication"] == "on" && plugin.Options["remote_password_encryption"] == "on")
+	return plugin.UsesExecutable() && (plugin.Options["password_encryption"] == "on" ||
+		(plugin.Options["replication"] == "on" && plugin.Options["remote_password_encryption"] == "on"))
 }
 
 func (plugin *PluginConfig) GetPluginName(c *cluster.Cluster) (pluginName string, err error) {
@@ -476,8 +660,130 @@ func (plugin *PluginConfig) SetBackupPluginVersion(timestamp string, historicalP
 	}
 }
 
+/*
+ * A plugin that reports its capabilities can restore subsets if it says so,
+ * unless disabled in the config.  For older plugins, subset restore is
+ * enabled in the config, or assumed for the DD Boost plugin.
+ */
 func (plugin *PluginConfig) CanRestoreSubset() bool {
+	if !plugin.UsesExecutable() {
+		return false
+	}
+	if plugin.Capabilities != nil {
+		return plugin.Capabilities.RestoreSubset && plugin.Options["restore_subset"] != "off"
+	}
 	return (plugin.Options["restore_subset"] == "on") ||
 		(strings.HasSuffix(plugin.ExecutablePath, "ddboost_plugin") &&
 			plugin.Options["restore_subset"] != "off")
 }
+
+/*
+ * gprestore reads metadata files directly from the backup destination, rather
+ * than copying them to the master first, for the built-in backends and for
+ * plugins that report they can stream files, unless disabled in the config.
+ */
+func (plugin *PluginConfig) CanStreamMetadata() bool {
+	if plugin.Options["stream_metadata"] == "off" {
+		return false
+	}
+	if !plugin.UsesExecutable() {
+		return true
+	}
+	return plugin.Capabilities != nil && plugin.Capabilities.Streaming
+}
+
+/*-----------------------------Retries----------------------------------------*/
+
+/*
+ * Commands that may be configured to be retried.  Each attempt of these
+ * replaces whatever a failed attempt left behind, so re-running them after a
+ * transient storage failure is safe.
+ */
+var RetryableCommands = []string{"backup_file", "restore_file", "backup_data", "restore_data"}
+
+type RetryPolicy struct {
+	MaxAttempts        int      `yaml:"max_attempts"`
+	BackoffSeconds     int      `yaml:"backoff_seconds"`
+	MaxBackoffSeconds  int      `yaml:"max_backoff_seconds,omitempty"`
+	IdempotentCommands []string `yaml:"idempotent_commands,omitempty"`
+}
+
+type PluginRetry struct {
+	Command  string
+	Filename string
+	Attempt  int
+	Error    string
+}
+
+var retryMutex sync.Mutex
+
+func (policy *RetryPolicy) validate() error {
+	if policy.MaxAttempts < 1 {
+		return errors.New("max_attempts in the retry section of the config file must be at least 1")
+	}
+	if policy.BackoffSeconds < 0 || policy.MaxBackoffSeconds < 0 {
+		return errors.New("backoff_seconds and max_backoff_seconds in the retry section of the config file cannot be negative")
+	}
+	for _, command := range policy.IdempotentCommands {
+		if !Exists(RetryableCommands, command) {
+			return errors.Errorf("Command %s in the retry section of the config file cannot be retried; valid commands are %s", command, strings.Join(RetryableCommands, ", "))
+		}
+	}
+	return nil
+}
+
+// Commands are retried only if listed, or if no commands are listed at all
+func (plugin *PluginConfig) CanRetry(command string) bool {
+	if plugin.Retry == nil || plugin.Retry.MaxAttempts < 2 {
+		return false
+	}
+	if len(plugin.Retry.IdempotentCommands) == 0 {
+		return Exists(RetryableCommands, command)
+	}
+	return Exists(plugin.Retry.IdempotentCommands, command)
+}
+
+// The backoff doubles with each attempt, up to max_backoff_seconds if set
+func (plugin *PluginConfig) RetryBackoff(attempt int) time.Duration {
+	backoff := time.Duration(plugin.Retry.BackoffSeconds) * time.Second
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+	}
+	maxBackoff := time.Duration(plugin.Retry.MaxBackoffSeconds) * time.Second
+	if maxBackoff > 0 && backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+/*
+ * Runs operation, re-running it after a backoff if it fails and the command
+ * can be retried.  Failed attempts that are retried are recorded so they can
+ * be included in the report.
+ */
+func (plugin *PluginConfig) WithRetries(command string, filename string, operation func() error) error {
+	attempt := 1
+	for {
+		err := operation()
+		if err == nil || !plugin.CanRetry(command) || attempt >= plugin.Retry.MaxAttempts {
+			return err
+		}
+		backoff := plugin.RetryBackoff(attempt)
+		gplog.Warn("Plugin command %s failed for %s on attempt %d of %d; retrying in %v: %v", command, filename, attempt, plugin.Retry.MaxAttempts, backoff, err)
+		plugin.recordRetry(PluginRetry{Command: command, Filename: filename, Attempt: attempt, Error: err.Error()})
+		time.Sleep(backoff)
+		attempt++
+	}
+}
+
+func (plugin *PluginConfig) recordRetry(retry PluginRetry) {
+	retryMutex.Lock()
+	defer retryMutex.Unlock()
+	plugin.retries = append(plugin.retries, retry)
+}
+
+func (plugin *PluginConfig) Retries() []PluginRetry {
+	retryMutex.Lock()
+	defer retryMutex.Unlock()
+	return append([]PluginRetry{}, plugin.retries...)
+}
@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/blang/semver"
 	"github.com/greenplum-db/gp-common-go-libs/cluster"
@@ -22,6 +23,16 @@ import (
 	. "github.com/onsi/gomega"
 )
 
+func capabilitiesOutput(capabilities string) *cluster.RemoteOutput {
+	return &cluster.RemoteOutput{
+		Commands: []cluster.ShellCommand{
+			{Content: -1, Stdout: capabilities},
+			{Content: 0, Stdout: capabilities},
+			{Content: 1, Stdout: capabilities},
+		},
+	}
+}
+
 var _ = Describe("utils/plugin tests", func() {
 	var testCluster *cluster.Cluster
 	var executor testutils.TestExecutorMultiple
@@ -223,6 +234,7 @@ options:
 
 				co[1].Stdout = greater.String()
 				co[2].Stdout = greater.String()
+				executor.ClusterOutputs = append(executor.ClusterOutputs, capabilitiesOutput("restore_subset: true"))
 
 				_ = subject.CheckPluginExistsOnAllHosts(testCluster)
 			})
@@ -267,6 +279,113 @@ options:
 			})
 		})
 	})
+	Describe("capability negotiation", func() {
+		BeforeEach(func() {
+			for i := range executor.ClusterOutputs[0].Commands {
+				executor.ClusterOutputs[0].Commands[i].Stdout = utils.CapabilitiesPluginVersion
+			}
+		})
+		It("reads the capabilities reported by the plugin on all hosts", func() {
+			executor.ClusterOutputs = append(executor.ClusterOutputs, capabilitiesOutput("restore_subset: true\ndelete_backup: true\nstreaming: true"))
+			operating.System.Getenv = func(key string) string {
+				return "my/install/dir"
+			}
+
+			_ = subject.CheckPluginExistsOnAllHosts(testCluster)
+
+			for _, shellCommand := range executor.ClusterCommands[2] {
+				Expect(shellCommand.CommandString).To(ContainSubstring("source my/install/dir/greenplum_path.sh && /a/b/myPlugin capabilities"))
+			}
+			Expect(*subject.Capabilities).To(Equal(utils.PluginCapabilities{RestoreSubset: true, DeleteBackup: true, Streaming: true}))
+		})
+		It("does not ask older plugins for their capabilities", func() {
+			for i := range executor.ClusterOutputs[0].Commands {
+				executor.ClusterOutputs[0].Commands[i].Stdout = "0.4.0"
+			}
+
+			_ = subject.CheckPluginExistsOnAllHosts(testCluster)
+
+			Expect(executor.NumRemoteExecutions).To(Equal(2))
+			Expect(subject.Capabilities).To(BeNil())
+		})
+		It("panics when the capabilities are inconsistent across hosts", func() {
+			output := capabilitiesOutput("restore_subset: true")
+			output.Commands[1].Stdout = "restore_subset: false"
+			executor.ClusterOutputs = append(executor.ClusterOutputs, output)
+			defer testhelper.ShouldPanicWithMessage("Plugin capabilities are inconsistent across segments")
+
+			_ = subject.CheckPluginExistsOnAllHosts(testCluster)
+		})
+		It("panics when the capabilities cannot be parsed", func() {
+			executor.ClusterOutputs = append(executor.ClusterOutputs, capabilitiesOutput("restore_subset: [true"))
+			defer testhelper.ShouldPanicWithMessage("Unable to parse capabilities of plugin /a/b/myPlugin on content -1")
+
+			_ = subject.CheckPluginExistsOnAllHosts(testCluster)
+		})
+		It("panics when the config enables encryption but the plugin does not support it", func() {
+			subject.ConfigPath = "/tmp/plugin_config"
+			subject.Options["password_encryption"] = "on"
+			executor.ClusterOutputs = append(executor.ClusterOutputs, capabilitiesOutput("restore_subset: true\nencryption: false"))
+			defer testhelper.ShouldPanicWithMessage("Plugin /a/b/myPlugin does not support encryption, but encryption is enabled in plugin config /tmp/plugin_config")
+
+			_ = subject.CheckPluginExistsOnAllHosts(testCluster)
+		})
+		It("accepts encryption in the config when the plugin supports it", func() {
+			subject.Options["password_encryption"] = "on"
+			executor.ClusterOutputs = append(executor.ClusterOutputs, capabilitiesOutput("encryption: true"))
+
+			_ = subject.CheckPluginExistsOnAllHosts(testCluster)
+
+			Expect(subject.UsesEncryption()).To(BeTrue())
+		})
+		It("ignores capabilities it does not recognize", func() {
+			capabilities, err := utils.ParsePluginCapabilities("list: true\nteleportation: true\n")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(capabilities).To(Equal(utils.PluginCapabilities{List: true}))
+		})
+	})
+	Describe("CanRestoreSubset", func() {
+		It("uses the restore_subset capability when the plugin reports capabilities", func() {
+			subject.ExecutablePath = "/a/b/gpbackup_ddboost_plugin"
+			subject.Capabilities = &utils.PluginCapabilities{RestoreSubset: false}
+			Expect(subject.CanRestoreSubset()).To(BeFalse())
+			subject.Capabilities.RestoreSubset = true
+			Expect(subject.CanRestoreSubset()).To(BeTrue())
+		})
+		It("can be disabled in the config when the plugin supports it", func() {
+			subject.Capabilities = &utils.PluginCapabilities{RestoreSubset: true}
+			subject.Options["restore_subset"] = "off"
+			Expect(subject.CanRestoreSubset()).To(BeFalse())
+		})
+		It("infers subset support for plugins that do not report capabilities", func() {
+			Expect(subject.CanRestoreSubset()).To(BeFalse())
+			subject.Options["restore_subset"] = "on"
+			Expect(subject.CanRestoreSubset()).To(BeTrue())
+			subject.Options["restore_subset"] = ""
+			subject.ExecutablePath = "/a/b/gpbackup_ddboost_plugin"
+			Expect(subject.CanRestoreSubset()).To(BeTrue())
+		})
+	})
+	Describe("CanStreamMetadata", func() {
+		It("streams metadata from the built-in backends", func() {
+			subject.Backend = utils.DIRECTORY_BACKEND
+			Expect(subject.CanStreamMetadata()).To(BeTrue())
+		})
+		It("streams metadata only from plugins that report the streaming capability", func() {
+			Expect(subject.CanStreamMetadata()).To(BeFalse())
+			subject.Capabilities = &utils.PluginCapabilities{Streaming: false}
+			Expect(subject.CanStreamMetadata()).To(BeFalse())
+			subject.Capabilities.Streaming = true
+			Expect(subject.CanStreamMetadata()).To(BeTrue())
+		})
+		It("can be disabled in the config", func() {
+			subject.Capabilities = &utils.PluginCapabilities{Streaming: true}
+			subject.Options["stream_metadata"] = "off"
+			Expect(subject.CanStreamMetadata()).To(BeFalse())
+			subject.Backend = utils.DIRECTORY_BACKEND
+			Expect(subject.CanStreamMetadata()).To(BeFalse())
+		})
+	})
 	Describe("UsesEncryption", func() {
 		It("returns false when there is no encryption in config", func() {
 			Expect(subject.UsesEncryption()).To(BeFalse())
@@ -404,5 +523,148 @@ hostname: "myhostname"`), nil
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(Equal("plugin config file is formatted incorrectly"))
 		})
+		It("reads a config file for a built-in backend without an executablepath", func() {
+			operating.System.ReadFile = func(string) ([]byte, error) {
+				return []byte(`backend: directory
+options:
+  directory: "/mnt/backups"`), nil
+			}
+
+			config, err := utils.ReadPluginConfig("myconfigpath")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.Backend).To(Equal("directory"))
+			Expect(config.Options["directory"]).To(Equal("/mnt/backups"))
+			Expect(config.ConfigPath).To(Equal("/tmp/myconfigpath"))
+		})
+		It("returns an error if the backend is not recognized", func() {
+			operating.System.ReadFile = func(string) ([]byte, error) {
+				return []byte(`backend: tape`), nil
+			}
+
+			_, err := utils.ReadPluginConfig("myconfigpath")
+			Expect(err).To(MatchError("Unrecognized backend tape in config file; valid backends are directory and s3"))
+		})
+		It("returns an error if both a backend and an executablepath are specified", func() {
+			operating.System.ReadFile = func(string) ([]byte, error) {
+				return []byte(`backend: s3
+executablepath: "/usr/local/gpdb/bin/gpbackup_s3_plugin"`), nil
+			}
+
+			_, err := utils.ReadPluginConfig("myconfigpath")
+			Expect(err).To(MatchError("executablepath cannot be used with a built-in backend"))
+		})
+	})
+	Describe("retries", func() {
+		It("reads a retry policy from the config file", func() {
+			operating.System.ReadFile = func(string) ([]byte, error) {
+				return []byte(`executablepath: /usr/local/gpdb/bin/gpbackup_s3_plugin
+retry:
+  max_attempts: 3
+  backoff_seconds: 2
+  idempotent_commands: [backup_file, restore_data]`), nil
+			}
+
+			config, err := utils.ReadPluginConfig("myconfigpath")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*config.Retry).To(Equal(utils.RetryPolicy{MaxAttempts: 3, BackoffSeconds: 2, IdempotentCommands: []string{"backup_file", "restore_data"}}))
+			Expect(config.CanRetry("backup_file")).To(BeTrue())
+			Expect(config.CanRetry("restore_data")).To(BeTrue())
+			Expect(config.CanRetry("restore_file")).To(BeFalse())
+		})
+		It("returns an error if max_attempts is less than 1", func() {
+			operating.System.ReadFile = func(string) ([]byte, error) {
+				return []byte(`executablepath: /usr/local/gpdb/bin/gpbackup_s3_plugin
+retry:
+  max_attempts: 0`), nil
+			}
+
+			_, err := utils.ReadPluginConfig("myconfigpath")
+			Expect(err).To(MatchError("max_attempts in the retry section of the config file must be at least 1"))
+		})
+		It("returns an error if a command cannot be retried", func() {
+			operating.System.ReadFile = func(string) ([]byte, error) {
+				return []byte(`executablepath: /usr/local/gpdb/bin/gpbackup_s3_plugin
+retry:
+  max_attempts: 3
+  idempotent_commands: [delete_backup]`), nil
+			}
+
+			_, err := utils.ReadPluginConfig("myconfigpath")
+			Expect(err).To(MatchError("Command delete_backup in the retry section of the config file cannot be retried; valid commands are backup_file, restore_file, backup_data, restore_data"))
+		})
+		It("retries every retryable command when no commands are listed", func() {
+			config := utils.PluginConfig{Retry: &utils.RetryPolicy{MaxAttempts: 2}}
+			for _, command := range utils.RetryableCommands {
+				Expect(config.CanRetry(command)).To(BeTrue())
+			}
+			Expect(config.CanRetry("delete_backup")).To(BeFalse())
+		})
+		It("does not retry without a retry policy", func() {
+			config := utils.PluginConfig{}
+			Expect(config.CanRetry("backup_file")).To(BeFalse())
+		})
+		It("doubles the backoff with each attempt up to the maximum", func() {
+			config := utils.PluginConfig{Retry: &utils.RetryPolicy{MaxAttempts: 5, BackoffSeconds: 2, MaxBackoffSeconds: 5}}
+			Expect(config.RetryBackoff(1)).To(Equal(2 * time.Second))
+			Expect(config.RetryBackoff(2)).To(Equal(4 * time.Second))
+			Expect(config.RetryBackoff(3)).To(Equal(5 * time.Second))
+		})
+		It("retries a failed command and records each retry", func() {
+			config := utils.PluginConfig{Retry: &utils.RetryPolicy{MaxAttempts: 3}}
+			attempts := 0
+			err := config.WithRetries("backup_file", "/tmp/foo", func() error {
+				attempts++
+				if attempts < 3 {
+					return errors.Errorf("failure %d", attempts)
+				}
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(attempts).To(Equal(3))
+			Expect(config.Retries()).To(Equal([]utils.PluginRetry{
+				{Command: "backup_file", Filename: "/tmp/foo", Attempt: 1, Error: "failure 1"},
+				{Command: "backup_file", Filename: "/tmp/foo", Attempt: 2, Error: "failure 2"},
+			}))
+		})
+		It("returns the last error once max_attempts is reached", func() {
+			config := utils.PluginConfig{Retry: &utils.RetryPolicy{MaxAttempts: 2}}
+			attempts := 0
+			err := config.WithRetries("restore_file", "/tmp/foo", func() error {
+				attempts++
+				return errors.Errorf("failure %d", attempts)
+			})
+			Expect(err).To(MatchError("failure 2"))
+			Expect(attempts).To(Equal(2))
+			Expect(config.Retries()).To(HaveLen(1))
+		})
+		It("does not retry a command that is not listed", func() {
+			config := utils.PluginConfig{Retry: &utils.RetryPolicy{MaxAttempts: 3, IdempotentCommands: []string{"backup_file"}}}
+			attempts := 0
+			err := config.WithRetries("restore_file", "/tmp/foo", func() error {
+				attempts++
+				return errors.New("failure")
+			})
+			Expect(err).To(MatchError("failure"))
+			Expect(attempts).To(Equal(1))
+			Expect(config.Retries()).To(BeEmpty())
+		})
+	})
+	Describe("storage commands", func() {
+		It("runs the plugin executable when there is no built-in backend", func() {
+			config := utils.PluginConfig{ExecutablePath: "/tmp/fake-plugin.sh", ConfigPath: "/tmp/plugin_config"}
+			Expect(config.BackupDataCommand()).To(Equal("/tmp/fake-plugin.sh backup_data /tmp/plugin_config"))
+			Expect(config.RestoreDataCommand()).To(Equal("/tmp/fake-plugin.sh restore_data /tmp/plugin_config"))
+			Expect(config.BackupFileCommand("/data/foo")).To(Equal("/tmp/fake-plugin.sh backup_file /tmp/plugin_config /data/foo"))
+			Expect(config.RestoreFileCommand("/data/foo")).To(Equal("/tmp/fake-plugin.sh restore_file /tmp/plugin_config /data/foo"))
+			Expect(config.Name()).To(Equal("fake-plugin.sh"))
+		})
+		It("runs gpbackup_helper for a built-in backend", func() {
+			config := utils.PluginConfig{Backend: "s3", ConfigPath: "/tmp/plugin_config"}
+			Expect(config.BackupDataCommand()).To(Equal("gpbackup_helper --put-data --plugin-config /tmp/plugin_config --data-file"))
+			Expect(config.RestoreDataCommand()).To(Equal("gpbackup_helper --get-data --plugin-config /tmp/plugin_config --data-file"))
+			Expect(config.BackupFileCommand("/data/foo")).To(Equal("gpbackup_helper --put-file --plugin-config /tmp/plugin_config --data-file /data/foo"))
+			Expect(config.RestoreFileCommand("/data/foo")).To(Equal("gpbackup_helper --get-file --plugin-config /tmp/plugin_config --data-file /data/foo"))
+			Expect(config.Name()).To(Equal("s3"))
+		})
 	})
 })
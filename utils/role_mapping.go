@@ -0,0 +1,76 @@
+package utils
+
+/*
+ * This file contains functions for reading the role mapping file given to
+ * gprestore with --role-mapping, which replaces the roles named in restored
+ * ownership, privilege, role configuration, and role membership statements.
+ *
+ * The file has one mapping per line, from the role name in the backup to the
+ * role name to use instead, or to DROP to leave out statements for the role:
+ *
+ *     # Comments and blank lines are ignored
+ *     etl_owner -> etl_admin
+ *     old_reporting -> DROP
+ */
+
+import (
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+const roleMappingSeparator = "->"
+
+func ReadRoleMappingFile(filename string) (map[string]string, error) {
+	gplog.Info("Reading role mapping file %s", filename)
+	contents, err := operating.System.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	roleMapping, err := ParseRoleMapping(string(contents))
+	if err != nil {
+		return nil, errors.Errorf("Invalid role mapping file %s: %v", filename, err)
+	}
+	for oldRole, newRole := range roleMapping {
+		if newRole == "" {
+			gplog.Verbose("Role mapping: %s -> DROP", oldRole)
+		} else {
+			gplog.Verbose("Role mapping: %s -> %s", oldRole, newRole)
+		}
+	}
+	return roleMapping, nil
+}
+
+/*
+ * Returns a map from each old role name to its new role name, or to an empty
+ * string if statements for the role are to be dropped.  Role names are given
+ * as they are in pg_roles, without quotes.
+ */
+func ParseRoleMapping(contents string) (map[string]string, error) {
+	roleMapping := make(map[string]string)
+	for i, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		separator := strings.Index(line, roleMappingSeparator)
+		if separator == -1 {
+			return nil, errors.Errorf("line %d is not of the form oldrole -> newrole", i+1)
+		}
+		oldRole := strings.TrimSpace(line[:separator])
+		newRole := strings.TrimSpace(line[separator+len(roleMappingSeparator):])
+		if oldRole == "" || newRole == "" {
+			return nil, errors.Errorf("line %d is not of the form oldrole -> newrole", i+1)
+		}
+		if _, ok := roleMapping[oldRole]; ok {
+			return nil, errors.Errorf("line %d maps role %s more than once", i+1, oldRole)
+		}
+		if strings.ToUpper(newRole) == "DROP" {
+			newRole = ""
+		}
+		roleMapping[oldRole] = newRole
+	}
+	return roleMapping, nil
+}
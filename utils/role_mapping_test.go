@@ -0,0 +1,80 @@
+package utils_test
+
+import (
+	"errors"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("utils/role_mapping tests", func() {
+	Describe("ParseRoleMapping", func() {
+		It("parses one mapping per line, ignoring comments and blank lines", func() {
+			contents := `# Roles in production
+etl_owner -> etl_admin
+
+  Report Users->reporting
+old_reporting -> drop
+`
+			roleMapping, err := utils.ParseRoleMapping(contents)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(roleMapping).To(Equal(map[string]string{
+				"etl_owner":     "etl_admin",
+				"Report Users":  "reporting",
+				"old_reporting": "",
+			}))
+		})
+		It("returns no mappings for an empty file", func() {
+			roleMapping, err := utils.ParseRoleMapping("")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(roleMapping).To(BeEmpty())
+		})
+		It("returns an error for a line without a separator", func() {
+			_, err := utils.ParseRoleMapping("etl_owner -> etl_admin\netl_reader etl_admin")
+
+			Expect(err).To(MatchError("line 2 is not of the form oldrole -> newrole"))
+		})
+		It("returns an error for a line without a new role", func() {
+			_, err := utils.ParseRoleMapping("etl_owner ->")
+
+			Expect(err).To(MatchError("line 1 is not of the form oldrole -> newrole"))
+		})
+		It("returns an error for a role that is mapped more than once", func() {
+			_, err := utils.ParseRoleMapping("etl_owner -> etl_admin\netl_owner -> DROP")
+
+			Expect(err).To(MatchError("line 2 maps role etl_owner more than once"))
+		})
+	})
+	Describe("ReadRoleMappingFile", func() {
+		AfterEach(func() {
+			operating.System = operating.InitializeSystemFunctions()
+		})
+		It("reads the mappings in the file", func() {
+			operating.System.ReadFile = func(string) ([]byte, error) { return []byte("etl_owner -> etl_admin\n"), nil }
+
+			roleMapping, err := utils.ReadRoleMappingFile("/tmp/roles.txt")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(roleMapping).To(Equal(map[string]string{"etl_owner": "etl_admin"}))
+		})
+		It("names the file in the error for an invalid file", func() {
+			operating.System.ReadFile = func(string) ([]byte, error) { return []byte("etl_owner\n"), nil }
+
+			_, err := utils.ReadRoleMappingFile("/tmp/roles.txt")
+
+			Expect(err).To(MatchError("Invalid role mapping file /tmp/roles.txt: line 1 is not of the form oldrole -> newrole"))
+		})
+		It("returns an error if the file cannot be read", func() {
+			operating.System.ReadFile = func(string) ([]byte, error) { return nil, errors.New("permission denied") }
+
+			_, err := utils.ReadRoleMappingFile("/tmp/roles.txt")
+
+			Expect(err).To(MatchError("permission denied"))
+		})
+	})
+})
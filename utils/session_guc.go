@@ -0,0 +1,98 @@
+package utils
+
+/*
+ * This file contains functions for reading the session GUC file given to
+ * gpbackup and gprestore with --session-guc-file, whose settings are applied
+ * on every connection after the settings that the utilities always use.
+ *
+ * The file has one setting per line, in the same format as postgresql.conf:
+ *
+ *     # Comments and blank lines are ignored
+ *     statement_mem = '1GB'
+ *     optimizer = off
+ *     role = backup_role
+ */
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+type SessionGUC struct {
+	Name  string
+	Value string
+}
+
+var sessionGUCNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+/*
+ * The utilities rely on these settings to read and write metadata and data
+ * in a portable format and to compute comparable fingerprints, so they cannot
+ * be overridden.
+ */
+var protectedSessionGUCs = map[string]bool{
+	"client_encoding":             true,
+	"datestyle":                   true,
+	"extra_float_digits":          true,
+	"intervalstyle":               true,
+	"search_path":                 true,
+	"standard_conforming_strings": true,
+	"timezone":                    true,
+}
+
+func ReadSessionGUCFile(filename string) ([]SessionGUC, error) {
+	gplog.Info("Reading session GUC file %s", filename)
+	contents, err := operating.System.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	gucs, err := ParseSessionGUCs(string(contents))
+	if err != nil {
+		return nil, errors.Errorf("Invalid session GUC file %s: %v", filename, err)
+	}
+	for _, guc := range gucs {
+		gplog.Verbose("Session GUC: %s", guc)
+	}
+	return gucs, nil
+}
+
+func ParseSessionGUCs(contents string) ([]SessionGUC, error) {
+	gucs := make([]SessionGUC, 0)
+	for i, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		separator := strings.Index(line, "=")
+		if separator == -1 {
+			return nil, errors.Errorf("line %d is not of the form name = value", i+1)
+		}
+		name := strings.TrimSpace(line[:separator])
+		if !sessionGUCNameRegex.MatchString(name) {
+			return nil, errors.Errorf("line %d has an invalid setting name %q", i+1, name)
+		}
+		if protectedSessionGUCs[strings.ToLower(name)] {
+			return nil, errors.Errorf("line %d sets %s, which cannot be overridden", i+1, name)
+		}
+		value := strings.TrimSpace(line[separator+1:])
+		if len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") {
+			value = strings.Replace(value[1:len(value)-1], "''", "'", -1)
+		}
+		gucs = append(gucs, SessionGUC{Name: name, Value: value})
+	}
+	return gucs, nil
+}
+
+// The value is always passed as a string, which the server converts to the type of the setting
+func (guc SessionGUC) SetStatement() string {
+	return fmt.Sprintf("SET %s TO '%s'", guc.Name, EscapeSingleQuotes(guc.Value))
+}
+
+func (guc SessionGUC) String() string {
+	return fmt.Sprintf("%s = '%s'", guc.Name, EscapeSingleQuotes(guc.Value))
+}
@@ -0,0 +1,102 @@
+package utils_test
+
+import (
+	"errors"
+
+	"github.com/greenplum-db/gp-common-go-libs/operating"
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("utils/session_guc tests", func() {
+	Describe("ParseSessionGUCs", func() {
+		It("parses one setting per line, ignoring comments and blank lines", func() {
+			contents := `# Settings for backups
+statement_mem = '1GB'
+
+optimizer=off
+  role = backup_role
+gp_interconnect_type = 'udpifc'
+custom.setting = 'it''s'
+`
+			gucs, err := utils.ParseSessionGUCs(contents)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(gucs).To(Equal([]utils.SessionGUC{
+				{Name: "statement_mem", Value: "1GB"},
+				{Name: "optimizer", Value: "off"},
+				{Name: "role", Value: "backup_role"},
+				{Name: "gp_interconnect_type", Value: "udpifc"},
+				{Name: "custom.setting", Value: "it's"},
+			}))
+		})
+		It("returns no settings for an empty file", func() {
+			gucs, err := utils.ParseSessionGUCs("")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(gucs).To(BeEmpty())
+		})
+		It("returns an error for a line without a value", func() {
+			_, err := utils.ParseSessionGUCs("work_mem = '64MB'\noptimizer")
+
+			Expect(err).To(MatchError("line 2 is not of the form name = value"))
+		})
+		It("returns an error for an invalid setting name", func() {
+			_, err := utils.ParseSessionGUCs("work_mem; DROP TABLE foo = 1")
+
+			Expect(err).To(MatchError(`line 1 has an invalid setting name "work_mem; DROP TABLE foo"`))
+		})
+		It("returns an error for a setting the utilities rely on", func() {
+			_, err := utils.ParseSessionGUCs("DateStyle = 'SQL, DMY'")
+
+			Expect(err).To(MatchError("line 1 sets DateStyle, which cannot be overridden"))
+		})
+		It("returns an error for a time zone setting, which would change fingerprints", func() {
+			_, err := utils.ParseSessionGUCs("work_mem = '64MB'\nTimeZone = 'Asia/Tokyo'")
+
+			Expect(err).To(MatchError("line 2 sets TimeZone, which cannot be overridden"))
+		})
+	})
+	Describe("ReadSessionGUCFile", func() {
+		AfterEach(func() {
+			operating.System = operating.InitializeSystemFunctions()
+		})
+		It("reads the settings in the file", func() {
+			operating.System.ReadFile = func(string) ([]byte, error) { return []byte("work_mem = 64MB\n"), nil }
+
+			gucs, err := utils.ReadSessionGUCFile("/tmp/gucs.conf")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(gucs).To(Equal([]utils.SessionGUC{{Name: "work_mem", Value: "64MB"}}))
+		})
+		It("names the file in the error for an invalid file", func() {
+			operating.System.ReadFile = func(string) ([]byte, error) { return []byte("work_mem\n"), nil }
+
+			_, err := utils.ReadSessionGUCFile("/tmp/gucs.conf")
+
+			Expect(err).To(MatchError("Invalid session GUC file /tmp/gucs.conf: line 1 is not of the form name = value"))
+		})
+		It("returns an error if the file cannot be read", func() {
+			operating.System.ReadFile = func(string) ([]byte, error) { return nil, errors.New("permission denied") }
+
+			_, err := utils.ReadSessionGUCFile("/tmp/gucs.conf")
+
+			Expect(err).To(MatchError("permission denied"))
+		})
+	})
+	Describe("SessionGUC", func() {
+		It("sets the value as a string", func() {
+			guc := utils.SessionGUC{Name: "statement_mem", Value: "1GB"}
+
+			Expect(guc.SetStatement()).To(Equal("SET statement_mem TO '1GB'"))
+		})
+		It("escapes single quotes in the value", func() {
+			guc := utils.SessionGUC{Name: "application_name", Value: "it's"}
+
+			Expect(guc.SetStatement()).To(Equal("SET application_name TO 'it''s'"))
+			Expect(guc.String()).To(Equal("application_name = 'it''s'"))
+		})
+	})
+})
@@ -0,0 +1,343 @@
+package utils
+
+/*
+ * This file contains functions for limiting the rate at which table data is
+ * moved to and from the backup destination.
+ *
+ * gpbackup and gprestore write the rate allowed for each stream of data to a
+ * rate file on every segment.  The streams themselves are limited by
+ * gpbackup_helper, either in the helper agent for single data file backups or
+ * as a throttling stage in each COPY ... PROGRAM command otherwise, and the
+ * rate files are re-read periodically so that the limits can be changed
+ * while data is moved.
+ */
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/gplog"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	bytesPerMB = 1024 * 1024
+	// How often rate files and the rate limit control file are checked for changes
+	RateLimitCheckInterval = time.Second
+)
+
+var (
+	rateController      *RateController
+	rateControllerMutex sync.Mutex
+)
+
+/*
+ * Rate limits are in MB per second, and 0 means no limit.  The total limit
+ * is shared evenly between the segments.
+ */
+type RateLimit struct {
+	MaxRate        int `yaml:"max_rate"`
+	MaxSegmentRate int `yaml:"max_segment_rate"`
+}
+
+func (limit RateLimit) IsLimited() bool {
+	return limit.MaxRate > 0 || limit.MaxSegmentRate > 0
+}
+
+func (limit RateLimit) Validate() error {
+	if limit.MaxRate < 0 || limit.MaxSegmentRate < 0 {
+		return errors.New("Rate limits cannot be negative")
+	}
+	return nil
+}
+
+func (limit RateLimit) String() string {
+	limits := make([]string, 0)
+	if limit.MaxRate > 0 {
+		limits = append(limits, fmt.Sprintf("%d MB/s in total", limit.MaxRate))
+	}
+	if limit.MaxSegmentRate > 0 {
+		limits = append(limits, fmt.Sprintf("%d MB/s per segment", limit.MaxSegmentRate))
+	}
+	if len(limits) == 0 {
+		return "no limit"
+	}
+	return strings.Join(limits, " and ")
+}
+
+/*
+ * Returns the rate for each stream of data, in bytes per second, when each of
+ * numSegments segments moves streamsPerSegment streams at once.
+ */
+func (limit RateLimit) StreamRate(numSegments int, streamsPerSegment int) int64 {
+	var segmentRate int64
+	if limit.MaxSegmentRate > 0 {
+		segmentRate = int64(limit.MaxSegmentRate) * bytesPerMB
+	}
+	if limit.MaxRate > 0 && numSegments > 0 {
+		sharedRate := int64(limit.MaxRate) * bytesPerMB / int64(numSegments)
+		if segmentRate == 0 || sharedRate < segmentRate {
+			segmentRate = sharedRate
+		}
+	}
+	if streamsPerSegment > 1 {
+		segmentRate /= int64(streamsPerSegment)
+	}
+	if segmentRate == 0 && limit.IsLimited() {
+		// A rate of 0 means no limit, so the smallest limit is used instead
+		return 1
+	}
+	return segmentRate
+}
+
+func ReadRateLimitFile(filename string) (RateLimit, error) {
+	limit := RateLimit{}
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return limit, err
+	}
+	err = yaml.UnmarshalStrict(contents, &limit)
+	if err != nil {
+		return limit, errors.Wrapf(err, "Unable to parse rate limit file %s", filename)
+	}
+	return limit, limit.Validate()
+}
+
+func WriteRateLimitFile(filename string, limit RateLimit) error {
+	contents, err := yaml.Marshal(limit)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, contents, 0644)
+}
+
+// A rate file holds the rate for each stream on a segment, in bytes per second
+func ReadRateFile(filename string) (int64, error) {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(contents)), 10, 64)
+}
+
+/*
+ * A RateLimiter limits one stream of data to the rate in its rate file.  If
+ * the file cannot be read, the last rate read is kept, and a stream without a
+ * rate is not limited.  A stream may be split across several readers or
+ * writers sharing a limiter, in which case they wait their turn in Wait.
+ */
+type RateLimiter struct {
+	mutex          sync.Mutex
+	rateFile       string
+	bytesPerSecond int64
+	checkedAt      time.Time
+	windowStart    time.Time
+	windowBytes    int64
+}
+
+func NewRateLimiter(rateFile string) *RateLimiter {
+	limiter := &RateLimiter{rateFile: rateFile}
+	limiter.checkRateFile(time.Now())
+	return limiter
+}
+
+func (limiter *RateLimiter) checkRateFile(now time.Time) {
+	rate, err := ReadRateFile(limiter.rateFile)
+	if err == nil {
+		limiter.bytesPerSecond = rate
+	}
+	limiter.checkedAt = now
+	// Each check starts a new window, so that a pause in the stream does not allow a burst after it
+	limiter.windowStart = now
+	limiter.windowBytes = 0
+}
+
+// Waits until n more bytes can be moved without exceeding the rate
+func (limiter *RateLimiter) Wait(n int) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	now := time.Now()
+	if now.Sub(limiter.checkedAt) >= RateLimitCheckInterval {
+		limiter.checkRateFile(now)
+	}
+	if limiter.bytesPerSecond <= 0 {
+		return
+	}
+	limiter.windowBytes += int64(n)
+	target := time.Duration(float64(limiter.windowBytes) / float64(limiter.bytesPerSecond) * float64(time.Second))
+	if elapsed := now.Sub(limiter.windowStart); elapsed < target {
+		time.Sleep(target - elapsed)
+	}
+}
+
+type throttledReader struct {
+	reader  io.Reader
+	limiter *RateLimiter
+}
+
+func NewThrottledReader(reader io.Reader, limiter *RateLimiter) io.Reader {
+	return &throttledReader{reader: reader, limiter: limiter}
+}
+
+func (throttled *throttledReader) Read(p []byte) (int, error) {
+	n, err := throttled.reader.Read(p)
+	throttled.limiter.Wait(n)
+	return n, err
+}
+
+type throttledWriter struct {
+	writer  io.Writer
+	limiter *RateLimiter
+}
+
+func NewThrottledWriter(writer io.Writer, limiter *RateLimiter) io.Writer {
+	return &throttledWriter{writer: writer, limiter: limiter}
+}
+
+func (throttled *throttledWriter) Write(p []byte) (int, error) {
+	n, err := throttled.writer.Write(p)
+	throttled.limiter.Wait(n)
+	return n, err
+}
+
+/*
+ * A RateController writes the rate files on the segments, and while data is
+ * moved, watches the rate limit control file on the master so that the
+ * limits can be changed by editing it.
+ */
+type RateController struct {
+	cluster           *cluster.Cluster
+	fpInfo            filepath.FilePathInfo
+	controlFile       string
+	streamsPerSegment int
+	limit             RateLimit
+	modTime           time.Time
+	mutex             sync.Mutex
+	stop              chan struct{}
+	stopped           sync.WaitGroup
+}
+
+func StartRateController(c *cluster.Cluster, fpInfo filepath.FilePathInfo, controlFile string, limit RateLimit, streamsPerSegment int) *RateController {
+	controller := &RateController{
+		cluster:           c,
+		fpInfo:            fpInfo,
+		controlFile:       controlFile,
+		streamsPerSegment: streamsPerSegment,
+		limit:             limit,
+		stop:              make(chan struct{}),
+	}
+	err := WriteRateLimitFile(controlFile, limit)
+	gplog.FatalOnError(err, fmt.Sprintf("Unable to write rate limit file %s", controlFile))
+	if info, err := os.Stat(controlFile); err == nil {
+		controller.modTime = info.ModTime()
+	}
+	remoteOutput := controller.writeRateFiles()
+	c.CheckClusterError(remoteOutput, "Unable to write rate limit files", func(contentID int) string {
+		return fmt.Sprintf("Unable to write rate limit file for segment %d", contentID)
+	})
+	gplog.Info("Data transfer is limited to %s; edit %s to change the limits", limit, controlFile)
+
+	rateControllerMutex.Lock()
+	rateController = controller
+	rateControllerMutex.Unlock()
+	controller.stopped.Add(1)
+	go controller.watch()
+	return controller
+}
+
+func (controller *RateController) watch() {
+	defer controller.stopped.Done()
+	ticker := time.NewTicker(RateLimitCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-controller.stop:
+			return
+		case <-ticker.C:
+			controller.CheckControlFile()
+		}
+	}
+}
+
+// Applies the limits in the control file if it has changed since it was last read
+func (controller *RateController) CheckControlFile() {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+	info, err := os.Stat(controller.controlFile)
+	if err != nil || info.ModTime().Equal(controller.modTime) {
+		return
+	}
+	controller.modTime = info.ModTime()
+	limit, err := ReadRateLimitFile(controller.controlFile)
+	if err != nil {
+		gplog.Warn("Ignoring invalid rate limit file %s: %v", controller.controlFile, err)
+		return
+	}
+	if limit == controller.limit {
+		return
+	}
+	controller.limit = limit
+	gplog.Info("Data transfer limit changed to %s", limit)
+	remoteOutput := controller.writeRateFiles()
+	controller.cluster.CheckClusterError(remoteOutput, "Unable to update rate limit files", func(contentID int) string {
+		return fmt.Sprintf("Unable to update rate limit file for segment %d", contentID)
+	}, true)
+}
+
+// Each file is replaced in one step, so that gpbackup_helper never reads a partial file
+func (controller *RateController) writeRateFiles() *cluster.RemoteOutput {
+	rate := controller.limit.StreamRate(len(controller.cluster.ContentIDs)-1, controller.streamsPerSegment)
+	return controller.cluster.GenerateAndExecuteCommand("Writing rate limit files", cluster.ON_SEGMENTS, func(contentID int) string {
+		rateFile := controller.fpInfo.GetSegmentHelperFilePath(contentID, "rate")
+		return fmt.Sprintf("echo %d > %s.tmp && mv %s.tmp %s", rate, rateFile, rateFile, rateFile)
+	})
+}
+
+// Stops the active rate controller, if any, and removes its files
+func StopRateController() {
+	rateControllerMutex.Lock()
+	controller := rateController
+	rateController = nil
+	rateControllerMutex.Unlock()
+	if controller == nil {
+		return
+	}
+	close(controller.stop)
+	controller.stopped.Wait()
+	_ = os.Remove(controller.controlFile)
+	remoteOutput := controller.cluster.GenerateAndExecuteCommand("Removing rate limit files", cluster.ON_SEGMENTS, func(contentID int) string {
+		return fmt.Sprintf("rm -f %s", controller.fpInfo.GetSegmentHelperFilePath(contentID, "rate"))
+	})
+	controller.cluster.CheckClusterError(remoteOutput, "Unable to remove rate limit files", func(contentID int) string {
+		return fmt.Sprintf("Unable to remove rate limit file for segment %d", contentID)
+	}, true)
+}
+
+// Returns the throttling stage for COPY ... PROGRAM commands, or "" if data is not rate limited
+func GetThrottleCommand() string {
+	rateControllerMutex.Lock()
+	defer rateControllerMutex.Unlock()
+	if rateController == nil {
+		return ""
+	}
+	return fmt.Sprintf("gpbackup_helper --throttle --rate-file %s", rateController.fpInfo.GetSegmentHelperFilePathForCopyCommand("rate"))
+}
+
+// Returns the gpbackup_helper agent option for a segment's rate file, or "" if data is not rate limited
+func getRateFileOption(contentID int) string {
+	rateControllerMutex.Lock()
+	defer rateControllerMutex.Unlock()
+	if rateController == nil {
+		return ""
+	}
+	return fmt.Sprintf(" --rate-file %s", rateController.fpInfo.GetSegmentHelperFilePath(contentID, "rate"))
+}
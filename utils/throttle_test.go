@@ -0,0 +1,190 @@
+package utils_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	path "path/filepath"
+	"time"
+
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/greenplum-db/gp-common-go-libs/testhelper"
+	"github.com/greenplum-db/gpbackup/filepath"
+	"github.com/greenplum-db/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("utils/throttle tests", func() {
+	var tempDir string
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "throttle")
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		_ = os.RemoveAll(tempDir)
+	})
+	Describe("RateLimit", func() {
+		It("is not limited when both limits are 0", func() {
+			Expect(utils.RateLimit{}.IsLimited()).To(BeFalse())
+			Expect(utils.RateLimit{}.StreamRate(4, 1)).To(Equal(int64(0)))
+		})
+		It("shares the total limit between the segments and streams", func() {
+			limit := utils.RateLimit{MaxRate: 100}
+			Expect(limit.StreamRate(4, 1)).To(Equal(int64(25 * 1024 * 1024)))
+			Expect(limit.StreamRate(4, 5)).To(Equal(int64(5 * 1024 * 1024)))
+		})
+		It("uses the lower of the total and per-segment limits", func() {
+			Expect(utils.RateLimit{MaxRate: 100, MaxSegmentRate: 10}.StreamRate(4, 1)).To(Equal(int64(10 * 1024 * 1024)))
+			Expect(utils.RateLimit{MaxRate: 20, MaxSegmentRate: 10}.StreamRate(4, 1)).To(Equal(int64(5 * 1024 * 1024)))
+		})
+		It("never rounds a limit down to no limit", func() {
+			Expect(utils.RateLimit{MaxRate: 1}.StreamRate(4*1024*1024, 2)).To(Equal(int64(1)))
+		})
+		It("rejects negative limits", func() {
+			Expect(utils.RateLimit{MaxRate: -1}.Validate()).To(MatchError("Rate limits cannot be negative"))
+		})
+		It("describes the limits", func() {
+			Expect(utils.RateLimit{MaxRate: 100, MaxSegmentRate: 10}.String()).To(Equal("100 MB/s in total and 10 MB/s per segment"))
+			Expect(utils.RateLimit{}.String()).To(Equal("no limit"))
+		})
+	})
+	Describe("ReadRateLimitFile", func() {
+		It("reads the limits written by WriteRateLimitFile", func() {
+			filename := path.Join(tempDir, "rate_limit.yaml")
+			err := utils.WriteRateLimitFile(filename, utils.RateLimit{MaxRate: 100, MaxSegmentRate: 10})
+			Expect(err).ToNot(HaveOccurred())
+
+			limit, err := utils.ReadRateLimitFile(filename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(limit).To(Equal(utils.RateLimit{MaxRate: 100, MaxSegmentRate: 10}))
+		})
+		It("returns an error for an unrecognized limit", func() {
+			filename := path.Join(tempDir, "rate_limit.yaml")
+			_ = ioutil.WriteFile(filename, []byte("max_rates: 100\n"), 0644)
+
+			_, err := utils.ReadRateLimitFile(filename)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Describe("RateLimiter", func() {
+		It("does not limit a stream without a rate file", func() {
+			limiter := utils.NewRateLimiter(path.Join(tempDir, "missing"))
+			reader := utils.NewThrottledReader(bytes.NewReader(make([]byte, 10*1024*1024)), limiter)
+
+			start := time.Now()
+			contents, err := ioutil.ReadAll(reader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(contents).To(HaveLen(10 * 1024 * 1024))
+			Expect(time.Since(start)).To(BeNumerically("<", 500*time.Millisecond))
+		})
+		It("limits a reader to the rate in the rate file", func() {
+			rateFile := path.Join(tempDir, "rate")
+			_ = ioutil.WriteFile(rateFile, []byte("1048576\n"), 0644)
+			limiter := utils.NewRateLimiter(rateFile)
+			reader := utils.NewThrottledReader(bytes.NewReader(make([]byte, 512*1024)), limiter)
+
+			start := time.Now()
+			contents, err := ioutil.ReadAll(reader)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(contents).To(HaveLen(512 * 1024))
+			Expect(time.Since(start)).To(BeNumerically(">=", 450*time.Millisecond))
+		})
+		It("limits a writer to the rate in the rate file", func() {
+			rateFile := path.Join(tempDir, "rate")
+			_ = ioutil.WriteFile(rateFile, []byte("1048576\n"), 0644)
+			limiter := utils.NewRateLimiter(rateFile)
+			output := &bytes.Buffer{}
+			writer := utils.NewThrottledWriter(output, limiter)
+
+			start := time.Now()
+			for i := 0; i < 16; i++ {
+				_, err := writer.Write(make([]byte, 32*1024))
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(output.Len()).To(Equal(512 * 1024))
+			Expect(time.Since(start)).To(BeNumerically(">=", 450*time.Millisecond))
+		})
+	})
+	Describe("RateController", func() {
+		var (
+			testCluster  *cluster.Cluster
+			testExecutor *testhelper.TestExecutor
+			fpInfo       filepath.FilePathInfo
+			controlFile  string
+		)
+		BeforeEach(func() {
+			testExecutor = &testhelper.TestExecutor{ClusterOutput: &cluster.RemoteOutput{}}
+			testCluster = cluster.NewCluster([]cluster.SegConfig{
+				{ContentID: -1, Hostname: "localhost", DataDir: "/data/gpseg-1"},
+				{ContentID: 0, Hostname: "localhost", DataDir: "/data/gpseg0"},
+				{ContentID: 1, Hostname: "remotehost1", DataDir: "/data/gpseg1"},
+			})
+			testCluster.Executor = testExecutor
+			fpInfo = filepath.NewFilePathInfo(testCluster, "", "11112233445566", "")
+			controlFile = path.Join(tempDir, "rate_limit.yaml")
+		})
+		AfterEach(func() {
+			utils.StopRateController()
+		})
+		It("writes the control file and the rate file on each segment", func() {
+			utils.StartRateController(testCluster, fpInfo, controlFile, utils.RateLimit{MaxRate: 8}, 2)
+
+			limit, err := utils.ReadRateLimitFile(controlFile)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(limit).To(Equal(utils.RateLimit{MaxRate: 8}))
+			cc := testExecutor.ClusterCommands[0]
+			Expect(cc).To(HaveLen(2))
+			rateFile := fmt.Sprintf("/data/gpseg1/gpbackup_1_11112233445566_rate_%d", fpInfo.PID)
+			Expect(cc[1].CommandString).To(ContainSubstring(fmt.Sprintf("echo 2097152 > %[1]s.tmp && mv %[1]s.tmp %[1]s", rateFile)))
+		})
+		It("adds a throttling stage and helper option while running", func() {
+			Expect(utils.GetThrottleCommand()).To(Equal(""))
+			utils.StartRateController(testCluster, fpInfo, controlFile, utils.RateLimit{MaxSegmentRate: 10}, 1)
+
+			Expect(utils.GetThrottleCommand()).To(Equal(fmt.Sprintf("gpbackup_helper --throttle --rate-file <SEG_DATA_DIR>/gpbackup_<SEGID>_11112233445566_rate_%d", fpInfo.PID)))
+			wasTerminated := false
+			utils.StartGpbackupHelpers(testCluster, fpInfo, "--backup-agent", "", "", false, false, 1, &wasTerminated)
+			cc := testExecutor.ClusterCommands[1]
+			Expect(cc[0].CommandString).To(ContainSubstring(fmt.Sprintf(" --rate-file /data/gpseg0/gpbackup_0_11112233445566_rate_%d", fpInfo.PID)))
+		})
+		It("applies changes to the control file", func() {
+			controller := utils.StartRateController(testCluster, fpInfo, controlFile, utils.RateLimit{MaxRate: 8}, 1)
+			err := utils.WriteRateLimitFile(controlFile, utils.RateLimit{MaxRate: 2})
+			Expect(err).ToNot(HaveOccurred())
+			later := time.Now().Add(time.Minute)
+			_ = os.Chtimes(controlFile, later, later)
+
+			controller.CheckControlFile()
+
+			Expect(testExecutor.NumExecutions).To(Equal(2))
+			cc := testExecutor.ClusterCommands[1]
+			Expect(cc[0].CommandString).To(ContainSubstring("echo 1048576 > "))
+			Expect(string(logfile.Contents())).To(ContainSubstring("Data transfer limit changed to 2 MB/s in total"))
+		})
+		It("ignores an invalid control file", func() {
+			controller := utils.StartRateController(testCluster, fpInfo, controlFile, utils.RateLimit{MaxRate: 8}, 1)
+			_ = ioutil.WriteFile(controlFile, []byte("max_rate: -1\n"), 0644)
+			later := time.Now().Add(time.Minute)
+			_ = os.Chtimes(controlFile, later, later)
+
+			controller.CheckControlFile()
+
+			Expect(testExecutor.NumExecutions).To(Equal(1))
+			Expect(string(logfile.Contents())).To(ContainSubstring("Ignoring invalid rate limit file"))
+		})
+		It("removes its files when stopped", func() {
+			utils.StartRateController(testCluster, fpInfo, controlFile, utils.RateLimit{MaxRate: 8}, 1)
+			utils.StopRateController()
+
+			Expect(controlFile).ToNot(BeAnExistingFile())
+			Expect(utils.GetThrottleCommand()).To(Equal(""))
+			cc := testExecutor.ClusterCommands[1]
+			Expect(cc[0].CommandString).To(ContainSubstring(fmt.Sprintf("rm -f /data/gpseg0/gpbackup_0_11112233445566_rate_%d", fpInfo.PID)))
+		})
+	})
+})